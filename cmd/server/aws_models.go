@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"aws-cursor-router/internal/bedrockproxy"
 	"aws-cursor-router/internal/store"
 	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
@@ -34,9 +35,13 @@ func (a *App) reloadAWSConfig(ctx context.Context) error {
 	runtimeCfg.DefaultModelID = pickDefaultModelID(a.cfg.DefaultModelID, runtimeCfg.DefaultModelID)
 
 	if strings.TrimSpace(runtimeCfg.Region) == "" {
-		a.proxy.ReplaceClient(nil)
+		a.setRuntimeClient(nil)
+		if err := a.reloadUpstreams(ctx); err != nil {
+			return err
+		}
 		a.proxy.SetDefaultModelID(runtimeCfg.DefaultModelID)
 		a.setAWSRuntimeState(runtimeCfg, nil, nil)
+		a.setFetchedModelMetadata(nil)
 		return nil
 	}
 
@@ -45,10 +50,13 @@ func (a *App) reloadAWSConfig(ctx context.Context) error {
 		return fmt.Errorf("initialize bedrock clients: %w", err)
 	}
 
-	a.proxy.ReplaceClient(runtimeClient)
+	a.setRuntimeClient(runtimeClient)
+	if err := a.reloadUpstreams(ctx); err != nil {
+		return err
+	}
 	a.proxy.SetDefaultModelID(runtimeCfg.DefaultModelID)
 
-	availableModels, err := fetchAvailableModels(ctx, controlClient, runtimeCfg.Region)
+	availableModels, metadata, err := fetchAvailableModels(ctx, controlClient, runtimeCfg.Region)
 	if err != nil {
 		a.logger.Printf("warning: failed to fetch available bedrock models: %v", err)
 		availableModels = nil
@@ -59,6 +67,69 @@ func (a *App) reloadAWSConfig(ctx context.Context) error {
 	}
 
 	a.setAWSRuntimeState(runtimeCfg, controlClient, availableModels)
+	a.setFetchedModelMetadata(metadata)
+	return nil
+}
+
+// reloadUpstreams 用主账号客户端与 admin_upstreams 中的额外上游重建上游池，并替换代理使用的客户端。
+// 主账号在池中名为 default，默认服务所有模型、Priority 0、Weight 1，可由同名的上游行调整；
+// 没有任何可用上游时代理不配置客户端。重建后健康状态清零。
+func (a *App) reloadUpstreams(ctx context.Context) error {
+	rows, err := a.store.ListUpstreams(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.awsState.mu.RLock()
+	primary := a.awsState.runtimeClient
+	a.awsState.mu.RUnlock()
+
+	defaultRow := store.UpstreamRow{Name: store.DefaultUpstreamName, Weight: 1}
+	upstreams := make([]bedrockproxy.Upstream, 0, len(rows)+1)
+	for _, row := range rows {
+		if row.Name == store.DefaultUpstreamName {
+			defaultRow = row
+			continue
+		}
+		if row.Disabled {
+			continue
+		}
+		client, _, err := buildBedrockClients(ctx, store.AWSRuntimeConfig{
+			Region:          row.Region,
+			AccessKeyID:     row.AccessKeyID,
+			SecretAccessKey: row.SecretAccessKey,
+			SessionToken:    row.SessionToken,
+		})
+		if err != nil {
+			return fmt.Errorf("initialize upstream %s: %w", row.Name, err)
+		}
+		upstreams = append(upstreams, bedrockproxy.Upstream{
+			Name:     row.Name,
+			Client:   client,
+			Models:   row.Models,
+			Priority: row.Priority,
+			Weight:   row.Weight,
+		})
+	}
+	if primary != nil && !defaultRow.Disabled {
+		upstreams = append([]bedrockproxy.Upstream{{
+			Name:     store.DefaultUpstreamName,
+			Client:   primary,
+			Models:   defaultRow.Models,
+			Priority: defaultRow.Priority,
+			Weight:   defaultRow.Weight,
+		}}, upstreams...)
+	}
+
+	pool := bedrockproxy.NewUpstreamPool(upstreams, a.cfg.UpstreamCooldown)
+	if pool.Len() == 0 {
+		a.proxy.ReplaceClient(nil)
+	} else {
+		a.proxy.ReplaceClient(pool)
+	}
+	a.awsState.mu.Lock()
+	a.awsState.upstreamPool = pool
+	a.awsState.mu.Unlock()
 	return nil
 }
 
@@ -74,11 +145,12 @@ func (a *App) refreshAvailableModels(ctx context.Context) ([]string, error) {
 		region = strings.TrimSpace(a.cfg.AWSRegion)
 	}
 
-	availableModels, err := fetchAvailableModels(ctx, controlClient, region)
+	availableModels, metadata, err := fetchAvailableModels(ctx, controlClient, region)
 	if err != nil {
 		return nil, err
 	}
 	a.setAvailableModels(availableModels)
+	a.setFetchedModelMetadata(metadata)
 
 	if len(availableModels) > 0 {
 		if err := a.store.SeedEnabledModelsIfEmpty(ctx, availableModels); err != nil {
@@ -140,17 +212,23 @@ func buildBedrockClients(ctx context.Context, runtimeCfg store.AWSRuntimeConfig)
 		return nil, nil, err
 	}
 
-	return bedrockruntime.NewFromConfig(sdkCfg), bedrock.NewFromConfig(sdkCfg), nil
+	// runtime 调用的重试由 bedrockproxy.Service（RetryPolicy）与上游池的故障切换负责，关闭 SDK 自带的重试以免叠加
+	runtimeClient := bedrockruntime.NewFromConfig(sdkCfg, func(o *bedrockruntime.Options) {
+		o.Retryer = awssdk.NopRetryer{}
+	})
+	return runtimeClient, bedrock.NewFromConfig(sdkCfg), nil
 }
 
-func fetchAvailableModels(ctx context.Context, client foundationModelLister, region string) ([]string, error) {
+// fetchAvailableModels 列出可用的文本 / 嵌入模型 ID，同时返回按基础模型 ID 索引的元数据（供 /v1/models 使用）。
+func fetchAvailableModels(ctx context.Context, client foundationModelLister, region string) ([]string, map[string]modelMetadata, error) {
 	output, err := client.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{
 		ByOutputModality: bedrocktypes.ModelModalityText,
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	metadata := make(map[string]modelMetadata, len(output.ModelSummaries))
 	modelIDs := make([]string, 0, len(output.ModelSummaries))
 	for _, summary := range output.ModelSummaries {
 		modelID := strings.TrimSpace(awssdk.ToString(summary.ModelId))
@@ -164,14 +242,19 @@ func fetchAvailableModels(ctx context.Context, client foundationModelLister, reg
 			continue
 		}
 		modelIDs = append(modelIDs, modelID)
+		metadata[modelID] = metadataFromSummary(summary)
 	}
 
 	modelIDs = normalizeModelIDs(modelIDs)
+	embeddingModelIDs, err := fetchEmbeddingModels(ctx, client, metadata)
+	if err != nil {
+		return nil, nil, err
+	}
 	if !isNorthAmericaRegion(region) {
-		return modelIDs, nil
+		return normalizeModelIDs(append(modelIDs, embeddingModelIDs...)), metadata, nil
 	}
 
-	withUSPrefix := make([]string, 0, len(modelIDs))
+	withUSPrefix := make([]string, 0, len(modelIDs)+len(embeddingModelIDs))
 	for _, modelID := range modelIDs {
 		if strings.HasPrefix(modelID, "us.") {
 			withUSPrefix = append(withUSPrefix, modelID)
@@ -179,7 +262,33 @@ func fetchAvailableModels(ctx context.Context, client foundationModelLister, reg
 		}
 		withUSPrefix = append(withUSPrefix, "us."+modelID)
 	}
-	return normalizeModelIDs(withUSPrefix), nil
+	// 嵌入模型没有跨区域推理配置文件，保持原始 ID
+	withUSPrefix = append(withUSPrefix, embeddingModelIDs...)
+	return normalizeModelIDs(withUSPrefix), metadata, nil
+}
+
+// fetchEmbeddingModels 列出 /v1/embeddings 支持的嵌入模型（Titan Text Embeddings / Cohere Embed），元数据写入 metadata。
+func fetchEmbeddingModels(ctx context.Context, client foundationModelLister, metadata map[string]modelMetadata) ([]string, error) {
+	output, err := client.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{
+		ByOutputModality: bedrocktypes.ModelModalityEmbedding,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	modelIDs := make([]string, 0, len(output.ModelSummaries))
+	for _, summary := range output.ModelSummaries {
+		modelID := strings.TrimSpace(awssdk.ToString(summary.ModelId))
+		if modelID == "" || !bedrockproxy.IsEmbeddingModel(modelID) {
+			continue
+		}
+		if len(summary.InputModalities) > 0 && !containsModality(summary.InputModalities, bedrocktypes.ModelModalityText) {
+			continue
+		}
+		modelIDs = append(modelIDs, modelID)
+		metadata[modelID] = metadataFromSummary(summary)
+	}
+	return modelIDs, nil
 }
 
 func containsModality(modalities []bedrocktypes.ModelModality, target bedrocktypes.ModelModality) bool {
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	// batchPollInterval 是执行器在没有新任务通知时重新检查未完成任务的间隔（例如 Bedrock 客户端稍后才配置好）。
+	batchPollInterval = time.Minute
+	// 单行请求失败后的重试退避：从 batchRetryBaseDelay 开始逐次翻倍，不超过 batchRetryMaxDelay
+	batchRetryBaseDelay = time.Second
+	batchRetryMaxDelay  = 30 * time.Second
+)
+
+// batchRunner 在进程内按创建顺序逐个执行 /v1/batches 任务，每个任务内最多 BATCH_CONCURRENCY 行同时调用 Bedrock。
+// 任务状态与每一行的结果保存在 SQLite 中，服务重启后从尚未完成的行继续执行。
+type batchRunner struct {
+	wake chan struct{}
+
+	mu sync.Mutex
+	// 正在执行的任务的取消函数，取消 batch 时立即中止进行中的 Bedrock 调用
+	cancels map[string]context.CancelFunc
+}
+
+func newBatchRunner() *batchRunner {
+	return &batchRunner{
+		wake:    make(chan struct{}, 1),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// notify 通知执行器有新任务或任务状态变化（不阻塞）。
+func (r *batchRunner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// cancel 中止正在执行的任务；任务未在执行时什么也不做。
+func (r *batchRunner) cancel(batchID string) {
+	r.mu.Lock()
+	cancel, ok := r.cancels[batchID]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// startBatchRunner 启动 batch 执行器，并立即处理上次进程遗留的未完成任务。
+func (a *App) startBatchRunner() {
+	go func() {
+		for {
+			a.runPendingBatches()
+			select {
+			case <-a.batches.wake:
+			case <-time.After(batchPollInterval):
+			}
+		}
+	}()
+	a.batches.notify()
+}
+
+// runPendingBatches 依次执行当前所有未结束的 batch 任务。
+func (a *App) runPendingBatches() {
+	records, err := a.store.ListUnfinishedBatches(context.Background())
+	if err != nil {
+		a.logger.Printf("warning: list unfinished batches failed: %v", err)
+		return
+	}
+	for _, record := range records {
+		a.runBatch(record)
+	}
+}
+
+// runBatch 执行一个 batch 任务直到结束（completed / expired / cancelled / failed），
+// Bedrock 客户端尚未配置时保持原状态，等待下一轮。
+func (a *App) runBatch(record store.BatchRecord) {
+	switch record.Status {
+	case "cancelling":
+		a.finalizeBatch(record, "cancelled")
+		return
+	case "finalizing":
+		a.finalizeBatch(record, "completed")
+		return
+	}
+	if !a.proxy.HasClient() {
+		return
+	}
+
+	client, err := a.auth.ClientByID(record.ClientID)
+	if err != nil {
+		a.logger.Printf("warning: batch %s cannot run for client_id=%s: %v", record.BatchID, record.ClientID, err)
+		a.finalizeBatch(record, "failed")
+		return
+	}
+	if record.Status == "validating" {
+		ok, err := a.store.TransitionBatch(context.Background(), record.BatchID, []string{"validating"}, "in_progress", time.Now().UTC())
+		if err != nil {
+			a.logger.Printf("warning: start batch %s failed: %v", record.BatchID, err)
+			return
+		}
+		if !ok {
+			// 已被取消，下一轮按 cancelling 处理
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.batches.mu.Lock()
+	a.batches.cancels[record.BatchID] = cancel
+	a.batches.mu.Unlock()
+	defer func() {
+		a.batches.mu.Lock()
+		delete(a.batches.cancels, record.BatchID)
+		a.batches.mu.Unlock()
+		cancel()
+	}()
+
+	pending, err := a.store.PendingBatchItems(ctx, record.BatchID)
+	if err != nil {
+		a.logger.Printf("warning: load batch %s items failed: %v", record.BatchID, err)
+		return
+	}
+
+	items := make(chan store.BatchItem)
+	var wg sync.WaitGroup
+	for range a.cfg.BatchConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				a.runBatchItem(ctx, client, record, item)
+			}
+		}()
+	}
+	expired := false
+feed:
+	for _, item := range pending {
+		if !time.Now().Before(record.ExpiresAt) {
+			expired = true
+			break
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	current, ok, err := a.store.GetBatch(context.Background(), record.ClientID, record.BatchID)
+	if err != nil || !ok {
+		a.logger.Printf("warning: reload batch %s failed: ok=%v err=%v", record.BatchID, ok, err)
+		return
+	}
+	switch {
+	case current.Status == "cancelling":
+		a.finalizeBatch(current, "cancelled")
+	case expired:
+		a.expireBatchItems(current)
+		a.finalizeBatch(current, "expired")
+	default:
+		ok, err := a.store.TransitionBatch(context.Background(), current.BatchID, []string{"in_progress"}, "finalizing", time.Now().UTC())
+		if err != nil {
+			a.logger.Printf("warning: finalize batch %s failed: %v", current.BatchID, err)
+			return
+		}
+		if !ok {
+			// 最后一行结束后、进入 finalizing 前被取消
+			a.finalizeBatch(current, "cancelled")
+			return
+		}
+		a.finalizeBatch(current, "completed")
+	}
+}
+
+// runBatchItem 执行一行请求：与 /v1/chat/completions 相同的校验、模型白名单、Guardrail、调用日志与计费，
+// 调用日志标记为 batch。非请求错误（限流、5xx、超时等）按指数退避最多重试 BATCH_MAX_RETRIES 次。
+// 任务被取消时该行保持 pending，不写入结果。
+func (a *App) runBatchItem(ctx context.Context, client *auth.Client, batch store.BatchRecord, item store.BatchItem) {
+	requestID := "batch_req_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	attempts := 0
+	finish := func(statusCode int, body any) {
+		status := "completed"
+		if statusCode != http.StatusOK {
+			status = "failed"
+		}
+		line, _ := json.Marshal(openai.BatchOutputLine{
+			ID:       requestID,
+			CustomID: item.CustomID,
+			Response: &openai.BatchOutputResponse{StatusCode: statusCode, RequestID: requestID, Body: body},
+		})
+		if err := a.store.FinishBatchItem(context.Background(), batch.BatchID, item.LineIndex, status, attempts, string(line)); err != nil {
+			a.logger.Printf("warning: save batch %s line %d failed: %v", batch.BatchID, item.LineIndex, err)
+		}
+	}
+	fail := func(statusCode int, message string) {
+		finish(statusCode, openai.ErrorResponse{Error: openai.OpenAIErrorPayload{
+			Message: strings.TrimSpace(message),
+			Type:    "invalid_request_error",
+			Code:    fmt.Sprint(statusCode),
+		}})
+	}
+
+	var request openai.ChatCompletionRequest
+	if err := json.Unmarshal([]byte(item.BodyJSON), &request); err != nil {
+		fail(http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if request.Stream {
+		fail(http.StatusBadRequest, "stream is not supported in batch requests")
+		return
+	}
+	if choiceCount(request.N) > 1 {
+		fail(http.StatusBadRequest, "n > 1 is not supported in batch requests")
+		return
+	}
+	if err := openai.ValidateChatRequest(request); err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(request.Model)
+	if err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !a.isModelEnabled(bedrockModelID) {
+		fail(http.StatusForbidden, "model is not enabled by admin")
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		fail(http.StatusForbidden, "model is not allowed for this api key")
+		return
+	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	request.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+	startedAt := time.Now().UTC()
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          modelName,
+		BedrockModelID: bedrockModelID,
+		RequestContent: openai.RenderRequestForLog(request, a.cfg.MaxContentChars),
+		CreatedAt:      startedAt,
+		IsBatch:        true,
+	}
+	if request.Guardrail != nil {
+		record.GuardrailID = request.Guardrail.Identifier
+	}
+
+	var result bedrockproxy.ChatResult
+	for {
+		if err = a.checkGlobalCostLimit(); err != nil {
+			break
+		}
+		attempts++
+		callCtx, callCancel := context.WithTimeout(ctx, a.cfg.RequestTimeout)
+		// 与在线请求一样按管理员配置的降级链依次尝试
+		result, err = a.proxy.ConverseWithFallback(callCtx, request, a.modelFallbackChain(client, bedrockModelID), a.guardrailResolver(client.ID))
+		callCancel()
+		// 执行器的逐行重试与 Service 内部的重试一起计入尝试次数
+		record.Attempts += result.Attempts
+		a.recordServedModel(&record, client.ID, result.ModelID)
+		if err == nil || bedrockproxy.IsRequestError(err) || bedrockproxy.IsStructuredOutputError(err) || ctx.Err() != nil || attempts > a.cfg.BatchMaxRetries {
+			break
+		}
+		delay := min(batchRetryBaseDelay<<(attempts-1), batchRetryMaxDelay)
+		a.logger.Printf("batch %s line %d attempt %d failed, retrying in %s: %v", batch.BatchID, item.LineIndex, attempts, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if attempts == 0 {
+		// 全局费用上限已用完，不调用 Bedrock 也不写调用日志
+		fail(http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	record.InputTokens = result.InputTokens
+	record.OutputTokens = result.OutputTokens
+	record.TotalTokens = result.TotalTokens
+	record.CacheReadInputTokens = result.CacheReadInputTokens
+	record.CacheWriteInputTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
+	record.LatencyMs = result.LatencyMs
+	if record.LatencyMs == 0 {
+		record.LatencyMs = time.Since(startedAt).Milliseconds()
+	}
+	statusCode := http.StatusOK
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		if ctx.Err() != nil {
+			statusCode = 499
+			clientMessage = "batch cancelled"
+		}
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(err.Error(), a.cfg.MaxContentChars)
+		a.enqueueBatchCall(record)
+		if ctx.Err() == nil {
+			fail(statusCode, clientMessage)
+		}
+		return
+	}
+
+	record.StatusCode = statusCode
+	record.ResponseContent = truncateRunes(renderAssistantContentForLog(result.Text, result.ToolCalls), a.cfg.MaxContentChars)
+	a.enqueueBatchCall(record)
+
+	if record.BedrockModelID != bedrockModelID {
+		// 降级到备选模型时报告实际服务的模型
+		modelName = record.BedrockModelID
+	}
+	finish(http.StatusOK, openai.ChatCompletionResponse{
+		ID:      "chatcmpl-" + requestID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+		Choices: []openai.ChatCompletionChoice{{
+			Index: 0,
+			Message: openai.ChatMessage{
+				Role:             "assistant",
+				Content:          buildAssistantMessageContent(result.Text, len(result.ToolCalls) > 0),
+				ToolCalls:        result.ToolCalls,
+				ReasoningContent: openai.ReasoningText(result.ThinkingBlocks),
+				ThinkingBlocks:   result.ThinkingBlocks,
+			},
+			FinishReason: defaultFinishReason(result.FinishReason),
+		}},
+		Usage: buildChatUsage(result),
+	})
+}
+
+func (a *App) enqueueBatchCall(record store.CallRecord) {
+	if !a.store.Enqueue(record) {
+		a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", record.RequestID, record.ClientID)
+		return
+	}
+	a.addCostFromUsage(
+		record.BedrockModelID,
+		int64(record.InputTokens),
+		int64(record.OutputTokens),
+		int64(record.CacheReadInputTokens),
+		int64(record.CacheWriteInputTokens),
+	)
+}
+
+// expireBatchItems 把超过 completion_window 仍未执行的行记为失败（error.code=batch_expired），写入错误文件。
+func (a *App) expireBatchItems(batch store.BatchRecord) {
+	pending, err := a.store.PendingBatchItems(context.Background(), batch.BatchID)
+	if err != nil {
+		a.logger.Printf("warning: load batch %s items failed: %v", batch.BatchID, err)
+		return
+	}
+	for _, item := range pending {
+		line, _ := json.Marshal(openai.BatchOutputLine{
+			ID:       "batch_req_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			CustomID: item.CustomID,
+			Error: &openai.OpenAIErrorPayload{
+				Code:    "batch_expired",
+				Message: "This request could not be executed before the completion window expired.",
+			},
+		})
+		if err := a.store.FinishBatchItem(context.Background(), batch.BatchID, item.LineIndex, "failed", item.Attempts, string(line)); err != nil {
+			a.logger.Printf("warning: expire batch %s line %d failed: %v", batch.BatchID, item.LineIndex, err)
+		}
+	}
+}
+
+// finalizeBatch 把已完成 / 失败的行分别写成输出文件与错误文件（JSONL，按输入行顺序），再把任务切换到最终状态。
+func (a *App) finalizeBatch(batch store.BatchRecord, status string) {
+	ctx := context.Background()
+	outputFileID, errorFileID := batch.OutputFileID, batch.ErrorFileID
+	if outputFileID == "" && errorFileID == "" {
+		var err error
+		if outputFileID, err = a.saveBatchResultFile(ctx, batch, "completed", "output"); err != nil {
+			a.logger.Printf("warning: write batch %s output file failed: %v", batch.BatchID, err)
+			return
+		}
+		if errorFileID, err = a.saveBatchResultFile(ctx, batch, "failed", "error"); err != nil {
+			a.logger.Printf("warning: write batch %s error file failed: %v", batch.BatchID, err)
+			return
+		}
+		if err := a.store.SetBatchFiles(ctx, batch.BatchID, outputFileID, errorFileID); err != nil {
+			a.logger.Printf("warning: save batch %s files failed: %v", batch.BatchID, err)
+			return
+		}
+	}
+	from := []string{"validating", "in_progress", "finalizing", "cancelling"}
+	if _, err := a.store.TransitionBatch(ctx, batch.BatchID, from, status, time.Now().UTC()); err != nil {
+		a.logger.Printf("warning: finish batch %s (%s) failed: %v", batch.BatchID, status, err)
+	}
+}
+
+// saveBatchResultFile 把指定状态的行写成一个 purpose=batch_output 的文件；没有这类行时不生成文件，返回空 ID。
+func (a *App) saveBatchResultFile(ctx context.Context, batch store.BatchRecord, itemStatus, kind string) (string, error) {
+	items, err := a.store.FinishedBatchItems(ctx, batch.BatchID, itemStatus)
+	if err != nil || len(items) == 0 {
+		return "", err
+	}
+	var content bytes.Buffer
+	for _, item := range items {
+		content.WriteString(item.ResultJSON)
+		content.WriteByte('\n')
+	}
+	fileID := newFileID()
+	if err := a.store.SaveFile(ctx, store.FileRecord{
+		FileID:   fileID,
+		ClientID: batch.ClientID,
+		Purpose:  "batch_output",
+		Filename: fmt.Sprintf("%s_%s.jsonl", batch.BatchID, kind),
+	}, content.Bytes()); err != nil {
+		return "", err
+	}
+	return fileID, nil
+}
+
+func newFileID() string {
+	return "file-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
@@ -38,7 +38,14 @@ func (a *App) reloadBillingState(ctx context.Context) error {
 	}
 	totalCost := 0.0
 	for _, row := range usageRows {
-		totalCost += calculateCostByTokens(row.Model, row.InputTokens, row.OutputTokens, priceByModel)
+		totalCost += calculateCostByTokens(
+			row.Model,
+			row.InputTokens,
+			row.OutputTokens,
+			row.CacheReadInputTokens,
+			row.CacheWriteInputTokens,
+			priceByModel,
+		) + calculateCostByImages(row.Model, row.ImageCount, priceByModel)
 	}
 
 	a.billingState.mu.Lock()
@@ -49,6 +56,16 @@ func (a *App) reloadBillingState(ctx context.Context) error {
 	return nil
 }
 
+// rekeyAliasUsage 把旧版本按别名 / default 汇总的历史用量并入其当前指向的 Bedrock model id。
+// 新用量直接按实际调用的模型汇总，因此之后别名改指向不会重新计价历史用量。
+func (a *App) rekeyAliasUsage(ctx context.Context) error {
+	targets := a.proxy.ListModelMappings()
+	if target, ok := a.proxy.AliasTarget("default"); ok {
+		targets["default"] = target
+	}
+	return a.store.RekeyModelUsage(ctx, targets)
+}
+
 func (a *App) getBillingSnapshot() (store.BillingConfig, float64) {
 	a.billingState.mu.RLock()
 	cfg := a.billingState.cfg
@@ -73,18 +90,36 @@ func (a *App) checkGlobalCostLimit() error {
 	return nil
 }
 
-func (a *App) addCostFromUsage(modelID string, inputTokens, outputTokens int64) {
+// addCostFromUsage 把一次调用的费用累加到内存中的总费用；提示缓存读取 / 写入 token 按各自单价计费。
+func (a *App) addCostFromUsage(modelID string, inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens int64) {
 	modelID = strings.TrimSpace(modelID)
 	if modelID == "" {
 		return
 	}
-	if inputTokens < 0 {
-		inputTokens = 0
+	if inputTokens == 0 && outputTokens == 0 && cacheReadTokens == 0 && cacheWriteTokens == 0 {
+		return
+	}
+
+	a.billingState.mu.Lock()
+	defer a.billingState.mu.Unlock()
+
+	pricing, ok := a.billingState.priceByModel[modelID]
+	if !ok {
+		return
 	}
-	if outputTokens < 0 {
-		outputTokens = 0
+
+	delta := costForTokens(pricing, inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens)
+	if math.IsNaN(delta) || math.IsInf(delta, 0) || delta <= 0 {
+		return
 	}
-	if inputTokens == 0 && outputTokens == 0 {
+
+	a.billingState.totalCost += delta
+}
+
+// addImageCost 把一次图片生成的费用（张数 × 单张价格）累加到内存中的总费用。
+func (a *App) addImageCost(modelID string, images int64) {
+	modelID = strings.TrimSpace(modelID)
+	if modelID == "" || images <= 0 {
 		return
 	}
 
@@ -96,8 +131,7 @@ func (a *App) addCostFromUsage(modelID string, inputTokens, outputTokens int64)
 		return
 	}
 
-	delta := ((float64(inputTokens) * pricing.InputPricePer1K) +
-		(float64(outputTokens) * pricing.OutputPricePer1K)) / 1_000.0
+	delta := costForImages(pricing, images)
 	if math.IsNaN(delta) || math.IsInf(delta, 0) || delta <= 0 {
 		return
 	}
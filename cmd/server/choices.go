@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+)
+
+// errChoiceSlotUnavailable 表示 n>1 的某个候选在超时前没有拿到客户端并发名额。
+var errChoiceSlotUnavailable = errors.New("concurrency limit exceeded")
+
+// choiceCount 返回请求的候选数量；n 未设置或小于 1 时为 1（范围已在 ValidateChatRequest 中校验）。
+func choiceCount(n *int) int {
+	if n == nil || *n < 1 {
+		return 1
+	}
+	return *n
+}
+
+// fanOutChoices 并发执行 n 次 call，每个候选各自通过 auth.Manager.Acquire 占用一个并发名额，
+// 因此总并发仍受客户端 max_concurrent 限制。调用方必须先释放 handler 自己占用的名额，
+// 否则 max_concurrent 小于 n 时候选之间会互相等待直到超时。
+// 任一候选失败都会取消其余候选并返回该错误。
+func (a *App) fanOutChoices(
+	ctx context.Context,
+	client *auth.Client,
+	n int,
+	call func(ctx context.Context, index int) (bedrockproxy.ChatResult, error),
+) ([]bedrockproxy.ChatResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]bedrockproxy.ChatResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for index := 0; index < n; index++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			release, err := a.auth.Acquire(ctx, client)
+			if err != nil {
+				errs[index] = errChoiceSlotUnavailable
+				cancel()
+				return
+			}
+			defer release()
+
+			result, err := call(ctx, index)
+			results[index] = result
+			if err != nil {
+				errs[index] = err
+				cancel()
+			}
+		}(index)
+	}
+	wg.Wait()
+
+	// 优先返回真正导致失败的错误，而不是被兄弟候选取消引起的 context canceled
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, errChoiceSlotUnavailable) {
+			return results, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+// mergeChoiceUsage 汇总所有候选的 token 用量（每个候选都是一次独立的 Bedrock 调用，输入 token 也分别计费）。
+// ModelID 取第一个有实际服务模型的候选：各候选按同一降级链调用，通常由同一模型服务。
+func mergeChoiceUsage(results []bedrockproxy.ChatResult) bedrockproxy.ChatResult {
+	var merged bedrockproxy.ChatResult
+	for _, result := range results {
+		if merged.ModelID == "" {
+			merged.ModelID = result.ModelID
+		}
+		merged.InputTokens += result.InputTokens
+		merged.OutputTokens += result.OutputTokens
+		merged.TotalTokens += result.TotalTokens
+		merged.CacheReadInputTokens += result.CacheReadInputTokens
+		merged.CacheWriteInputTokens += result.CacheWriteInputTokens
+		merged.GuardrailIntervened = merged.GuardrailIntervened || result.GuardrailIntervened
+		// 多个候选合计：1 + 各候选的重试次数
+		if result.Attempts > 0 {
+			merged.Attempts = max(merged.Attempts, 1) + result.Attempts - 1
+		}
+		if result.LatencyMs > merged.LatencyMs {
+			merged.LatencyMs = result.LatencyMs
+		}
+	}
+	return merged
+}
+
+func renderChoicesForLog(results []bedrockproxy.ChatResult) string {
+	if len(results) == 1 {
+		return renderAssistantContentForLog(results[0].Text, results[0].ToolCalls)
+	}
+	parts := make([]string, 0, len(results))
+	for index, result := range results {
+		parts = append(parts, fmt.Sprintf("[choice %d]\n%s", index, renderAssistantContentForLog(result.Text, result.ToolCalls)))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// handleChatCompletionsStreamChoices 是 n>1 时的流式实现：各候选并发按降级链调用 ConverseStream，
+// chunk 按到达顺序交错写出并带上各自的 choices[].index 与实际服务的模型；设置 stream_options.include_usage 时，
+// 全部结束后追加一个汇总 usage 的 chunk。
+func (a *App) handleChatCompletionsStreamChoices(
+	w http.ResponseWriter,
+	client *auth.Client,
+	request openai.ChatCompletionRequest,
+	requestID string,
+	resolvedModel string,
+	modelIDs []string,
+	guardrails bedrockproxy.GuardrailResolver,
+	n int,
+) ([]bedrockproxy.ChatResult, int, string) {
+	setSSEHeaders(w)
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = modelIDs[0]
+	}
+	chunkID := "chatcmpl-" + requestID
+	createdAt := time.Now().Unix()
+
+	var writeMu sync.Mutex
+	wrote := false
+	writeChunk := func(model string, choice openai.ChatChunkChoice) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		wrote = true
+		return writeSSEData(w, openai.ChatCompletionChunk{
+			ID:      chunkID,
+			Object:  "chat.completion.chunk",
+			Created: createdAt,
+			Model:   model,
+			Choices: []openai.ChatChunkChoice{choice},
+		})
+	}
+
+	// 与单候选流式一致：使用与请求断开无关的 context，仅受 REQUEST_TIMEOUT 限制
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
+	defer streamCancel()
+
+	results, err := a.fanOutChoices(streamCtx, client, n, func(ctx context.Context, index int) (bedrockproxy.ChatResult, error) {
+		var text strings.Builder
+		// 每个候选各自降级，降级只发生在该候选输出第一个 chunk 之前
+		choiceModel := modelName
+		result, err := a.proxy.ConverseStreamWithFallback(ctx, request, modelIDs, guardrails, func(modelID string) {
+			choiceModel = modelID
+		}, func(delta bedrockproxy.StreamDelta) error {
+			if len(delta.ToolCalls) == 0 && delta.Role == "" && delta.Text == "" &&
+				delta.ReasoningContent == "" && len(delta.ThinkingBlocks) == 0 {
+				return nil
+			}
+			text.WriteString(delta.Text)
+			return writeChunk(choiceModel, openai.ChatChunkChoice{
+				Index: index,
+				Delta: openai.ChatChunkDelta{
+					Role:             delta.Role,
+					Content:          delta.Text,
+					ReasoningContent: delta.ReasoningContent,
+					ThinkingBlocks:   delta.ThinkingBlocks,
+					ToolCalls:        delta.ToolCalls,
+				},
+			})
+		})
+		if err != nil {
+			return bedrockproxy.ChatResult{Text: text.String(), Attempts: result.Attempts, ModelID: result.ModelID}, err
+		}
+
+		finishReason := defaultFinishReason(result.FinishReason)
+		if err := writeChunk(choiceModel, openai.ChatChunkChoice{
+			Index:        index,
+			Delta:        openai.ChatChunkDelta{},
+			FinishReason: &finishReason,
+		}); err != nil {
+			return result, err
+		}
+		result.Text = text.String()
+		return result, nil
+	})
+	if err != nil {
+		statusCode := http.StatusBadGateway
+		errorMessage := "bedrock stream failed: " + err.Error()
+		switch {
+		case errors.Is(err, errChoiceSlotUnavailable):
+			statusCode = http.StatusTooManyRequests
+			errorMessage = err.Error()
+		case bedrockproxy.IsRequestError(err):
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+		case bedrockproxy.IsStructuredOutputError(err):
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+		}
+
+		writeMu.Lock()
+		started := wrote
+		writeMu.Unlock()
+		if !started {
+			writeOpenAIError(w, statusCode, errorMessage)
+		} else {
+			_ = writeSSEData(w, openai.ChatCompletionChunk{
+				ID:      chunkID,
+				Object:  "chat.completion.chunk",
+				Created: createdAt,
+				Model:   modelName,
+				Choices: []openai.ChatChunkChoice{},
+				Error: &openai.OpenAIErrorPayload{
+					Message: errorMessage,
+					Type:    "api_error",
+					Code:    fmt.Sprint(statusCode),
+				},
+			})
+		}
+		return results, statusCode, errorMessage
+	}
+
+	if openai.StreamIncludeUsage(request.StreamOptions) {
+		merged := mergeChoiceUsage(results)
+		usageModel := modelName
+		if merged.ModelID != "" && merged.ModelID != modelIDs[0] {
+			usageModel = merged.ModelID
+		}
+		usage := buildChatUsage(merged)
+		if err := writeSSEData(w, buildUsageChunk(chunkID, createdAt, usageModel, &usage)); err != nil {
+			return results, http.StatusBadGateway, "stream write failed: " + err.Error()
+		}
+	}
+	if err := writeSSEDone(w); err != nil {
+		return results, http.StatusBadGateway, "stream completion failed: " + err.Error()
+	}
+	return results, http.StatusOK, ""
+}
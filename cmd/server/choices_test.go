@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/config"
+	"aws-cursor-router/internal/openai"
+)
+
+func newChoicesTestApp(t *testing.T, maxConcurrent int) (*App, *auth.Client) {
+	t.Helper()
+	manager := auth.NewManager(config.Config{})
+	if err := manager.UpsertClient(config.ClientConfig{
+		ID:                   "team-a",
+		Name:                 "Team A",
+		APIKey:               "key-a",
+		MaxRequestsPerMinute: 100,
+		MaxConcurrent:        maxConcurrent,
+	}); err != nil {
+		t.Fatalf("upsert client failed: %v", err)
+	}
+
+	request := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	request.Header.Set("Authorization", "Bearer key-a")
+	client, err := manager.Authenticate(request)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	return &App{auth: manager}, client
+}
+
+func TestFanOutChoicesRespectsClientConcurrency(t *testing.T) {
+	app, client := newChoicesTestApp(t, 2)
+
+	var running, peak int32
+	results, err := app.fanOutChoices(context.Background(), client, 4, func(ctx context.Context, index int) (bedrockproxy.ChatResult, error) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			observed := atomic.LoadInt32(&peak)
+			if current <= observed || atomic.CompareAndSwapInt32(&peak, observed, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return bedrockproxy.ChatResult{Text: "ok", InputTokens: 10, OutputTokens: index + 1, TotalTokens: 11 + index}, nil
+	})
+	if err != nil {
+		t.Fatalf("fanOutChoices returned error: %v", err)
+	}
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent candidates, observed %d", peak)
+	}
+
+	usage := mergeChoiceUsage(results)
+	if usage.InputTokens != 40 || usage.OutputTokens != 10 || usage.TotalTokens != 50 {
+		t.Fatalf("unexpected aggregated usage: %+v", usage)
+	}
+}
+
+func TestFanOutChoicesReturnsCandidateError(t *testing.T) {
+	app, client := newChoicesTestApp(t, 4)
+	failure := errors.New("throttled")
+
+	_, err := app.fanOutChoices(context.Background(), client, 3, func(ctx context.Context, index int) (bedrockproxy.ChatResult, error) {
+		if index == 1 {
+			return bedrockproxy.ChatResult{}, failure
+		}
+		<-ctx.Done()
+		return bedrockproxy.ChatResult{}, ctx.Err()
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected candidate error, got %v", err)
+	}
+}
+
+func TestBuildUsageChunkHasEmptyChoices(t *testing.T) {
+	chunk := buildUsageChunk("chatcmpl-1", 1, "model", &openai.Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7})
+	blob, err := json.Marshal(chunk)
+	if err != nil {
+		t.Fatalf("marshal usage chunk failed: %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(blob, &decoded); err != nil {
+		t.Fatalf("unmarshal usage chunk failed: %v", err)
+	}
+	choices, ok := decoded["choices"].([]any)
+	if !ok || len(choices) != 0 {
+		t.Fatalf("expected empty choices array, got %s", blob)
+	}
+	usage, ok := decoded["usage"].(map[string]any)
+	if !ok || usage["total_tokens"] != float64(7) {
+		t.Fatalf("unexpected usage: %s", blob)
+	}
+}
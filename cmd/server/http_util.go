@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"aws-cursor-router/internal/anthropic"
 	"aws-cursor-router/internal/openai"
 	"github.com/google/uuid"
 )
@@ -82,6 +83,16 @@ func writeOpenAIError(w http.ResponseWriter, status int, message string) {
 	}})
 }
 
+func writeAnthropicError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, anthropic.ErrorResponse{
+		Type: "error",
+		Error: anthropic.ErrorPayload{
+			Type:    anthropic.ErrorTypeForStatus(status),
+			Message: strings.TrimSpace(message),
+		},
+	})
+}
+
 func decodeJSONBody(w http.ResponseWriter, r *http.Request, maxBodyBytes int64, dst any) error {
 	if maxBodyBytes > 0 {
 		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
@@ -140,6 +151,12 @@ func newRequestID() string {
 	return "req-" + uuid.NewString()
 }
 
+// newResponseID 生成 Responses API 的 response ID。ID 决定存储记录的归属，必须由服务端生成，
+// 不能像 request ID 那样取自客户端可控的 x-request-id。
+func newResponseID() string {
+	return "resp_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
 func truncateRunes(value string, maxChars int) string {
 	if maxChars <= 0 {
 		return ""
@@ -183,6 +200,45 @@ func writeSSEData(w http.ResponseWriter, payload any) error {
 	return nil
 }
 
+// writeNDJSONLine 写出一行 JSON（Ollama 流式格式）并立即 flush。
+func writeNDJSONLine(w http.ResponseWriter, payload any) error {
+	blob, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(blob); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return fmt.Errorf("streaming not supported")
+	}
+	return nil
+}
+
+// writeSSEEvent 写出带 event 名称的 SSE 帧（Anthropic Messages 流式格式）。
+func writeSSEEvent(w http.ResponseWriter, event string, payload any) error {
+	blob, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "event: "+event+"\ndata: "); err != nil {
+		return err
+	}
+	if _, err := w.Write(blob); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "\n\n"); err != nil {
+		return err
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return fmt.Errorf("streaming not supported")
+	}
+	return nil
+}
+
 func writeSSEDone(w http.ResponseWriter) error {
 	if _, err := io.WriteString(w, "data: [DONE]\n\n"); err != nil {
 		return err
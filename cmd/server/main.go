@@ -34,11 +34,16 @@ type App struct {
 	logger *log.Logger
 
 	adminStatic http.Handler
-
-	awsState        awsState
-	modelState      modelState
-	billingState    billingState
-	adminTokenState adminTokenState
+	background  *backgroundManager
+	batches     *batchRunner
+
+	awsState           awsState
+	modelState         modelState
+	billingState       billingState
+	modelMetadataState modelMetadataState
+	guardrailState     guardrailState
+	modelFallbackState modelFallbackState
+	adminTokenState    adminTokenState
 }
 
 type adminClientPayload struct {
@@ -63,6 +68,30 @@ type adminEnabledModelsPayload struct {
 	EnabledModelIDs []string `json:"enabled_model_ids"`
 }
 
+type adminRequestFieldsPayload struct {
+	AllowedRequestFields []string `json:"allowed_request_fields"`
+}
+
+type adminPromptCachePayload struct {
+	Items []store.PromptCacheRow `json:"items"`
+}
+
+type adminGuardrailsPayload struct {
+	Items []store.GuardrailRow `json:"items"`
+}
+
+type adminModelFallbacksPayload struct {
+	Items []store.ModelFallbackRow `json:"items"`
+}
+
+type adminUpstreamsPayload struct {
+	Items []store.UpstreamRow `json:"items"`
+}
+
+type adminModelMetadataPayload struct {
+	Items []store.ModelMetadataRow `json:"items"`
+}
+
 func main() {
 	// 优先从可执行文件所在目录加载 .env，保证双击 exe 也能读到本地配置
 	if exePath, err := os.Executable(); err == nil {
@@ -147,6 +176,12 @@ func main() {
 		cfg.ForceToolUse,
 		cfg.BufferToolCallArgs,
 	)
+	proxy.SetRetryPolicy(bedrockproxy.RetryPolicy{
+		MaxRetries:  cfg.BedrockMaxRetries,
+		BaseDelay:   cfg.BedrockRetryBaseDelay,
+		MaxDelay:    cfg.BedrockRetryMaxDelay,
+		BudgetRatio: float64(cfg.BedrockRetryBudgetPercent) / 100,
+	})
 
 	adminSubFS, err := fs.Sub(adminUIFiles, "web/admin")
 	if err != nil {
@@ -161,6 +196,8 @@ func main() {
 		logger: logger,
 
 		adminStatic: http.StripPrefix(adminStaticPath(), http.FileServer(http.FS(adminSubFS))),
+		background:  newBackgroundManager(cfg.BackgroundQueueSize),
+		batches:     newBatchRunner(),
 	}
 
 	if err := app.reloadAWSConfig(context.Background()); err != nil {
@@ -169,12 +206,42 @@ func main() {
 	if err := app.reloadEnabledModels(context.Background()); err != nil {
 		log.Fatalf("failed to initialize enabled models: %v", err)
 	}
+	if err := app.reloadAllowedRequestFields(context.Background()); err != nil {
+		log.Fatalf("failed to initialize request field allowlist: %v", err)
+	}
+	if err := app.reloadPromptCache(context.Background()); err != nil {
+		log.Fatalf("failed to initialize prompt cache config: %v", err)
+	}
+	if err := app.reloadGuardrails(context.Background()); err != nil {
+		log.Fatalf("failed to initialize guardrails: %v", err)
+	}
+	if err := app.reloadModelAliases(context.Background()); err != nil {
+		log.Fatalf("failed to initialize model aliases: %v", err)
+	}
+	if err := app.reloadModelFallbacks(context.Background()); err != nil {
+		log.Fatalf("failed to initialize model fallbacks: %v", err)
+	}
+	if err := app.reloadModelMetadata(context.Background()); err != nil {
+		log.Fatalf("failed to initialize model metadata overrides: %v", err)
+	}
+	if err := app.rekeyAliasUsage(context.Background()); err != nil {
+		log.Fatalf("failed to migrate alias usage: %v", err)
+	}
 	if err := app.reloadBillingState(context.Background()); err != nil {
 		log.Fatalf("failed to initialize billing state: %v", err)
 	}
 	if err := app.reloadAdminToken(context.Background()); err != nil {
 		log.Fatalf("failed to initialize admin token: %v", err)
 	}
+	// 后台任务只在内存中排队，上次进程未完成的后台响应无法继续执行
+	if failed, err := routerStore.FailUnfinishedResponses(context.Background(), "server restarted before the background response finished"); err != nil {
+		log.Fatalf("failed to reset unfinished background responses: %v", err)
+	} else if failed > 0 {
+		logger.Printf("marked %d unfinished background responses as failed", failed)
+	}
+	app.startBackgroundWorkers(cfg.BackgroundWorkers)
+	// batch 任务的进度保存在 SQLite 中，上次进程未完成的任务从未执行的行继续
+	app.startBatchRunner()
 
 	mux := http.NewServeMux()
 	registerPublicRoutes(mux, app)
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// modelMetadata 是 ListFoundationModels 返回的单个基础模型信息。
+// Bedrock 不提供上下文窗口、最大输出、工具调用能力与发布时间（created），这些只能通过管理员覆盖表配置。
+type modelMetadata struct {
+	Name              string
+	Provider          string
+	InputModalities   []string
+	OutputModalities  []string
+	SupportsStreaming *bool
+	LifecycleStatus   string
+}
+
+type modelMetadataState struct {
+	mu        sync.RWMutex
+	fetched   map[string]modelMetadata
+	overrides map[string]store.ModelMetadataRow
+}
+
+// crossRegionPrefixes 是跨区域推理配置文件的 ID 前缀，元数据按去掉前缀后的基础模型 ID 查找。
+var crossRegionPrefixes = []string{"us.", "eu.", "apac.", "global."}
+
+func metadataFromSummary(summary bedrocktypes.FoundationModelSummary) modelMetadata {
+	metadata := modelMetadata{
+		Name:              strings.TrimSpace(awssdk.ToString(summary.ModelName)),
+		Provider:          strings.TrimSpace(awssdk.ToString(summary.ProviderName)),
+		InputModalities:   modalityNames(summary.InputModalities),
+		OutputModalities:  modalityNames(summary.OutputModalities),
+		SupportsStreaming: summary.ResponseStreamingSupported,
+	}
+	if summary.ModelLifecycle != nil {
+		metadata.LifecycleStatus = string(summary.ModelLifecycle.Status)
+	}
+	return metadata
+}
+
+func modalityNames(modalities []bedrocktypes.ModelModality) []string {
+	names := make([]string, 0, len(modalities))
+	for _, modality := range modalities {
+		names = append(names, string(modality))
+	}
+	return names
+}
+
+func (a *App) setFetchedModelMetadata(metadata map[string]modelMetadata) {
+	a.modelMetadataState.mu.Lock()
+	a.modelMetadataState.fetched = metadata
+	a.modelMetadataState.mu.Unlock()
+}
+
+func (a *App) reloadModelMetadata(ctx context.Context) error {
+	rows, err := a.store.ListModelMetadataOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]store.ModelMetadataRow, len(rows))
+	for _, row := range rows {
+		overrides[row.ModelID] = row
+	}
+
+	a.modelMetadataState.mu.Lock()
+	a.modelMetadataState.overrides = overrides
+	a.modelMetadataState.mu.Unlock()
+	return nil
+}
+
+// buildModelInfo 组装 /v1/models 中的模型对象：先取 Bedrock 返回的元数据，再用管理员覆盖的非空字段替换。
+// 两者都按精确 ID 优先、去掉跨区域前缀后的基础模型 ID 其次查找。created 未配置覆盖时为 0，而不是编造一个时间。
+func (a *App) buildModelInfo(modelID string) openai.ModelInfo {
+	a.modelMetadataState.mu.RLock()
+	defer a.modelMetadataState.mu.RUnlock()
+
+	info := openai.ModelInfo{
+		ID:      modelID,
+		Object:  "model",
+		OwnedBy: "aws-bedrock",
+	}
+
+	// 别名使用目标模型的元数据
+	metadataID := modelID
+	if target, ok := a.proxy.AliasTarget(modelID); ok {
+		metadataID = target
+		info.AliasFor = target
+	}
+
+	if metadata, ok := lookupModelMetadata(a.modelMetadataState.fetched, metadataID); ok {
+		info.Name = metadata.Name
+		info.Provider = metadata.Provider
+		info.InputModalities = metadata.InputModalities
+		info.OutputModalities = metadata.OutputModalities
+		info.SupportsStreaming = metadata.SupportsStreaming
+		info.LifecycleStatus = metadata.LifecycleStatus
+	}
+	if override, ok := lookupModelMetadata(a.modelMetadataState.overrides, metadataID); ok {
+		if override.Provider != "" {
+			info.Provider = override.Provider
+		}
+		if len(override.InputModalities) > 0 {
+			info.InputModalities = override.InputModalities
+		}
+		if len(override.OutputModalities) > 0 {
+			info.OutputModalities = override.OutputModalities
+		}
+		if override.SupportsStreaming != nil {
+			info.SupportsStreaming = override.SupportsStreaming
+		}
+		if override.SupportsTools != nil {
+			info.SupportsTools = override.SupportsTools
+		}
+		if override.ContextWindow != nil {
+			info.ContextWindow = override.ContextWindow
+		}
+		if override.MaxOutputTokens != nil {
+			info.MaxOutputTokens = override.MaxOutputTokens
+		}
+		if override.LifecycleStatus != "" {
+			info.LifecycleStatus = override.LifecycleStatus
+		}
+		if override.Created != nil {
+			info.Created = *override.Created
+		}
+	}
+	if info.Provider != "" {
+		info.OwnedBy = strings.ToLower(info.Provider)
+	}
+	return info
+}
+
+func lookupModelMetadata[T any](items map[string]T, modelID string) (T, bool) {
+	if item, ok := items[modelID]; ok {
+		return item, true
+	}
+	for _, prefix := range crossRegionPrefixes {
+		if baseModelID, found := strings.CutPrefix(modelID, prefix); found {
+			item, ok := items[baseModelID]
+			return item, ok
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// modelContextWindow 返回管理员为模型配置的上下文窗口（Bedrock 不提供该信息），未配置时为 nil。
+func (a *App) modelContextWindow(modelID string) *int {
+	a.modelMetadataState.mu.RLock()
+	defer a.modelMetadataState.mu.RUnlock()
+	if override, ok := lookupModelMetadata(a.modelMetadataState.overrides, modelID); ok {
+		return override.ContextWindow
+	}
+	return nil
+}
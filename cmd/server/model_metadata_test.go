@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/store"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+type fakeFoundationModelLister struct {
+	summaries map[bedrocktypes.ModelModality][]bedrocktypes.FoundationModelSummary
+}
+
+func (f fakeFoundationModelLister) ListFoundationModels(
+	_ context.Context,
+	params *bedrock.ListFoundationModelsInput,
+	_ ...func(*bedrock.Options),
+) (*bedrock.ListFoundationModelsOutput, error) {
+	return &bedrock.ListFoundationModelsOutput{ModelSummaries: f.summaries[params.ByOutputModality]}, nil
+}
+
+func TestModelInfoMergesBedrockMetadataAndOverrides(t *testing.T) {
+	lister := fakeFoundationModelLister{summaries: map[bedrocktypes.ModelModality][]bedrocktypes.FoundationModelSummary{
+		bedrocktypes.ModelModalityText: {{
+			ModelId:                    awssdk.String("anthropic.claude-test-v1:0"),
+			ModelName:                  awssdk.String("Claude Test"),
+			ProviderName:               awssdk.String("Anthropic"),
+			InputModalities:            []bedrocktypes.ModelModality{bedrocktypes.ModelModalityText, bedrocktypes.ModelModalityImage},
+			OutputModalities:           []bedrocktypes.ModelModality{bedrocktypes.ModelModalityText},
+			ResponseStreamingSupported: awssdk.Bool(true),
+			ModelLifecycle:             &bedrocktypes.FoundationModelLifecycle{Status: bedrocktypes.FoundationModelLifecycleStatusActive},
+		}},
+	}}
+	modelIDs, metadata, err := fetchAvailableModels(context.Background(), lister, "us-east-1")
+	if err != nil {
+		t.Fatalf("fetchAvailableModels returned error: %v", err)
+	}
+	if len(modelIDs) != 1 || modelIDs[0] != "us.anthropic.claude-test-v1:0" {
+		t.Fatalf("unexpected model ids: %v", modelIDs)
+	}
+
+	s, err := store.New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	app := &App{
+		store: s,
+		proxy: bedrockproxy.NewService(nil, "", map[string]string{"claude-test": modelIDs[0]}, 0, 0, false, false),
+	}
+	app.setFetchedModelMetadata(metadata)
+
+	info := app.buildModelInfo(modelIDs[0])
+	if info.Name != "Claude Test" || info.OwnedBy != "anthropic" || info.LifecycleStatus != "ACTIVE" ||
+		len(info.InputModalities) != 2 || info.SupportsStreaming == nil || !*info.SupportsStreaming {
+		t.Fatalf("unexpected model info from bedrock metadata: %+v", info)
+	}
+	if info.ContextWindow != nil || info.SupportsTools != nil || info.Created != 0 {
+		t.Fatalf("expected unknown capabilities to be omitted: %+v", info)
+	}
+
+	contextWindow := 200000
+	if err := s.ReplaceModelMetadataOverrides(context.Background(), []store.ModelMetadataRow{{
+		ModelID:         "anthropic.claude-test-v1:0",
+		SupportsTools:   awssdk.Bool(true),
+		ContextWindow:   &contextWindow,
+		LifecycleStatus: "legacy",
+		Created:         awssdk.Int64(1718841600),
+	}}); err != nil {
+		t.Fatalf("replace overrides failed: %v", err)
+	}
+	if err := app.reloadModelMetadata(context.Background()); err != nil {
+		t.Fatalf("reload overrides failed: %v", err)
+	}
+	info = app.buildModelInfo(modelIDs[0])
+	if info.ContextWindow == nil || *info.ContextWindow != 200000 || info.SupportsTools == nil || !*info.SupportsTools ||
+		info.LifecycleStatus != "LEGACY" || info.Name != "Claude Test" || info.Created != 1718841600 {
+		t.Fatalf("expected overrides to apply on top of bedrock metadata: %+v", info)
+	}
+
+	alias := app.buildModelInfo("claude-test")
+	if alias.ID != "claude-test" || alias.AliasFor != modelIDs[0] || alias.Name != "Claude Test" || alias.ContextWindow == nil {
+		t.Fatalf("expected alias to carry its target's metadata: %+v", alias)
+	}
+
+	invalid := 0
+	if err := s.ReplaceModelMetadataOverrides(context.Background(), []store.ModelMetadataRow{{ModelID: "x", MaxOutputTokens: &invalid}}); err == nil {
+		t.Fatalf("expected invalid max_output_tokens to be rejected")
+	}
+}
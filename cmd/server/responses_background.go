@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// backgroundJobRetention 是后台任务结束后事件仍保留在内存中的时间，期间客户端可以重新挂接事件流。
+const backgroundJobRetention = 10 * time.Minute
+
+var errBackgroundQueueFull = errors.New("background queue is full")
+
+// backgroundManager 管理 background=true 的 Responses 请求：固定数量的 worker 从队列中取任务执行，
+// 任务与已产生的 SSE 事件只保存在内存中（结果本身写入 responses 表）。
+type backgroundManager struct {
+	mu    sync.Mutex
+	jobs  map[string]*backgroundJob
+	queue chan *backgroundJob
+}
+
+func newBackgroundManager(queueSize int) *backgroundManager {
+	return &backgroundManager{
+		jobs:  make(map[string]*backgroundJob),
+		queue: make(chan *backgroundJob, queueSize),
+	}
+}
+
+// submit 登记任务并放入队列；队列已满时返回 errBackgroundQueueFull。
+func (m *backgroundManager) submit(job *backgroundJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- job:
+		m.jobs[job.responseID] = job
+		return nil
+	default:
+		return errBackgroundQueueFull
+	}
+}
+
+// lookup 返回属于 clientID 的后台任务；其他客户端的任务视为不存在。
+func (m *backgroundManager) lookup(clientID, responseID string) *backgroundJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[responseID]
+	if !ok || job.client.ID != clientID {
+		return nil
+	}
+	return job
+}
+
+// finish 标记任务的事件流结束，并在保留期后从内存中移除。
+func (m *backgroundManager) finish(job *backgroundJob) {
+	job.mu.Lock()
+	job.done = true
+	job.cancel()
+	job.wakeLocked()
+	job.mu.Unlock()
+
+	time.AfterFunc(backgroundJobRetention, func() {
+		m.mu.Lock()
+		if m.jobs[job.responseID] == job {
+			delete(m.jobs, job.responseID)
+		}
+		m.mu.Unlock()
+	})
+}
+
+type backgroundJob struct {
+	responseID     string
+	requestID      string
+	client         *auth.Client
+	request        openai.ResponsesCreateRequest
+	chatRequest    openai.ChatCompletionRequest
+	resolvedModel  string
+	bedrockModelID string
+	// 排队时保存的记录（输入项、模型、previous_response_id 等），状态变化时以它为模板重新保存
+	record   store.ResponseRecord
+	response map[string]any
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	status string
+	// 已产生的 SSE 帧（"data: ...\n\n"），第 i 项的 sequence_number 为 i+1
+	events [][]byte
+	done   bool
+	notify chan struct{}
+}
+
+// snapshot 返回 sequence_number 大于 after 的事件、事件流是否已结束，以及下一次有新事件时会关闭的 channel。
+func (j *backgroundJob) snapshot(after int) ([][]byte, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var events [][]byte
+	if after < len(j.events) {
+		events = j.events[max(after, 0):]
+	}
+	return events, j.done, j.notify
+}
+
+// appendStreamEvent 追加 Bedrock 流产生的事件；任务已取消或结束时返回错误，
+// 保证 response.cancelled 之后不会再出现流事件。
+func (j *backgroundJob) appendStreamEvent(frame []byte) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.ctx.Err(); err != nil {
+		return err
+	}
+	j.events = append(j.events, frame)
+	j.wakeLocked()
+	return nil
+}
+
+// appendTerminalEventLocked 追加 response.failed / response.cancelled 等终止事件；调用方持有 job.mu。
+func (j *backgroundJob) appendTerminalEventLocked(eventType string, response map[string]any) {
+	blob, err := json.Marshal(map[string]any{
+		"type":            eventType,
+		"response":        response,
+		"sequence_number": len(j.events) + 1,
+	})
+	if err != nil {
+		return
+	}
+	j.events = append(j.events, []byte("data: "+string(blob)+"\n\n"))
+	j.wakeLocked()
+}
+
+func (j *backgroundJob) wakeLocked() {
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+// responseObject 以排队时的 response 对象为模板生成指定状态的副本。
+func (j *backgroundJob) responseObject(status string, errorObject any) map[string]any {
+	response := make(map[string]any, len(j.response)+1)
+	for key, value := range j.response {
+		response[key] = value
+	}
+	response["status"] = status
+	response["error"] = errorObject
+	return response
+}
+
+// saveBackgroundStatusLocked 保存状态变化后的 response 对象；调用方持有 job.mu。
+func (a *App) saveBackgroundStatusLocked(job *backgroundJob, status string, errorObject any) map[string]any {
+	job.status = status
+	response := job.responseObject(status, errorObject)
+	responseJSON, _ := json.Marshal(response)
+
+	record := job.record
+	record.Status = status
+	record.ResponseJSON = string(responseJSON)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.store.SaveResponse(ctx, record); err != nil {
+		a.logger.Printf("warning: store background response %s (%s) failed: %v", job.responseID, status, err)
+	}
+	return response
+}
+
+// submitBackgroundResponse 保存 queued 状态的响应并放入后台队列。
+func (a *App) submitBackgroundResponse(
+	client *auth.Client,
+	request openai.ResponsesCreateRequest,
+	chatRequest openai.ChatCompletionRequest,
+	requestID string,
+	responseID string,
+	resolvedModel string,
+	bedrockModelID string,
+) (*backgroundJob, error) {
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+	var previousResponseID any
+	if request.PreviousResponseID != "" {
+		previousResponseID = request.PreviousResponseID
+	}
+	response := map[string]any{
+		"id":                   responseID,
+		"object":               "response",
+		"created_at":           time.Now().Unix(),
+		"status":               "queued",
+		"background":           true,
+		"model":                modelName,
+		"output":               []any{},
+		"parallel_tool_calls":  boolOrDefault(request.ParallelToolCalls, true),
+		"tool_choice":          request.ToolChoice,
+		"error":                nil,
+		"incomplete_details":   nil,
+		"usage":                nil,
+		"truncation":           "disabled",
+		"text":                 responsesTextConfig(request.Text),
+		"reasoning":            responsesReasoningConfig(chatRequest.ReasoningEffort),
+		"tools":                []any{},
+		"instructions":         nil,
+		"previous_response_id": previousResponseID,
+		"store":                true,
+		"metadata":             map[string]any{},
+	}
+
+	inputItems, err := openai.NormalizeResponsesInputItems(request.Input)
+	if err != nil {
+		return nil, err
+	}
+	inputJSON, _ := json.Marshal(inputItems)
+	now := time.Now().UTC()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &backgroundJob{
+		responseID:     responseID,
+		requestID:      requestID,
+		client:         client,
+		request:        request,
+		chatRequest:    chatRequest,
+		resolvedModel:  resolvedModel,
+		bedrockModelID: bedrockModelID,
+		record: store.ResponseRecord{
+			ResponseID:         responseID,
+			ClientID:           client.ID,
+			Model:              bedrockModelID,
+			PreviousResponseID: request.PreviousResponseID,
+			InputItemsJSON:     string(inputJSON),
+			OutputItemsJSON:    "[]",
+			MessagesJSON:       "[]",
+			CreatedAt:          now,
+			ExpiresAt:          now.Add(a.cfg.ResponsesStoreTTL),
+		},
+		response: response,
+		ctx:      ctx,
+		cancel:   cancel,
+		notify:   make(chan struct{}),
+	}
+
+	job.mu.Lock()
+	a.saveBackgroundStatusLocked(job, "queued", nil)
+	job.mu.Unlock()
+
+	if err := a.background.submit(job); err != nil {
+		cancel()
+		deleteCtx, deleteCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer deleteCancel()
+		_, _ = a.store.DeleteResponse(deleteCtx, client.ID, responseID)
+		return nil, err
+	}
+	return job, nil
+}
+
+// cancelBackgroundResponse 取消排队中或执行中的后台响应，向事件流追加 response.cancelled，返回取消后的 response 对象；
+// 已结束的任务不做修改，返回 false。
+func (a *App) cancelBackgroundResponse(job *backgroundJob) (map[string]any, bool) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.status != "queued" && job.status != "in_progress" {
+		return nil, false
+	}
+	job.cancel()
+	response := a.saveBackgroundStatusLocked(job, "cancelled", nil)
+	job.appendTerminalEventLocked("response.cancelled", response)
+	return response, true
+}
+
+// startBackgroundWorkers 启动固定数量的后台 worker。
+func (a *App) startBackgroundWorkers(workers int) {
+	for range workers {
+		go func() {
+			for job := range a.background.queue {
+				a.runBackgroundResponse(job)
+			}
+		}()
+	}
+}
+
+// runBackgroundResponse 执行一个后台响应：与前台请求一样占用该 API key 的并发槽位并检查全局费用上限，
+// 以流式方式调用 Bedrock，事件写入任务的事件缓冲区，完成后保存结果并记录调用日志与费用。
+func (a *App) runBackgroundResponse(job *backgroundJob) {
+	defer a.background.finish(job)
+
+	if job.ctx.Err() != nil {
+		return
+	}
+	release, err := a.auth.Acquire(job.ctx, job.client)
+	if err != nil {
+		// 只有任务被取消时 Acquire 才会失败
+		return
+	}
+	defer release()
+
+	if err := a.checkGlobalCostLimit(); err != nil {
+		a.failBackgroundResponse(job, "rate_limit_exceeded", err.Error())
+		return
+	}
+
+	job.mu.Lock()
+	if job.status != "queued" {
+		job.mu.Unlock()
+		return
+	}
+	a.saveBackgroundStatusLocked(job, "in_progress", nil)
+	job.mu.Unlock()
+
+	startedAt := time.Now().UTC()
+	logModel := job.resolvedModel
+	if logModel == "default" {
+		logModel = job.bedrockModelID
+	}
+	record := store.CallRecord{
+		RequestID:      job.requestID,
+		ClientID:       job.client.ID,
+		Model:          logModel,
+		BedrockModelID: job.bedrockModelID,
+		RequestContent: openai.RenderRequestForLog(job.chatRequest, a.cfg.MaxContentChars),
+		IsStream:       job.chatRequest.Stream,
+		CreatedAt:      startedAt,
+	}
+	if job.chatRequest.Guardrail != nil {
+		record.GuardrailID = job.chatRequest.Guardrail.Identifier
+	}
+
+	// 后台任务不受 REQUEST_TIMEOUT 限制；cancel 会立即中止进行中的 Bedrock 调用
+	streamCtx, streamCancel := context.WithTimeout(job.ctx, a.cfg.BackgroundTimeout)
+	defer streamCancel()
+	writer := &backgroundEventWriter{job: job, header: make(http.Header)}
+	result, statusCode, errorMessage := a.handleResponsesStream(
+		writer,
+		streamCtx,
+		job.request,
+		job.chatRequest,
+		job.requestID,
+		job.responseID,
+		job.resolvedModel,
+		a.modelFallbackChain(job.client, job.bedrockModelID),
+		a.guardrailResolver(job.client.ID),
+		func(responseID string, result bedrockproxy.ChatResult, output any, response any) error {
+			return a.completeBackgroundResponse(job, result, output, response)
+		},
+	)
+
+	job.mu.Lock()
+	cancelled := job.status == "cancelled"
+	job.mu.Unlock()
+	if cancelled {
+		statusCode = 499
+		errorMessage = "background response cancelled"
+	} else if statusCode != http.StatusOK {
+		a.failBackgroundResponse(job, "server_error", errorMessage)
+	}
+
+	record.StatusCode = statusCode
+	record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+	record.ResponseContent = truncateRunes(renderResponsesOutputForLog(buildResponsesOutputItems(job.requestID, result)), a.cfg.MaxContentChars)
+	record.InputTokens = result.InputTokens
+	record.OutputTokens = result.OutputTokens
+	record.TotalTokens = result.TotalTokens
+	record.CacheReadInputTokens = result.CacheReadInputTokens
+	record.CacheWriteInputTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
+	record.Attempts = result.Attempts
+	a.recordServedModel(&record, job.client.ID, result.ModelID)
+	record.LatencyMs = result.LatencyMs
+	if record.LatencyMs == 0 {
+		record.LatencyMs = time.Since(startedAt).Milliseconds()
+	}
+	if !a.store.Enqueue(record) {
+		a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", job.requestID, job.client.ID)
+		return
+	}
+	a.addCostFromUsage(
+		record.BedrockModelID,
+		int64(record.InputTokens),
+		int64(record.OutputTokens),
+		int64(record.CacheReadInputTokens),
+		int64(record.CacheWriteInputTokens),
+	)
+}
+
+// completeBackgroundResponse 保存后台响应的结果，保存成功后才把任务标记为 completed。
+// 保存失败（如超过 RESPONSES_STORE_MAX_BYTES）时任务保持 in_progress 并返回错误，
+// handleResponsesStream 因此不发送 response.completed，runBackgroundResponse 随后把任务标记为 failed。
+func (a *App) completeBackgroundResponse(job *backgroundJob, result bedrockproxy.ChatResult, output any, response any) error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.status != "in_progress" {
+		return nil
+	}
+	if err := a.saveResponse(job.client.ID, job.request, job.responseID, result.ModelID, result, output, response); err != nil {
+		return err
+	}
+	job.status = "completed"
+	return nil
+}
+
+// failBackgroundResponse 把未完成的后台响应标记为 failed，并向事件流追加 response.failed。
+func (a *App) failBackgroundResponse(job *backgroundJob, code, message string) {
+	job.mu.Lock()
+	if job.status != "queued" && job.status != "in_progress" {
+		job.mu.Unlock()
+		return
+	}
+	response := a.saveBackgroundStatusLocked(job, "failed", map[string]any{
+		"code":    code,
+		"message": message,
+	})
+	job.appendTerminalEventLocked("response.failed", response)
+	job.mu.Unlock()
+}
+
+// streamBackgroundResponse 把后台响应的事件流转发给客户端：先补发 sequence_number 大于 startingAfter 的事件，
+// 再跟随新事件直到任务结束。客户端断开不会影响后台任务。
+func (a *App) streamBackgroundResponse(w http.ResponseWriter, ctx context.Context, job *backgroundJob, startingAfter int) {
+	setSSEHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	next := startingAfter
+	for {
+		events, done, notify := job.snapshot(next)
+		for _, frame := range events {
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			next++
+		}
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			return
+		}
+		if done && len(events) == 0 {
+			_ = writeSSEDone(w)
+			return
+		}
+		if done {
+			continue
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backgroundEventWriter 是后台任务执行流式调用时使用的 http.ResponseWriter：
+// 把写入的 SSE 帧追加到任务的事件缓冲区，任务取消后写入失败，从而中止 Bedrock 流。
+type backgroundEventWriter struct {
+	job     *backgroundJob
+	header  http.Header
+	pending []byte
+}
+
+func (w *backgroundEventWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *backgroundEventWriter) WriteHeader(int) {}
+
+func (w *backgroundEventWriter) Flush() {}
+
+func (w *backgroundEventWriter) Write(p []byte) (int, error) {
+	if err := w.job.ctx.Err(); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.Index(w.pending, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		frame := append([]byte(nil), w.pending[:end+2]...)
+		w.pending = w.pending[end+2:]
+		// [DONE] 由转发方在任务结束时补发
+		if bytes.Equal(frame, []byte("data: [DONE]\n\n")) {
+			continue
+		}
+		if err := w.job.appendStreamEvent(frame); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// maxResponsesChainDepth 限制 previous_response_id 链的回溯深度，防止异常数据导致无限回溯。
+const maxResponsesChainDepth = 1000
+
+var (
+	errPreviousResponseNotFound = errors.New("previous response not found")
+	errPreviousResponseNotReady = errors.New("previous response is not completed")
+)
+
+// loadResponsesHistory 沿 previous_response_id 链回溯，按时间顺序拼接每一轮保存的消息。
+// 只能引用本客户端（API key）保存且未过期的响应；链中任何一环缺失都视为找不到，避免静默丢失上下文。
+func (a *App) loadResponsesHistory(ctx context.Context, clientID, previousResponseID string) ([]openai.ChatMessage, error) {
+	chain := make([]store.ResponseRecord, 0, 8)
+	seen := make(map[string]struct{})
+	for responseID := previousResponseID; responseID != ""; {
+		if _, ok := seen[responseID]; ok || len(chain) >= maxResponsesChainDepth {
+			return nil, fmt.Errorf("previous response chain is too long or cyclic: %s", previousResponseID)
+		}
+		seen[responseID] = struct{}{}
+
+		record, ok, err := a.store.GetResponse(ctx, clientID, responseID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errPreviousResponseNotFound, responseID)
+		}
+		if record.Status != "completed" {
+			// 排队中 / 执行中 / 失败 / 已取消的后台响应没有可用的对话消息
+			return nil, fmt.Errorf("%w: %s is %s", errPreviousResponseNotReady, responseID, record.Status)
+		}
+		chain = append(chain, record)
+		responseID = record.PreviousResponseID
+	}
+
+	history := make([]openai.ChatMessage, 0, len(chain)*2)
+	for index := len(chain) - 1; index >= 0; index-- {
+		var messages []openai.ChatMessage
+		if err := json.Unmarshal([]byte(chain[index].MessagesJSON), &messages); err != nil {
+			return nil, fmt.Errorf("decode stored response %s failed: %w", chain[index].ResponseID, err)
+		}
+		history = append(history, messages...)
+	}
+	return history, nil
+}
+
+// saveResponse 保存 store=true 的响应：本轮输入项、输出项、本轮新增的对话消息（输入 + 模型回复）与完整 response 对象。
+// 超过 RESPONSES_STORE_MAX_BYTES 的记录不保存并返回错误，后续以它为 previous_response_id 的请求会返回 400。
+func (a *App) saveResponse(
+	clientID string,
+	request openai.ResponsesCreateRequest,
+	responseID string,
+	model string,
+	result bedrockproxy.ChatResult,
+	outputItems any,
+	response any,
+) error {
+	inputItems, err := openai.NormalizeResponsesInputItems(request.Input)
+	if err != nil {
+		return err
+	}
+	messages, err := openai.ParseResponsesInputMessages(request.Input, "")
+	if err != nil {
+		return err
+	}
+	messages = append(messages, openai.ChatMessage{
+		Role:           "assistant",
+		Content:        buildAssistantMessageContent(result.Text, len(result.ToolCalls) > 0),
+		ToolCalls:      result.ToolCalls,
+		ThinkingBlocks: result.ThinkingBlocks,
+	})
+
+	inputJSON, _ := json.Marshal(inputItems)
+	outputJSON, _ := json.Marshal(outputItems)
+	messagesJSON, _ := json.Marshal(messages)
+	responseJSON, _ := json.Marshal(response)
+
+	now := time.Now().UTC()
+	record := store.ResponseRecord{
+		ResponseID:         responseID,
+		ClientID:           clientID,
+		Model:              model,
+		PreviousResponseID: request.PreviousResponseID,
+		InputItemsJSON:     string(inputJSON),
+		OutputItemsJSON:    string(outputJSON),
+		MessagesJSON:       string(messagesJSON),
+		ResponseJSON:       string(responseJSON),
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(a.cfg.ResponsesStoreTTL),
+	}
+	if size := record.SizeBytes(); size > a.cfg.ResponsesStoreMaxBytes {
+		return fmt.Errorf("%d bytes exceeds RESPONSES_STORE_MAX_BYTES=%d", size, a.cfg.ResponsesStoreMaxBytes)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return a.store.SaveResponse(ctx, record)
+}
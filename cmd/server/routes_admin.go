@@ -29,15 +29,44 @@ type adminClientResponse struct {
 }
 
 type adminConfigResponse struct {
-	AWS               store.AWSRuntimeConfig  `json:"aws"`
-	BedrockReady      bool                    `json:"bedrock_client_ready"`
-	AvailableModels   []string                `json:"available_models"`
-	EnabledModelIDs   []string                `json:"enabled_model_ids"`
-	ModelPricing      []store.ModelPricingRow `json:"model_pricing"`
-	PricingUnitTokens int                     `json:"pricing_unit_tokens"`
-	Billing           store.BillingConfig     `json:"billing"`
-	CurrentTotalCost  float64                 `json:"current_total_cost"`
-	Clients           []adminClientResponse   `json:"clients"`
+	AWS               store.AWSRuntimeConfig   `json:"aws"`
+	BedrockReady      bool                     `json:"bedrock_client_ready"`
+	AvailableModels   []string                 `json:"available_models"`
+	EnabledModelIDs   []string                 `json:"enabled_model_ids"`
+	AllowedFields     []string                 `json:"allowed_request_fields"`
+	PromptCache       []store.PromptCacheRow   `json:"prompt_cache"`
+	Guardrails        []store.GuardrailRow     `json:"guardrails"`
+	ModelAliases      []adminModelAliasRow     `json:"model_aliases"`
+	ModelFallbacks    []store.ModelFallbackRow `json:"model_fallbacks"`
+	Upstreams         []store.UpstreamRow      `json:"upstreams"`
+	UpstreamHealth    []adminUpstreamHealthRow `json:"upstream_health"`
+	ModelMetadata     []store.ModelMetadataRow `json:"model_metadata"`
+	ModelPricing      []store.ModelPricingRow  `json:"model_pricing"`
+	PricingUnitTokens int                      `json:"pricing_unit_tokens"`
+	Billing           store.BillingConfig      `json:"billing"`
+	CurrentTotalCost  float64                  `json:"current_total_cost"`
+	Clients           []adminClientResponse    `json:"clients"`
+}
+
+// adminModelAliasRow 是一个模型别名：客户端以 alias 请求时实际调用 bedrock_model_id。
+type adminModelAliasRow struct {
+	Alias          string `json:"alias"`
+	BedrockModelID string `json:"bedrock_model_id"`
+}
+
+// adminUpstreamHealthRow 是上游池中一个上游的健康状态；healthy=false 表示仍在限流 / 5xx 后的冷却期内。
+type adminUpstreamHealthRow struct {
+	Name                string `json:"name"`
+	Region              string `json:"region"`
+	Priority            int    `json:"priority"`
+	Weight              int    `json:"weight"`
+	Healthy             bool   `json:"healthy"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Requests            int64  `json:"requests"`
+	Failures            int64  `json:"failures"`
+	LastError           string `json:"last_error,omitempty"`
+	LastErrorAt         string `json:"last_error_at,omitempty"`
+	CooldownUntil       string `json:"cooldown_until,omitempty"`
 }
 
 type adminModelPricingPayload struct {
@@ -53,22 +82,32 @@ type adminTokenPayload struct {
 }
 
 type adminUsageClientRow struct {
-	ClientID     string  `json:"client_id"`
-	InputTokens  int64   `json:"input_tokens"`
-	OutputTokens int64   `json:"output_tokens"`
-	TotalTokens  int64   `json:"total_tokens"`
-	RequestCount int64   `json:"request_count"`
-	CostAmount   float64 `json:"cost_amount"`
+	ClientID              string  `json:"client_id"`
+	InputTokens           int64   `json:"input_tokens"`
+	OutputTokens          int64   `json:"output_tokens"`
+	TotalTokens           int64   `json:"total_tokens"`
+	CacheReadInputTokens  int64   `json:"cache_read_input_tokens"`
+	CacheWriteInputTokens int64   `json:"cache_write_input_tokens"`
+	RequestCount          int64   `json:"request_count"`
+	BatchRequestCount     int64   `json:"batch_request_count"`
+	BatchTotalTokens      int64   `json:"batch_total_tokens"`
+	ImageCount            int64   `json:"image_count"`
+	CostAmount            float64 `json:"cost_amount"`
 }
 
 type adminUsageByModelRow struct {
-	ClientID     string  `json:"client_id"`
-	Model        string  `json:"model"`
-	InputTokens  int64   `json:"input_tokens"`
-	OutputTokens int64   `json:"output_tokens"`
-	TotalTokens  int64   `json:"total_tokens"`
-	RequestCount int64   `json:"request_count"`
-	CostAmount   float64 `json:"cost_amount"`
+	ClientID              string  `json:"client_id"`
+	Model                 string  `json:"model"`
+	InputTokens           int64   `json:"input_tokens"`
+	OutputTokens          int64   `json:"output_tokens"`
+	TotalTokens           int64   `json:"total_tokens"`
+	CacheReadInputTokens  int64   `json:"cache_read_input_tokens"`
+	CacheWriteInputTokens int64   `json:"cache_write_input_tokens"`
+	RequestCount          int64   `json:"request_count"`
+	BatchRequestCount     int64   `json:"batch_request_count"`
+	BatchTotalTokens      int64   `json:"batch_total_tokens"`
+	ImageCount            int64   `json:"image_count"`
+	CostAmount            float64 `json:"cost_amount"`
 }
 
 type adminCallRow struct {
@@ -89,6 +128,13 @@ func registerAdminRoutes(mux *http.ServeMux, app *App) {
 	mux.HandleFunc(adminAPIPath("/config/models"), app.requireAdmin(app.handleAdminEnabledModels))
 	mux.HandleFunc(adminAPIPath("/config/models/refresh"), app.requireAdmin(app.handleAdminRefreshModels))
 	mux.HandleFunc(adminAPIPath("/config/model-pricing"), app.requireAdmin(app.handleAdminModelPricing))
+	mux.HandleFunc(adminAPIPath("/config/request-fields"), app.requireAdmin(app.handleAdminRequestFields))
+	mux.HandleFunc(adminAPIPath("/config/prompt-cache"), app.requireAdmin(app.handleAdminPromptCache))
+	mux.HandleFunc(adminAPIPath("/config/model-metadata"), app.requireAdmin(app.handleAdminModelMetadata))
+	mux.HandleFunc(adminAPIPath("/config/guardrails"), app.requireAdmin(app.handleAdminGuardrails))
+	mux.HandleFunc(adminAPIPath("/config/model-aliases"), app.requireAdmin(app.handleAdminModelAliases))
+	mux.HandleFunc(adminAPIPath("/config/model-fallbacks"), app.requireAdmin(app.handleAdminModelFallbacks))
+	mux.HandleFunc(adminAPIPath("/config/upstreams"), app.requireAdmin(app.handleAdminUpstreams))
 	mux.HandleFunc(adminAPIPath("/config/salessavvy-token"), app.requireAdmin(app.handleAdminTokenConfig))
 	mux.HandleFunc(adminAPIPath("/config/billing"), app.requireAdmin(app.handleAdminBillingConfig))
 	mux.HandleFunc(adminAPIPath("/config/clients"), app.requireAdmin(app.handleAdminClients))
@@ -265,6 +311,333 @@ func (a *App) handleAdminModelPricing(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"items": pricing})
 }
 
+// handleAdminRequestFields 维护允许透传到 Bedrock AdditionalModelRequestFields 的字段白名单（如 top_k）。
+func (a *App) handleAdminRequestFields(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		fields, err := a.store.ListAllowedRequestFields(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"allowed_request_fields": fields})
+	case http.MethodPost:
+		var payload adminRequestFieldsPayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplaceAllowedRequestFields(r.Context(), payload.AllowedRequestFields); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := a.reloadAllowedRequestFields(r.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		fields, err := a.store.ListAllowedRequestFields(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"allowed_request_fields": fields})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminModelAliases 维护模型别名（admin_model_mappings）：GET 列出，POST {alias, bedrock_model_id} 新增或改指向，
+// DELETE ?alias= 删除。修改立即生效，客户端可以用稳定的别名（如 claude-fast）而无需随模型升级修改配置。
+func (a *App) handleAdminModelAliases(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var payload adminModelAliasRow
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		// 先在内存别名表上校验（保留名、别名链），通过后再持久化
+		if err := a.proxy.UpsertModelMapping(payload.Alias, payload.BedrockModelID); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := a.store.UpsertModelMapping(r.Context(), payload.Alias, payload.BedrockModelID); err != nil {
+			_ = a.reloadModelAliases(r.Context())
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		alias := strings.TrimSpace(r.URL.Query().Get("alias"))
+		if alias == "" {
+			writeAdminError(w, http.StatusBadRequest, "alias is required")
+			return
+		}
+		if _, ok := a.proxy.ListModelMappings()[strings.ToLower(alias)]; !ok {
+			writeAdminError(w, http.StatusNotFound, "alias not found: "+alias)
+			return
+		}
+		if err := a.store.DeleteModelMapping(r.Context(), alias); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		a.proxy.DeleteModelMapping(alias)
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"items": a.listModelAliasRows()})
+}
+
+// handleAdminModelFallbacks 维护模型降级链：请求的模型遇到限流、模型未就绪或无权限错误时，
+// chat completions / responses 按顺序改用 fallback_models，响应的 model 字段与调用日志记录实际服务的模型。
+// fallback_models 可以写 Bedrock 模型 ID 或模型别名；未启用的模型在请求时被跳过。
+func (a *App) handleAdminModelFallbacks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := a.store.ListModelFallbacks(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var payload adminModelFallbacksPayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplaceModelFallbacks(r.Context(), payload.Items); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := a.reloadModelFallbacks(r.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		items, err := a.store.ListModelFallbacks(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminUpstreams 维护额外的 Bedrock 上游（账号 / 区域），保存后立即重建上游池；
+// 响应同时返回每个上游的健康状态（请求数、失败数、最近错误与冷却截止时间）。
+func (a *App) handleAdminUpstreams(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var payload adminUpstreamsPayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplaceUpstreams(r.Context(), payload.Items); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := a.reloadUpstreams(r.Context()); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	items, err := a.store.ListUpstreams(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"items":  items,
+		"health": a.listUpstreamHealthRows(items),
+	})
+}
+
+func (a *App) listUpstreamHealthRows(rows []store.UpstreamRow) []adminUpstreamHealthRow {
+	byName := make(map[string]store.UpstreamRow, len(rows)+1)
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+	if row, ok := byName[store.DefaultUpstreamName]; ok {
+		row.Region = a.getAWSConfig().Region
+		byName[store.DefaultUpstreamName] = row
+	} else {
+		byName[store.DefaultUpstreamName] = store.UpstreamRow{Region: a.getAWSConfig().Region, Weight: 1}
+	}
+
+	pool := a.getUpstreamPool()
+	if pool == nil {
+		return []adminUpstreamHealthRow{}
+	}
+	health := pool.Health()
+	out := make([]adminUpstreamHealthRow, 0, len(health))
+	for _, item := range health {
+		row := byName[item.Name]
+		out = append(out, adminUpstreamHealthRow{
+			Name:                item.Name,
+			Region:              row.Region,
+			Priority:            row.Priority,
+			Weight:              row.Weight,
+			Healthy:             item.Healthy,
+			ConsecutiveFailures: item.ConsecutiveFailures,
+			Requests:            item.Requests,
+			Failures:            item.Failures,
+			LastError:           item.LastError,
+			LastErrorAt:         formatOptionalTime(item.LastErrorAt),
+			CooldownUntil:       formatOptionalTime(item.CooldownUntil),
+		})
+	}
+	return out
+}
+
+func formatOptionalTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func (a *App) listModelAliasRows() []adminModelAliasRow {
+	mappings := a.proxy.ListModelMappings()
+	rows := make([]adminModelAliasRow, 0, len(mappings))
+	for alias, target := range mappings {
+		rows = append(rows, adminModelAliasRow{Alias: alias, BedrockModelID: target})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Alias < rows[j].Alias
+	})
+	return rows
+}
+
+// handleAdminPromptCache 按模型配置提示缓存：在 system、tools、最后一个 user 轮次之后插入 Bedrock cachePoint。
+// 只应为支持提示缓存的模型开启，否则 Bedrock 会拒绝请求。
+func (a *App) handleAdminPromptCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := a.store.ListPromptCacheModels(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var payload adminPromptCachePayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplacePromptCacheModels(r.Context(), payload.Items); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := a.reloadPromptCache(r.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		items, err := a.store.ListPromptCacheModels(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminGuardrails 为 API key（client_id）或模型（model_id）绑定 Bedrock Guardrail，
+// 作为 GuardrailConfig 传给 Converse / ConverseStream；同时命中时以 API key 的配置为准。
+// /v1/moderations 按同样的规则查找，model_id 为请求中的 moderation 模型名（默认 omni-moderation-latest）。
+func (a *App) handleAdminGuardrails(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := a.store.ListGuardrails(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var payload adminGuardrailsPayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplaceGuardrails(r.Context(), payload.Items); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := a.reloadGuardrails(r.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		items, err := a.store.ListGuardrails(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleAdminModelMetadata 维护 /v1/models 的模型元数据覆盖表：上下文窗口、最大输出、工具调用等
+// Bedrock 不返回的信息在这里配置，其余字段为空时沿用 ListFoundationModels 的结果。
+func (a *App) handleAdminModelMetadata(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := a.store.ListModelMetadataOverrides(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var payload adminModelMetadataPayload
+		if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &payload); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+
+		if err := a.store.ReplaceModelMetadataOverrides(r.Context(), payload.Items); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := a.reloadModelMetadata(r.Context()); err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		items, err := a.store.ListModelMetadataOverrides(r.Context())
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
 func (a *App) handleAdminTokenConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -425,29 +798,46 @@ func (a *App) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
 	costByClient := make(map[string]float64, len(byClient))
 	totalCost := 0.0
 	for _, row := range byModel {
-		cost := calculateCostByTokens(row.Model, row.InputTokens, row.OutputTokens, priceByModel)
+		cost := calculateCostByTokens(
+			row.Model,
+			row.InputTokens,
+			row.OutputTokens,
+			row.CacheReadInputTokens,
+			row.CacheWriteInputTokens,
+			priceByModel,
+		) + calculateCostByImages(row.Model, row.ImageCount, priceByModel)
 		costByClient[row.ClientID] += cost
 		totalCost += cost
 		usageByModel = append(usageByModel, adminUsageByModelRow{
-			ClientID:     row.ClientID,
-			Model:        row.Model,
-			InputTokens:  row.InputTokens,
-			OutputTokens: row.OutputTokens,
-			TotalTokens:  row.TotalTokens,
-			RequestCount: row.RequestCount,
-			CostAmount:   roundCost(cost),
+			ClientID:              row.ClientID,
+			Model:                 row.Model,
+			InputTokens:           row.InputTokens,
+			OutputTokens:          row.OutputTokens,
+			TotalTokens:           row.TotalTokens,
+			CacheReadInputTokens:  row.CacheReadInputTokens,
+			CacheWriteInputTokens: row.CacheWriteInputTokens,
+			RequestCount:          row.RequestCount,
+			BatchRequestCount:     row.BatchRequestCount,
+			BatchTotalTokens:      row.BatchTotalTokens,
+			ImageCount:            row.ImageCount,
+			CostAmount:            roundCost(cost),
 		})
 	}
 
 	usageByClient := make([]adminUsageClientRow, 0, len(byClient))
 	for _, row := range byClient {
 		usageByClient = append(usageByClient, adminUsageClientRow{
-			ClientID:     row.ClientID,
-			InputTokens:  row.InputTokens,
-			OutputTokens: row.OutputTokens,
-			TotalTokens:  row.TotalTokens,
-			RequestCount: row.RequestCount,
-			CostAmount:   roundCost(costByClient[row.ClientID]),
+			ClientID:              row.ClientID,
+			InputTokens:           row.InputTokens,
+			OutputTokens:          row.OutputTokens,
+			TotalTokens:           row.TotalTokens,
+			CacheReadInputTokens:  row.CacheReadInputTokens,
+			CacheWriteInputTokens: row.CacheWriteInputTokens,
+			RequestCount:          row.RequestCount,
+			BatchRequestCount:     row.BatchRequestCount,
+			BatchTotalTokens:      row.BatchTotalTokens,
+			ImageCount:            row.ImageCount,
+			CostAmount:            roundCost(costByClient[row.ClientID]),
 		})
 	}
 
@@ -506,7 +896,14 @@ func (a *App) handleAdminCalls(w http.ResponseWriter, r *http.Request) {
 		if costModelID == "" {
 			costModelID = strings.TrimSpace(row.Model)
 		}
-		cost := calculateCostByTokens(costModelID, int64(row.InputTokens), int64(row.OutputTokens), priceByModel)
+		cost := calculateCostByTokens(
+			costModelID,
+			int64(row.InputTokens),
+			int64(row.OutputTokens),
+			int64(row.CacheReadInputTokens),
+			int64(row.CacheWriteInputTokens),
+			priceByModel,
+		) + calculateCostByImages(costModelID, int64(row.ImageCount), priceByModel)
 		totalCost += cost
 		items = append(items, adminCallRow{
 			CallLogRow: row,
@@ -689,6 +1086,30 @@ func (a *App) buildAdminConfigResponse(ctx context.Context) (adminConfigResponse
 	if err != nil {
 		return adminConfigResponse{}, err
 	}
+	allowedFields, err := a.store.ListAllowedRequestFields(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
+	promptCache, err := a.store.ListPromptCacheModels(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
+	modelMetadata, err := a.store.ListModelMetadataOverrides(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
+	guardrails, err := a.store.ListGuardrails(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
+	upstreams, err := a.store.ListUpstreams(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
+	modelFallbacks, err := a.store.ListModelFallbacks(ctx)
+	if err != nil {
+		return adminConfigResponse{}, err
+	}
 
 	clientPayload := make([]adminClientResponse, 0, len(clients))
 	for _, client := range clients {
@@ -710,6 +1131,14 @@ func (a *App) buildAdminConfigResponse(ctx context.Context) (adminConfigResponse
 		BedrockReady:      a.proxy.HasClient(),
 		AvailableModels:   a.listAvailableModels(),
 		EnabledModelIDs:   a.listEnabledModels(),
+		AllowedFields:     allowedFields,
+		PromptCache:       promptCache,
+		ModelMetadata:     modelMetadata,
+		Guardrails:        guardrails,
+		ModelAliases:      a.listModelAliasRows(),
+		ModelFallbacks:    modelFallbacks,
+		Upstreams:         upstreams,
+		UpstreamHealth:    a.listUpstreamHealthRows(upstreams),
 		ModelPricing:      modelPricing,
 		PricingUnitTokens: 1000,
 		Billing:           billingCfg,
@@ -740,22 +1169,39 @@ func buildModelPricingMap(pricing []store.ModelPricingRow) map[string]store.Mode
 	return out
 }
 
-func calculateCostByTokens(modelID string, inputTokens, outputTokens int64, priceByModel map[string]store.ModelPricingRow) float64 {
+func calculateCostByTokens(
+	modelID string,
+	inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens int64,
+	priceByModel map[string]store.ModelPricingRow,
+) float64 {
 	pricing, ok := priceByModel[strings.TrimSpace(modelID)]
 	if !ok {
 		return 0
 	}
-	if inputTokens < 0 {
-		inputTokens = 0
-	}
-	if outputTokens < 0 {
-		outputTokens = 0
-	}
+	return costForTokens(pricing, inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens)
+}
 
+// costForTokens 按每 1K token 单价计算费用，负数 token 视为 0。
+func costForTokens(pricing store.ModelPricingRow, inputTokens, outputTokens, cacheReadTokens, cacheWriteTokens int64) float64 {
 	const tokenBase = 1_000.0
-	inputCost := (float64(inputTokens) / tokenBase) * pricing.InputPricePer1K
-	outputCost := (float64(outputTokens) / tokenBase) * pricing.OutputPricePer1K
-	return inputCost + outputCost
+	inputCost := (float64(max(inputTokens, 0)) / tokenBase) * pricing.InputPricePer1K
+	outputCost := (float64(max(outputTokens, 0)) / tokenBase) * pricing.OutputPricePer1K
+	cacheReadCost := (float64(max(cacheReadTokens, 0)) / tokenBase) * pricing.CacheReadPricePer1K
+	cacheWriteCost := (float64(max(cacheWriteTokens, 0)) / tokenBase) * pricing.CacheWritePricePer1K
+	return inputCost + outputCost + cacheReadCost + cacheWriteCost
+}
+
+func calculateCostByImages(modelID string, images int64, priceByModel map[string]store.ModelPricingRow) float64 {
+	pricing, ok := priceByModel[strings.TrimSpace(modelID)]
+	if !ok {
+		return 0
+	}
+	return costForImages(pricing, images)
+}
+
+// costForImages 按单张图片价格计算图片生成费用，负数视为 0。
+func costForImages(pricing store.ModelPricingRow, images int64) float64 {
+	return float64(max(images, 0)) * pricing.PricePerImage
 }
 
 func candidateModelPricingKeys(modelID string) []string {
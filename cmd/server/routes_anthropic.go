@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/anthropic"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// handleAnthropicMessages 实现 Anthropic Messages API（POST /v1/messages），
+// 请求转换为 OpenAI Chat 格式后复用 bedrockproxy.Service 与 /v1/chat/completions 相同的鉴权、限额与调用日志流程。
+func (a *App) handleAnthropicMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAnthropicError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeAnthropicError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeAnthropicError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeAnthropicError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeAnthropicError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeAnthropicError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	var request anthropic.MessagesRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := anthropic.ValidateMessagesRequest(request); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chatRequest, err := anthropic.MessagesRequestToChat(request)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(chatRequest.Model)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+	logModel := resolvedModel
+	if logModel == "default" {
+		logModel = bedrockModelID
+	}
+
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          logModel,
+		BedrockModelID: bedrockModelID,
+		RequestContent: openai.RenderRequestForLog(chatRequest, a.cfg.MaxContentChars),
+		IsStream:       chatRequest.Stream,
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	inputTokens := 0
+	outputTokens := 0
+	totalTokens := 0
+	cacheReadTokens := 0
+	cacheWriteTokens := 0
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		record.InputTokens = inputTokens
+		record.OutputTokens = outputTokens
+		record.TotalTokens = totalTokens
+		record.CacheReadInputTokens = cacheReadTokens
+		record.CacheWriteInputTokens = cacheWriteTokens
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+			return
+		}
+		a.addCostFromUsage(
+			record.BedrockModelID,
+			int64(record.InputTokens),
+			int64(record.OutputTokens),
+			int64(record.CacheReadInputTokens),
+			int64(record.CacheWriteInputTokens),
+		)
+	}()
+
+	if !a.isModelEnabled(bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not enabled by admin"
+		writeAnthropicError(w, statusCode, errorMessage)
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not allowed for this api key"
+		writeAnthropicError(w, statusCode, errorMessage)
+		return
+	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	chatRequest.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+	if chatRequest.Guardrail != nil {
+		record.GuardrailID = chatRequest.Guardrail.Identifier
+	}
+
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+
+	if chatRequest.Stream {
+		result, streamStatus, streamErr := a.handleAnthropicMessagesStream(
+			w,
+			chatRequest,
+			requestID,
+			modelName,
+			bedrockModelID,
+		)
+		statusCode = streamStatus
+		errorMessage = streamErr
+		inputTokens = result.InputTokens
+		outputTokens = result.OutputTokens
+		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		record.Attempts = result.Attempts
+		latencyMs = result.LatencyMs
+		responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+		if latencyMs == 0 {
+			latencyMs = time.Since(startedAt).Milliseconds()
+		}
+		return
+	}
+
+	result, err := a.proxy.Converse(ctx, chatRequest, bedrockModelID)
+	record.Attempts = result.Attempts
+	// 出错时（如结构化输出校验失败）Bedrock 可能已计费，照样记录 usage
+	inputTokens = result.InputTokens
+	outputTokens = result.OutputTokens
+	totalTokens = result.TotalTokens
+	cacheReadTokens = result.CacheReadInputTokens
+	cacheWriteTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeAnthropicError(w, statusCode, clientMessage)
+		return
+	}
+
+	stopReason, stopSequence := anthropic.StopDetails(result.FinishReason, result.StopReason, request.StopSequences)
+	response := anthropic.MessagesResponse{
+		ID:           "msg_" + requestID,
+		Type:         "message",
+		Role:         "assistant",
+		Model:        modelName,
+		Content:      anthropic.BuildContentBlocks(result.Text, result.ToolCalls),
+		StopReason:   stopReason,
+		StopSequence: stopSequence,
+		Usage: anthropic.Usage{
+			InputTokens:              result.InputTokens,
+			OutputTokens:             result.OutputTokens,
+			CacheCreationInputTokens: result.CacheWriteInputTokens,
+			CacheReadInputTokens:     result.CacheReadInputTokens,
+		},
+	}
+
+	responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+	latencyMs = result.LatencyMs
+	if latencyMs == 0 {
+		latencyMs = time.Since(startedAt).Milliseconds()
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleAnthropicMessagesStream 按 Anthropic SSE 事件序列输出：
+// message_start → content_block_start/delta/stop（文本、tool_use）→ message_delta → message_stop。
+//
+// 文本按 delta 实时转发；tool_use 块在 Bedrock 流结束后一次性输出完整 input，
+// 这样无论 BUFFER_TOOL_CALL_ARGS 是否开启、参数是否经过兼容修正，content block 都保持严格的顺序。
+func (a *App) handleAnthropicMessagesStream(
+	w http.ResponseWriter,
+	chatRequest openai.ChatCompletionRequest,
+	requestID string,
+	modelName string,
+	bedrockModelID string,
+) (bedrockproxy.ChatResult, int, string) {
+	messageID := "msg_" + requestID
+	started := false
+	nextBlockIndex := 0
+	textBlockIndex := -1
+	var responseText strings.Builder
+
+	// message_start 延迟到第一个 delta 才发送：Bedrock 在建立流之前报错时仍可返回普通 JSON 错误和真实状态码。
+	startMessage := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		setSSEHeaders(w)
+		if err := writeSSEEvent(w, "message_start", map[string]any{
+			"type": "message_start",
+			"message": map[string]any{
+				"id":            messageID,
+				"type":          "message",
+				"role":          "assistant",
+				"model":         modelName,
+				"content":       []any{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage": map[string]any{
+					"input_tokens":  0,
+					"output_tokens": 0,
+				},
+			},
+		}); err != nil {
+			return err
+		}
+		return writeSSEEvent(w, "ping", map[string]any{"type": "ping"})
+	}
+
+	closeTextBlock := func() error {
+		if textBlockIndex < 0 {
+			return nil
+		}
+		index := textBlockIndex
+		textBlockIndex = -1
+		return writeSSEEvent(w, "content_block_stop", map[string]any{
+			"type":  "content_block_stop",
+			"index": index,
+		})
+	}
+
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
+	defer streamCancel()
+
+	result, err := a.proxy.ConverseStream(streamCtx, chatRequest, bedrockModelID, func(delta bedrockproxy.StreamDelta) error {
+		if err := startMessage(); err != nil {
+			return err
+		}
+		if delta.Text == "" {
+			return nil
+		}
+		if textBlockIndex < 0 {
+			textBlockIndex = nextBlockIndex
+			nextBlockIndex++
+			if err := writeSSEEvent(w, "content_block_start", map[string]any{
+				"type":  "content_block_start",
+				"index": textBlockIndex,
+				"content_block": map[string]any{
+					"type": "text",
+					"text": "",
+				},
+			}); err != nil {
+				return err
+			}
+		}
+		responseText.WriteString(delta.Text)
+		return writeSSEEvent(w, "content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": textBlockIndex,
+			"delta": map[string]any{
+				"type": "text_delta",
+				"text": delta.Text,
+			},
+		})
+	})
+	if err != nil {
+		statusCode := http.StatusBadGateway
+		errorMessage := "bedrock stream failed: " + err.Error()
+		if bedrockproxy.IsRequestError(err) {
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+		} else if bedrockproxy.IsStructuredOutputError(err) {
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+		}
+		if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
+			errorMessage += " (请求被取消：请检查客户端/代理是否过早断开，或调大环境变量 REQUEST_TIMEOUT_SECONDS)"
+		}
+		if !started {
+			writeAnthropicError(w, statusCode, errorMessage)
+		} else {
+			_ = writeSSEEvent(w, "error", anthropic.ErrorResponse{
+				Type: "error",
+				Error: anthropic.ErrorPayload{
+					Type:    "api_error",
+					Message: errorMessage,
+				},
+			})
+		}
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
+	}
+
+	result.Text = responseText.String()
+	if err := startMessage(); err != nil {
+		return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+	}
+	if err := closeTextBlock(); err != nil {
+		return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+	}
+	stopSequences, _ := openai.ParseStopSequences(chatRequest.Stop)
+	if err := writeAnthropicStreamTail(w, result, nextBlockIndex, stopSequences); err != nil {
+		return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+	}
+	return result, http.StatusOK, ""
+}
+
+// writeAnthropicStreamTail 输出 tool_use 块以及 message_delta / message_stop 收尾事件。
+func writeAnthropicStreamTail(w http.ResponseWriter, result bedrockproxy.ChatResult, nextBlockIndex int, stopSequences []string) error {
+	for _, toolCall := range result.ToolCalls {
+		index := nextBlockIndex
+		nextBlockIndex++
+		if err := writeSSEEvent(w, "content_block_start", map[string]any{
+			"type":  "content_block_start",
+			"index": index,
+			"content_block": map[string]any{
+				"type":  "tool_use",
+				"id":    toolCall.ID,
+				"name":  toolCall.Function.Name,
+				"input": map[string]any{},
+			},
+		}); err != nil {
+			return err
+		}
+		if err := writeSSEEvent(w, "content_block_delta", map[string]any{
+			"type":  "content_block_delta",
+			"index": index,
+			"delta": map[string]any{
+				"type":         "input_json_delta",
+				"partial_json": string(anthropic.ToolInputJSON(toolCall.Function.Arguments)),
+			},
+		}); err != nil {
+			return err
+		}
+		if err := writeSSEEvent(w, "content_block_stop", map[string]any{
+			"type":  "content_block_stop",
+			"index": index,
+		}); err != nil {
+			return err
+		}
+	}
+
+	stopReason, stopSequence := anthropic.StopDetails(result.FinishReason, result.StopReason, stopSequences)
+	if err := writeSSEEvent(w, "message_delta", map[string]any{
+		"type": "message_delta",
+		"delta": map[string]any{
+			"stop_reason":   stopReason,
+			"stop_sequence": stopSequence,
+		},
+		"usage": map[string]any{
+			"input_tokens":                result.InputTokens,
+			"output_tokens":               result.OutputTokens,
+			"cache_creation_input_tokens": result.CacheWriteInputTokens,
+			"cache_read_input_tokens":     result.CacheReadInputTokens,
+		},
+	}); err != nil {
+		return err
+	}
+	return writeSSEEvent(w, "message_stop", map[string]any{"type": "message_stop"})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+	"github.com/google/uuid"
+)
+
+const (
+	batchesPathPrefix   = "/v1/batches/"
+	defaultBatchesLimit = 20
+	maxBatchesLimit     = 100
+	batchCompletionTime = 24 * time.Hour
+)
+
+// handleBatches 处理 POST /v1/batches（从已上传的 JSONL 文件创建任务）与 GET /v1/batches（按创建时间倒序分页列出）。
+// 任务由进程内的 batchRunner 以较低并发逐行调用 Bedrock，结果写入输出文件，用量计入常规用量表并标记为 batch。
+func (a *App) handleBatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		limit := parseLimit(query.Get("limit"), defaultBatchesLimit, maxBatchesLimit)
+		records, err := a.store.ListBatches(r.Context(), client.ID, limit+1, query.Get("after"))
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		list := openai.BatchList{Object: "list", Data: make([]openai.BatchObject, 0, len(records))}
+		if len(records) > limit {
+			records = records[:limit]
+			list.HasMore = true
+		}
+		for _, record := range records {
+			list.Data = append(list.Data, batchObject(record))
+		}
+		if len(records) > 0 {
+			firstID, lastID := records[0].BatchID, records[len(records)-1].BatchID
+			list.FirstID = &firstID
+			list.LastID = &lastID
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+
+	var request openai.BatchCreateRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := openai.ValidateBatchCreateRequest(request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, ok, err := a.store.GetFile(r.Context(), client.ID, request.InputFileID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("file not found: %s", request.InputFileID))
+		return
+	}
+	if file.Purpose != "batch" {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("file %s was not uploaded with purpose=batch", file.FileID))
+		return
+	}
+	content, _, err := a.store.GetFileContent(r.Context(), client.ID, file.FileID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	lines, err := openai.ParseBatchInput(content, request.Endpoint)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid batch input file: "+err.Error())
+		return
+	}
+
+	metadataJSON := []byte("{}")
+	if len(request.Metadata) > 0 {
+		metadataJSON, _ = json.Marshal(request.Metadata)
+	}
+	now := time.Now().UTC()
+	record := store.BatchRecord{
+		BatchID:          "batch_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		ClientID:         client.ID,
+		Endpoint:         request.Endpoint,
+		InputFileID:      file.FileID,
+		CompletionWindow: request.CompletionWindow,
+		Status:           "validating",
+		MetadataJSON:     string(metadataJSON),
+		RequestTotal:     len(lines),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(batchCompletionTime),
+	}
+	items := make([]store.BatchItem, 0, len(lines))
+	for index, line := range lines {
+		items = append(items, store.BatchItem{
+			LineIndex: index,
+			CustomID:  line.CustomID,
+			BodyJSON:  string(line.Body),
+		})
+	}
+	if err := a.store.CreateBatch(r.Context(), record, items); err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.batches.notify()
+
+	writeJSON(w, http.StatusOK, batchObject(record))
+}
+
+// handleBatchByID 处理 GET /v1/batches/{id} 与 POST /v1/batches/{id}/cancel，只能访问本 API key 的任务。
+// 取消时任务先进入 cancelling，执行器中止进行中的调用并把已完成的行写入输出文件后变为 cancelled。
+func (a *App) handleBatchByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, batchesPathPrefix), "/")
+	batchID, action, _ := strings.Cut(rest, "/")
+	if batchID == "" || (action != "" && action != "cancel") {
+		writeOpenAIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if (action == "" && r.Method != http.MethodGet) || (action == "cancel" && r.Method != http.MethodPost) {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	record, ok, err := a.store.GetBatch(r.Context(), client.ID, batchID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("batch not found: %s", batchID))
+		return
+	}
+
+	if action == "cancel" {
+		cancelled, err := a.store.TransitionBatch(r.Context(), record.BatchID, []string{"validating", "in_progress"}, "cancelling", time.Now().UTC())
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if cancelled {
+			a.batches.cancel(record.BatchID)
+			a.batches.notify()
+			record, _, err = a.store.GetBatch(r.Context(), client.ID, batchID)
+			if err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		// 已结束的任务不做修改，按 OpenAI 行为返回当前对象
+	}
+
+	writeJSON(w, http.StatusOK, batchObject(record))
+}
+
+func batchObject(record store.BatchRecord) openai.BatchObject {
+	metadata := map[string]string{}
+	_ = json.Unmarshal([]byte(record.MetadataJSON), &metadata)
+	return openai.BatchObject{
+		ID:               record.BatchID,
+		Object:           "batch",
+		Endpoint:         record.Endpoint,
+		InputFileID:      record.InputFileID,
+		CompletionWindow: record.CompletionWindow,
+		Status:           record.Status,
+		OutputFileID:     optionalString(record.OutputFileID),
+		ErrorFileID:      optionalString(record.ErrorFileID),
+		CreatedAt:        record.CreatedAt.Unix(),
+		InProgressAt:     optionalUnix(record.InProgressAt),
+		ExpiresAt:        optionalUnix(record.ExpiresAt),
+		FinalizingAt:     optionalUnix(record.FinalizingAt),
+		CompletedAt:      optionalUnix(record.CompletedAt),
+		FailedAt:         optionalUnix(record.FailedAt),
+		ExpiredAt:        optionalUnix(record.ExpiredAt),
+		CancellingAt:     optionalUnix(record.CancellingAt),
+		CancelledAt:      optionalUnix(record.CancelledAt),
+		RequestCounts: openai.BatchRequestCount{
+			Total:     record.RequestTotal,
+			Completed: record.RequestCompleted,
+			Failed:    record.RequestFailed,
+		},
+		Metadata: metadata,
+	}
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func optionalUnix(value time.Time) *int64 {
+	if value.IsZero() {
+		return nil
+	}
+	unix := value.Unix()
+	return &unix
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/config"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestBatchLifecycle(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	manager := auth.NewManager(config.Config{})
+	if err := manager.UpsertClient(config.ClientConfig{
+		ID: "team-a", Name: "Team A", APIKey: "key-a", MaxRequestsPerMinute: 100, MaxConcurrent: 4,
+	}); err != nil {
+		t.Fatalf("upsert client failed: %v", err)
+	}
+	pong := bedrocktest.TextOutput("pong")
+	pong.Usage = &brtypes.TokenUsage{
+		InputTokens:  awssdk.Int32(3),
+		OutputTokens: awssdk.Int32(1),
+		TotalTokens:  awssdk.Int32(4),
+	}
+	converse := &bedrocktest.Client{Output: pong}
+	app := &App{
+		cfg: config.Config{
+			RequestTimeout:   time.Minute,
+			MaxContentChars:  1000,
+			BatchConcurrency: 2,
+			FilesMaxBytes:    1 << 20,
+		},
+		auth:    manager,
+		store:   s,
+		logger:  log.New(io.Discard, "", 0),
+		proxy:   bedrockproxy.NewService(converse, "anthropic.claude-test-v1:0", nil, 1024, 1024, false, false),
+		batches: newBatchRunner(),
+	}
+
+	input := `{"custom_id":"ok","method":"POST","url":"/v1/chat/completions","body":{"model":"anthropic.claude-test-v1:0","messages":[{"role":"user","content":"ping"}]}}
+{"custom_id":"bad","method":"POST","url":"/v1/chat/completions","body":{"model":"anthropic.claude-test-v1:0","stream":true,"messages":[{"role":"user","content":"ping"}]}}
+`
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("purpose", "batch")
+	part, _ := form.CreateFormFile("file", "requests.jsonl")
+	_, _ = part.Write([]byte(input))
+	_ = form.Close()
+	request := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder := httptest.NewRecorder()
+	app.handleFiles(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("upload failed: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var file openai.FileObject
+	_ = json.Unmarshal(recorder.Body.Bytes(), &file)
+	if file.Purpose != "batch" || file.Bytes != int64(len(input)) || file.Filename != "requests.jsonl" {
+		t.Fatalf("unexpected file object: %+v", file)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(
+		`{"input_file_id":"`+file.ID+`","endpoint":"/v1/chat/completions","completion_window":"24h","metadata":{"job":"nightly"}}`))
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder = httptest.NewRecorder()
+	app.handleBatches(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("create batch failed: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var batch openai.BatchObject
+	_ = json.Unmarshal(recorder.Body.Bytes(), &batch)
+	if batch.Status != "validating" || batch.RequestCounts.Total != 2 || batch.Metadata["job"] != "nightly" {
+		t.Fatalf("unexpected created batch: %+v", batch)
+	}
+
+	app.runPendingBatches()
+
+	request = httptest.NewRequest(http.MethodGet, "/v1/batches/"+batch.ID, nil)
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder = httptest.NewRecorder()
+	app.handleBatchByID(recorder, request)
+	_ = json.Unmarshal(recorder.Body.Bytes(), &batch)
+	if batch.Status != "completed" || batch.RequestCounts.Completed != 1 || batch.RequestCounts.Failed != 1 ||
+		batch.OutputFileID == nil || batch.ErrorFileID == nil || batch.CompletedAt == nil {
+		t.Fatalf("unexpected finished batch: %s", recorder.Body.String())
+	}
+
+	readFile := func(fileID string) openai.BatchOutputLine {
+		request := httptest.NewRequest(http.MethodGet, "/v1/files/"+fileID+"/content", nil)
+		request.Header.Set("Authorization", "Bearer key-a")
+		recorder := httptest.NewRecorder()
+		app.handleFileByID(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("read file failed: %d %s", recorder.Code, recorder.Body.String())
+		}
+		var line openai.BatchOutputLine
+		if err := json.Unmarshal(bytes.TrimSpace(recorder.Body.Bytes()), &line); err != nil {
+			t.Fatalf("decode output line failed: %v (%s)", err, recorder.Body.String())
+		}
+		return line
+	}
+	output := readFile(*batch.OutputFileID)
+	responseBody, _ := json.Marshal(output.Response.Body)
+	if output.CustomID != "ok" || output.Response.StatusCode != http.StatusOK || !strings.Contains(string(responseBody), `"content":"pong"`) {
+		t.Fatalf("unexpected output line: %+v %s", output, responseBody)
+	}
+	errorLine := readFile(*batch.ErrorFileID)
+	if errorLine.CustomID != "bad" || errorLine.Response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error line: %+v", errorLine)
+	}
+
+	// 已结束的任务取消时不做修改
+	request = httptest.NewRequest(http.MethodPost, "/v1/batches/"+batch.ID+"/cancel", nil)
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder = httptest.NewRecorder()
+	app.handleBatchByID(recorder, request)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"completed"`) {
+		t.Fatalf("unexpected cancel response: %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"aws-cursor-router/internal/anthropic"
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/openai"
+)
+
+// tokenCount 是两个 count_tokens 接口共用的计数结果。
+type tokenCount struct {
+	Model         string
+	InputTokens   int
+	ContextWindow *int
+	Estimated     bool
+}
+
+// handleChatCountTokens 实现 POST /v1/chat/completions/count_tokens：请求体与 /v1/chat/completions 相同，
+// 按完整的 Bedrock 转换统计输入 token 数，并返回模型的上下文窗口供客户端判断是否需要压缩历史。
+// 计数不调用模型、不计费，也不写调用日志，但仍校验 API key、速率与模型白名单。
+func (a *App) handleChatCountTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	var request openai.ChatCompletionRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := openai.ValidateChatRequest(request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, statusCode, err := a.countTokens(r.Context(), client, request)
+	if err != nil {
+		writeOpenAIError(w, statusCode, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, openai.TokenCountResponse{
+		Object:        "token_count",
+		Model:         count.Model,
+		InputTokens:   count.InputTokens,
+		ContextWindow: count.ContextWindow,
+		Estimated:     count.Estimated,
+	})
+}
+
+// handleAnthropicCountTokens 实现 Anthropic 的 POST /v1/messages/count_tokens（max_tokens 可省略）。
+func (a *App) handleAnthropicCountTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAnthropicError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeAnthropicError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeAnthropicError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeAnthropicError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	var request anthropic.MessagesRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := anthropic.ValidateCountTokensRequest(request); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatRequest, err := anthropic.MessagesRequestToChat(request)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count, statusCode, err := a.countTokens(r.Context(), client, chatRequest)
+	if err != nil {
+		writeAnthropicError(w, statusCode, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, anthropic.CountTokensResponse{
+		InputTokens:   count.InputTokens,
+		ContextWindow: count.ContextWindow,
+		Estimated:     count.Estimated,
+	})
+}
+
+// countTokens 解析模型并检查管理员启用与 API key 白名单后计数；出错时返回应使用的 HTTP 状态码。
+func (a *App) countTokens(ctx context.Context, client *auth.Client, request openai.ChatCompletionRequest) (tokenCount, int, error) {
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(request.Model)
+	if err != nil {
+		return tokenCount{}, http.StatusBadRequest, err
+	}
+	if !a.isModelEnabled(bedrockModelID) {
+		return tokenCount{}, http.StatusForbidden, errors.New("model is not enabled by admin")
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		return tokenCount{}, http.StatusForbidden, errors.New("model is not allowed for this api key")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.RequestTimeout)
+	defer cancel()
+
+	result, err := a.proxy.CountTokens(ctx, request, bedrockModelID)
+	if err != nil {
+		statusCode, message := describeBedrockError(err)
+		return tokenCount{}, statusCode, errors.New(message)
+	}
+
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+	return tokenCount{
+		Model:         modelName,
+		InputTokens:   result.InputTokens,
+		ContextWindow: a.modelContextWindow(bedrockModelID),
+		Estimated:     result.Estimated,
+	}, http.StatusOK, nil
+}
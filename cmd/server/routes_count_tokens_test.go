@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/config"
+	"aws-cursor-router/internal/store"
+)
+
+func TestAnthropicCountTokensReturnsEstimateAndContextWindow(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	contextWindow := 200000
+	if err := s.ReplaceModelMetadataOverrides(context.Background(), []store.ModelMetadataRow{
+		{ModelID: "anthropic.claude-test-v1:0", ContextWindow: &contextWindow},
+	}); err != nil {
+		t.Fatalf("replace overrides failed: %v", err)
+	}
+
+	manager := auth.NewManager(config.Config{})
+	if err := manager.UpsertClient(config.ClientConfig{
+		ID: "team-a", Name: "Team A", APIKey: "key-a", MaxRequestsPerMinute: 100, MaxConcurrent: 4,
+		AllowedModels: []string{"us.anthropic.claude-test-v1:0"},
+	}); err != nil {
+		t.Fatalf("upsert client failed: %v", err)
+	}
+	app := &App{
+		cfg:   config.Config{RequestTimeout: time.Minute},
+		auth:  manager,
+		store: s,
+		// bedrocktest.Client 不实现 CountTokens，计数走本地估算
+		proxy: bedrockproxy.NewService(&bedrocktest.Client{}, "", nil, 0, 0, false, false),
+	}
+	if err := app.reloadModelMetadata(context.Background()); err != nil {
+		t.Fatalf("reload model metadata failed: %v", err)
+	}
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(body))
+		request.Header.Set("x-api-key", "key-a")
+		recorder := httptest.NewRecorder()
+		app.handleAnthropicCountTokens(recorder, request)
+		return recorder
+	}
+
+	recorder := serve(`{"model":"us.anthropic.claude-test-v1:0","system":"be brief","messages":[{"role":"user","content":"hello there"}]}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		InputTokens   int  `json:"input_tokens"`
+		ContextWindow *int `json:"context_window"`
+		Estimated     bool `json:"estimated"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if response.InputTokens <= 0 || !response.Estimated || response.ContextWindow == nil || *response.ContextWindow != contextWindow {
+		t.Fatalf("unexpected count tokens response: %s", recorder.Body.String())
+	}
+
+	recorder = serve(`{"model":"anthropic.other-model","messages":[{"role":"user","content":"hello"}]}`)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected model allowlist to apply, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// handleEmbeddings 实现 OpenAI 兼容的 /v1/embeddings：通过 InvokeModel 调用 Titan / Cohere 嵌入模型。
+// 鉴权、模型白名单、用量与计费与聊天接口共用同一套逻辑，输出 token 恒为 0。
+func (a *App) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasInvokeClient() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	var request openai.EmbeddingsRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := openai.ValidateEmbeddingsRequest(request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(request.Model)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !bedrockproxy.IsEmbeddingModel(bedrockModelID) {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("model %s is not a supported embedding model", request.Model))
+		return
+	}
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+
+	inputs, _ := openai.ParseEmbeddingInput(request.Input)
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          modelName,
+		BedrockModelID: bedrockModelID,
+		RequestContent: truncateRunes(renderEmbeddingInputsForLog(inputs), a.cfg.MaxContentChars),
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	inputTokens := 0
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		record.InputTokens = inputTokens
+		record.TotalTokens = inputTokens
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+			return
+		}
+		a.addCostFromUsage(record.BedrockModelID, int64(record.InputTokens), 0, 0, 0)
+	}()
+
+	if !a.isModelEnabled(bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not enabled by admin"
+		writeOpenAIError(w, statusCode, errorMessage)
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not allowed for this api key"
+		writeOpenAIError(w, statusCode, errorMessage)
+		return
+	}
+
+	result, err := a.proxy.Embed(ctx, request, bedrockModelID)
+	record.Attempts = result.Attempts
+	inputTokens = result.InputTokens
+	latencyMs = result.LatencyMs
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeOpenAIError(w, statusCode, clientMessage)
+		return
+	}
+
+	data := make([]openai.EmbeddingData, 0, len(result.Embeddings))
+	for index, embedding := range result.Embeddings {
+		data = append(data, openai.EmbeddingData{
+			Object:    "embedding",
+			Index:     index,
+			Embedding: openai.EncodeEmbedding(embedding, request.EncodingFormat),
+		})
+	}
+	dimensions := 0
+	if len(result.Embeddings) > 0 {
+		dimensions = len(result.Embeddings[0])
+	}
+	responseContent = fmt.Sprintf("[%d embeddings x %d dims]", len(result.Embeddings), dimensions)
+
+	writeJSON(w, http.StatusOK, openai.EmbeddingsResponse{
+		Object: "list",
+		Data:   data,
+		Model:  modelName,
+		Usage: openai.EmbeddingsUsage{
+			PromptTokens: inputTokens,
+			TotalTokens:  inputTokens,
+		},
+	})
+}
+
+func renderEmbeddingInputsForLog(inputs []string) string {
+	if len(inputs) == 1 {
+		return inputs[0]
+	}
+	parts := make([]string, 0, len(inputs))
+	for index, input := range inputs {
+		parts = append(parts, fmt.Sprintf("[input %d]\n%s", index, input))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+type fakeEmbeddingClient struct {
+	bedrocktest.Client
+}
+
+func (f *fakeEmbeddingClient) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	return &bedrockruntime.InvokeModelOutput{
+		Body:        []byte(`{"embedding":[0.1,0.2],"inputTextTokenCount":1}`),
+		ContentType: aws.String("application/json"),
+	}, nil
+}
+
+func TestEmbeddingsDefaultModelReportsBedrockModel(t *testing.T) {
+	app := newResponsesTestApp(t)
+	app.cfg.RequestTimeout = time.Minute
+	app.proxy = bedrockproxy.NewService(&fakeEmbeddingClient{}, "amazon.titan-embed-text-v2:0", nil, 1024, 1024, false, false)
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"default","input":"hello"}`))
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder := httptest.NewRecorder()
+	app.handleEmbeddings(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("embeddings failed: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response openai.EmbeddingsResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if response.Model != "amazon.titan-embed-text-v2:0" || len(response.Data) != 1 {
+		t.Fatalf("expected the default model to be reported as its bedrock model id: %s", recorder.Body.String())
+	}
+}
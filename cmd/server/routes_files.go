@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+const (
+	filesPathPrefix      = "/v1/files/"
+	defaultFilesLimit    = 100
+	maxFilesLimit        = 10000
+	multipartMemoryBytes = 32 << 20
+)
+
+// handleFiles 处理 POST /v1/files（multipart 上传，目前只接受 purpose=batch 的 JSONL）与 GET /v1/files（列出本 API key 的文件）。
+func (a *App) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		records, err := a.store.ListFiles(r.Context(), client.ID, strings.TrimSpace(query.Get("purpose")), parseLimit(query.Get("limit"), defaultFilesLimit, maxFilesLimit))
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		files := make([]openai.FileObject, 0, len(records))
+		for _, record := range records {
+			files = append(files, fileObject(record))
+		}
+		writeJSON(w, http.StatusOK, openai.FileList{Object: "list", Data: files})
+		return
+	}
+
+	// 为 multipart 边界与其他表单字段预留 1 MiB
+	r.Body = http.MaxBytesReader(w, r.Body, a.cfg.FilesMaxBytes+1<<20)
+	if err := r.ParseMultipartForm(multipartMemoryBytes); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds FILES_MAX_BYTES (%d bytes)", a.cfg.FilesMaxBytes))
+			return
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "invalid multipart body: "+err.Error())
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	purpose := strings.TrimSpace(r.FormValue("purpose"))
+	if purpose != "batch" {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("unsupported purpose: %q (only batch is supported)", purpose))
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, a.cfg.FilesMaxBytes+1))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "read file failed: "+err.Error())
+		return
+	}
+	if int64(len(content)) > a.cfg.FilesMaxBytes {
+		writeOpenAIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds FILES_MAX_BYTES (%d bytes)", a.cfg.FilesMaxBytes))
+		return
+	}
+
+	record := store.FileRecord{
+		FileID:    newFileID(),
+		ClientID:  client.ID,
+		Purpose:   purpose,
+		Filename:  header.Filename,
+		Bytes:     int64(len(content)),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.store.SaveFile(r.Context(), record, content); err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, fileObject(record))
+}
+
+// handleFileByID 处理 GET / DELETE /v1/files/{id} 与 GET /v1/files/{id}/content，只能访问本 API key 的文件
+// （包括 batch 生成的输出文件与错误文件）。
+func (a *App) handleFileByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, filesPathPrefix), "/")
+	fileID, action, _ := strings.Cut(rest, "/")
+	if fileID == "" || (action != "" && action != "content") {
+		writeOpenAIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet && (action != "" || r.Method != http.MethodDelete) {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		deleted, err := a.store.DeleteFile(r.Context(), client.ID, fileID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !deleted {
+			writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("file not found: %s", fileID))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      fileID,
+			"object":  "file",
+			"deleted": true,
+		})
+		return
+	}
+
+	if action == "content" {
+		content, ok, err := a.store.GetFileContent(r.Context(), client.ID, fileID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !ok {
+			writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("file not found: %s", fileID))
+			return
+		}
+		w.Header().Set("Content-Type", "application/jsonl")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(content)
+		return
+	}
+
+	record, ok, err := a.store.GetFile(r.Context(), client.ID, fileID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("file not found: %s", fileID))
+		return
+	}
+	writeJSON(w, http.StatusOK, fileObject(record))
+}
+
+func fileObject(record store.FileRecord) openai.FileObject {
+	return openai.FileObject{
+		ID:        record.FileID,
+		Object:    "file",
+		Bytes:     record.Bytes,
+		CreatedAt: record.CreatedAt.Unix(),
+		Filename:  record.Filename,
+		Purpose:   record.Purpose,
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/gemini"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+const geminiModelsPathPrefix = "/v1beta/models/"
+
+// handleGeminiModelAction 实现 Gemini REST API 的 POST /v1beta/models/{model}:generateContent
+// 与 :streamGenerateContent（alt=sse 时为 SSE，否则为流式 JSON 数组），供按 Gemini API 编写的服务直接切到 Bedrock。
+// Bedrock 模型 ID 本身可能含冒号（如 ...-v1:0），因此按最后一个冒号拆分动作名。
+func (a *App) handleGeminiModelAction(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, geminiModelsPathPrefix)
+	separator := strings.LastIndex(rest, ":")
+	if separator <= 0 {
+		writeGeminiError(w, http.StatusNotFound, "not found")
+		return
+	}
+	model, action := rest[:separator], rest[separator+1:]
+	if action != "generateContent" && action != "streamGenerateContent" {
+		writeGeminiError(w, http.StatusNotFound, "unsupported method: "+action)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeGeminiError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeGeminiError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.AuthenticateWithFallback(r)
+	if err != nil {
+		writeGeminiError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeGeminiError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeGeminiError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeGeminiError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	var body json.RawMessage
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &body); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	request, err := gemini.ParseGenerateContentRequest(body)
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := gemini.ValidateGenerateContentRequest(request); err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatRequest, err := gemini.GenerateContentRequestToChat(model, request, action == "streamGenerateContent")
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(chatRequest.Model)
+	if err != nil {
+		writeGeminiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          resolvedModel,
+		BedrockModelID: bedrockModelID,
+		RequestContent: openai.RenderRequestForLog(chatRequest, a.cfg.MaxContentChars),
+		IsStream:       chatRequest.Stream,
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	inputTokens := 0
+	outputTokens := 0
+	totalTokens := 0
+	cacheReadTokens := 0
+	cacheWriteTokens := 0
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		record.InputTokens = inputTokens
+		record.OutputTokens = outputTokens
+		record.TotalTokens = totalTokens
+		record.CacheReadInputTokens = cacheReadTokens
+		record.CacheWriteInputTokens = cacheWriteTokens
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+			return
+		}
+		a.addCostFromUsage(
+			record.BedrockModelID,
+			int64(record.InputTokens),
+			int64(record.OutputTokens),
+			int64(record.CacheReadInputTokens),
+			int64(record.CacheWriteInputTokens),
+		)
+	}()
+
+	if !a.isModelEnabled(bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not enabled by admin"
+		writeGeminiError(w, statusCode, errorMessage)
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not allowed for this api key"
+		writeGeminiError(w, statusCode, errorMessage)
+		return
+	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	chatRequest.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+	if chatRequest.Guardrail != nil {
+		record.GuardrailID = chatRequest.Guardrail.Identifier
+	}
+
+	includeThoughts := gemini.IncludeThoughts(request)
+	if chatRequest.Stream {
+		sse := strings.EqualFold(r.URL.Query().Get("alt"), "sse")
+		result, streamStatus, streamErr := a.handleGeminiStream(w, chatRequest, requestID, resolvedModel, bedrockModelID, sse, includeThoughts)
+		statusCode = streamStatus
+		errorMessage = streamErr
+		inputTokens = result.InputTokens
+		outputTokens = result.OutputTokens
+		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		record.Attempts = result.Attempts
+		latencyMs = result.LatencyMs
+		responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+		if latencyMs == 0 {
+			latencyMs = time.Since(startedAt).Milliseconds()
+		}
+		return
+	}
+
+	result, err := a.proxy.Converse(ctx, chatRequest, bedrockModelID)
+	record.Attempts = result.Attempts
+	// 出错时（如结构化输出校验失败）Bedrock 可能已计费，照样记录 usage
+	inputTokens = result.InputTokens
+	outputTokens = result.OutputTokens
+	totalTokens = result.TotalTokens
+	cacheReadTokens = result.CacheReadInputTokens
+	cacheWriteTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeGeminiError(w, statusCode, clientMessage)
+		return
+	}
+
+	responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+	latencyMs = result.LatencyMs
+	if latencyMs == 0 {
+		latencyMs = time.Since(startedAt).Milliseconds()
+	}
+
+	parts := make([]gemini.Part, 0, len(result.ToolCalls)+2)
+	if includeThoughts {
+		if thinking := thinkingText(result.ThinkingBlocks); thinking != "" {
+			parts = append(parts, gemini.Part{Text: thinking, Thought: true})
+		}
+	}
+	if result.Text != "" {
+		parts = append(parts, gemini.Part{Text: result.Text})
+	}
+	parts = append(parts, gemini.BuildFunctionCallParts(result.ToolCalls)...)
+	writeJSON(w, http.StatusOK, gemini.GenerateContentResponse{
+		Candidates: []gemini.Candidate{{
+			Content:      gemini.Content{Role: "model", Parts: parts},
+			FinishReason: gemini.FinishReason(result.FinishReason),
+		}},
+		UsageMetadata: geminiUsage(result),
+		ModelVersion:  resolvedModel,
+		ResponseID:    requestID,
+	})
+}
+
+// handleGeminiStream 输出 streamGenerateContent：每个文本增量一个 GenerateContentResponse，
+// functionCall 部分、finishReason 与 usageMetadata 在 Bedrock 流结束后的最后一个响应中输出。
+// sse 为 false 时按 Gemini 默认格式输出一个逐步写出的 JSON 数组。
+func (a *App) handleGeminiStream(
+	w http.ResponseWriter,
+	chatRequest openai.ChatCompletionRequest,
+	requestID string,
+	modelName string,
+	bedrockModelID string,
+	sse bool,
+	includeThoughts bool,
+) (bedrockproxy.ChatResult, int, string) {
+	started := false
+	var responseText strings.Builder
+
+	writeChunk := func(payload any) error {
+		if !started {
+			started = true
+			if sse {
+				setSSEHeaders(w)
+			} else {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.Header().Set("X-Accel-Buffering", "no")
+			}
+			w.WriteHeader(http.StatusOK)
+			if !sse {
+				if _, err := io.WriteString(w, "["); err != nil {
+					return err
+				}
+			}
+		} else if !sse {
+			if _, err := io.WriteString(w, ",\r\n"); err != nil {
+				return err
+			}
+		}
+		if sse {
+			return writeSSEData(w, payload)
+		}
+		return writeNDJSONLine(w, payload)
+	}
+	finishArray := func() {
+		if started && !sse {
+			_, _ = io.WriteString(w, "]")
+		}
+	}
+
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
+	defer streamCancel()
+
+	result, err := a.proxy.ConverseStream(streamCtx, chatRequest, bedrockModelID, func(delta bedrockproxy.StreamDelta) error {
+		var parts []gemini.Part
+		if includeThoughts && delta.ReasoningContent != "" {
+			parts = append(parts, gemini.Part{Text: delta.ReasoningContent, Thought: true})
+		}
+		if delta.Text != "" {
+			responseText.WriteString(delta.Text)
+			parts = append(parts, gemini.Part{Text: delta.Text})
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+		return writeChunk(gemini.GenerateContentResponse{
+			Candidates:   []gemini.Candidate{{Content: gemini.Content{Role: "model", Parts: parts}}},
+			ModelVersion: modelName,
+			ResponseID:   requestID,
+		})
+	})
+	if err != nil {
+		statusCode := http.StatusBadGateway
+		errorMessage := "bedrock stream failed: " + err.Error()
+		if bedrockproxy.IsRequestError(err) {
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+		} else if bedrockproxy.IsStructuredOutputError(err) {
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+		}
+		if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
+			errorMessage += " (请求被取消：请检查客户端/代理是否过早断开，或调大环境变量 REQUEST_TIMEOUT_SECONDS)"
+		}
+		if !started {
+			writeGeminiError(w, statusCode, errorMessage)
+		} else {
+			_ = writeChunk(gemini.ErrorResponse{Error: gemini.ErrorPayload{
+				Code:    statusCode,
+				Message: errorMessage,
+				Status:  gemini.ErrorStatus(statusCode),
+			}})
+			finishArray()
+		}
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
+	}
+
+	result.Text = responseText.String()
+	parts := gemini.BuildFunctionCallParts(result.ToolCalls)
+	if len(parts) == 0 {
+		// 最后一个响应的 content.parts 不能为空
+		parts = []gemini.Part{{Text: ""}}
+	}
+	if err := writeChunk(gemini.GenerateContentResponse{
+		Candidates: []gemini.Candidate{{
+			Content:      gemini.Content{Role: "model", Parts: parts},
+			FinishReason: gemini.FinishReason(result.FinishReason),
+		}},
+		UsageMetadata: geminiUsage(result),
+		ModelVersion:  modelName,
+		ResponseID:    requestID,
+	}); err != nil {
+		return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+	}
+	finishArray()
+	return result, http.StatusOK, ""
+}
+
+func geminiUsage(result bedrockproxy.ChatResult) *gemini.UsageMetadata {
+	promptTokens := promptTokensWithCache(result)
+	return &gemini.UsageMetadata{
+		PromptTokenCount:        promptTokens,
+		CandidatesTokenCount:    result.OutputTokens,
+		TotalTokenCount:         promptTokens + result.OutputTokens,
+		CachedContentTokenCount: result.CacheReadInputTokens,
+	}
+}
+
+func writeGeminiError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, gemini.ErrorResponse{Error: gemini.ErrorPayload{
+		Code:    status,
+		Message: strings.TrimSpace(message),
+		Status:  gemini.ErrorStatus(status),
+	}})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/config"
+	"aws-cursor-router/internal/store"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestGeminiGenerateContentMapsFunctionCalls(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	manager := auth.NewManager(config.Config{})
+	if err := manager.UpsertClient(config.ClientConfig{
+		ID: "team-a", Name: "Team A", APIKey: "key-a", MaxRequestsPerMinute: 100, MaxConcurrent: 4,
+	}); err != nil {
+		t.Fatalf("upsert client failed: %v", err)
+	}
+	converse := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonToolUse,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role: brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{
+				&brtypes.ContentBlockMemberText{Value: "Checking."},
+				&brtypes.ContentBlockMemberToolUse{Value: brtypes.ToolUseBlock{
+					ToolUseId: awssdk.String("tooluse_1"),
+					Name:      awssdk.String("get_weather"),
+					Input:     document.NewLazyDocument(map[string]any{"city": "Paris"}),
+				}},
+			},
+		}},
+		Usage: &brtypes.TokenUsage{
+			InputTokens:  awssdk.Int32(12),
+			OutputTokens: awssdk.Int32(5),
+			TotalTokens:  awssdk.Int32(17),
+		},
+	}}
+	app := &App{
+		cfg:    config.Config{RequestTimeout: time.Minute, MaxContentChars: 1000},
+		auth:   manager,
+		store:  s,
+		logger: log.New(io.Discard, "", 0),
+		proxy:  bedrockproxy.NewService(converse, "", nil, 1024, 1024, false, false),
+	}
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"weather in Paris?"}]}],
+		"tools":[{"functionDeclarations":[{"name":"get_weather","parameters":{"type":"OBJECT","properties":{"city":{"type":"STRING"}}}}]}]}`
+	request := httptest.NewRequest(http.MethodPost, "/v1beta/models/anthropic.claude-test-v1:0:generateContent?key=key-a", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	app.handleGeminiModelAction(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d %s", recorder.Code, recorder.Body.String())
+	}
+	if awssdk.ToString(converse.LastInput().ModelId) != "anthropic.claude-test-v1:0" {
+		t.Fatalf("expected model id with colon to be kept, got %q", awssdk.ToString(converse.LastInput().ModelId))
+	}
+
+	var response struct {
+		Candidates []struct {
+			Content struct {
+				Role  string `json:"role"`
+				Parts []struct {
+					Text         string `json:"text"`
+					FunctionCall *struct {
+						Name string         `json:"name"`
+						Args map[string]any `json:"args"`
+					} `json:"functionCall"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+	if len(response.Candidates) != 1 || response.Candidates[0].FinishReason != "STOP" {
+		t.Fatalf("unexpected candidates: %s", recorder.Body.String())
+	}
+	parts := response.Candidates[0].Content.Parts
+	if len(parts) != 2 || parts[0].Text != "Checking." || parts[1].FunctionCall == nil ||
+		parts[1].FunctionCall.Name != "get_weather" || parts[1].FunctionCall.Args["city"] != "Paris" {
+		t.Fatalf("unexpected parts: %s", recorder.Body.String())
+	}
+	if response.UsageMetadata.PromptTokenCount != 12 || response.UsageMetadata.CandidatesTokenCount != 5 {
+		t.Fatalf("unexpected usage: %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// handleImageGenerations 实现 OpenAI 兼容的 /v1/images/generations：通过 InvokeModel 调用
+// Titan Image Generator / Nova Canvas / Stability 图片模型，只返回 b64_json。
+// 图片生成不产生 token 用量，按生成的张数乘以模型的 price_per_image 计费。
+func (a *App) handleImageGenerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasInvokeClient() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	var request openai.ImagesRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := openai.ValidateImagesRequest(request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(request.Model)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !bedrockproxy.IsImageModel(bedrockModelID) {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("model %s is not a supported image model", request.Model))
+		return
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          resolvedModel,
+		BedrockModelID: bedrockModelID,
+		RequestContent: truncateRunes(request.Prompt, a.cfg.MaxContentChars),
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	imageCount := 0
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		record.ImageCount = imageCount
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+			return
+		}
+		a.addImageCost(record.BedrockModelID, int64(record.ImageCount))
+	}()
+
+	if !a.isModelEnabled(bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not enabled by admin"
+		writeOpenAIError(w, statusCode, errorMessage)
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not allowed for this api key"
+		writeOpenAIError(w, statusCode, errorMessage)
+		return
+	}
+
+	result, err := a.proxy.GenerateImages(ctx, request, bedrockModelID)
+	record.Attempts = result.Attempts
+	// 部分批次成功后失败时，已生成的图片同样由 Bedrock 计费
+	imageCount = len(result.Images)
+	latencyMs = result.LatencyMs
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeOpenAIError(w, statusCode, clientMessage)
+		return
+	}
+
+	data := make([]openai.ImageData, 0, len(result.Images))
+	for _, image := range result.Images {
+		data = append(data, openai.ImageData{B64JSON: image})
+	}
+	responseContent = fmt.Sprintf("[%d images]", len(result.Images))
+
+	writeJSON(w, http.StatusOK, openai.ImagesResponse{
+		Created: startedAt.Unix(),
+		Data:    data,
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+	"github.com/google/uuid"
+)
+
+// handleModerations 实现 OpenAI 兼容的 /v1/moderations：用管理员配置的 Bedrock Guardrail（ApplyGuardrail）评估输入。
+// Guardrail 沿用 /config/guardrails 的绑定：API key 的配置优先，其次是绑定到 model（如 omni-moderation-latest）的配置。
+// 请求的 model 只是 Guardrail 的查找键，不经过模型启用与 API key 模型白名单检查。
+func (a *App) handleModerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasGuardrailClient() {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeOpenAIError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	var request openai.ModerationRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	inputs, err := openai.ParseModerationInput(request.Input)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	model := strings.TrimSpace(request.Model)
+	if model == "" {
+		model = openai.DefaultModerationModel
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          model,
+		RequestContent: truncateRunes(renderEmbeddingInputsForLog(inputs), a.cfg.MaxContentChars),
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+		}
+	}()
+
+	guardrail := a.guardrailFor(client.ID, model)
+	if guardrail == nil {
+		statusCode = http.StatusBadRequest
+		errorMessage = fmt.Sprintf("no guardrail is configured for model %s or this api key", model)
+		writeOpenAIError(w, statusCode, errorMessage)
+		return
+	}
+	record.GuardrailID = guardrail.Identifier
+
+	result, err := a.proxy.Moderate(ctx, inputs, guardrail)
+	record.Attempts = result.Attempts
+	latencyMs = result.LatencyMs
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeOpenAIError(w, statusCode, clientMessage)
+		return
+	}
+	record.GuardrailIntervened = result.Intervened
+	responseContent = renderModerationResultsForLog(result.Results)
+
+	writeJSON(w, http.StatusOK, openai.ModerationResponse{
+		ID:      "modr-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		Model:   model,
+		Results: result.Results,
+	})
+}
+
+// renderModerationResultsForLog 每条输入一行，列出命中的类别。
+func renderModerationResultsForLog(results []openai.ModerationResult) string {
+	lines := make([]string, 0, len(results))
+	for index, result := range results {
+		categories := make([]string, 0)
+		for category, flagged := range result.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+		sort.Strings(categories)
+		line := fmt.Sprintf("[input %d] flagged=%t", index, result.Flagged)
+		if len(categories) > 0 {
+			line += " categories=" + strings.Join(categories, ",")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/ollama"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+// handleOllamaChat 实现 Ollama 的 POST /api/chat，供只支持 Ollama 协议的编辑器插件使用。
+// 请求转换为 OpenAI Chat 格式后复用 bedrockproxy.Service；Ollama 客户端很少发送 Bearer token，
+// 因此鉴权额外接受 Basic 认证与查询参数 api_key / key。
+func (a *App) handleOllamaChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOllamaError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeOllamaError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.AuthenticateWithFallback(r)
+	if err != nil {
+		writeOllamaError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOllamaError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeOllamaError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	var request ollama.ChatRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := ollama.ValidateChatRequest(request); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatRequest, err := ollama.ChatRequestToChat(request)
+	if err != nil {
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.serveOllamaCompletion(w, r, client, chatRequest, false)
+}
+
+// handleOllamaGenerate 实现 Ollama 的 POST /api/generate：prompt / system / images 转为单轮对话。
+func (a *App) handleOllamaGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOllamaError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if !a.proxy.HasClient() {
+		writeOllamaError(w, http.StatusServiceUnavailable, "bedrock client is not configured")
+		return
+	}
+
+	client, err := a.auth.AuthenticateWithFallback(r)
+	if err != nil {
+		writeOllamaError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOllamaError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if err := a.checkGlobalCostLimit(); err != nil {
+		writeOllamaError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+
+	var request ollama.GenerateRequest
+	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if err := ollama.ValidateGenerateRequest(request); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chatRequest, err := ollama.GenerateRequestToChat(request)
+	if err != nil {
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.serveOllamaCompletion(w, r, client, chatRequest, true)
+}
+
+// handleOllamaTags 实现 GET /api/tags，列出当前 API key 可用的模型（与 /v1/models 相同的目录）。
+func (a *App) handleOllamaTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeOllamaError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.AuthenticateWithFallback(r)
+	if err != nil {
+		writeOllamaError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOllamaError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	models := a.listModelsForClient(client)
+	tags := make([]ollama.ModelTag, 0, len(models))
+	for _, modelID := range models {
+		info := a.buildModelInfo(modelID)
+		family := info.OwnedBy
+		digest := sha256.Sum256([]byte(modelID))
+		tags = append(tags, ollama.ModelTag{
+			Name:       modelID,
+			Model:      modelID,
+			ModifiedAt: time.Unix(info.Created, 0).UTC().Format(time.RFC3339),
+			Digest:     hex.EncodeToString(digest[:]),
+			Details: ollama.ModelDetails{
+				Format:   "bedrock",
+				Family:   family,
+				Families: []string{family},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, ollama.TagsResponse{Models: tags})
+}
+
+// serveOllamaCompletion 是 /api/chat 与 /api/generate 共用的调用流程：并发控制、模型白名单、调用日志与计费，
+// 与 /v1/chat/completions 一致；generate 为 true 时按 /api/generate 的响应格式输出。
+func (a *App) serveOllamaCompletion(
+	w http.ResponseWriter,
+	r *http.Request,
+	client *auth.Client,
+	chatRequest openai.ChatCompletionRequest,
+	generate bool,
+) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.cfg.RequestTimeout)
+	defer cancel()
+
+	release, err := a.auth.Acquire(ctx, client)
+	if err != nil {
+		writeOllamaError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
+		return
+	}
+	defer release()
+
+	resolvedModel, bedrockModelID, err := a.proxy.ResolveModel(chatRequest.Model)
+	if err != nil {
+		writeOllamaError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requestID := strings.TrimSpace(r.Header.Get("x-request-id"))
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	startedAt := time.Now().UTC()
+	logModel := resolvedModel
+	if logModel == "default" {
+		logModel = bedrockModelID
+	}
+
+	record := store.CallRecord{
+		RequestID:      requestID,
+		ClientID:       client.ID,
+		Model:          logModel,
+		BedrockModelID: bedrockModelID,
+		RequestContent: openai.RenderRequestForLog(chatRequest, a.cfg.MaxContentChars),
+		IsStream:       chatRequest.Stream,
+		CreatedAt:      startedAt,
+	}
+
+	statusCode := http.StatusOK
+	errorMessage := ""
+	responseContent := ""
+	inputTokens := 0
+	outputTokens := 0
+	totalTokens := 0
+	cacheReadTokens := 0
+	cacheWriteTokens := 0
+	latencyMs := int64(0)
+
+	defer func() {
+		record.StatusCode = statusCode
+		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
+		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
+		record.InputTokens = inputTokens
+		record.OutputTokens = outputTokens
+		record.TotalTokens = totalTokens
+		record.CacheReadInputTokens = cacheReadTokens
+		record.CacheWriteInputTokens = cacheWriteTokens
+		if latencyMs > 0 {
+			record.LatencyMs = latencyMs
+		} else {
+			record.LatencyMs = time.Since(startedAt).Milliseconds()
+		}
+		if !a.store.Enqueue(record) {
+			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
+			return
+		}
+		a.addCostFromUsage(
+			record.BedrockModelID,
+			int64(record.InputTokens),
+			int64(record.OutputTokens),
+			int64(record.CacheReadInputTokens),
+			int64(record.CacheWriteInputTokens),
+		)
+	}()
+
+	if !a.isModelEnabled(bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not enabled by admin"
+		writeOllamaError(w, statusCode, errorMessage)
+		return
+	}
+	if !client.IsModelAllowed(resolvedModel, bedrockModelID) {
+		statusCode = http.StatusForbidden
+		errorMessage = "model is not allowed for this api key"
+		writeOllamaError(w, statusCode, errorMessage)
+		return
+	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	chatRequest.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+	if chatRequest.Guardrail != nil {
+		record.GuardrailID = chatRequest.Guardrail.Identifier
+	}
+
+	modelName := resolvedModel
+	if modelName == "default" {
+		modelName = bedrockModelID
+	}
+
+	if chatRequest.Stream {
+		result, streamStatus, streamErr := a.handleOllamaStream(w, chatRequest, modelName, bedrockModelID, generate)
+		statusCode = streamStatus
+		errorMessage = streamErr
+		inputTokens = result.InputTokens
+		outputTokens = result.OutputTokens
+		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		record.Attempts = result.Attempts
+		latencyMs = result.LatencyMs
+		responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+		if latencyMs == 0 {
+			latencyMs = time.Since(startedAt).Milliseconds()
+		}
+		return
+	}
+
+	result, err := a.proxy.Converse(ctx, chatRequest, bedrockModelID)
+	record.Attempts = result.Attempts
+	// 出错时（如结构化输出校验失败）Bedrock 可能已计费，照样记录 usage
+	inputTokens = result.InputTokens
+	outputTokens = result.OutputTokens
+	totalTokens = result.TotalTokens
+	cacheReadTokens = result.CacheReadInputTokens
+	cacheWriteTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
+	if err != nil {
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		errorMessage = err.Error()
+		writeOllamaError(w, statusCode, clientMessage)
+		return
+	}
+
+	responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
+	latencyMs = result.LatencyMs
+	if latencyMs == 0 {
+		latencyMs = time.Since(startedAt).Milliseconds()
+	}
+	result.LatencyMs = latencyMs
+
+	writeJSON(w, http.StatusOK, buildOllamaFinal(result, modelName, generate, true))
+}
+
+// handleOllamaStream 按 Ollama 的 NDJSON 流输出：每个文本 / 思考增量一行 done=false，
+// tool_calls 在 Bedrock 流结束后一次性输出（/api/chat），最后一行 done=true 携带 done_reason 与 token 统计。
+func (a *App) handleOllamaStream(
+	w http.ResponseWriter,
+	chatRequest openai.ChatCompletionRequest,
+	modelName string,
+	bedrockModelID string,
+	generate bool,
+) (bedrockproxy.ChatResult, int, string) {
+	started := false
+	var responseText strings.Builder
+	startedAt := time.Now()
+
+	writeLine := func(payload any) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+		return writeNDJSONLine(w, payload)
+	}
+
+	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
+	defer streamCancel()
+
+	result, err := a.proxy.ConverseStream(streamCtx, chatRequest, bedrockModelID, func(delta bedrockproxy.StreamDelta) error {
+		if delta.Text == "" && delta.ReasoningContent == "" {
+			return nil
+		}
+		responseText.WriteString(delta.Text)
+		createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+		if generate {
+			return writeLine(ollama.GenerateResponse{
+				Model:     modelName,
+				CreatedAt: createdAt,
+				Response:  delta.Text,
+				Thinking:  delta.ReasoningContent,
+			})
+		}
+		return writeLine(ollama.ChatResponse{
+			Model:     modelName,
+			CreatedAt: createdAt,
+			Message: &ollama.Message{
+				Role:     "assistant",
+				Content:  delta.Text,
+				Thinking: delta.ReasoningContent,
+			},
+		})
+	})
+	if err != nil {
+		statusCode := http.StatusBadGateway
+		errorMessage := "bedrock stream failed: " + err.Error()
+		if bedrockproxy.IsRequestError(err) {
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+		} else if bedrockproxy.IsStructuredOutputError(err) {
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+		}
+		if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
+			errorMessage += " (请求被取消：请检查客户端/代理是否过早断开，或调大环境变量 REQUEST_TIMEOUT_SECONDS)"
+		}
+		if !started {
+			writeOllamaError(w, statusCode, errorMessage)
+		} else {
+			_ = writeNDJSONLine(w, ollama.ErrorResponse{Error: errorMessage})
+		}
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
+	}
+
+	result.Text = responseText.String()
+	if result.LatencyMs == 0 {
+		result.LatencyMs = time.Since(startedAt).Milliseconds()
+	}
+	if !generate && len(result.ToolCalls) > 0 {
+		if err := writeLine(ollama.ChatResponse{
+			Model:     modelName,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			Message: &ollama.Message{
+				Role:      "assistant",
+				ToolCalls: ollama.BuildToolCalls(result.ToolCalls),
+			},
+		}); err != nil {
+			return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+		}
+	}
+	if err := writeLine(buildOllamaFinal(result, modelName, generate, false)); err != nil {
+		return result, http.StatusBadGateway, "stream write failed: " + err.Error()
+	}
+	return result, http.StatusOK, ""
+}
+
+// buildOllamaFinal 构造 done=true 的响应；withContent 为 false 时（流式最后一行）不重复输出已发送的内容。
+func buildOllamaFinal(result bedrockproxy.ChatResult, modelName string, generate bool, withContent bool) any {
+	metrics := ollama.Metrics{
+		TotalDuration:   result.LatencyMs * int64(time.Millisecond),
+		PromptEvalCount: promptTokensWithCache(result),
+		EvalCount:       result.OutputTokens,
+	}
+	createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+	doneReason := ollama.DoneReason(result.FinishReason)
+
+	if generate {
+		response := ollama.GenerateResponse{
+			Model:      modelName,
+			CreatedAt:  createdAt,
+			Done:       true,
+			DoneReason: doneReason,
+			Metrics:    metrics,
+		}
+		if withContent {
+			response.Response = result.Text
+			response.Thinking = thinkingText(result.ThinkingBlocks)
+		}
+		return response
+	}
+
+	message := &ollama.Message{Role: "assistant"}
+	if withContent {
+		message.Content = result.Text
+		message.Thinking = thinkingText(result.ThinkingBlocks)
+		message.ToolCalls = ollama.BuildToolCalls(result.ToolCalls)
+	}
+	return ollama.ChatResponse{
+		Model:      modelName,
+		CreatedAt:  createdAt,
+		Message:    message,
+		Done:       true,
+		DoneReason: doneReason,
+		Metrics:    metrics,
+	}
+}
+
+func thinkingText(blocks []openai.ThinkingBlock) string {
+	var builder strings.Builder
+	for _, block := range blocks {
+		builder.WriteString(block.Thinking)
+	}
+	return builder.String()
+}
+
+func writeOllamaError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ollama.ErrorResponse{Error: strings.TrimSpace(message)})
+}
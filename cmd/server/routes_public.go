@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"aws-cursor-router/internal/auth"
@@ -19,8 +20,24 @@ import (
 func registerPublicRoutes(mux *http.ServeMux, app *App) {
 	mux.HandleFunc("/healthz", app.handleHealthz)
 	mux.HandleFunc("/v1/models", app.handleListModels)
+	mux.HandleFunc("/v1/models/", app.handleRetrieveModel)
 	mux.HandleFunc("/v1/chat/completions", app.handleChatCompletions)
+	mux.HandleFunc("/v1/chat/completions/count_tokens", app.handleChatCountTokens)
 	mux.HandleFunc("/v1/responses", app.handleResponsesCreate)
+	mux.HandleFunc(responsesPathPrefix, app.handleResponseByID)
+	mux.HandleFunc("/v1/embeddings", app.handleEmbeddings)
+	mux.HandleFunc("/v1/images/generations", app.handleImageGenerations)
+	mux.HandleFunc("/v1/moderations", app.handleModerations)
+	mux.HandleFunc("/v1/files", app.handleFiles)
+	mux.HandleFunc(filesPathPrefix, app.handleFileByID)
+	mux.HandleFunc("/v1/batches", app.handleBatches)
+	mux.HandleFunc(batchesPathPrefix, app.handleBatchByID)
+	mux.HandleFunc("/v1/messages", app.handleAnthropicMessages)
+	mux.HandleFunc("/v1/messages/count_tokens", app.handleAnthropicCountTokens)
+	mux.HandleFunc("/api/chat", app.handleOllamaChat)
+	mux.HandleFunc("/api/generate", app.handleOllamaGenerate)
+	mux.HandleFunc("/api/tags", app.handleOllamaTags)
+	mux.HandleFunc(geminiModelsPathPrefix, app.handleGeminiModelAction)
 	mux.HandleFunc("/debug/test-tool-call", app.handleTestToolCall)
 }
 
@@ -91,16 +108,10 @@ func (a *App) handleListModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	models := modelsForClient(a.listCatalogModels(), client)
-	now := time.Now().Unix()
+	models := a.listModelsForClient(client)
 	items := make([]openai.ModelInfo, 0, len(models))
 	for _, modelID := range models {
-		items = append(items, openai.ModelInfo{
-			ID:      modelID,
-			Object:  "model",
-			Created: now,
-			OwnedBy: "aws-bedrock",
-		})
+		items = append(items, a.buildModelInfo(modelID))
 	}
 
 	writeJSON(w, http.StatusOK, openai.ModelsResponse{
@@ -109,6 +120,37 @@ func (a *App) handleListModels(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleRetrieveModel 处理 GET /v1/models/{id}，只能查询当前 API key 在 /v1/models 中可见的模型。
+func (a *App) handleRetrieveModel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	modelID := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/v1/models/"))
+	if modelID == "" {
+		writeOpenAIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	for _, candidate := range a.listModelsForClient(client) {
+		if candidate == modelID {
+			writeJSON(w, http.StatusOK, a.buildModelInfo(modelID))
+			return
+		}
+	}
+	writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("model not found: %s", modelID))
+}
+
 func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
@@ -142,7 +184,9 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
 		return
 	}
-	defer release()
+	// n>1 时各候选会各自申请并发名额，届时提前释放 handler 占用的名额
+	releaseSlot := sync.OnceFunc(release)
+	defer releaseSlot()
 
 	var request openai.ChatCompletionRequest
 	if err := decodeJSONBody(w, r, a.cfg.MaxBodyBytes, &request); err != nil {
@@ -226,6 +270,8 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	inputTokens := 0
 	outputTokens := 0
 	totalTokens := 0
+	cacheReadTokens := 0
+	cacheWriteTokens := 0
 	latencyMs := int64(0)
 
 	defer func() {
@@ -235,6 +281,8 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		record.InputTokens = inputTokens
 		record.OutputTokens = outputTokens
 		record.TotalTokens = totalTokens
+		record.CacheReadInputTokens = cacheReadTokens
+		record.CacheWriteInputTokens = cacheWriteTokens
 		if latencyMs > 0 {
 			record.LatencyMs = latencyMs
 		} else {
@@ -244,7 +292,13 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
 			return
 		}
-		a.addCostFromUsage(record.BedrockModelID, int64(record.InputTokens), int64(record.OutputTokens))
+		a.addCostFromUsage(
+			record.BedrockModelID,
+			int64(record.InputTokens),
+			int64(record.OutputTokens),
+			int64(record.CacheReadInputTokens),
+			int64(record.CacheWriteInputTokens),
+		)
 	}()
 
 	if !a.isModelEnabled(bedrockModelID) {
@@ -259,6 +313,40 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, statusCode, errorMessage)
 		return
 	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	request.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+	if request.Guardrail != nil {
+		record.GuardrailID = request.Guardrail.Identifier
+	}
+
+	choices := choiceCount(request.N)
+	if choices > 1 && request.Stream {
+		releaseSlot()
+		results, streamStatus, streamErr := a.handleChatCompletionsStreamChoices(
+			w,
+			client,
+			request,
+			requestID,
+			resolvedModel,
+			a.modelFallbackChain(client, bedrockModelID),
+			a.guardrailResolver(client.ID),
+			choices,
+		)
+		usage := mergeChoiceUsage(results)
+		a.recordServedModel(&record, client.ID, usage.ModelID)
+		statusCode = streamStatus
+		errorMessage = streamErr
+		inputTokens = usage.InputTokens
+		outputTokens = usage.OutputTokens
+		totalTokens = usage.TotalTokens
+		cacheReadTokens = usage.CacheReadInputTokens
+		cacheWriteTokens = usage.CacheWriteInputTokens
+		record.GuardrailIntervened = usage.GuardrailIntervened
+		record.Attempts = usage.Attempts
+		latencyMs = time.Since(startedAt).Milliseconds()
+		responseContent = renderChoicesForLog(results)
+		return
+	}
 
 	if request.Stream {
 		result, streamStatus, streamErr := a.handleChatCompletionsStream(
@@ -267,13 +355,19 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 			request,
 			requestID,
 			resolvedModel,
-			bedrockModelID,
+			a.modelFallbackChain(client, bedrockModelID),
+			a.guardrailResolver(client.ID),
 		)
+		a.recordServedModel(&record, client.ID, result.ModelID)
 		statusCode = streamStatus
 		errorMessage = streamErr
 		inputTokens = result.InputTokens
 		outputTokens = result.OutputTokens
 		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		record.Attempts = result.Attempts
 		latencyMs = result.LatencyMs
 		responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
 		if latencyMs == 0 {
@@ -282,46 +376,80 @@ func (a *App) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := a.proxy.Converse(ctx, request, bedrockModelID)
+	// 按管理员配置的降级链依次尝试；n>1 时每个候选各自降级
+	modelIDs := a.modelFallbackChain(client, bedrockModelID)
+	guardrails := a.guardrailResolver(client.ID)
+	var results []bedrockproxy.ChatResult
+	if choices > 1 {
+		releaseSlot()
+		results, err = a.fanOutChoices(ctx, client, choices, func(ctx context.Context, index int) (bedrockproxy.ChatResult, error) {
+			return a.proxy.ConverseWithFallback(ctx, request, modelIDs, guardrails)
+		})
+	} else {
+		var result bedrockproxy.ChatResult
+		result, err = a.proxy.ConverseWithFallback(ctx, request, modelIDs, guardrails)
+		results = []bedrockproxy.ChatResult{result}
+	}
+	a.recordServedModel(&record, client.ID, mergeChoiceUsage(results).ModelID)
 	if err != nil {
-		statusCode = http.StatusBadGateway
+		usage := mergeChoiceUsage(results)
+		inputTokens = usage.InputTokens
+		outputTokens = usage.OutputTokens
+		totalTokens = usage.TotalTokens
+		cacheReadTokens = usage.CacheReadInputTokens
+		cacheWriteTokens = usage.CacheWriteInputTokens
+		record.GuardrailIntervened = usage.GuardrailIntervened
+		record.Attempts = usage.Attempts
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
+		if errors.Is(err, errChoiceSlotUnavailable) {
+			statusCode, clientMessage = http.StatusTooManyRequests, err.Error()
+		}
 		errorMessage = err.Error()
-		writeOpenAIError(w, statusCode, "bedrock call failed: "+err.Error())
+		writeOpenAIError(w, statusCode, clientMessage)
 		return
 	}
 
-	assistantContent := buildAssistantMessageContent(result.Text, len(result.ToolCalls) > 0)
 	modelName := resolvedModel
-	if modelName == "default" {
-		modelName = bedrockModelID
+	if modelName == "default" || record.BedrockModelID != bedrockModelID {
+		// 降级到备选模型时报告实际服务的模型
+		modelName = record.BedrockModelID
 	}
 
-	response := openai.ChatCompletionResponse{
-		ID:      "chatcmpl-" + requestID,
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   modelName,
-		Choices: []openai.ChatCompletionChoice{{
-			Index: 0,
+	usage := mergeChoiceUsage(results)
+	responseChoices := make([]openai.ChatCompletionChoice, 0, len(results))
+	for index, result := range results {
+		responseChoices = append(responseChoices, openai.ChatCompletionChoice{
+			Index: index,
 			Message: openai.ChatMessage{
-				Role:      "assistant",
-				Content:   assistantContent,
-				ToolCalls: result.ToolCalls,
+				Role:             "assistant",
+				Content:          buildAssistantMessageContent(result.Text, len(result.ToolCalls) > 0),
+				ToolCalls:        result.ToolCalls,
+				ReasoningContent: openai.ReasoningText(result.ThinkingBlocks),
+				ThinkingBlocks:   result.ThinkingBlocks,
 			},
 			FinishReason: defaultFinishReason(result.FinishReason),
-		}},
-		Usage: openai.Usage{
-			PromptTokens:     result.InputTokens,
-			CompletionTokens: result.OutputTokens,
-			TotalTokens:      result.TotalTokens,
-		},
+		})
 	}
 
-	responseContent = renderAssistantContentForLog(result.Text, result.ToolCalls)
-	inputTokens = result.InputTokens
-	outputTokens = result.OutputTokens
-	totalTokens = result.TotalTokens
-	latencyMs = result.LatencyMs
+	response := openai.ChatCompletionResponse{
+		ID:      "chatcmpl-" + requestID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+		Choices: responseChoices,
+		Usage:   buildChatUsage(usage),
+	}
+
+	responseContent = renderChoicesForLog(results)
+	inputTokens = usage.InputTokens
+	outputTokens = usage.OutputTokens
+	totalTokens = usage.TotalTokens
+	cacheReadTokens = usage.CacheReadInputTokens
+	cacheWriteTokens = usage.CacheWriteInputTokens
+	record.GuardrailIntervened = usage.GuardrailIntervened
+	record.Attempts = usage.Attempts
+	latencyMs = usage.LatencyMs
 	if latencyMs == 0 {
 		latencyMs = time.Since(startedAt).Milliseconds()
 	}
@@ -362,6 +490,8 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusTooManyRequests, "concurrency limit exceeded")
 		return
 	}
+	// 后台响应挂接事件流前要提前释放并发槽位，后台 worker 执行时需要占用它
+	release = sync.OnceFunc(release)
 	defer release()
 
 	var request openai.ResponsesCreateRequest
@@ -373,6 +503,19 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	request.PreviousResponseID = strings.TrimSpace(request.PreviousResponseID)
+	if request.PreviousResponseID != "" {
+		history, err := a.loadResponsesHistory(ctx, client.ID, request.PreviousResponseID)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			if errors.Is(err, errPreviousResponseNotFound) || errors.Is(err, errPreviousResponseNotReady) {
+				statusCode = http.StatusBadRequest
+			}
+			writeOpenAIError(w, statusCode, err.Error())
+			return
+		}
+		request.History = history
+	}
 
 	chatRequest, err := openai.ResponsesRequestToChat(request)
 	if err != nil {
@@ -394,6 +537,7 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 	if requestID == "" {
 		requestID = newRequestID()
 	}
+	responseID := newResponseID()
 	startedAt := time.Now().UTC()
 	logModel := resolvedModel
 	if logModel == "default" {
@@ -416,15 +560,24 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 	inputTokens := 0
 	outputTokens := 0
 	totalTokens := 0
+	cacheReadTokens := 0
+	cacheWriteTokens := 0
 	latencyMs := int64(0)
+	// 后台响应的调用日志与费用由后台 worker 记录
+	detached := false
 
 	defer func() {
+		if detached {
+			return
+		}
 		record.StatusCode = statusCode
 		record.ErrorMessage = truncateRunes(errorMessage, a.cfg.MaxContentChars)
 		record.ResponseContent = truncateRunes(responseContent, a.cfg.MaxContentChars)
 		record.InputTokens = inputTokens
 		record.OutputTokens = outputTokens
 		record.TotalTokens = totalTokens
+		record.CacheReadInputTokens = cacheReadTokens
+		record.CacheWriteInputTokens = cacheWriteTokens
 		if latencyMs > 0 {
 			record.LatencyMs = latencyMs
 		} else {
@@ -434,7 +587,13 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 			a.logger.Printf("warning: dropped call log for request_id=%s client_id=%s", requestID, client.ID)
 			return
 		}
-		a.addCostFromUsage(record.BedrockModelID, int64(record.InputTokens), int64(record.OutputTokens))
+		a.addCostFromUsage(
+			record.BedrockModelID,
+			int64(record.InputTokens),
+			int64(record.OutputTokens),
+			int64(record.CacheReadInputTokens),
+			int64(record.CacheWriteInputTokens),
+		)
 	}()
 
 	if !a.isModelEnabled(bedrockModelID) {
@@ -449,24 +608,71 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 		writeOpenAIError(w, statusCode, errorMessage)
 		return
 	}
+	// 管理员为该 API key 或模型配置的 Bedrock Guardrail
+	chatRequest.Guardrail = a.guardrailFor(client.ID, bedrockModelID)
+	if chatRequest.Guardrail != nil {
+		record.GuardrailID = chatRequest.Guardrail.Identifier
+	}
+
+	if request.Background {
+		job, err := a.submitBackgroundResponse(client, request, chatRequest, requestID, responseID, resolvedModel, bedrockModelID)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+			if errors.Is(err, errBackgroundQueueFull) {
+				statusCode = http.StatusTooManyRequests
+			}
+			errorMessage = err.Error()
+			writeOpenAIError(w, statusCode, errorMessage)
+			return
+		}
+		detached = true
+		if !chatRequest.Stream {
+			writeJSON(w, http.StatusOK, job.responseObject("queued", nil))
+			return
+		}
+		release()
+		a.streamBackgroundResponse(w, r.Context(), job, 0)
+		return
+	}
 
 	if chatRequest.Stream {
+		var onCompleted func(responseID string, result bedrockproxy.ChatResult, output any, response any) error
+		if request.StoreEnabled() {
+			onCompleted = func(responseID string, result bedrockproxy.ChatResult, output any, response any) error {
+				// 前台请求已拿到完整结果，保存失败只记录日志
+				if err := a.saveResponse(client.ID, request, responseID, result.ModelID, result, output, response); err != nil {
+					a.logger.Printf("warning: store response %s failed: %v", responseID, err)
+				}
+				return nil
+			}
+		}
+		// 使用与请求断开无关的 context，避免客户端断开时取消 Bedrock 流；仅受 REQUEST_TIMEOUT 限制
+		streamCtx, streamCancel := context.WithTimeout(context.WithoutCancel(r.Context()), a.cfg.RequestTimeout)
+		defer streamCancel()
 		result, streamStatus, streamErr := a.handleResponsesStream(
 			w,
-			ctx,
+			streamCtx,
 			request,
 			chatRequest,
 			requestID,
+			responseID,
 			resolvedModel,
-			bedrockModelID,
+			a.modelFallbackChain(client, bedrockModelID),
+			a.guardrailResolver(client.ID),
+			onCompleted,
 		)
+		a.recordServedModel(&record, client.ID, result.ModelID)
 		statusCode = streamStatus
 		errorMessage = streamErr
 		inputTokens = result.InputTokens
 		outputTokens = result.OutputTokens
 		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		record.Attempts = result.Attempts
 		latencyMs = result.LatencyMs
-		responseItems := openai.BuildResponsesOutputItems(requestID, result.Text, result.ToolCalls)
+		responseItems := buildResponsesOutputItems(requestID, result)
 		responseContent = renderResponsesOutputForLog(responseItems)
 		if latencyMs == 0 {
 			latencyMs = time.Since(startedAt).Milliseconds()
@@ -474,44 +680,62 @@ func (a *App) handleResponsesCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := a.proxy.Converse(ctx, chatRequest, bedrockModelID)
+	result, err := a.proxy.ConverseWithFallback(ctx, chatRequest, a.modelFallbackChain(client, bedrockModelID), a.guardrailResolver(client.ID))
+	record.Attempts = result.Attempts
+	a.recordServedModel(&record, client.ID, result.ModelID)
 	if err != nil {
-		statusCode = http.StatusBadGateway
+		inputTokens = result.InputTokens
+		outputTokens = result.OutputTokens
+		totalTokens = result.TotalTokens
+		cacheReadTokens = result.CacheReadInputTokens
+		cacheWriteTokens = result.CacheWriteInputTokens
+		record.GuardrailIntervened = result.GuardrailIntervened
+		var clientMessage string
+		statusCode, clientMessage = describeBedrockError(err)
 		errorMessage = err.Error()
-		writeOpenAIError(w, statusCode, "bedrock call failed: "+err.Error())
+		writeOpenAIError(w, statusCode, clientMessage)
 		return
 	}
 
 	modelName := resolvedModel
-	if modelName == "default" {
-		modelName = bedrockModelID
+	if modelName == "default" || result.ModelID != bedrockModelID {
+		// 降级到备选模型时报告实际服务的模型
+		modelName = result.ModelID
 	}
-	outputItems := openai.BuildResponsesOutputItems(requestID, result.Text, result.ToolCalls)
+	outputItems := buildResponsesOutputItems(requestID, result)
 	outputText := openai.BuildResponsesOutputText(outputItems)
 
 	response := openai.ResponsesCreateResponse{
-		ID:        "resp-" + requestID,
-		Object:    "response",
-		CreatedAt: time.Now().Unix(),
-		Status:    "completed",
-		Model:     modelName,
-		Output:    outputItems,
-		Usage: openai.ResponsesUsage{
-			InputTokens:  result.InputTokens,
-			OutputTokens: result.OutputTokens,
-			TotalTokens:  result.TotalTokens,
-		},
-		ParallelToolCalls: boolOrDefault(request.ParallelToolCalls, true),
-		ToolChoice:        request.ToolChoice,
-		OutputText:        outputText,
-		Error:             nil,
-		IncompleteDetails: nil,
+		ID:                 responseID,
+		Object:             "response",
+		CreatedAt:          time.Now().Unix(),
+		Status:             "completed",
+		Model:              modelName,
+		Output:             outputItems,
+		Usage:              buildResponsesUsage(result),
+		ParallelToolCalls:  boolOrDefault(request.ParallelToolCalls, true),
+		ToolChoice:         request.ToolChoice,
+		OutputText:         outputText,
+		Text:               responsesTextConfig(request.Text),
+		Reasoning:          responsesReasoningConfig(chatRequest.ReasoningEffort),
+		Error:              nil,
+		IncompleteDetails:  nil,
+		PreviousResponseID: request.PreviousResponseID,
+		Store:              request.StoreEnabled(),
+	}
+	if response.Store {
+		if err := a.saveResponse(client.ID, request, response.ID, result.ModelID, result, outputItems, response); err != nil {
+			a.logger.Printf("warning: store response %s failed: %v", response.ID, err)
+		}
 	}
 
 	responseContent = renderResponsesOutputForLog(outputItems)
 	inputTokens = result.InputTokens
 	outputTokens = result.OutputTokens
 	totalTokens = result.TotalTokens
+	cacheReadTokens = result.CacheReadInputTokens
+	cacheWriteTokens = result.CacheWriteInputTokens
+	record.GuardrailIntervened = result.GuardrailIntervened
 	latencyMs = result.LatencyMs
 	if latencyMs == 0 {
 		latencyMs = time.Since(startedAt).Milliseconds()
@@ -526,12 +750,13 @@ func (a *App) handleChatCompletionsStream(
 	request openai.ChatCompletionRequest,
 	requestID string,
 	resolvedModel string,
-	bedrockModelID string,
+	modelIDs []string,
+	guardrails bedrockproxy.GuardrailResolver,
 ) (bedrockproxy.ChatResult, int, string) {
 	setSSEHeaders(w)
 	modelName := resolvedModel
 	if modelName == "default" {
-		modelName = bedrockModelID
+		modelName = modelIDs[0]
 	}
 	chunkID := "chatcmpl-" + requestID
 	createdAt := time.Now().Unix()
@@ -543,7 +768,17 @@ func (a *App) handleChatCompletionsStream(
 	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
 	defer streamCancel()
 
-	result, err := a.proxy.ConverseStream(streamCtx, request, bedrockModelID, func(delta bedrockproxy.StreamDelta) error {
+	// 是否已向客户端写出 chunk；之后出错只能在 SSE 流中报告
+	wrote := false
+	writeChunk := func(chunk openai.ChatCompletionChunk) error {
+		wrote = true
+		return writeSSEData(w, chunk)
+	}
+
+	// 降级到备选模型只发生在输出第一个 chunk 之前，之后的 chunk 都带实际服务的模型
+	result, err := a.proxy.ConverseStreamWithFallback(streamCtx, request, modelIDs, guardrails, func(modelID string) {
+		modelName = modelID
+	}, func(delta bedrockproxy.StreamDelta) error {
 		// 根据 OpenAI 规范，tool_calls 的第一个 chunk 需要同时包含 role 和 tool_calls
 		// 所以我们需要检查是否同时有 role 和 tool_calls，如果有则合并到一个 chunk 中发送
 		if len(delta.ToolCalls) > 0 {
@@ -551,7 +786,7 @@ func (a *App) handleChatCompletionsStream(
 			if delta.Role != "" {
 				chunkDelta.Role = delta.Role
 			}
-			if err := writeSSEData(w, openai.ChatCompletionChunk{
+			if err := writeChunk(openai.ChatCompletionChunk{
 				ID:      chunkID,
 				Object:  "chat.completion.chunk",
 				Created: createdAt,
@@ -566,7 +801,7 @@ func (a *App) handleChatCompletionsStream(
 			return nil
 		}
 		if delta.Role != "" {
-			if err := writeSSEData(w, openai.ChatCompletionChunk{
+			if err := writeChunk(openai.ChatCompletionChunk{
 				ID:      chunkID,
 				Object:  "chat.completion.chunk",
 				Created: createdAt,
@@ -579,9 +814,27 @@ func (a *App) handleChatCompletionsStream(
 				return err
 			}
 		}
+		// 思考增量以 reasoning_content 发送；思考块结束时带签名的完整块通过 thinking_blocks 发送，供客户端下一轮回传
+		if delta.ReasoningContent != "" || len(delta.ThinkingBlocks) > 0 {
+			if err := writeChunk(openai.ChatCompletionChunk{
+				ID:      chunkID,
+				Object:  "chat.completion.chunk",
+				Created: createdAt,
+				Model:   modelName,
+				Choices: []openai.ChatChunkChoice{{
+					Index: 0,
+					Delta: openai.ChatChunkDelta{
+						ReasoningContent: delta.ReasoningContent,
+						ThinkingBlocks:   delta.ThinkingBlocks,
+					},
+				}},
+			}); err != nil {
+				return err
+			}
+		}
 		if delta.Text != "" {
 			responseText.WriteString(delta.Text)
-			if err := writeSSEData(w, openai.ChatCompletionChunk{
+			if err := writeChunk(openai.ChatCompletionChunk{
 				ID:      chunkID,
 				Object:  "chat.completion.chunk",
 				Created: createdAt,
@@ -599,6 +852,13 @@ func (a *App) handleChatCompletionsStream(
 	if err != nil {
 		statusCode = http.StatusBadGateway
 		errorMessage := "bedrock stream failed: " + err.Error()
+		if bedrockproxy.IsRequestError(err) {
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+		} else if bedrockproxy.IsStructuredOutputError(err) {
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+		}
 		// context canceled 多为客户端断开、代理超时或服务端 REQUEST_TIMEOUT 过短
 		if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
 			errorMessage += " (请求被取消：请检查 Cursor/代理是否过早断开，或调大环境变量 REQUEST_TIMEOUT_SECONDS)"
@@ -606,9 +866,27 @@ func (a *App) handleChatCompletionsStream(
 
 		// 在尚未开始向客户端写入任何 SSE 数据时，直接按 OpenAI 错误格式返回 JSON，
 		// 这样 Cursor 可以在 UI 中清晰展示错误信息，而不会出现“什么都没显示”的情况。
-		writeOpenAIError(w, statusCode, errorMessage)
+		// 已输出部分内容后（如结构化输出在流结束时校验失败），改为发送带 error 的 chunk 与 [DONE]。
+		if !wrote {
+			writeOpenAIError(w, statusCode, errorMessage)
+		} else {
+			_ = writeSSEData(w, openai.ChatCompletionChunk{
+				ID:      chunkID,
+				Object:  "chat.completion.chunk",
+				Created: createdAt,
+				Model:   modelName,
+				Choices: []openai.ChatChunkChoice{},
+				Error: &openai.OpenAIErrorPayload{
+					Message: errorMessage,
+					Type:    "api_error",
+					Code:    fmt.Sprint(statusCode),
+				},
+			})
+			_ = writeSSEDone(w)
+		}
 
-		return bedrockproxy.ChatResult{Text: responseText.String()}, statusCode, errorMessage
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
 	}
 
 	finishReason := defaultFinishReason(result.FinishReason)
@@ -624,7 +902,12 @@ func (a *App) handleChatCompletionsStream(
 	}
 	a.logger.Printf("===================================")
 	
-	if err := writeSSEData(w, openai.ChatCompletionChunk{
+	chatUsage := buildChatUsage(result)
+	usage := &chatUsage
+	// 未设置 stream_options.include_usage 时沿用旧行为：usage 附在 finish chunk 上（Cursor 依赖）；
+	// 设置后按 OpenAI 规范单独发送 choices 为空的 usage chunk，finish chunk 不再重复携带，避免客户端重复累计。
+	includeUsage := openai.StreamIncludeUsage(request.StreamOptions)
+	finishChunk := openai.ChatCompletionChunk{
 		ID:      chunkID,
 		Object:  "chat.completion.chunk",
 		Created: createdAt,
@@ -634,42 +917,59 @@ func (a *App) handleChatCompletionsStream(
 			Delta:        openai.ChatChunkDelta{},
 			FinishReason: &finishReason,
 		}},
-		Usage: &openai.Usage{
-			PromptTokens:     result.InputTokens,
-			CompletionTokens: result.OutputTokens,
-			TotalTokens:      result.TotalTokens,
-		},
-	}); err != nil {
+	}
+	if !includeUsage {
+		finishChunk.Usage = usage
+	}
+	if err := writeSSEData(w, finishChunk); err != nil {
 		statusCode = http.StatusBadGateway
 		errorMessage := "stream write failed: " + err.Error()
-		return bedrockproxy.ChatResult{Text: responseText.String()}, statusCode, errorMessage
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
+	}
+	if includeUsage {
+		if err := writeSSEData(w, buildUsageChunk(chunkID, createdAt, modelName, usage)); err != nil {
+			statusCode = http.StatusBadGateway
+			errorMessage := "stream write failed: " + err.Error()
+			result.Text = responseText.String()
+			return result, statusCode, errorMessage
+		}
 	}
 	if err := writeSSEDone(w); err != nil {
 		statusCode = http.StatusBadGateway
 		errorMessage := "stream completion failed: " + err.Error()
-		return bedrockproxy.ChatResult{Text: responseText.String()}, statusCode, errorMessage
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
 	}
 
 	result.Text = responseText.String()
 	return result, http.StatusOK, ""
 }
 
+// handleResponsesStream 以 Responses 事件流输出一次 ConverseStream 调用。ctx 决定 Bedrock 流的生命周期：
+// 前台请求传入与客户端断开无关、受 REQUEST_TIMEOUT 限制的 context，后台任务传入可被 cancel 的任务 context。
 func (a *App) handleResponsesStream(
 	w http.ResponseWriter,
 	ctx context.Context,
 	request openai.ResponsesCreateRequest,
 	chatRequest openai.ChatCompletionRequest,
 	requestID string,
+	responseID string,
 	resolvedModel string,
-	bedrockModelID string,
+	modelIDs []string,
+	guardrails bedrockproxy.GuardrailResolver,
+	onCompleted func(responseID string, result bedrockproxy.ChatResult, output any, response any) error,
 ) (bedrockproxy.ChatResult, int, string) {
 	setSSEHeaders(w)
 
 	modelName := resolvedModel
 	if modelName == "default" {
-		modelName = bedrockModelID
+		modelName = modelIDs[0]
+	}
+	var previousResponseID any
+	if request.PreviousResponseID != "" {
+		previousResponseID = request.PreviousResponseID
 	}
-	responseID := "resp_" + requestID
 	createdAt := time.Now().Unix()
 	statusCode := http.StatusOK
 
@@ -691,30 +991,25 @@ func (a *App) handleResponsesStream(
 	}
 
 	baseResponse := map[string]any{
-		"id":                  responseID,
-		"object":              "response",
-		"created_at":          createdAt,
-		"status":              "in_progress",
-		"model":               modelName,
-		"output":              []any{},
-		"parallel_tool_calls": boolOrDefault(request.ParallelToolCalls, true),
-		"tool_choice":         request.ToolChoice,
-		"error":               nil,
-		"incomplete_details":  nil,
-		"usage":               nil,
-		"truncation":          "disabled",
-		"text": map[string]any{
-			"format": map[string]any{
-				"type": "text",
-			},
-		},
-		"reasoning": map[string]any{
-			"effort":  nil,
-			"summary": nil,
-		},
+		"id":                   responseID,
+		"object":               "response",
+		"created_at":           createdAt,
+		"status":               "in_progress",
+		"model":                modelName,
+		"output":               []any{},
+		"parallel_tool_calls":  boolOrDefault(request.ParallelToolCalls, true),
+		"tool_choice":          request.ToolChoice,
+		"error":                nil,
+		"incomplete_details":   nil,
+		"usage":                nil,
+		"truncation":           "disabled",
+		"text":                 responsesTextConfig(request.Text),
+		"reasoning":            responsesReasoningConfig(chatRequest.ReasoningEffort),
 		"tools":                []any{},
 		"instructions":         nil,
-		"previous_response_id": nil,
+		"previous_response_id": previousResponseID,
+		"store":                request.StoreEnabled(),
+		"background":           request.Background,
 		"metadata":             map[string]any{},
 	}
 	// 只有用户传入 temperature/top_p 时才在响应中包含
@@ -750,10 +1045,62 @@ func (a *App) handleResponsesStream(
 	var responseText strings.Builder
 	toolStates := make(map[int]*openai.ResponsesFunctionCallState)
 
-	streamCtx, streamCancel := context.WithTimeout(context.Background(), a.cfg.RequestTimeout)
-	defer streamCancel()
+	// extended thinking：思考内容作为 reasoning 输出项（summary_text）流式发送，排在消息与工具调用之前
+	reasoningItemID := "rs_" + requestID
+	reasoningOutputIndex := -1
+	var reasoningText strings.Builder
+	openReasoningItem := func() error {
+		if reasoningOutputIndex >= 0 {
+			return nil
+		}
+		reasoningOutputIndex = nextOutputIndex
+		nextOutputIndex++
+		if err := emitEvent(map[string]any{
+			"type":         "response.output_item.added",
+			"output_index": reasoningOutputIndex,
+			"item": map[string]any{
+				"id":      reasoningItemID,
+				"type":    "reasoning",
+				"summary": []any{},
+			},
+		}); err != nil {
+			return err
+		}
+		return emitEvent(map[string]any{
+			"type":          "response.reasoning_summary_part.added",
+			"item_id":       reasoningItemID,
+			"output_index":  reasoningOutputIndex,
+			"summary_index": 0,
+			"part": map[string]any{
+				"type": "summary_text",
+				"text": "",
+			},
+		})
+	}
+
+	// response.created 已带上请求的模型；降级后 response.completed 等后续事件报告实际服务的模型
+	result, err := a.proxy.ConverseStreamWithFallback(ctx, chatRequest, modelIDs, guardrails, func(modelID string) {
+		modelName = modelID
+		baseResponse["model"] = modelID
+	}, func(delta bedrockproxy.StreamDelta) error {
+		if delta.ReasoningContent != "" || len(delta.ThinkingBlocks) > 0 {
+			if err := openReasoningItem(); err != nil {
+				return err
+			}
+		}
+		if delta.ReasoningContent != "" {
+			reasoningText.WriteString(delta.ReasoningContent)
+			if err := emitEvent(map[string]any{
+				"type":          "response.reasoning_summary_text.delta",
+				"item_id":       reasoningItemID,
+				"output_index":  reasoningOutputIndex,
+				"summary_index": 0,
+				"delta":         delta.ReasoningContent,
+			}); err != nil {
+				return err
+			}
+		}
 
-	result, err := a.proxy.ConverseStream(streamCtx, chatRequest, bedrockModelID, func(delta bedrockproxy.StreamDelta) error {
 		if delta.Text != "" {
 			if messageOutputIndex < 0 {
 				messageOutputIndex = nextOutputIndex
@@ -867,6 +1214,16 @@ func (a *App) handleResponsesStream(
 	if err != nil {
 		statusCode = http.StatusBadGateway
 		errorMessage := "bedrock stream failed: " + err.Error()
+		errorCode := "stream_error"
+		if bedrockproxy.IsRequestError(err) {
+			statusCode = http.StatusBadRequest
+			errorMessage = err.Error()
+			errorCode = "invalid_request"
+		} else if bedrockproxy.IsStructuredOutputError(err) {
+			statusCode = http.StatusUnprocessableEntity
+			errorMessage = err.Error()
+			errorCode = "invalid_structured_output"
+		}
 		if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
 			errorMessage += " (请求被取消：请检查 Cursor/代理是否过早断开，或调大环境变量 REQUEST_TIMEOUT_SECONDS)"
 		}
@@ -875,16 +1232,59 @@ func (a *App) handleResponsesStream(
 			"error": map[string]any{
 				"message": errorMessage,
 				"type":    "server_error",
-				"code":    "stream_error",
+				"code":    errorCode,
 			},
 		})
 		_ = writeSSEDone(w)
-		return bedrockproxy.ChatResult{Text: responseText.String()}, statusCode, errorMessage
+		result.Text = responseText.String()
+		return result, statusCode, errorMessage
 	}
 
 	result.Text = responseText.String()
 
 	// 发送完成事件
+	// 0. reasoning 项的完成事件（encrypted_content 携带签名思考块，客户端下一轮原样回传）
+	reasoningItem, hasReasoningItem := openai.BuildResponsesReasoningItem(requestID, result.ThinkingBlocks)
+	if reasoningOutputIndex >= 0 {
+		if !hasReasoningItem {
+			reasoningItem = openai.ResponsesOutputItem{ID: reasoningItemID, Type: "reasoning", Status: "completed"}
+		}
+		if reasoningItem.Summary == nil {
+			reasoningItem.Summary = []openai.ResponsesOutputContent{}
+		}
+		if err := emitEvent(map[string]any{
+			"type":          "response.reasoning_summary_text.done",
+			"item_id":       reasoningItemID,
+			"output_index":  reasoningOutputIndex,
+			"summary_index": 0,
+			"text":          reasoningText.String(),
+		}); err != nil {
+			statusCode = http.StatusBadGateway
+			return result, statusCode, err.Error()
+		}
+		if err := emitEvent(map[string]any{
+			"type":          "response.reasoning_summary_part.done",
+			"item_id":       reasoningItemID,
+			"output_index":  reasoningOutputIndex,
+			"summary_index": 0,
+			"part": map[string]any{
+				"type": "summary_text",
+				"text": reasoningText.String(),
+			},
+		}); err != nil {
+			statusCode = http.StatusBadGateway
+			return result, statusCode, err.Error()
+		}
+		if err := emitEvent(map[string]any{
+			"type":         "response.output_item.done",
+			"output_index": reasoningOutputIndex,
+			"item":         reasoningItem,
+		}); err != nil {
+			statusCode = http.StatusBadGateway
+			return result, statusCode, err.Error()
+		}
+	}
+
 	// 1. 消息项的完成事件
 	if messageOutputIndex >= 0 {
 		// response.output_text.done
@@ -966,7 +1366,10 @@ func (a *App) handleResponsesStream(
 	}
 
 	// 构建完成的 output 数组
-	completedOutput := make([]map[string]any, 0)
+	completedOutput := make([]any, 0)
+	if reasoningOutputIndex >= 0 {
+		completedOutput = append(completedOutput, reasoningItem)
+	}
 	if messageOutputIndex >= 0 {
 		completedOutput = append(completedOutput, map[string]any{
 			"id":     messageItemID,
@@ -994,37 +1397,32 @@ func (a *App) handleResponsesStream(
 	// response.completed
 	completedAt := time.Now().Unix()
 	completedResponse := map[string]any{
-		"id":                  responseID,
-		"object":              "response",
-		"created_at":          createdAt,
-		"completed_at":        completedAt,
-		"status":              "completed",
-		"model":               modelName,
-		"output":              completedOutput,
-		"parallel_tool_calls": boolOrDefault(request.ParallelToolCalls, true),
-		"tool_choice":         request.ToolChoice,
-		"error":               nil,
-		"incomplete_details":  nil,
-		"truncation":          "disabled",
-		"text": map[string]any{
-			"format": map[string]any{
-				"type": "text",
-			},
-		},
-		"reasoning": map[string]any{
-			"effort":  nil,
-			"summary": nil,
-		},
+		"id":                   responseID,
+		"object":               "response",
+		"created_at":           createdAt,
+		"completed_at":         completedAt,
+		"status":               "completed",
+		"model":                modelName,
+		"output":               completedOutput,
+		"parallel_tool_calls":  boolOrDefault(request.ParallelToolCalls, true),
+		"tool_choice":          request.ToolChoice,
+		"error":                nil,
+		"incomplete_details":   nil,
+		"truncation":           "disabled",
+		"text":                 responsesTextConfig(request.Text),
+		"reasoning":            responsesReasoningConfig(chatRequest.ReasoningEffort),
 		"tools":                []any{},
 		"instructions":         nil,
-		"previous_response_id": nil,
+		"previous_response_id": previousResponseID,
+		"store":                request.StoreEnabled(),
+		"background":           request.Background,
 		"metadata":             map[string]any{},
 		"usage": map[string]any{
-			"input_tokens":  result.InputTokens,
+			"input_tokens":  promptTokensWithCache(result),
 			"output_tokens": result.OutputTokens,
 			"total_tokens":  result.TotalTokens,
 			"input_tokens_details": map[string]any{
-				"cached_tokens": 0,
+				"cached_tokens": result.CacheReadInputTokens,
 			},
 			"output_tokens_details": map[string]any{
 				"reasoning_tokens": 0,
@@ -1038,6 +1436,15 @@ func (a *App) handleResponsesStream(
 	if request.TopP != nil {
 		completedResponse["top_p"] = *request.TopP
 	}
+	// 在发送 response.completed 之前保存，客户端收到完成事件后立即以该 ID 续接也能找到；
+	// 保存失败时不发送 response.completed，由调用方决定如何结束
+	if onCompleted != nil {
+		if err := onCompleted(responseID, result, completedOutput, completedResponse); err != nil {
+			statusCode = http.StatusInternalServerError
+			errorMessage := "store response failed: " + err.Error()
+			return result, statusCode, errorMessage
+		}
+	}
 	if err := emitEvent(map[string]any{
 		"type":     "response.completed",
 		"response": completedResponse,
@@ -1083,6 +1490,58 @@ func modelsForClient(catalog []string, client *auth.Client) []string {
 	return out
 }
 
+// describeBedrockError 返回 Bedrock 调用失败时的 HTTP 状态码与返回给客户端的错误信息：
+// 请求内容不合法（图片格式、tool 参数等）返回 400，其余返回 502。
+func describeBedrockError(err error) (int, string) {
+	if bedrockproxy.IsRequestError(err) {
+		return http.StatusBadRequest, err.Error()
+	}
+	if bedrockproxy.IsStructuredOutputError(err) {
+		// 模型已正常应答但输出不符合 response_format 的 schema，不属于上游故障
+		return http.StatusUnprocessableEntity, err.Error()
+	}
+	return http.StatusBadGateway, "bedrock call failed: " + err.Error()
+}
+
+// promptTokensWithCache 返回 OpenAI 语义的输入 token：Bedrock inputTokens 不含提示缓存读取 / 写入部分，这里加回去。
+func promptTokensWithCache(result bedrockproxy.ChatResult) int {
+	return result.InputTokens + result.CacheReadInputTokens + result.CacheWriteInputTokens
+}
+
+// buildChatUsage 构造 Chat Completions usage；命中提示缓存时通过 prompt_tokens_details.cached_tokens 标出。
+func buildChatUsage(result bedrockproxy.ChatResult) openai.Usage {
+	usage := openai.Usage{
+		PromptTokens:     promptTokensWithCache(result),
+		CompletionTokens: result.OutputTokens,
+		TotalTokens:      result.TotalTokens,
+	}
+	if result.CacheReadInputTokens > 0 || result.CacheWriteInputTokens > 0 {
+		usage.PromptTokensDetails = &openai.PromptTokensDetails{CachedTokens: result.CacheReadInputTokens}
+	}
+	return usage
+}
+
+func buildResponsesUsage(result bedrockproxy.ChatResult) openai.ResponsesUsage {
+	return openai.ResponsesUsage{
+		InputTokens:        promptTokensWithCache(result),
+		OutputTokens:       result.OutputTokens,
+		TotalTokens:        result.TotalTokens,
+		InputTokensDetails: openai.ResponsesInputTokensDetails{CachedTokens: result.CacheReadInputTokens},
+	}
+}
+
+// buildUsageChunk 构造 stream_options.include_usage 要求的结尾 chunk：choices 为空，仅携带 usage。
+func buildUsageChunk(chunkID string, createdAt int64, modelName string, usage *openai.Usage) openai.ChatCompletionChunk {
+	return openai.ChatCompletionChunk{
+		ID:      chunkID,
+		Object:  "chat.completion.chunk",
+		Created: createdAt,
+		Model:   modelName,
+		Choices: []openai.ChatChunkChoice{},
+		Usage:   usage,
+	}
+}
+
 func defaultFinishReason(reason string) string {
 	reason = strings.TrimSpace(reason)
 	if reason == "" {
@@ -1117,6 +1576,27 @@ func renderAssistantContentForLog(text string, toolCalls []openai.ToolCall) stri
 	return text + "\ntool_calls=" + string(payload)
 }
 
+// buildResponsesOutputItems 在消息 / 工具调用之前加上 reasoning 项（开启 extended thinking 时）。
+func buildResponsesOutputItems(requestID string, result bedrockproxy.ChatResult) []openai.ResponsesOutputItem {
+	items := openai.BuildResponsesOutputItems(requestID, result.Text, result.ToolCalls)
+	if reasoning, ok := openai.BuildResponsesReasoningItem(requestID, result.ThinkingBlocks); ok {
+		items = append([]openai.ResponsesOutputItem{reasoning}, items...)
+	}
+	return items
+}
+
+// responsesReasoningConfig 回显 reasoning 配置；effort 未设置时为 null。
+func responsesReasoningConfig(effort string) map[string]any {
+	var value any
+	if effort != "" {
+		value = effort
+	}
+	return map[string]any{
+		"effort":  value,
+		"summary": nil,
+	}
+}
+
 func renderResponsesOutputForLog(items []openai.ResponsesOutputItem) string {
 	if len(items) == 0 {
 		return ""
@@ -1128,6 +1608,15 @@ func renderResponsesOutputForLog(items []openai.ResponsesOutputItem) string {
 	return string(payload)
 }
 
+// responsesTextConfig 回显请求中的 text 配置（含 text.format），未设置时为默认的纯文本格式。
+func responsesTextConfig(raw json.RawMessage) json.RawMessage {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return json.RawMessage(`{"format":{"type":"text"}}`)
+	}
+	return raw
+}
+
 func boolOrDefault(value *bool, fallback bool) bool {
 	if value == nil {
 		return fallback
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+func TestChatCompletionsFallbackUsesServedModelGuardrail(t *testing.T) {
+	app := newResponsesTestApp(t)
+	app.cfg.RequestTimeout = time.Minute
+	converse := &bedrocktest.Client{ModelErrors: map[string]error{
+		"anthropic.primary": &smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient},
+	}}
+	app.proxy = bedrockproxy.NewService(converse, "anthropic.primary", nil, 1024, 1024, false, false)
+	app.modelFallbackState.chains = map[string][]string{"anthropic.primary": {"anthropic.backup"}}
+	app.guardrailState.byModel = map[string]*openai.GuardrailConfig{
+		"anthropic.primary": {Identifier: "gr-primary", Version: "1"},
+		"anthropic.backup":  {Identifier: "gr-backup", Version: "2"},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"anthropic.primary","messages":[{"role":"user","content":"ping"}]}`,
+	))
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder := httptest.NewRecorder()
+	app.handleChatCompletions(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("chat completion failed: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response openai.ChatCompletionResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if response.Model != "anthropic.backup" {
+		t.Fatalf("expected the fallback model to be reported, got %q", response.Model)
+	}
+	if modelIDs := converse.ModelIDs(); len(modelIDs) != 2 || modelIDs[1] != "anthropic.backup" {
+		t.Fatalf("unexpected models called: %v", modelIDs)
+	}
+	// 备选模型使用自己的 Guardrail，而不是主模型的
+	if guardrail := converse.LastInput().GuardrailConfig; guardrail == nil || awssdk.ToString(guardrail.GuardrailIdentifier) != "gr-backup" {
+		t.Fatalf("expected the fallback model's guardrail, got %#v", guardrail)
+	}
+}
+
+func TestChatCompletionsChoicesFallBack(t *testing.T) {
+	app := newResponsesTestApp(t)
+	app.cfg.RequestTimeout = time.Minute
+	converse := &bedrocktest.Client{ModelErrors: map[string]error{
+		"anthropic.primary": &smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient},
+	}}
+	app.proxy = bedrockproxy.NewService(converse, "anthropic.primary", nil, 1024, 1024, false, false)
+	app.modelFallbackState.chains = map[string][]string{"anthropic.primary": {"anthropic.backup"}}
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(
+		`{"model":"anthropic.primary","n":2,"messages":[{"role":"user","content":"ping"}]}`,
+	))
+	request.Header.Set("Authorization", "Bearer key-a")
+	recorder := httptest.NewRecorder()
+	app.handleChatCompletions(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("chat completion failed: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	var response openai.ChatCompletionResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	if response.Model != "anthropic.backup" || len(response.Choices) != 2 {
+		t.Fatalf("expected 2 choices served by the fallback model, got %q with %d choices", response.Model, len(response.Choices))
+	}
+	// 每个候选各自先尝试主模型，再降级到备选模型
+	served := map[string]int{}
+	for _, modelID := range converse.ModelIDs() {
+		served[modelID]++
+	}
+	if served["anthropic.primary"] != 2 || served["anthropic.backup"] != 2 {
+		t.Fatalf("unexpected models called: %v", converse.ModelIDs())
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+)
+
+const (
+	responsesPathPrefix             = "/v1/responses/"
+	defaultResponsesInputItemsLimit = 20
+	maxResponsesInputItemsLimit     = 100
+)
+
+// handleResponseByID 处理已保存响应的查询类接口，只能访问当前 API key 自己保存的响应：
+// GET / DELETE /v1/responses/{id}，GET /v1/responses/{id}/input_items，POST /v1/responses/{id}/cancel。
+// GET 带 stream=true 时重新挂接后台响应的事件流（starting_after 为已收到的最后一个 sequence_number）。
+func (a *App) handleResponseByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, responsesPathPrefix), "/")
+	responseID, action, _ := strings.Cut(rest, "/")
+	if responseID == "" || (action != "" && action != "input_items" && action != "cancel") {
+		writeOpenAIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if action == "input_items" && r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if action == "cancel" && r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if action == "" && r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := a.auth.Authenticate(r)
+	if err != nil {
+		writeOpenAIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !client.AllowRequest() {
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if job := a.background.lookup(client.ID, responseID); job != nil {
+			a.cancelBackgroundResponse(job)
+		}
+		deleted, err := a.store.DeleteResponse(r.Context(), client.ID, responseID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !deleted {
+			writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("response not found: %s", responseID))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      responseID,
+			"object":  "response",
+			"deleted": true,
+		})
+		return
+	}
+
+	if action == "cancel" {
+		if job := a.background.lookup(client.ID, responseID); job != nil {
+			if response, ok := a.cancelBackgroundResponse(job); ok {
+				writeJSON(w, http.StatusOK, response)
+				return
+			}
+		}
+		// 已结束（或非后台）的响应不做修改，按 OpenAI 行为返回当前对象
+	}
+
+	if action == "" && strings.EqualFold(r.URL.Query().Get("stream"), "true") {
+		job := a.background.lookup(client.ID, responseID)
+		if job == nil {
+			writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("response %s has no background event stream to attach to", responseID))
+			return
+		}
+		startingAfter, err := parseStartingAfter(r.URL.Query().Get("starting_after"))
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.streamBackgroundResponse(w, r.Context(), job, startingAfter)
+		return
+	}
+
+	record, ok, err := a.store.GetResponse(r.Context(), client.ID, responseID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("response not found: %s", responseID))
+		return
+	}
+
+	if action == "" || action == "cancel" {
+		// 保存的就是创建时返回给客户端的 response 对象（流式时为 response.completed 中的对象）
+		writeJSON(w, http.StatusOK, json.RawMessage(record.ResponseJSON))
+		return
+	}
+
+	var rawItems []json.RawMessage
+	if err := json.Unmarshal([]byte(record.InputItemsJSON), &rawItems); err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "decode stored input items failed: "+err.Error())
+		return
+	}
+	query := r.URL.Query()
+	order := strings.ToLower(strings.TrimSpace(query.Get("order")))
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		writeOpenAIError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+	list, err := paginateResponsesInputItems(
+		openai.BuildResponsesInputItems(record.ResponseID, rawItems),
+		order,
+		strings.TrimSpace(query.Get("after")),
+		parseLimit(query.Get("limit"), defaultResponsesInputItemsLimit, maxResponsesInputItemsLimit),
+	)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// parseStartingAfter 解析重新挂接事件流时的 starting_after（已收到的最后一个 sequence_number）。
+func parseStartingAfter(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("starting_after must be a non-negative integer")
+	}
+	return value, nil
+}
+
+// paginateResponsesInputItems 按 order 排列后，从游标 after（上一页最后一项的 id）之后取最多 limit 项。
+func paginateResponsesInputItems(items []map[string]any, order, after string, limit int) (openai.ResponsesInputItemList, error) {
+	if order == "desc" {
+		reversed := make([]map[string]any, 0, len(items))
+		for index := len(items) - 1; index >= 0; index-- {
+			reversed = append(reversed, items[index])
+		}
+		items = reversed
+	}
+
+	start := 0
+	if after != "" {
+		start = -1
+		for index, item := range items {
+			if id, _ := item["id"].(string); id == after {
+				start = index + 1
+				break
+			}
+		}
+		if start < 0 {
+			return openai.ResponsesInputItemList{}, fmt.Errorf("input item not found: %s", after)
+		}
+	}
+
+	end := min(start+limit, len(items))
+	page := items[start:end]
+	list := openai.ResponsesInputItemList{
+		Object:  "list",
+		Data:    page,
+		HasMore: end < len(items),
+	}
+	if len(page) > 0 {
+		firstID, _ := page[0]["id"].(string)
+		lastID, _ := page[len(page)-1]["id"].(string)
+		list.FirstID = &firstID
+		list.LastID = &lastID
+	}
+	return list, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/config"
+	"aws-cursor-router/internal/openai"
+	"aws-cursor-router/internal/store"
+)
+
+func newResponsesTestApp(t *testing.T) *App {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	manager := auth.NewManager(config.Config{})
+	for _, client := range []config.ClientConfig{
+		{ID: "team-a", Name: "Team A", APIKey: "key-a", MaxRequestsPerMinute: 100, MaxConcurrent: 4},
+		{ID: "team-b", Name: "Team B", APIKey: "key-b", MaxRequestsPerMinute: 100, MaxConcurrent: 4},
+	} {
+		if err := manager.UpsertClient(client); err != nil {
+			t.Fatalf("upsert client failed: %v", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := s.SaveResponse(context.Background(), store.ResponseRecord{
+		ResponseID:      "resp_1",
+		ClientID:        "team-a",
+		Model:           "anthropic.model",
+		InputItemsJSON:  `[{"role":"user","content":"a"},{"type":"function_call_output","call_id":"c1","output":"b"},{"type":"message","role":"user","content":"c"}]`,
+		OutputItemsJSON: `[]`,
+		MessagesJSON:    `[]`,
+		ResponseJSON:    `{"id":"resp_1","object":"response","status":"completed"}`,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("save response failed: %v", err)
+	}
+	return &App{
+		cfg:        config.Config{ResponsesStoreTTL: time.Hour},
+		auth:       manager,
+		store:      s,
+		logger:     log.New(io.Discard, "", 0),
+		background: newBackgroundManager(4),
+	}
+}
+
+func serveResponses(app *App, method, target, apiKey string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	request.Header.Set("Authorization", "Bearer "+apiKey)
+	recorder := httptest.NewRecorder()
+	app.handleResponseByID(recorder, request)
+	return recorder
+}
+
+func TestResponsesRetrieveScopedToOwner(t *testing.T) {
+	app := newResponsesTestApp(t)
+
+	recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_1", "key-a")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var response map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response["id"] != "resp_1" {
+		t.Fatalf("unexpected response body: %s", recorder.Body.String())
+	}
+
+	if recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_1", "key-b"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another api key, got %d", recorder.Code)
+	}
+	if recorder := serveResponses(app, http.MethodDelete, "/v1/responses/resp_1", "key-b"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected delete by another api key to fail, got %d", recorder.Code)
+	}
+	if recorder := serveResponses(app, http.MethodDelete, "/v1/responses/resp_1", "key-a"); recorder.Code != http.StatusOK {
+		t.Fatalf("expected delete to succeed, got %d", recorder.Code)
+	}
+	if recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_1", "key-a"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", recorder.Code)
+	}
+}
+
+func TestResponsesInputItemsPagination(t *testing.T) {
+	app := newResponsesTestApp(t)
+
+	recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_1/input_items?order=asc&limit=2", "key-a")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var page struct {
+		Data    []map[string]any `json:"data"`
+		LastID  string           `json:"last_id"`
+		HasMore bool             `json:"has_more"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode page failed: %v", err)
+	}
+	if len(page.Data) != 2 || !page.HasMore || page.LastID != "resp_1_in_1" {
+		t.Fatalf("unexpected first page: %s", recorder.Body.String())
+	}
+	if page.Data[0]["type"] != "message" || page.Data[0]["status"] != "completed" {
+		t.Fatalf("expected normalized message item, got %#v", page.Data[0])
+	}
+
+	recorder = serveResponses(app, http.MethodGet, "/v1/responses/resp_1/input_items?order=asc&limit=2&after="+page.LastID, "key-a")
+	page.Data = nil
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode page failed: %v", err)
+	}
+	if len(page.Data) != 1 || page.HasMore || page.Data[0]["id"] != "resp_1_in_2" {
+		t.Fatalf("unexpected second page: %s", recorder.Body.String())
+	}
+
+	recorder = serveResponses(app, http.MethodGet, "/v1/responses/resp_1/input_items", "key-a")
+	page.Data = nil
+	_ = json.Unmarshal(recorder.Body.Bytes(), &page)
+	if len(page.Data) != 3 || page.Data[0]["id"] != "resp_1_in_2" {
+		t.Fatalf("expected newest item first by default: %s", recorder.Body.String())
+	}
+}
+
+func submitTestBackgroundResponse(t *testing.T, app *App, requestID string) *backgroundJob {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	request.Header.Set("Authorization", "Bearer key-a")
+	client, err := app.auth.Authenticate(request)
+	if err != nil {
+		t.Fatalf("authenticate failed: %v", err)
+	}
+	job, err := app.submitBackgroundResponse(
+		client,
+		openai.ResponsesCreateRequest{Model: "anthropic.model", Input: json.RawMessage(`"hi"`), Background: true},
+		openai.ChatCompletionRequest{Model: "anthropic.model", Messages: []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}}},
+		requestID,
+		"resp_"+requestID,
+		"anthropic.model",
+		"anthropic.model",
+	)
+	if err != nil {
+		t.Fatalf("submit background response failed: %v", err)
+	}
+	return job
+}
+
+func TestBackgroundResponseCancelWhileQueued(t *testing.T) {
+	app := newResponsesTestApp(t)
+	job := submitTestBackgroundResponse(t, app, "bg1")
+
+	recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_bg1", "key-a")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"queued"`) {
+		t.Fatalf("expected queued response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if _, err := app.loadResponsesHistory(context.Background(), "team-a", "resp_bg1"); !errors.Is(err, errPreviousResponseNotReady) {
+		t.Fatalf("expected queued response to be rejected as previous_response_id, got %v", err)
+	}
+
+	if recorder := serveResponses(app, http.MethodPost, "/v1/responses/resp_bg1/cancel", "key-b"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected cancel by another api key to fail, got %d", recorder.Code)
+	}
+	recorder = serveResponses(app, http.MethodPost, "/v1/responses/resp_bg1/cancel", "key-a")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"cancelled"`) {
+		t.Fatalf("expected cancelled response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if job.ctx.Err() == nil {
+		t.Fatalf("expected job context to be cancelled")
+	}
+	if events, _, _ := job.snapshot(0); len(events) != 1 || !strings.Contains(string(events[0]), `"type":"response.cancelled"`) {
+		t.Fatalf("expected a response.cancelled event, got %q", events)
+	}
+
+	// 已取消的任务不会再被 worker 执行，再次取消返回保存的对象
+	app.runBackgroundResponse(<-app.background.queue)
+	recorder = serveResponses(app, http.MethodPost, "/v1/responses/resp_bg1/cancel", "key-a")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"cancelled"`) {
+		t.Fatalf("expected stored cancelled response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestBackgroundResponseStreamReattach(t *testing.T) {
+	app := newResponsesTestApp(t)
+	job := submitTestBackgroundResponse(t, app, "bg2")
+
+	writer := &backgroundEventWriter{job: job, header: make(http.Header)}
+	for seq := 1; seq <= 3; seq++ {
+		if err := writeSSEData(writer, map[string]any{"type": "response.output_text.delta", "sequence_number": seq}); err != nil {
+			t.Fatalf("write event failed: %v", err)
+		}
+	}
+	if err := writeSSEDone(writer); err != nil {
+		t.Fatalf("write done failed: %v", err)
+	}
+	app.background.finish(job)
+	if err := writeSSEData(writer, map[string]any{"type": "late"}); err == nil {
+		t.Fatalf("expected writes after the job finished to fail")
+	}
+
+	recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_bg2?stream=true&starting_after=1", "key-a")
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected stream response: %d %s", recorder.Code, body)
+	}
+	if strings.Contains(body, `"sequence_number":1}`) || strings.Count(body, "data: {") != 2 || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected replayed events: %q", body)
+	}
+
+	if recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_1?stream=true", "key-a"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a response without background stream, got %d", recorder.Code)
+	}
+}
+
+func TestBackgroundResponseCancelAbortsBedrockCall(t *testing.T) {
+	app := newResponsesTestApp(t)
+	// 后台任务不受 REQUEST_TIMEOUT 限制，只受 BACKGROUND_TIMEOUT 与 cancel 控制
+	app.cfg.RequestTimeout = time.Millisecond
+	app.cfg.BackgroundTimeout = time.Minute
+	app.cfg.MaxContentChars = 1000
+	started := make(chan struct{})
+	converse := &bedrocktest.Client{Hook: func(ctx context.Context, modelID string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	app.proxy = bedrockproxy.NewService(converse, "", nil, 0, 0, false, false)
+
+	job := submitTestBackgroundResponse(t, app, "bg3")
+	finished := make(chan struct{})
+	go func() {
+		app.runBackgroundResponse(<-app.background.queue)
+		close(finished)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("background job did not call bedrock")
+	}
+	select {
+	case <-finished:
+		t.Fatalf("background job stopped before it was cancelled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	recorder := serveResponses(app, http.MethodPost, "/v1/responses/resp_bg3/cancel", "key-a")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"cancelled"`) {
+		t.Fatalf("expected cancelled response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cancel did not abort the in-flight bedrock call")
+	}
+	events, done, _ := job.snapshot(0)
+	if !done {
+		t.Fatalf("expected the job event stream to be finished")
+	}
+	// 事件流以 response.cancelled 结束，重新挂接的客户端可以看到终止状态
+	if len(events) == 0 {
+		t.Fatalf("expected a terminal event")
+	}
+	last := string(events[len(events)-1])
+	if !strings.Contains(last, `"type":"response.cancelled"`) || !strings.Contains(last, `"status":"cancelled"`) {
+		t.Fatalf("expected the event stream to end with response.cancelled, got %q", last)
+	}
+}
+
+func TestBackgroundResponseStoreFailureIsNotCompleted(t *testing.T) {
+	app := newResponsesTestApp(t)
+	app.cfg.ResponsesStoreMaxBytes = 16
+	job := submitTestBackgroundResponse(t, app, "bg4")
+	<-app.background.queue
+	job.mu.Lock()
+	app.saveBackgroundStatusLocked(job, "in_progress", nil)
+	job.mu.Unlock()
+
+	result := bedrockproxy.ChatResult{Text: "hello", FinishReason: "stop", ModelID: "anthropic.model"}
+	err := app.completeBackgroundResponse(job, result, buildResponsesOutputItems("bg4", result), job.responseObject("completed", nil))
+	if err == nil || !strings.Contains(err.Error(), "RESPONSES_STORE_MAX_BYTES") {
+		t.Fatalf("expected oversized response to fail to store, got %v", err)
+	}
+	if job.status != "in_progress" {
+		t.Fatalf("expected job to stay in_progress until the result is stored, got %s", job.status)
+	}
+
+	// runBackgroundResponse 在 handleResponsesStream 返回错误后把任务标记为 failed
+	app.failBackgroundResponse(job, "server_error", "store response failed: "+err.Error())
+	recorder := serveResponses(app, http.MethodGet, "/v1/responses/resp_bg4", "key-a")
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, `"status":"failed"`) || !strings.Contains(body, "RESPONSES_STORE_MAX_BYTES") {
+		t.Fatalf("expected failed response with reason, got %d %s", recorder.Code, body)
+	}
+	if events, _, _ := job.snapshot(0); len(events) != 1 || !strings.Contains(string(events[0]), `"type":"response.failed"`) {
+		t.Fatalf("expected a response.failed event, got %q", events)
+	}
+
+	app.cfg.ResponsesStoreMaxBytes = 1 << 20
+	job = submitTestBackgroundResponse(t, app, "bg5")
+	<-app.background.queue
+	job.mu.Lock()
+	app.saveBackgroundStatusLocked(job, "in_progress", nil)
+	job.mu.Unlock()
+	if err := app.completeBackgroundResponse(job, result, buildResponsesOutputItems("bg5", result), job.responseObject("completed", nil)); err != nil {
+		t.Fatalf("complete background response failed: %v", err)
+	}
+	if job.status != "completed" {
+		t.Fatalf("expected completed job, got %s", job.status)
+	}
+	recorder = serveResponses(app, http.MethodGet, "/v1/responses/resp_bg5", "key-a")
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"completed"`) {
+		t.Fatalf("expected completed response, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"strings"
 	"sync"
 
+	"aws-cursor-router/internal/auth"
+	"aws-cursor-router/internal/bedrockproxy"
+	"aws-cursor-router/internal/openai"
 	"aws-cursor-router/internal/store"
 	"github.com/aws/aws-sdk-go-v2/service/bedrock"
 )
@@ -23,6 +26,9 @@ type awsState struct {
 	cfg             store.AWSRuntimeConfig
 	controlClient   foundationModelLister
 	availableModels []string
+	// 主账号（/config/aws）的 runtime 客户端，与额外上游一起组成 upstreamPool
+	runtimeClient bedrockproxy.ConverseAPI
+	upstreamPool  *bedrockproxy.UpstreamPool
 }
 
 type modelState struct {
@@ -31,6 +37,19 @@ type modelState struct {
 	enabledSet      map[string]struct{}
 }
 
+type guardrailState struct {
+	mu       sync.RWMutex
+	byClient map[string]*openai.GuardrailConfig
+	// 按模型 ID（小写）
+	byModel map[string]*openai.GuardrailConfig
+}
+
+type modelFallbackState struct {
+	mu sync.RWMutex
+	// 主模型 ID（小写）→ 按顺序尝试的备选模型
+	chains map[string][]string
+}
+
 type adminTokenState struct {
 	mu    sync.RWMutex
 	token string
@@ -50,6 +69,19 @@ func (a *App) setAvailableModels(availableModels []string) {
 	a.awsState.mu.Unlock()
 }
 
+func (a *App) setRuntimeClient(client bedrockproxy.ConverseAPI) {
+	a.awsState.mu.Lock()
+	a.awsState.runtimeClient = client
+	a.awsState.mu.Unlock()
+}
+
+func (a *App) getUpstreamPool() *bedrockproxy.UpstreamPool {
+	a.awsState.mu.RLock()
+	pool := a.awsState.upstreamPool
+	a.awsState.mu.RUnlock()
+	return pool
+}
+
 func (a *App) getAWSConfig() store.AWSRuntimeConfig {
 	a.awsState.mu.RLock()
 	cfg := a.awsState.cfg
@@ -133,6 +165,157 @@ func (a *App) listCatalogModels() []string {
 	return []string{fallback}
 }
 
+// listModelsForClient 返回 /v1/models（及 Ollama /api/tags）中对该 API key 可见的模型：目录中允许的模型，
+// 加上目标模型已启用、且别名或目标在 API key 白名单中的别名。
+func (a *App) listModelsForClient(client *auth.Client) []string {
+	models := modelsForClient(a.listCatalogModels(), client)
+	for alias, target := range a.proxy.ListModelMappings() {
+		if !a.isModelEnabled(target) {
+			continue
+		}
+		if client != nil && !client.IsModelAllowed(alias, target) {
+			continue
+		}
+		models = append(models, alias)
+	}
+	return normalizeModelIDs(models)
+}
+
+func (a *App) reloadAllowedRequestFields(ctx context.Context) error {
+	fields, err := a.store.ListAllowedRequestFields(ctx)
+	if err != nil {
+		return err
+	}
+	a.proxy.SetAllowedRequestFields(fields)
+	return nil
+}
+
+func (a *App) reloadPromptCache(ctx context.Context) error {
+	rows, err := a.store.ListPromptCacheModels(ctx)
+	if err != nil {
+		return err
+	}
+	policies := make(map[string]bedrockproxy.PromptCachePolicy, len(rows))
+	for _, row := range rows {
+		policies[row.ModelID] = bedrockproxy.PromptCachePolicy{
+			System:   row.CacheSystem,
+			Tools:    row.CacheTools,
+			Messages: row.CacheMessages,
+		}
+	}
+	a.proxy.SetPromptCachePolicies(policies)
+	return nil
+}
+
+// reloadModelAliases 从 admin_model_mappings 加载模型别名，整体替换代理中的别名表。
+func (a *App) reloadModelAliases(ctx context.Context) error {
+	mappings, err := a.store.ListModelMappings(ctx)
+	if err != nil {
+		return err
+	}
+	a.proxy.ReplaceModelRouter(mappings)
+	return nil
+}
+
+func (a *App) reloadGuardrails(ctx context.Context) error {
+	rows, err := a.store.ListGuardrails(ctx)
+	if err != nil {
+		return err
+	}
+	byClient := make(map[string]*openai.GuardrailConfig)
+	byModel := make(map[string]*openai.GuardrailConfig)
+	for _, row := range rows {
+		guardrail := &openai.GuardrailConfig{
+			Identifier:           row.GuardrailIdentifier,
+			Version:              row.GuardrailVersion,
+			StreamProcessingMode: row.StreamProcessingMode,
+		}
+		if row.ClientID != "" {
+			byClient[row.ClientID] = guardrail
+		} else {
+			byModel[strings.ToLower(row.ModelID)] = guardrail
+		}
+	}
+
+	a.guardrailState.mu.Lock()
+	a.guardrailState.byClient = byClient
+	a.guardrailState.byModel = byModel
+	a.guardrailState.mu.Unlock()
+	return nil
+}
+
+// guardrailFor 返回请求应使用的 Guardrail：API key 的配置优先，其次按 Bedrock 模型 ID；都没有时返回 nil。
+func (a *App) guardrailFor(clientID, bedrockModelID string) *openai.GuardrailConfig {
+	a.guardrailState.mu.RLock()
+	defer a.guardrailState.mu.RUnlock()
+	if guardrail, ok := a.guardrailState.byClient[clientID]; ok {
+		return guardrail
+	}
+	return a.guardrailState.byModel[strings.ToLower(strings.TrimSpace(bedrockModelID))]
+}
+
+// guardrailResolver 返回按模型取该 API key Guardrail 的函数，降级链中的每个模型各自取一次。
+func (a *App) guardrailResolver(clientID string) bedrockproxy.GuardrailResolver {
+	return func(modelID string) *openai.GuardrailConfig {
+		return a.guardrailFor(clientID, modelID)
+	}
+}
+
+// recordServedModel 把调用日志的 Bedrock 模型与 Guardrail 改为实际服务的模型（可能是降级链中的备选模型），
+// 用量也因此按该模型汇总、计价。modelID 为空（未调用 Bedrock）时保持不变。
+func (a *App) recordServedModel(record *store.CallRecord, clientID, modelID string) {
+	if modelID == "" {
+		return
+	}
+	record.BedrockModelID = modelID
+	record.GuardrailID = ""
+	if guardrail := a.guardrailFor(clientID, modelID); guardrail != nil {
+		record.GuardrailID = guardrail.Identifier
+	}
+}
+
+func (a *App) reloadModelFallbacks(ctx context.Context) error {
+	rows, err := a.store.ListModelFallbacks(ctx)
+	if err != nil {
+		return err
+	}
+	chains := make(map[string][]string, len(rows))
+	for _, row := range rows {
+		chains[strings.ToLower(row.ModelID)] = row.FallbackModels
+	}
+
+	a.modelFallbackState.mu.Lock()
+	a.modelFallbackState.chains = chains
+	a.modelFallbackState.mu.Unlock()
+	return nil
+}
+
+// modelFallbackChain 返回一次请求依次尝试的 Bedrock 模型：bedrockModelID 本身，加上管理员为它配置的降级链。
+// 降级链中的别名解析为目标模型；未启用或该 API key 无权使用的备选模型被跳过。
+func (a *App) modelFallbackChain(client *auth.Client, bedrockModelID string) []string {
+	a.modelFallbackState.mu.RLock()
+	fallbacks := a.modelFallbackState.chains[strings.ToLower(strings.TrimSpace(bedrockModelID))]
+	a.modelFallbackState.mu.RUnlock()
+
+	chain := []string{bedrockModelID}
+	seen := map[string]struct{}{strings.ToLower(bedrockModelID): {}}
+	for _, model := range fallbacks {
+		modelID := model
+		if target, ok := a.proxy.AliasTarget(model); ok {
+			modelID = target
+		}
+		if _, ok := seen[strings.ToLower(modelID)]; ok {
+			continue
+		}
+		if !a.isModelEnabled(modelID) || !client.IsModelAllowed(model, modelID) {
+			continue
+		}
+		seen[strings.ToLower(modelID)] = struct{}{}
+		chain = append(chain, modelID)
+	}
+	return chain
+}
+
 func (a *App) setAdminToken(adminToken string) {
 	a.adminTokenState.mu.Lock()
 	a.adminTokenState.token = strings.TrimSpace(adminToken)
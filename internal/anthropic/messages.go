@@ -0,0 +1,340 @@
+// Package anthropic 实现 Anthropic Messages API（/v1/messages）与内部 OpenAI Chat 请求之间的转换。
+//
+// 转换后的请求仍交给 bedrockproxy.Service 处理：
+//   - system（字符串或 text block 数组）映射为 role=system 消息；
+//   - messages[].content 原样保留，tool_use / tool_result / image block 由 BuildBedrockMessages 的内联解析处理；
+//   - tools / tool_choice 映射为 OpenAI function tools。
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+)
+
+type MessagesRequest struct {
+	Model         string          `json:"model"`
+	MaxTokens     int             `json:"max_tokens"`
+	System        json.RawMessage `json:"system,omitempty"`
+	Messages      []Message       `json:"messages"`
+	Tools         []Tool          `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice     `json:"tool_choice,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
+	TopK          *int            `json:"top_k,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	Metadata      *Metadata       `json:"metadata,omitempty"`
+}
+
+type Message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type Tool struct {
+	Type        string          `json:"type,omitempty"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+}
+
+type ToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse *bool  `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type Metadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+type MessagesResponse struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Model        string         `json:"model"`
+	Content      []ContentBlock `json:"content"`
+	StopReason   string         `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        Usage          `json:"usage"`
+}
+
+// CountTokensResponse 是 /v1/messages/count_tokens 的响应；context_window 与 estimated 为本代理的扩展字段。
+type CountTokensResponse struct {
+	InputTokens   int  `json:"input_tokens"`
+	ContextWindow *int `json:"context_window,omitempty"`
+	Estimated     bool `json:"estimated"`
+}
+
+type ContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// 提示缓存写入 / 命中的 token 数，与 input_tokens 互不包含（与 Anthropic API 一致）
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+type ErrorResponse struct {
+	Type  string       `json:"type"`
+	Error ErrorPayload `json:"error"`
+}
+
+type ErrorPayload struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func ValidateMessagesRequest(request MessagesRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	if request.MaxTokens <= 0 {
+		return errors.New("max_tokens must be greater than 0")
+	}
+	return validateMessages(request.Messages)
+}
+
+// ValidateCountTokensRequest 校验 /v1/messages/count_tokens 请求，与 Messages 相同但不要求 max_tokens。
+func ValidateCountTokensRequest(request MessagesRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	return validateMessages(request.Messages)
+}
+
+func validateMessages(messages []Message) error {
+	if len(messages) == 0 {
+		return errors.New("messages cannot be empty")
+	}
+	for index, message := range messages {
+		role := strings.ToLower(strings.TrimSpace(message.Role))
+		if role != "user" && role != "assistant" {
+			return fmt.Errorf("messages.%d.role must be user or assistant", index)
+		}
+	}
+	return nil
+}
+
+// MessagesRequestToChat 将 Anthropic Messages 请求转为内部使用的 OpenAI Chat 请求。
+func MessagesRequestToChat(request MessagesRequest) (openai.ChatCompletionRequest, error) {
+	messages := make([]openai.ChatMessage, 0, len(request.Messages)+1)
+
+	system := strings.TrimSpace(string(request.System))
+	if system != "" && system != "null" {
+		messages = append(messages, openai.ChatMessage{
+			Role:    "system",
+			Content: request.System,
+		})
+	}
+
+	for _, message := range request.Messages {
+		content := message.Content
+		if len(strings.TrimSpace(string(content))) == 0 {
+			content = json.RawMessage(`""`)
+		}
+		messages = append(messages, openai.ChatMessage{
+			Role:    strings.ToLower(strings.TrimSpace(message.Role)),
+			Content: content,
+		})
+	}
+
+	tools, err := normalizeTools(request.Tools)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	toolChoice, parallelToolCalls, err := normalizeToolChoice(request.ToolChoice)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+
+	var stop json.RawMessage
+	if len(request.StopSequences) > 0 {
+		blob, err := json.Marshal(request.StopSequences)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, err
+		}
+		stop = blob
+	}
+
+	maxTokens := request.MaxTokens
+	chatRequest := openai.ChatCompletionRequest{
+		Model:             strings.TrimSpace(request.Model),
+		Messages:          messages,
+		Temperature:       request.Temperature,
+		TopP:              request.TopP,
+		MaxTokens:         &maxTokens,
+		Stream:            request.Stream,
+		Tools:             tools,
+		ToolChoice:        toolChoice,
+		ParallelToolCalls: parallelToolCalls,
+		Stop:              stop,
+		TopK:              request.TopK,
+	}
+	if request.Metadata != nil {
+		chatRequest.User = strings.TrimSpace(request.Metadata.UserID)
+	}
+	return chatRequest, nil
+}
+
+func normalizeTools(tools []Tool) ([]openai.Tool, error) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+
+	out := make([]openai.Tool, 0, len(tools))
+	for _, item := range tools {
+		toolType := strings.ToLower(strings.TrimSpace(item.Type))
+		// 服务端内置工具（web_search_*、bash_* 等）没有 input_schema，Bedrock 无法执行，直接跳过。
+		if toolType != "" && toolType != "custom" && len(item.InputSchema) == 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			return nil, errors.New("tool name is required")
+		}
+		out = append(out, openai.Tool{
+			Type: "function",
+			Function: &openai.ToolFunction{
+				Name:        name,
+				Description: strings.TrimSpace(item.Description),
+				Parameters:  item.InputSchema,
+			},
+		})
+	}
+	return out, nil
+}
+
+func normalizeToolChoice(choice *ToolChoice) (json.RawMessage, *bool, error) {
+	if choice == nil {
+		return nil, nil, nil
+	}
+
+	var parallelToolCalls *bool
+	if choice.DisableParallelToolUse != nil {
+		parallel := !*choice.DisableParallelToolUse
+		parallelToolCalls = &parallel
+	}
+
+	switch strings.ToLower(strings.TrimSpace(choice.Type)) {
+	case "", "auto":
+		return json.RawMessage(`"auto"`), parallelToolCalls, nil
+	case "any":
+		return json.RawMessage(`"required"`), parallelToolCalls, nil
+	case "none":
+		return json.RawMessage(`"none"`), parallelToolCalls, nil
+	case "tool":
+		name := strings.TrimSpace(choice.Name)
+		if name == "" {
+			return nil, nil, errors.New("tool_choice.name is required when type is tool")
+		}
+		blob, err := json.Marshal(map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": name},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return blob, parallelToolCalls, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported tool_choice type: %s", choice.Type)
+	}
+}
+
+// BuildContentBlocks 将模型输出（文本 + tool_calls）转为 Anthropic content blocks。
+func BuildContentBlocks(text string, toolCalls []openai.ToolCall) []ContentBlock {
+	blocks := make([]ContentBlock, 0, 1+len(toolCalls))
+	if text != "" {
+		blocks = append(blocks, ContentBlock{Type: "text", Text: text})
+	}
+	for _, toolCall := range toolCalls {
+		blocks = append(blocks, ContentBlock{
+			Type:  "tool_use",
+			ID:    toolCall.ID,
+			Name:  toolCall.Function.Name,
+			Input: ToolInputJSON(toolCall.Function.Arguments),
+		})
+	}
+	return blocks
+}
+
+// ToolInputJSON 把 tool_call arguments 字符串转成 tool_use.input 对象；无法解析时包装为 {"value": ...}。
+func ToolInputJSON(arguments string) json.RawMessage {
+	arguments = strings.TrimSpace(arguments)
+	if arguments == "" {
+		return json.RawMessage(`{}`)
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(arguments), &value); err != nil {
+		blob, _ := json.Marshal(map[string]string{"value": arguments})
+		return blob
+	}
+	if _, ok := value.(map[string]any); !ok {
+		blob, _ := json.Marshal(map[string]any{"value": value})
+		return blob
+	}
+	return json.RawMessage(arguments)
+}
+
+// StopReasonFromFinishReason 将 OpenAI finish_reason 映射为 Anthropic stop_reason。
+func StopReasonFromFinishReason(finishReason string) string {
+	switch strings.TrimSpace(finishReason) {
+	case "tool_calls":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	case "content_filter":
+		// Bedrock Guardrail 介入
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+// StopDetails 返回 stop_reason 与 stop_sequence。
+// Bedrock 只报告 stopReason=stop_sequence 而不返回命中的序列，因此仅当请求只配置了一个 stop sequence 时才能给出其值。
+func StopDetails(finishReason string, bedrockStopReason string, stopSequences []string) (string, *string) {
+	if bedrockStopReason != "stop_sequence" {
+		return StopReasonFromFinishReason(finishReason), nil
+	}
+	if len(stopSequences) == 1 {
+		matched := stopSequences[0]
+		return "stop_sequence", &matched
+	}
+	return "stop_sequence", nil
+}
+
+// ErrorTypeForStatus 返回 HTTP 状态码对应的 Anthropic error.type。
+func ErrorTypeForStatus(status int) string {
+	switch {
+	case status == 401:
+		return "authentication_error"
+	case status == 403:
+		return "permission_error"
+	case status == 404:
+		return "not_found_error"
+	case status == 413:
+		return "request_too_large"
+	case status == 429:
+		return "rate_limit_error"
+	case status == 503 || status == 529:
+		return "overloaded_error"
+	case status >= 500:
+		return "api_error"
+	default:
+		return "invalid_request_error"
+	}
+}
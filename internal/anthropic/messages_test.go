@@ -0,0 +1,133 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"aws-cursor-router/internal/openai"
+)
+
+func TestMessagesRequestToChat(t *testing.T) {
+	temperature := 0.3
+	topK := 20
+	disableParallel := true
+	request := MessagesRequest{
+		Model:     "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		MaxTokens: 1024,
+		System:    json.RawMessage(`[{"type":"text","text":"be concise"}]`),
+		Messages: []Message{
+			{Role: "user", Content: json.RawMessage(`"list files"`)},
+			{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_use","id":"toolu_1","name":"ls","input":{"path":"."}}]`)},
+			{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_use_id":"toolu_1","content":"a.txt"}]`)},
+		},
+		Tools: []Tool{
+			{Name: "ls", Description: "List files", InputSchema: json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}}}`)},
+			{Type: "web_search_20250305", Name: "web_search"},
+		},
+		ToolChoice:    &ToolChoice{Type: "tool", Name: "ls", DisableParallelToolUse: &disableParallel},
+		Temperature:   &temperature,
+		TopK:          &topK,
+		StopSequences: []string{"\n\nHuman:"},
+		Stream:        true,
+		Metadata:      &Metadata{UserID: "user-1"},
+	}
+
+	chatRequest, err := MessagesRequestToChat(request)
+	if err != nil {
+		t.Fatalf("MessagesRequestToChat returned error: %v", err)
+	}
+
+	if len(chatRequest.Messages) != 4 {
+		t.Fatalf("expected 4 messages (system + 3), got %d", len(chatRequest.Messages))
+	}
+	if chatRequest.Messages[0].Role != "system" {
+		t.Fatalf("unexpected first role: %q", chatRequest.Messages[0].Role)
+	}
+	if string(chatRequest.Messages[2].Content) != string(request.Messages[1].Content) {
+		t.Fatalf("expected assistant content to be passed through, got %s", chatRequest.Messages[2].Content)
+	}
+	if chatRequest.MaxTokens == nil || *chatRequest.MaxTokens != 1024 {
+		t.Fatalf("unexpected max tokens: %#v", chatRequest.MaxTokens)
+	}
+	if !chatRequest.Stream {
+		t.Fatalf("expected stream=true")
+	}
+	if chatRequest.User != "user-1" {
+		t.Fatalf("unexpected user: %q", chatRequest.User)
+	}
+	if len(chatRequest.Tools) != 1 || chatRequest.Tools[0].Function == nil || chatRequest.Tools[0].Function.Name != "ls" {
+		t.Fatalf("unexpected tools: %#v", chatRequest.Tools)
+	}
+	if string(chatRequest.ToolChoice) != `{"function":{"name":"ls"},"type":"function"}` {
+		t.Fatalf("unexpected tool_choice: %s", chatRequest.ToolChoice)
+	}
+	if chatRequest.ParallelToolCalls == nil || *chatRequest.ParallelToolCalls {
+		t.Fatalf("expected parallel_tool_calls=false, got %#v", chatRequest.ParallelToolCalls)
+	}
+	if chatRequest.TopK == nil || *chatRequest.TopK != 20 {
+		t.Fatalf("unexpected top_k: %#v", chatRequest.TopK)
+	}
+	if string(chatRequest.Stop) != `["\n\nHuman:"]` {
+		t.Fatalf("unexpected stop: %s", chatRequest.Stop)
+	}
+}
+
+func TestValidateMessagesRequestRequiresMaxTokens(t *testing.T) {
+	err := ValidateMessagesRequest(MessagesRequest{
+		Model:    "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		Messages: []Message{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	})
+	if err == nil {
+		t.Fatalf("expected error when max_tokens is missing")
+	}
+}
+
+func TestBuildContentBlocks(t *testing.T) {
+	blocks := BuildContentBlocks("checking", nil)
+	if len(blocks) != 1 || blocks[0].Type != "text" || blocks[0].Text != "checking" {
+		t.Fatalf("unexpected text blocks: %#v", blocks)
+	}
+
+	blocks = BuildContentBlocks("", []openai.ToolCall{
+		{ID: "toolu_1", Type: "function", Function: openai.ToolCallFunction{Name: "ls", Arguments: `{"path":"."}`}},
+		{ID: "toolu_2", Type: "function", Function: openai.ToolCallFunction{Name: "echo", Arguments: `not-json`}},
+	})
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 tool_use blocks, got %d", len(blocks))
+	}
+	if blocks[0].Type != "tool_use" || string(blocks[0].Input) != `{"path":"."}` {
+		t.Fatalf("unexpected first block: %#v", blocks[0])
+	}
+	if string(blocks[1].Input) != `{"value":"not-json"}` {
+		t.Fatalf("unexpected fallback input: %s", blocks[1].Input)
+	}
+}
+
+func TestStopReasonFromFinishReason(t *testing.T) {
+	cases := map[string]string{
+		"tool_calls": "tool_use",
+		"length":     "max_tokens",
+		"stop":       "end_turn",
+		"":           "end_turn",
+	}
+	for finishReason, want := range cases {
+		if got := StopReasonFromFinishReason(finishReason); got != want {
+			t.Fatalf("StopReasonFromFinishReason(%q) = %q, want %q", finishReason, got, want)
+		}
+	}
+}
+
+func TestStopDetails(t *testing.T) {
+	reason, sequence := StopDetails("stop", "stop_sequence", []string{"END"})
+	if reason != "stop_sequence" || sequence == nil || *sequence != "END" {
+		t.Fatalf("unexpected stop details: %q %v", reason, sequence)
+	}
+	reason, sequence = StopDetails("stop", "stop_sequence", []string{"A", "B"})
+	if reason != "stop_sequence" || sequence != nil {
+		t.Fatalf("expected unknown matched sequence, got %q %v", reason, sequence)
+	}
+	reason, sequence = StopDetails("length", "max_tokens", []string{"END"})
+	if reason != "max_tokens" || sequence != nil {
+		t.Fatalf("unexpected stop details for max_tokens: %q %v", reason, sequence)
+	}
+}
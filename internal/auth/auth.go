@@ -44,7 +44,35 @@ func NewManager(cfg config.Config) *Manager {
 }
 
 func (m *Manager) Authenticate(r *http.Request) (*Client, error) {
+	return m.authenticateToken(extractToken(r))
+}
+
+// AuthenticateWithFallback 用于很少发送 Bearer token 的客户端（如 Ollama 插件、Gemini SDK）：
+// 标准 header 之外，依次尝试 x-goog-api-key、Basic 认证（password，缺省时用 username）与查询参数 api_key / key。
+func (m *Manager) AuthenticateWithFallback(r *http.Request) (*Client, error) {
 	token := extractToken(r)
+	if token == "" {
+		token = strings.TrimSpace(r.Header.Get("x-goog-api-key"))
+	}
+	if token == "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			token = strings.TrimSpace(password)
+			if token == "" {
+				token = strings.TrimSpace(username)
+			}
+		}
+	}
+	if token == "" {
+		query := r.URL.Query()
+		token = strings.TrimSpace(query.Get("api_key"))
+		if token == "" {
+			token = strings.TrimSpace(query.Get("key"))
+		}
+	}
+	return m.authenticateToken(token)
+}
+
+func (m *Manager) authenticateToken(token string) (*Client, error) {
 	if token == "" {
 		return nil, errors.New("missing api key")
 	}
@@ -62,6 +90,21 @@ func (m *Manager) Authenticate(r *http.Request) (*Client, error) {
 	return client, nil
 }
 
+// ClientByID 按 ID 查找客户端，供不经过 HTTP 鉴权的后台任务（如 /v1/batches 执行器）使用；
+// 客户端已删除或被禁用时返回错误。
+func (m *Manager) ClientByID(clientID string) (*Client, error) {
+	m.mu.RLock()
+	client, ok := m.byID[strings.TrimSpace(clientID)]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("client not found")
+	}
+	if client.Disabled {
+		return nil, errors.New("api key is disabled")
+	}
+	return client, nil
+}
+
 func (m *Manager) Acquire(ctx context.Context, client *Client) (func(), error) {
 	if client == nil {
 		return nil, errors.New("client is nil")
@@ -84,3 +84,61 @@ func TestManagerAuthenticateDisabledClient(t *testing.T) {
 		t.Fatalf("expected authenticate success after re-enable, got: %v", err)
 	}
 }
+
+func TestManagerAuthenticateWithFallback(t *testing.T) {
+	manager := NewManager(config.Config{GlobalMaxConcurrent: 16})
+	if err := manager.ReplaceClients([]config.ClientConfig{
+		{ID: "team-a", Name: "Team A", APIKey: "key-a"},
+	}); err != nil {
+		t.Fatalf("replace clients failed: %v", err)
+	}
+
+	query := httptest.NewRequest(http.MethodGet, "/api/tags?api_key=key-a", nil)
+	if _, err := manager.Authenticate(query); err == nil {
+		t.Fatalf("expected query api key to be ignored by Authenticate")
+	}
+	if client, err := manager.AuthenticateWithFallback(query); err != nil || client.ID != "team-a" {
+		t.Fatalf("expected query api key to authenticate, got client=%v err=%v", client, err)
+	}
+
+	basic := httptest.NewRequest(http.MethodPost, "/api/chat", nil)
+	basic.SetBasicAuth("ollama", "key-a")
+	if client, err := manager.AuthenticateWithFallback(basic); err != nil || client.ID != "team-a" {
+		t.Fatalf("expected basic auth password to authenticate, got client=%v err=%v", client, err)
+	}
+
+	goog := httptest.NewRequest(http.MethodPost, "/v1beta/models/m:generateContent", nil)
+	goog.Header.Set("x-goog-api-key", "key-a")
+	if client, err := manager.AuthenticateWithFallback(goog); err != nil || client.ID != "team-a" {
+		t.Fatalf("expected x-goog-api-key to authenticate, got client=%v err=%v", client, err)
+	}
+
+	bearer := httptest.NewRequest(http.MethodPost, "/api/chat?key=wrong", nil)
+	bearer.Header.Set("Authorization", "Bearer key-a")
+	if client, err := manager.AuthenticateWithFallback(bearer); err != nil || client.ID != "team-a" {
+		t.Fatalf("expected bearer token to take precedence, got client=%v err=%v", client, err)
+	}
+}
+
+func TestClientIsModelAllowedAcceptsAliasOrTarget(t *testing.T) {
+	manager := NewManager(config.Config{GlobalMaxConcurrent: 16})
+	if err := manager.ReplaceClients([]config.ClientConfig{
+		{ID: "by-alias", APIKey: "key-a", MaxConcurrent: 1, AllowedModels: []string{"Fast"}},
+		{ID: "by-target", APIKey: "key-b", MaxConcurrent: 1, AllowedModels: []string{"anthropic.claude-3-5-haiku"}},
+	}); err != nil {
+		t.Fatalf("replace clients failed: %v", err)
+	}
+
+	for _, clientID := range []string{"by-alias", "by-target"} {
+		client, err := manager.ClientByID(clientID)
+		if err != nil {
+			t.Fatalf("client %s not found: %v", clientID, err)
+		}
+		if !client.IsModelAllowed("fast", "anthropic.claude-3-5-haiku") {
+			t.Fatalf("client %s should be allowed to call alias fast", client.ID)
+		}
+		if client.IsModelAllowed("other", "anthropic.other") {
+			t.Fatalf("client %s should not be allowed to call other models", client.ID)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// CountTokensAPI 是 Bedrock CountTokens 调用；并非所有模型都支持，不支持时退回本地估算。
+type CountTokensAPI interface {
+	CountTokens(ctx context.Context, params *bedrockruntime.CountTokensInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error)
+}
+
+const (
+	// estimatedImageTokens 是本地估算时每张图片计入的 token 数（约为 Claude 单张大图的上限）。
+	estimatedImageTokens = 1600
+	// estimatedMessageOverheadTokens 是本地估算时每条消息的角色与分隔开销。
+	estimatedMessageOverheadTokens = 3
+)
+
+type TokenCountResult struct {
+	InputTokens int
+	// Estimated 为 true 表示 Bedrock CountTokens 不可用，InputTokens 为本地近似值
+	Estimated bool
+}
+
+// CountTokens 按与 Converse 完全相同的转换（BuildBedrockMessages + buildToolConfiguration）统计输入 token 数，
+// 优先调用 Bedrock CountTokens，客户端不支持或模型不支持时按字符数本地估算。
+func (s *Service) CountTokens(ctx context.Context, request openai.ChatCompletionRequest, bedrockModelID string) (TokenCountResult, error) {
+	request.Messages = openai.EnsureToolCallIDs(request.Messages)
+	request.Messages = openai.FixMissingToolResponses(request.Messages)
+	if thinkingBudgetForRequest(request, bedrockModelID) == 0 {
+		stripThinkingBlocks(request.Messages)
+	}
+
+	messages, system, err := BuildBedrockMessages(request.Messages)
+	if err != nil {
+		return TokenCountResult{}, &RequestError{Err: err}
+	}
+
+	s.mu.RLock()
+	forceToolUse := s.forceToolUse && !hasToolResponses(request.Messages)
+	client := s.countClient
+	s.mu.RUnlock()
+
+	structured, err := newStructuredOutput(request.ResponseFormat)
+	if err != nil {
+		return TokenCountResult{}, &RequestError{Err: err}
+	}
+	toolConfig, err := buildToolConfiguration(request.Tools, request.ToolChoice, forceToolUse, structured)
+	if err != nil {
+		return TokenCountResult{}, &RequestError{Err: err}
+	}
+
+	if client != nil {
+		output, err := client.CountTokens(ctx, &bedrockruntime.CountTokensInput{
+			ModelId: aws.String(bedrockModelID),
+			Input: &brtypes.CountTokensInputMemberConverse{Value: brtypes.ConverseTokensRequest{
+				Messages:   messages,
+				System:     system,
+				ToolConfig: toolConfig,
+			}},
+		})
+		if err == nil && output.InputTokens != nil {
+			return TokenCountResult{InputTokens: int(*output.InputTokens)}, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return TokenCountResult{}, errors.Join(ctxErr, err)
+		}
+		if err != nil {
+			fmt.Printf("[WARN CountTokens] model=%s falls back to local estimate: %v\n", bedrockModelID, err)
+		}
+	}
+
+	return TokenCountResult{
+		InputTokens: estimateConverseTokens(messages, system, toolConfig),
+		Estimated:   true,
+	}, nil
+}
+
+// estimateConverseTokens 粗略估算 Converse 输入的 token 数：文本约 4 个字符一个 token，图片按固定值计。
+func estimateConverseTokens(messages []brtypes.Message, system []brtypes.SystemContentBlock, toolConfig *brtypes.ToolConfiguration) int {
+	total := 0
+	for _, block := range system {
+		if text, ok := block.(*brtypes.SystemContentBlockMemberText); ok {
+			total += estimateTextTokens(text.Value)
+		}
+	}
+	for _, message := range messages {
+		total += estimatedMessageOverheadTokens
+		for _, block := range message.Content {
+			total += estimateContentBlockTokens(block)
+		}
+	}
+	if toolConfig != nil {
+		for _, tool := range toolConfig.Tools {
+			spec, ok := tool.(*brtypes.ToolMemberToolSpec)
+			if !ok {
+				continue
+			}
+			total += estimateTextTokens(aws.ToString(spec.Value.Name))
+			total += estimateTextTokens(aws.ToString(spec.Value.Description))
+			if schema, ok := spec.Value.InputSchema.(*brtypes.ToolInputSchemaMemberJson); ok {
+				total += estimateTextTokens(documentToJSONString(schema.Value))
+			}
+		}
+	}
+	return total
+}
+
+func estimateContentBlockTokens(block brtypes.ContentBlock) int {
+	switch value := block.(type) {
+	case *brtypes.ContentBlockMemberText:
+		return estimateTextTokens(value.Value)
+	case *brtypes.ContentBlockMemberImage:
+		return estimatedImageTokens
+	case *brtypes.ContentBlockMemberDocument:
+		if source, ok := value.Value.Source.(*brtypes.DocumentSourceMemberBytes); ok {
+			return (len(source.Value) + 3) / 4
+		}
+		return 0
+	case *brtypes.ContentBlockMemberToolUse:
+		return estimateTextTokens(aws.ToString(value.Value.Name)) + estimateTextTokens(documentToJSONString(value.Value.Input))
+	case *brtypes.ContentBlockMemberToolResult:
+		total := 0
+		for _, item := range value.Value.Content {
+			switch content := item.(type) {
+			case *brtypes.ToolResultContentBlockMemberText:
+				total += estimateTextTokens(content.Value)
+			case *brtypes.ToolResultContentBlockMemberJson:
+				total += estimateTextTokens(documentToJSONString(content.Value))
+			case *brtypes.ToolResultContentBlockMemberImage:
+				total += estimatedImageTokens
+			}
+		}
+		return total
+	case *brtypes.ContentBlockMemberReasoningContent:
+		if reasoning, ok := value.Value.(*brtypes.ReasoningContentBlockMemberReasoningText); ok {
+			return estimateTextTokens(aws.ToString(reasoning.Value.Text))
+		}
+		return 0
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,90 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type fakeCountTokensClient struct {
+	bedrocktest.Client
+	countInput *bedrockruntime.CountTokensInput
+	tokens     int32
+	err        error
+}
+
+func (f *fakeCountTokensClient) CountTokens(ctx context.Context, params *bedrockruntime.CountTokensInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error) {
+	f.countInput = params
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &bedrockruntime.CountTokensOutput{InputTokens: aws.Int32(f.tokens)}, nil
+}
+
+func countTokensTestRequest() openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{
+			{Role: "system", Content: json.RawMessage(`"You are terse."`)},
+			{Role: "user", Content: json.RawMessage(`"What is the weather in Paris today?"`)},
+		},
+		Tools: []openai.Tool{{Type: "function", Function: &openai.ToolFunction{
+			Name:       "get_weather",
+			Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}}},
+	}
+}
+
+func TestCountTokensUsesBedrockTranslation(t *testing.T) {
+	client := &fakeCountTokensClient{tokens: 321}
+	service := NewService(client, "", nil, 0, 0, false, false)
+
+	result, err := service.CountTokens(context.Background(), countTokensTestRequest(), "anthropic.model")
+	if err != nil {
+		t.Fatalf("CountTokens returned error: %v", err)
+	}
+	if result.InputTokens != 321 || result.Estimated {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	input, ok := client.countInput.Input.(*brtypes.CountTokensInputMemberConverse)
+	if !ok {
+		t.Fatalf("expected converse count tokens input, got %T", client.countInput.Input)
+	}
+	if aws.ToString(client.countInput.ModelId) != "anthropic.model" ||
+		len(input.Value.System) != 1 || len(input.Value.Messages) != 1 ||
+		input.Value.ToolConfig == nil || len(input.Value.ToolConfig.Tools) != 1 {
+		t.Fatalf("unexpected count tokens input: %+v", input.Value)
+	}
+}
+
+func TestCountTokensFallsBackToEstimate(t *testing.T) {
+	for name, client := range map[string]ConverseAPI{
+		"no count client": &bedrocktest.Client{},
+		"count failed":    &fakeCountTokensClient{err: errors.New("model does not support CountTokens")},
+	} {
+		service := NewService(client, "", nil, 0, 0, false, false)
+		result, err := service.CountTokens(context.Background(), countTokensTestRequest(), "anthropic.model")
+		if err != nil {
+			t.Fatalf("%s: CountTokens returned error: %v", name, err)
+		}
+		if !result.Estimated || result.InputTokens <= 0 {
+			t.Fatalf("%s: expected positive estimate, got %+v", name, result)
+		}
+	}
+}
+
+func TestCountTokensRejectsInvalidMessages(t *testing.T) {
+	service := NewService(&bedrocktest.Client{}, "", nil, 0, 0, false, false)
+	_, err := service.CountTokens(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{{Role: "wizard", Content: json.RawMessage(`"hi"`)}},
+	}, "anthropic.model")
+	if err == nil || !IsRequestError(err) {
+		t.Fatalf("expected request error, got %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// InvokeModelAPI 是嵌入模型与图片生成模型使用的 InvokeModel 调用；Converse 不支持这两类模型，只能按模型家族拼原生请求体。
+type InvokeModelAPI interface {
+	InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+}
+
+const (
+	// cohereMaxTextsPerCall 是 Cohere Embed 单次调用允许的最大 texts 数量，超过时分批调用。
+	cohereMaxTextsPerCall = 96
+	// titanEmbeddingConcurrency 限制 Titan（每次只能嵌入一条文本）并发调用数，避免批量输入打满账号配额。
+	titanEmbeddingConcurrency = 4
+	// inputTokenCountHeader 是 Bedrock InvokeModel 响应中携带输入 token 数的 header。
+	inputTokenCountHeader = "X-Amzn-Bedrock-Input-Token-Count"
+)
+
+type EmbeddingResult struct {
+	Embeddings  [][]float64
+	InputTokens int
+	LatencyMs   int64
+	// 1 + 限流 / 瞬时错误后的重试次数
+	Attempts int
+}
+
+type embeddingFamily int
+
+const (
+	embeddingFamilyUnknown embeddingFamily = iota
+	embeddingFamilyTitanV1
+	embeddingFamilyTitanV2
+	embeddingFamilyCohereV3
+	embeddingFamilyCohereV4
+)
+
+// baseFoundationModelID 去掉 ARN 前缀与 us./eu./apac./global. 等跨区域推理前缀，返回小写的基础模型 ID。
+func baseFoundationModelID(bedrockModelID string) string {
+	modelID := strings.ToLower(strings.TrimSpace(bedrockModelID))
+	if index := strings.LastIndex(modelID, "/"); index >= 0 {
+		modelID = modelID[index+1:]
+	}
+	for _, prefix := range []string{"us.", "eu.", "apac.", "global."} {
+		modelID = strings.TrimPrefix(modelID, prefix)
+	}
+	return modelID
+}
+
+// detectEmbeddingFamily 按模型 ID 判断请求体格式，兼容跨区域推理前缀。
+func detectEmbeddingFamily(bedrockModelID string) embeddingFamily {
+	modelID := baseFoundationModelID(bedrockModelID)
+	switch {
+	case strings.HasPrefix(modelID, "amazon.titan-embed-text-v2"):
+		return embeddingFamilyTitanV2
+	case strings.HasPrefix(modelID, "amazon.titan-embed-text-v1"), strings.HasPrefix(modelID, "amazon.titan-embed-g1-text"):
+		return embeddingFamilyTitanV1
+	case strings.HasPrefix(modelID, "cohere.embed-v4"):
+		return embeddingFamilyCohereV4
+	case strings.HasPrefix(modelID, "cohere.embed-"):
+		return embeddingFamilyCohereV3
+	default:
+		return embeddingFamilyUnknown
+	}
+}
+
+// IsEmbeddingModel 判断模型 ID 是否为已支持的嵌入模型。
+func IsEmbeddingModel(bedrockModelID string) bool {
+	return detectEmbeddingFamily(bedrockModelID) != embeddingFamilyUnknown
+}
+
+func (s *Service) HasInvokeClient() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.invokeClient != nil
+}
+
+// Embed 为 request.Input 中的每条文本生成向量，结果顺序与输入一致。
+func (s *Service) Embed(ctx context.Context, request openai.EmbeddingsRequest, bedrockModelID string) (EmbeddingResult, error) {
+	inputs, err := openai.ParseEmbeddingInput(request.Input)
+	if err != nil {
+		return EmbeddingResult{}, &RequestError{Err: err}
+	}
+
+	s.mu.RLock()
+	client := s.invokeClient
+	s.mu.RUnlock()
+	if client == nil {
+		return EmbeddingResult{}, errors.New("bedrock invoke client is not configured")
+	}
+
+	family := detectEmbeddingFamily(bedrockModelID)
+	if err := validateEmbeddingDimensions(family, request.Dimensions); err != nil {
+		return EmbeddingResult{}, &RequestError{Err: err}
+	}
+
+	startedAt := time.Now()
+	invoker := s.retryingInvoker(client)
+	var result EmbeddingResult
+	switch family {
+	case embeddingFamilyTitanV1, embeddingFamilyTitanV2:
+		result, err = embedTitan(ctx, invoker, bedrockModelID, family, inputs, request.Dimensions)
+	case embeddingFamilyCohereV3, embeddingFamilyCohereV4:
+		result, err = embedCohere(ctx, invoker, bedrockModelID, family, inputs, request)
+	default:
+		return EmbeddingResult{}, &RequestError{Err: fmt.Errorf("model %s is not a supported embedding model", bedrockModelID)}
+	}
+	result.LatencyMs = time.Since(startedAt).Milliseconds()
+	result.Attempts = invoker.Attempts()
+	return result, err
+}
+
+func validateEmbeddingDimensions(family embeddingFamily, dimensions *int) error {
+	if dimensions == nil {
+		return nil
+	}
+	var supported []int
+	switch family {
+	case embeddingFamilyTitanV2:
+		supported = []int{256, 512, 1024}
+	case embeddingFamilyCohereV4:
+		supported = []int{256, 512, 1024, 1536}
+	case embeddingFamilyUnknown:
+		return nil
+	default:
+		return errors.New("dimensions is not supported by this embedding model")
+	}
+	for _, value := range supported {
+		if *dimensions == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("dimensions must be one of %v for this embedding model", supported)
+}
+
+// embedTitan 逐条调用 Titan（请求体只接受单个 inputText），有限并发执行。
+func embedTitan(
+	ctx context.Context,
+	client InvokeModelAPI,
+	bedrockModelID string,
+	family embeddingFamily,
+	inputs []string,
+	dimensions *int,
+) (EmbeddingResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	embeddings := make([][]float64, len(inputs))
+	tokens := make([]int, len(inputs))
+	errs := make([]error, len(inputs))
+	semaphore := make(chan struct{}, titanEmbeddingConcurrency)
+	var wg sync.WaitGroup
+	for index, input := range inputs {
+		wg.Add(1)
+		go func(index int, input string) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				errs[index] = ctx.Err()
+				return
+			}
+
+			body := map[string]any{"inputText": input}
+			if family == embeddingFamilyTitanV2 {
+				body["normalize"] = true
+				if dimensions != nil {
+					body["dimensions"] = *dimensions
+				}
+			}
+			var payload struct {
+				Embedding           []float64 `json:"embedding"`
+				InputTextTokenCount int       `json:"inputTextTokenCount"`
+			}
+			headerTokens, err := invokeModelJSON(ctx, client, bedrockModelID, body, &payload)
+			if err != nil {
+				errs[index] = err
+				cancel()
+				return
+			}
+			if len(payload.Embedding) == 0 {
+				errs[index] = errors.New("bedrock returned an empty embedding")
+				cancel()
+				return
+			}
+			embeddings[index] = payload.Embedding
+			tokens[index] = payload.InputTextTokenCount
+			if tokens[index] == 0 {
+				tokens[index] = headerTokens
+			}
+		}(index, input)
+	}
+	wg.Wait()
+
+	result := EmbeddingResult{Embeddings: embeddings}
+	for _, count := range tokens {
+		result.InputTokens += count
+	}
+	// 优先返回真正失败的错误，而不是被取消的兄弟调用返回的 context canceled
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return result, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return result, firstErr
+}
+
+// embedCohere 按 96 条一批调用 Cohere Embed。响应体不含 token 数，优先读取 header，缺失时按字符数估算。
+func embedCohere(
+	ctx context.Context,
+	client InvokeModelAPI,
+	bedrockModelID string,
+	family embeddingFamily,
+	inputs []string,
+	request openai.EmbeddingsRequest,
+) (EmbeddingResult, error) {
+	inputType := strings.TrimSpace(request.InputType)
+	if inputType == "" {
+		inputType = "search_document"
+	}
+
+	result := EmbeddingResult{Embeddings: make([][]float64, 0, len(inputs))}
+	for start := 0; start < len(inputs); start += cohereMaxTextsPerCall {
+		end := min(start+cohereMaxTextsPerCall, len(inputs))
+		batch := inputs[start:end]
+
+		body := map[string]any{
+			"texts":           batch,
+			"input_type":      inputType,
+			"embedding_types": []string{"float"},
+		}
+		if family == embeddingFamilyCohereV4 && request.Dimensions != nil {
+			body["output_dimension"] = *request.Dimensions
+		}
+		var payload struct {
+			Embeddings json.RawMessage `json:"embeddings"`
+		}
+		headerTokens, err := invokeModelJSON(ctx, client, bedrockModelID, body, &payload)
+		if err != nil {
+			return result, err
+		}
+		vectors, err := parseCohereEmbeddings(payload.Embeddings)
+		if err != nil {
+			return result, err
+		}
+		if len(vectors) != len(batch) {
+			return result, fmt.Errorf("bedrock returned %d embeddings for %d inputs", len(vectors), len(batch))
+		}
+		result.Embeddings = append(result.Embeddings, vectors...)
+
+		if headerTokens > 0 {
+			result.InputTokens += headerTokens
+		} else {
+			for _, text := range batch {
+				result.InputTokens += estimateTextTokens(text)
+			}
+		}
+	}
+	return result, nil
+}
+
+// parseCohereEmbeddings 兼容两种响应：embeddings 为二维数组（embeddings_floats），
+// 或 {"float": [[...]]}（请求了 embedding_types 时的 embeddings_by_type）。
+func parseCohereEmbeddings(raw json.RawMessage) ([][]float64, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, "[") {
+		var vectors [][]float64
+		if err := json.Unmarshal(raw, &vectors); err != nil {
+			return nil, fmt.Errorf("invalid cohere embeddings: %w", err)
+		}
+		return vectors, nil
+	}
+	var byType struct {
+		Float [][]float64 `json:"float"`
+	}
+	if err := json.Unmarshal(raw, &byType); err != nil {
+		return nil, fmt.Errorf("invalid cohere embeddings: %w", err)
+	}
+	return byType.Float, nil
+}
+
+// invokeModelJSON 发送 JSON 请求体并解析响应，返回 header 中的输入 token 数（缺失时为 0）。
+func invokeModelJSON(ctx context.Context, client InvokeModelAPI, bedrockModelID string, body map[string]any, out any) (int, error) {
+	blob, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	output, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(bedrockModelID),
+		Body:        blob,
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(output.Body, out); err != nil {
+		return 0, fmt.Errorf("invalid model response: %w", err)
+	}
+
+	tokens := 0
+	if raw, ok := awsmiddleware.GetRawResponse(output.ResultMetadata).(*smithyhttp.Response); ok && raw != nil {
+		tokens, _ = strconv.Atoi(strings.TrimSpace(raw.Header.Get(inputTokenCountHeader)))
+	}
+	return tokens, nil
+}
+
+// estimateTextTokens 粗略估算 token 数（约 4 个字符一个 token），仅在 Bedrock 未返回 token 数时用于计费。
+func estimateTextTokens(text string) int {
+	runes := len([]rune(text))
+	if runes == 0 {
+		return 0
+	}
+	return (runes + 3) / 4
+}
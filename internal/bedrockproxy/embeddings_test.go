@@ -0,0 +1,112 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+type fakeInvokeClient struct {
+	bedrocktest.Client
+	mu     sync.Mutex
+	bodies []map[string]any
+	handle func(body map[string]any) string
+}
+
+func (f *fakeInvokeClient) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	var body map[string]any
+	if err := json.Unmarshal(params.Body, &body); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.bodies = append(f.bodies, body)
+	f.mu.Unlock()
+	return &bedrockruntime.InvokeModelOutput{Body: []byte(f.handle(body)), ContentType: aws.String("application/json")}, nil
+}
+
+func TestEmbedTitanV2PerInput(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string {
+		if body["inputText"] == "b" {
+			return `{"embedding":[0.3,0.4],"inputTextTokenCount":2}`
+		}
+		return `{"embedding":[0.1,0.2],"inputTextTokenCount":1}`
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	dimensions := 256
+	result, err := service.Embed(context.Background(), openai.EmbeddingsRequest{
+		Input:      json.RawMessage(`["a","b"]`),
+		Dimensions: &dimensions,
+	}, "amazon.titan-embed-text-v2:0")
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(result.Embeddings) != 2 || result.Embeddings[1][0] != 0.3 {
+		t.Fatalf("unexpected embeddings order: %#v", result.Embeddings)
+	}
+	if result.InputTokens != 3 {
+		t.Fatalf("expected 3 input tokens, got %d", result.InputTokens)
+	}
+	if len(client.bodies) != 2 || client.bodies[0]["dimensions"] != float64(256) {
+		t.Fatalf("unexpected titan request bodies: %#v", client.bodies)
+	}
+}
+
+func TestEmbedCohereBatchesInputs(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string {
+		texts, _ := body["texts"].([]any)
+		vectors := make([][]float64, len(texts))
+		for index := range texts {
+			vectors[index] = []float64{float64(index)}
+		}
+		blob, _ := json.Marshal(map[string]any{"embeddings": map[string]any{"float": vectors}})
+		return string(blob)
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	inputs := make([]string, 100)
+	for index := range inputs {
+		inputs[index] = "abcdefgh"
+	}
+	raw, _ := json.Marshal(inputs)
+	result, err := service.Embed(context.Background(), openai.EmbeddingsRequest{Input: raw}, "cohere.embed-english-v3")
+	if err != nil {
+		t.Fatalf("Embed returned error: %v", err)
+	}
+	if len(client.bodies) != 2 {
+		t.Fatalf("expected 2 batched calls, got %d", len(client.bodies))
+	}
+	if client.bodies[0]["input_type"] != "search_document" {
+		t.Fatalf("unexpected input_type: %#v", client.bodies[0]["input_type"])
+	}
+	if len(result.Embeddings) != 100 || result.Embeddings[96][0] != 0 {
+		t.Fatalf("unexpected embeddings: %d", len(result.Embeddings))
+	}
+	// 响应未带 token header，按 4 字符 / token 估算
+	if result.InputTokens != 200 {
+		t.Fatalf("expected estimated 200 input tokens, got %d", result.InputTokens)
+	}
+}
+
+func TestEmbedRejectsUnsupportedDimensions(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string { return `{}` }}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	dimensions := 300
+	_, err := service.Embed(context.Background(), openai.EmbeddingsRequest{
+		Input:      json.RawMessage(`"hello"`),
+		Dimensions: &dimensions,
+	}, "us.amazon.titan-embed-text-v2:0")
+	if !IsRequestError(err) {
+		t.Fatalf("expected request error, got %v", err)
+	}
+	if IsEmbeddingModel("anthropic.claude-3-haiku") {
+		t.Fatalf("chat model must not be treated as embedding model")
+	}
+}
@@ -0,0 +1,104 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/smithy-go"
+)
+
+var errNoFallbackModels = errors.New("no bedrock model to call")
+
+// IsModelFallbackError 判断错误是否应切换到降级链中的下一个模型：限流 / 模型未就绪等上游不可用错误
+// （见 IsUpstreamUnavailable，此时已按 RetryPolicy 重试过），以及账号没有该模型访问权限（AccessDeniedException）。
+func IsModelFallbackError(err error) bool {
+	if err == nil || IsRequestError(err) {
+		return false
+	}
+	if IsUpstreamUnavailable(err) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
+
+// GuardrailResolver 返回调用某个模型时应使用的 Guardrail；降级链中的每个模型各自取一次。
+type GuardrailResolver func(modelID string) *openai.GuardrailConfig
+
+// ConverseWithFallback 依次用 modelIDs 中的模型调用 Converse，遇到 IsModelFallbackError 时换下一个模型。
+// guardrails 非 nil 时按每个模型重新设置 request.Guardrail。
+// ChatResult.ModelID 为实际服务的模型，Attempts 累计所有模型的尝试次数；全部失败时返回最后一个错误。
+func (s *Service) ConverseWithFallback(
+	ctx context.Context,
+	request openai.ChatCompletionRequest,
+	modelIDs []string,
+	guardrails GuardrailResolver,
+) (ChatResult, error) {
+	var (
+		result   ChatResult
+		err      error
+		attempts int
+	)
+	if len(modelIDs) == 0 {
+		return ChatResult{}, errNoFallbackModels
+	}
+	for index, modelID := range modelIDs {
+		if index > 0 {
+			fmt.Printf("[WARN Converse] model %s unavailable, falling back to %s: %v\n", modelIDs[index-1], modelID, err)
+		}
+		if guardrails != nil {
+			request.Guardrail = guardrails(modelID)
+		}
+		result, err = s.Converse(ctx, request, modelID)
+		attempts += result.Attempts
+		result.Attempts = attempts
+		if err == nil || !IsModelFallbackError(err) || ctx.Err() != nil {
+			return result, err
+		}
+	}
+	return result, err
+}
+
+// ConverseStreamWithFallback 同 ConverseWithFallback，但只有在尚未向 onDelta 输出任何内容时才切换模型；
+// 切换前调用 onFallback（可为 nil），以便调用方把后续输出中的模型名改为新模型。
+func (s *Service) ConverseStreamWithFallback(
+	ctx context.Context,
+	request openai.ChatCompletionRequest,
+	modelIDs []string,
+	guardrails GuardrailResolver,
+	onFallback func(modelID string),
+	onDelta func(delta StreamDelta) error,
+) (ChatResult, error) {
+	var (
+		result   ChatResult
+		err      error
+		attempts int
+	)
+	if len(modelIDs) == 0 {
+		return ChatResult{}, errNoFallbackModels
+	}
+	emitted := false
+	for index, modelID := range modelIDs {
+		if index > 0 {
+			fmt.Printf("[WARN ConverseStream] model %s unavailable, falling back to %s: %v\n", modelIDs[index-1], modelID, err)
+			if onFallback != nil {
+				onFallback(modelID)
+			}
+		}
+		if guardrails != nil {
+			request.Guardrail = guardrails(modelID)
+		}
+		result, err = s.ConverseStream(ctx, request, modelID, func(delta StreamDelta) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		attempts += result.Attempts
+		result.Attempts = attempts
+		if err == nil || emitted || !IsModelFallbackError(err) || ctx.Err() != nil {
+			return result, err
+		}
+	}
+	return result, err
+}
@@ -0,0 +1,73 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/smithy-go"
+)
+
+func TestConverseWithFallbackUsesNextModel(t *testing.T) {
+	client := &bedrocktest.Client{ModelErrors: map[string]error{
+		"sonnet-4":   &smithy.GenericAPIError{Code: "ThrottlingException"},
+		"sonnet-3.7": &smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient},
+	}}
+	service := newRetryTestService(client, 1)
+
+	request := testChatRequest()
+	request.Guardrail = &openai.GuardrailConfig{Identifier: "gr-sonnet", Version: "1"}
+	// 每个模型按自己的配置取 Guardrail，不沿用主模型的
+	guardrails := func(modelID string) *openai.GuardrailConfig {
+		if modelID == "haiku" {
+			return &openai.GuardrailConfig{Identifier: "gr-haiku", Version: "2"}
+		}
+		return request.Guardrail
+	}
+
+	result, err := service.ConverseWithFallback(context.Background(), request, []string{"sonnet-4", "sonnet-3.7", "haiku"}, guardrails)
+	if err != nil {
+		t.Fatalf("expected fallback to succeed, got %v", err)
+	}
+	if result.ModelID != "haiku" || result.Text != "ok" {
+		t.Fatalf("expected haiku to serve the request, got model=%q text=%q", result.ModelID, result.Text)
+	}
+	// sonnet-4 限流时先重试一次，再降级；AccessDenied 不重试
+	if result.Attempts != 4 || client.Calls() != 4 {
+		t.Fatalf("unexpected attempts: attempts=%d calls=%v", result.Attempts, client.ModelIDs())
+	}
+	if guardrail := client.LastInput().GuardrailConfig; guardrail == nil || aws.ToString(guardrail.GuardrailIdentifier) != "gr-haiku" {
+		t.Fatalf("expected the fallback model's guardrail, got %#v", guardrail)
+	}
+}
+
+func TestConverseWithFallbackStopsOnRequestErrors(t *testing.T) {
+	invalid := &smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient}
+	client := &bedrocktest.Client{ModelErrors: map[string]error{"sonnet-4": invalid}}
+	service := newRetryTestService(client, 1)
+
+	result, err := service.ConverseWithFallback(context.Background(), testChatRequest(), []string{"sonnet-4", "haiku"}, nil)
+	if !errors.Is(err, invalid) || result.ModelID != "sonnet-4" || client.Calls() != 1 {
+		t.Fatalf("expected validation errors not to fall back, got model=%q calls=%v err=%v", result.ModelID, client.ModelIDs(), err)
+	}
+}
+
+func TestIsModelFallbackError(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"throttling":      {&smithy.GenericAPIError{Code: "ThrottlingException"}, true},
+		"model not ready": {&smithy.GenericAPIError{Code: "ModelNotReadyException", Fault: smithy.FaultClient}, true},
+		"access denied":   {&smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient}, true},
+		"validation":      {&smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient}, false},
+		"request error":   {&RequestError{Err: errors.New("bad")}, false},
+	} {
+		if got := IsModelFallbackError(testCase.err); got != testCase.want {
+			t.Fatalf("%s: IsModelFallbackError = %v, want %v", name, got, testCase.want)
+		}
+	}
+}
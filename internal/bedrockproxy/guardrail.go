@@ -0,0 +1,42 @@
+package bedrockproxy
+
+import (
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// buildGuardrailConfig 转换为 Converse 的 GuardrailConfig；未配置时返回 nil。
+func buildGuardrailConfig(guardrail *openai.GuardrailConfig) *brtypes.GuardrailConfiguration {
+	if guardrail == nil || strings.TrimSpace(guardrail.Identifier) == "" {
+		return nil
+	}
+	return &brtypes.GuardrailConfiguration{
+		GuardrailIdentifier: aws.String(strings.TrimSpace(guardrail.Identifier)),
+		GuardrailVersion:    aws.String(strings.TrimSpace(guardrail.Version)),
+	}
+}
+
+// buildGuardrailStreamConfig 转换为 ConverseStream 的 GuardrailConfig。
+// async 模式先发送内容再异步检查，延迟更低但违规内容可能已部分发出；默认 sync。
+func buildGuardrailStreamConfig(guardrail *openai.GuardrailConfig) *brtypes.GuardrailStreamConfiguration {
+	if guardrail == nil || strings.TrimSpace(guardrail.Identifier) == "" {
+		return nil
+	}
+	mode := brtypes.GuardrailStreamProcessingModeSync
+	if strings.EqualFold(strings.TrimSpace(guardrail.StreamProcessingMode), string(brtypes.GuardrailStreamProcessingModeAsync)) {
+		mode = brtypes.GuardrailStreamProcessingModeAsync
+	}
+	return &brtypes.GuardrailStreamConfiguration{
+		GuardrailIdentifier:  aws.String(strings.TrimSpace(guardrail.Identifier)),
+		GuardrailVersion:     aws.String(strings.TrimSpace(guardrail.Version)),
+		StreamProcessingMode: mode,
+	}
+}
+
+// isGuardrailStop 判断 Bedrock 是否因 Guardrail / 内容过滤而停止生成。
+func isGuardrailStop(reason brtypes.StopReason) bool {
+	return reason == brtypes.StopReasonGuardrailIntervened || reason == brtypes.StopReasonContentFiltered
+}
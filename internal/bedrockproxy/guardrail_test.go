@@ -0,0 +1,53 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestConversePassesGuardrailAndMapsIntervention(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonGuardrailIntervened,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: "blocked"}},
+		}},
+	}}
+	service := NewService(client, "", nil, 0, 0, false, false)
+
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages:  []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		Guardrail: &openai.GuardrailConfig{Identifier: "gr-123", Version: "2"},
+	}, "anthropic.model")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	guardrail := client.LastInput().GuardrailConfig
+	if guardrail == nil || aws.ToString(guardrail.GuardrailIdentifier) != "gr-123" || aws.ToString(guardrail.GuardrailVersion) != "2" {
+		t.Fatalf("unexpected guardrail config: %#v", guardrail)
+	}
+	if result.FinishReason != "content_filter" || !result.GuardrailIntervened {
+		t.Fatalf("expected guardrail intervention to map to content_filter, got %+v", result)
+	}
+}
+
+func TestBuildGuardrailStreamConfig(t *testing.T) {
+	if buildGuardrailStreamConfig(nil) != nil || buildGuardrailConfig(&openai.GuardrailConfig{}) != nil {
+		t.Fatalf("expected no guardrail config without identifier")
+	}
+	cfg := buildGuardrailStreamConfig(&openai.GuardrailConfig{Identifier: "gr-1", Version: "DRAFT", StreamProcessingMode: "ASYNC"})
+	if cfg.StreamProcessingMode != brtypes.GuardrailStreamProcessingModeAsync {
+		t.Fatalf("expected async mode, got %q", cfg.StreamProcessingMode)
+	}
+	cfg = buildGuardrailStreamConfig(&openai.GuardrailConfig{Identifier: "gr-1", Version: "DRAFT"})
+	if cfg.StreamProcessingMode != brtypes.GuardrailStreamProcessingModeSync {
+		t.Fatalf("expected sync mode by default, got %q", cfg.StreamProcessingMode)
+	}
+}
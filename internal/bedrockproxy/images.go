@@ -0,0 +1,242 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+)
+
+// titanMaxImagesPerCall 是 Titan Image Generator / Nova Canvas 单次调用允许的最大 numberOfImages，超过时分批调用。
+const titanMaxImagesPerCall = 5
+
+// stabilityAspectRatios 是 SD3 / Stable Image Core / Ultra 支持的 aspect_ratio，这些模型不接受具体像素尺寸。
+var stabilityAspectRatios = []string{"21:9", "16:9", "3:2", "5:4", "1:1", "4:5", "2:3", "9:16", "9:21"}
+
+type ImageResult struct {
+	// base64 编码的 PNG
+	Images    []string
+	LatencyMs int64
+	// 1 + 限流 / 瞬时错误后的重试次数
+	Attempts int
+}
+
+type imageFamily int
+
+const (
+	imageFamilyUnknown imageFamily = iota
+	// Titan Image Generator v1 / v2 与 Nova Canvas 共用 taskType=TEXT_IMAGE 请求体
+	imageFamilyTitan
+	imageFamilyStabilitySDXL
+	// SD3 / SD3.5 / Stable Image Core / Ultra 共用 prompt + aspect_ratio 请求体
+	imageFamilyStability
+)
+
+func detectImageFamily(bedrockModelID string) imageFamily {
+	modelID := baseFoundationModelID(bedrockModelID)
+	switch {
+	case strings.HasPrefix(modelID, "amazon.titan-image-generator"), strings.HasPrefix(modelID, "amazon.nova-canvas"):
+		return imageFamilyTitan
+	case strings.HasPrefix(modelID, "stability.stable-diffusion-xl"):
+		return imageFamilyStabilitySDXL
+	case strings.HasPrefix(modelID, "stability.sd3"), strings.HasPrefix(modelID, "stability.stable-image-"):
+		return imageFamilyStability
+	default:
+		return imageFamilyUnknown
+	}
+}
+
+// IsImageModel 判断模型 ID 是否为已支持的图片生成模型。
+func IsImageModel(bedrockModelID string) bool {
+	return detectImageFamily(bedrockModelID) != imageFamilyUnknown
+}
+
+// GenerateImages 按 request 生成 n 张图片。只支持一次返回一张图片的模型会被调用 n 次，
+// 设置了 seed 时第 i 张使用 seed+i，避免多张图片完全相同。
+func (s *Service) GenerateImages(ctx context.Context, request openai.ImagesRequest, bedrockModelID string) (ImageResult, error) {
+	width, height, err := openai.ParseImageSize(request.Size)
+	if err != nil {
+		return ImageResult{}, &RequestError{Err: err}
+	}
+
+	s.mu.RLock()
+	client := s.invokeClient
+	s.mu.RUnlock()
+	if client == nil {
+		return ImageResult{}, errors.New("bedrock invoke client is not configured")
+	}
+
+	startedAt := time.Now()
+	invoker := s.retryingInvoker(client)
+	var result ImageResult
+	switch detectImageFamily(bedrockModelID) {
+	case imageFamilyTitan:
+		result.Images, err = generateTitanImages(ctx, invoker, bedrockModelID, request, width, height)
+	case imageFamilyStabilitySDXL:
+		result.Images, err = generateStabilitySDXLImages(ctx, invoker, bedrockModelID, request, width, height)
+	case imageFamilyStability:
+		result.Images, err = generateStabilityImages(ctx, invoker, bedrockModelID, request, width, height)
+	default:
+		return ImageResult{}, &RequestError{Err: fmt.Errorf("model %s is not a supported image model", bedrockModelID)}
+	}
+	result.LatencyMs = time.Since(startedAt).Milliseconds()
+	result.Attempts = invoker.Attempts()
+	return result, err
+}
+
+func generateTitanImages(ctx context.Context, client InvokeModelAPI, bedrockModelID string, request openai.ImagesRequest, width, height int) ([]string, error) {
+	quality := "standard"
+	switch strings.ToLower(strings.TrimSpace(request.Quality)) {
+	case "hd", "high", "premium":
+		quality = "premium"
+	}
+
+	total := request.ImageCount()
+	images := make([]string, 0, total)
+	for len(images) < total {
+		count := min(total-len(images), titanMaxImagesPerCall)
+		textParams := map[string]any{"text": request.Prompt}
+		if request.NegativePrompt != "" {
+			textParams["negativeText"] = request.NegativePrompt
+		}
+		config := map[string]any{
+			"numberOfImages": count,
+			"quality":        quality,
+		}
+		if width > 0 {
+			config["width"] = width
+			config["height"] = height
+		}
+		if request.Seed != nil {
+			config["seed"] = *request.Seed + int64(len(images))
+		}
+		body := map[string]any{
+			"taskType":              "TEXT_IMAGE",
+			"textToImageParams":     textParams,
+			"imageGenerationConfig": config,
+		}
+
+		var payload struct {
+			Images []string `json:"images"`
+			Error  string   `json:"error"`
+		}
+		if _, err := invokeModelJSON(ctx, client, bedrockModelID, body, &payload); err != nil {
+			return images, err
+		}
+		if payload.Error != "" {
+			return images, errors.New(payload.Error)
+		}
+		if len(payload.Images) == 0 {
+			return images, errors.New("bedrock returned no images")
+		}
+		images = append(images, payload.Images...)
+	}
+	return images[:total], nil
+}
+
+// generateStabilitySDXLImages 调用 SDXL；Bedrock 上的 SDXL 每次只生成一张图片（samples 只能为 1）。
+func generateStabilitySDXLImages(ctx context.Context, client InvokeModelAPI, bedrockModelID string, request openai.ImagesRequest, width, height int) ([]string, error) {
+	prompts := []map[string]any{{"text": request.Prompt, "weight": 1}}
+	if request.NegativePrompt != "" {
+		prompts = append(prompts, map[string]any{"text": request.NegativePrompt, "weight": -1})
+	}
+
+	total := request.ImageCount()
+	images := make([]string, 0, total)
+	for index := 0; index < total; index++ {
+		body := map[string]any{
+			"text_prompts": prompts,
+			"samples":      1,
+		}
+		if width > 0 {
+			body["width"] = width
+			body["height"] = height
+		}
+		if request.Seed != nil {
+			body["seed"] = *request.Seed + int64(index)
+		}
+
+		var payload struct {
+			Artifacts []struct {
+				Base64       string `json:"base64"`
+				FinishReason string `json:"finishReason"`
+			} `json:"artifacts"`
+		}
+		if _, err := invokeModelJSON(ctx, client, bedrockModelID, body, &payload); err != nil {
+			return images, err
+		}
+		if len(payload.Artifacts) == 0 {
+			return images, errors.New("bedrock returned no images")
+		}
+		artifact := payload.Artifacts[0]
+		if artifact.FinishReason == "CONTENT_FILTERED" {
+			return images, &RequestError{Err: errors.New("image was blocked by the content filter")}
+		}
+		if artifact.Base64 == "" {
+			return images, fmt.Errorf("image generation failed: %s", artifact.FinishReason)
+		}
+		images = append(images, artifact.Base64)
+	}
+	return images, nil
+}
+
+// generateStabilityImages 调用 SD3 / Stable Image 系列；这些模型只接受 aspect_ratio，按 size 选最接近的比例。
+func generateStabilityImages(ctx context.Context, client InvokeModelAPI, bedrockModelID string, request openai.ImagesRequest, width, height int) ([]string, error) {
+	total := request.ImageCount()
+	images := make([]string, 0, total)
+	for index := 0; index < total; index++ {
+		body := map[string]any{
+			"prompt":        request.Prompt,
+			"output_format": "png",
+		}
+		if width > 0 {
+			body["aspect_ratio"] = nearestAspectRatio(width, height)
+		}
+		if strings.HasPrefix(baseFoundationModelID(bedrockModelID), "stability.sd3") {
+			body["mode"] = "text-to-image"
+		}
+		if request.NegativePrompt != "" {
+			body["negative_prompt"] = request.NegativePrompt
+		}
+		if request.Seed != nil {
+			body["seed"] = *request.Seed + int64(index)
+		}
+
+		var payload struct {
+			Images        []string  `json:"images"`
+			FinishReasons []*string `json:"finish_reasons"`
+		}
+		if _, err := invokeModelJSON(ctx, client, bedrockModelID, body, &payload); err != nil {
+			return images, err
+		}
+		if len(payload.FinishReasons) > 0 && payload.FinishReasons[0] != nil {
+			return images, &RequestError{Err: fmt.Errorf("image generation was filtered: %s", *payload.FinishReasons[0])}
+		}
+		if len(payload.Images) == 0 {
+			return images, errors.New("bedrock returned no images")
+		}
+		images = append(images, payload.Images[0])
+	}
+	return images, nil
+}
+
+func nearestAspectRatio(width, height int) string {
+	target := math.Log(float64(width) / float64(height))
+	best := "1:1"
+	bestDistance := math.Inf(1)
+	for _, ratio := range stabilityAspectRatios {
+		var w, h float64
+		if _, err := fmt.Sscanf(ratio, "%g:%g", &w, &h); err != nil {
+			continue
+		}
+		if distance := math.Abs(math.Log(w/h) - target); distance < bestDistance {
+			best = ratio
+			bestDistance = distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,103 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"aws-cursor-router/internal/openai"
+)
+
+func TestGenerateImagesTitanBatchesNumberOfImages(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string {
+		config, _ := body["imageGenerationConfig"].(map[string]any)
+		count, _ := config["numberOfImages"].(float64)
+		if count == 5 {
+			return `{"images":["a","b","c","d","e"]}`
+		}
+		return `{"images":["f","g"]}`
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	n := 7
+	seed := int64(10)
+	result, err := service.GenerateImages(context.Background(), openai.ImagesRequest{
+		Prompt:  "a lighthouse",
+		N:       &n,
+		Size:    "1024x768",
+		Quality: "hd",
+		Seed:    &seed,
+	}, "amazon.titan-image-generator-v2:0")
+	if err != nil {
+		t.Fatalf("GenerateImages returned error: %v", err)
+	}
+	if len(result.Images) != 7 || result.Images[6] != "g" {
+		t.Fatalf("unexpected images: %#v", result.Images)
+	}
+	if len(client.bodies) != 2 {
+		t.Fatalf("expected 2 invocations, got %d", len(client.bodies))
+	}
+	first := client.bodies[0]["imageGenerationConfig"].(map[string]any)
+	if first["width"] != float64(1024) || first["height"] != float64(768) || first["quality"] != "premium" || first["seed"] != float64(10) {
+		t.Fatalf("unexpected titan config: %#v", first)
+	}
+	second := client.bodies[1]["imageGenerationConfig"].(map[string]any)
+	if second["numberOfImages"] != float64(2) || second["seed"] != float64(15) {
+		t.Fatalf("unexpected second titan config: %#v", second)
+	}
+}
+
+func TestGenerateImagesStabilityUsesAspectRatio(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string {
+		return `{"images":["png"],"finish_reasons":[null],"seeds":[1]}`
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	n := 2
+	result, err := service.GenerateImages(context.Background(), openai.ImagesRequest{
+		Prompt: "a lighthouse",
+		N:      &n,
+		Size:   "1792x1024",
+	}, "us.stability.sd3-5-large-v1:0")
+	if err != nil {
+		t.Fatalf("GenerateImages returned error: %v", err)
+	}
+	if len(result.Images) != 2 || len(client.bodies) != 2 {
+		t.Fatalf("expected one invocation per image, got %d images / %d calls", len(result.Images), len(client.bodies))
+	}
+	body := client.bodies[0]
+	if body["aspect_ratio"] != "16:9" || body["mode"] != "text-to-image" || body["output_format"] != "png" {
+		t.Fatalf("unexpected stability body: %#v", body)
+	}
+}
+
+func TestGenerateImagesStabilityFilteredIsRequestError(t *testing.T) {
+	client := &fakeInvokeClient{handle: func(body map[string]any) string {
+		return `{"images":[],"finish_reasons":["Filter reason: prompt"]}`
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	_, err := service.GenerateImages(context.Background(), openai.ImagesRequest{Prompt: "x"}, "stability.stable-image-core-v1:1")
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) {
+		t.Fatalf("expected RequestError, got %v", err)
+	}
+	if _, ok := client.bodies[0]["mode"]; ok {
+		t.Fatalf("stable image core request must not set mode: %#v", client.bodies[0])
+	}
+}
+
+func TestIsImageModel(t *testing.T) {
+	for modelID, want := range map[string]bool{
+		"amazon.titan-image-generator-v1":      true,
+		"amazon.nova-canvas-v1:0":              true,
+		"stability.stable-diffusion-xl-v1":     true,
+		"us.stability.stable-image-ultra-v1:1": true,
+		"amazon.titan-embed-text-v2:0":         false,
+		"anthropic.claude-3-5-sonnet":          false,
+	} {
+		if got := IsImageModel(modelID); got != want {
+			t.Fatalf("IsImageModel(%q) = %v, want %v", modelID, got, want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package bedrockproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateJSONSchema 用 response_format 中的 JSON Schema 校验模型输出。
+// 只实现结构化输出常用的子集：type / enum / const / properties / required /
+// additionalProperties / items / min*/max* / anyOf / oneOf / allOf，以及指向 #/$defs、#/definitions 的本地 $ref。
+func validateJSONSchema(value any, schema map[string]any) error {
+	validator := jsonSchemaValidator{root: schema}
+	return validator.validate(value, schema, "$", 0)
+}
+
+type jsonSchemaValidator struct {
+	root map[string]any
+}
+
+const maxJSONSchemaDepth = 64
+
+func (v jsonSchemaValidator) validate(value any, schema map[string]any, path string, depth int) error {
+	if depth > maxJSONSchemaDepth {
+		return fmt.Errorf("%s: schema nesting is too deep", path)
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return v.validate(value, resolved, path, depth+1)
+	}
+
+	if rawType, ok := schema["type"]; ok {
+		if !matchesSchemaType(value, rawType) {
+			return fmt.Errorf("%s: expected %s, got %s", path, describeSchemaType(rawType), jsonTypeName(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		matched := false
+		for _, candidate := range enum {
+			if jsonValuesEqual(value, candidate) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value is not one of the allowed enum values", path)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonValuesEqual(value, constant) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		if err := v.validateObject(typed, schema, path, depth); err != nil {
+			return err
+		}
+	case []any:
+		if err := v.validateArray(typed, schema, path, depth); err != nil {
+			return err
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(typed))
+		if limit, ok := schemaNumber(schema, "minLength"); ok && length < limit {
+			return fmt.Errorf("%s: string is shorter than %v", path, limit)
+		}
+		if limit, ok := schemaNumber(schema, "maxLength"); ok && length > limit {
+			return fmt.Errorf("%s: string is longer than %v", path, limit)
+		}
+	case float64:
+		if limit, ok := schemaNumber(schema, "minimum"); ok && typed < limit {
+			return fmt.Errorf("%s: %v is less than minimum %v", path, typed, limit)
+		}
+		if limit, ok := schemaNumber(schema, "maximum"); ok && typed > limit {
+			return fmt.Errorf("%s: %v is greater than maximum %v", path, typed, limit)
+		}
+		if limit, ok := schemaNumber(schema, "exclusiveMinimum"); ok && typed <= limit {
+			return fmt.Errorf("%s: %v must be greater than %v", path, typed, limit)
+		}
+		if limit, ok := schemaNumber(schema, "exclusiveMaximum"); ok && typed >= limit {
+			return fmt.Errorf("%s: %v must be less than %v", path, typed, limit)
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, item := range allOf {
+			if sub, ok := item.(map[string]any); ok {
+				if err := v.validate(value, sub, path, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		if v.countMatches(value, anyOf, path, depth) == 0 {
+			return fmt.Errorf("%s: value does not match any schema in anyOf", path)
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		if matches := v.countMatches(value, oneOf, path, depth); matches != 1 {
+			return fmt.Errorf("%s: value matches %d schemas in oneOf, expected exactly 1", path, matches)
+		}
+	}
+	return nil
+}
+
+func (v jsonSchemaValidator) validateObject(value map[string]any, schema map[string]any, path string, depth int) error {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if name == "" {
+				continue
+			}
+			if _, exists := value[name]; !exists {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propertySchema, ok := properties[key].(map[string]any); ok {
+			if err := v.validate(value[key], propertySchema, childPath, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, declared := properties[key]; declared {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: additional property %q is not allowed", path, key)
+			}
+		case map[string]any:
+			if err := v.validate(value[key], additional, childPath, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v jsonSchemaValidator) validateArray(value []any, schema map[string]any, path string, depth int) error {
+	count := float64(len(value))
+	if limit, ok := schemaNumber(schema, "minItems"); ok && count < limit {
+		return fmt.Errorf("%s: array has fewer than %v items", path, limit)
+	}
+	if limit, ok := schemaNumber(schema, "maxItems"); ok && count > limit {
+		return fmt.Errorf("%s: array has more than %v items", path, limit)
+	}
+
+	itemSchema, ok := schema["items"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	for index, item := range value {
+		if err := v.validate(item, itemSchema, fmt.Sprintf("%s[%d]", path, index), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v jsonSchemaValidator) countMatches(value any, schemas []any, path string, depth int) int {
+	matches := 0
+	for _, item := range schemas {
+		sub, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if v.validate(value, sub, path, depth+1) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+func (v jsonSchemaValidator) resolveRef(ref string) (map[string]any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	var current any = v.root
+	for _, segment := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		current, ok = object[segment]
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	resolved, ok := current.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return resolved, nil
+}
+
+func matchesSchemaType(value any, rawType any) bool {
+	switch typed := rawType.(type) {
+	case string:
+		return matchesSingleSchemaType(value, typed)
+	case []any:
+		for _, item := range typed {
+			if name, ok := item.(string); ok && matchesSingleSchemaType(value, name) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesSingleSchemaType(value any, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return true
+	}
+}
+
+func describeSchemaType(rawType any) string {
+	if items, ok := rawType.([]any); ok {
+		names := make([]string, 0, len(items))
+		for _, item := range items {
+			names = append(names, fmt.Sprint(item))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(rawType)
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func schemaNumber(schema map[string]any, key string) (float64, bool) {
+	switch typed := schema[key].(type) {
+	case float64:
+		return typed, true
+	case json.Number:
+		number, err := typed.Float64()
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func jsonValuesEqual(left, right any) bool {
+	return reflect.DeepEqual(left, right)
+}
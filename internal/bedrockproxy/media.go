@@ -0,0 +1,421 @@
+package bedrockproxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const (
+	// maxImageBytes 为 Bedrock Converse 单张图片的大小上限（3.75 MB）。
+	maxImageBytes = 3932160
+	// maxDocumentBytes 为 Bedrock Converse 单个文档的大小上限（4.5 MB）。
+	maxDocumentBytes = 4718592
+	// maxDocumentNameRunes 限制文档名长度，过长的文件名截断即可。
+	maxDocumentNameRunes = 200
+)
+
+// buildUserContentBlocks 将 user 消息的 content 转为 Bedrock content blocks，保持文本、图片与文档的原始顺序。
+// 连续的 text part 合并为一个文本块（与 DecodeContentAsText 的拼接方式一致）。
+func buildUserContentBlocks(raw json.RawMessage) ([]brtypes.ContentBlock, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed[0] != '[' {
+		text, err := openai.DecodeContentAsText(raw)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) == "" {
+			return nil, nil
+		}
+		return []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: text}}, nil
+	}
+
+	var parts []map[string]any
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, fmt.Errorf("invalid array content: %w", err)
+	}
+
+	blocks := make([]brtypes.ContentBlock, 0, len(parts))
+	documentNames := make(map[string]int)
+	var text strings.Builder
+	flushText := func() {
+		if strings.TrimSpace(text.String()) != "" {
+			blocks = append(blocks, &brtypes.ContentBlockMemberText{Value: text.String()})
+		}
+		text.Reset()
+	}
+
+	for _, part := range parts {
+		partType := strings.ToLower(strings.TrimSpace(stringField(part, "type")))
+		if partType == "" || partType == "text" {
+			text.WriteString(stringField(part, "text"))
+			continue
+		}
+
+		image, ok, err := imageBlockFromPart(part)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			flushText()
+			blocks = append(blocks, &brtypes.ContentBlockMemberImage{Value: image})
+			continue
+		}
+
+		document, ok, err := documentBlockFromPart(part)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			flushText()
+			// Bedrock 要求同一条消息中的文档名互不相同
+			name := aws.ToString(document.Name)
+			documentNames[name]++
+			if count := documentNames[name]; count > 1 {
+				document.Name = aws.String(fmt.Sprintf("%s (%d)", name, count))
+			}
+			blocks = append(blocks, &brtypes.ContentBlockMemberDocument{Value: document})
+		}
+	}
+	flushText()
+	return blocks, nil
+}
+
+// imageBlockFromPart 识别三种图片 part：
+//   - OpenAI Chat：{"type":"image_url","image_url":{"url":"data:image/png;base64,..."}}
+//   - Responses：{"type":"input_image","image_url":"data:..."}
+//   - Anthropic：{"type":"image","source":{"type":"base64","media_type":"image/png","data":"..."}}
+//
+// url 既可以是 data: URI，也可以是不带前缀的 base64；远程 http(s) 链接不会被代理下载。
+func imageBlockFromPart(part map[string]any) (brtypes.ImageBlock, bool, error) {
+	partType := strings.ToLower(strings.TrimSpace(stringField(part, "type")))
+
+	switch partType {
+	case "image_url", "input_image":
+		url := stringField(part, "image_url")
+		if nested, ok := part["image_url"].(map[string]any); ok {
+			url = stringField(nested, "url")
+		}
+		if strings.TrimSpace(url) == "" {
+			if stringField(part, "file_id") != "" {
+				return brtypes.ImageBlock{}, false, errors.New("image file_id references are not supported; send the image as a data: URI")
+			}
+			return brtypes.ImageBlock{}, false, fmt.Errorf("%s part requires image_url", partType)
+		}
+		mediaType, payload, err := splitDataURI(url, "image")
+		if err != nil {
+			return brtypes.ImageBlock{}, false, err
+		}
+		image, err := buildImageBlock(mediaType, payload)
+		return image, err == nil, err
+
+	case "image":
+		source, _ := part["source"].(map[string]any)
+		sourceType := strings.ToLower(strings.TrimSpace(stringField(source, "type")))
+		if sourceType != "base64" {
+			return brtypes.ImageBlock{}, false, fmt.Errorf("unsupported image source type: %q (only base64 is supported)", sourceType)
+		}
+		image, err := buildImageBlock(stringField(source, "media_type"), stringField(source, "data"))
+		return image, err == nil, err
+
+	default:
+		return brtypes.ImageBlock{}, false, nil
+	}
+}
+
+// splitDataURI 拆分 data:[<media type>][;base64],<data>；非 data: URI 视为裸 base64。
+// kind 仅用于错误信息（image / file）。
+func splitDataURI(url string, kind string) (string, string, error) {
+	url = strings.TrimSpace(url)
+	lower := strings.ToLower(url)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return "", "", fmt.Errorf("remote %s URLs are not supported; send the %s as a data: URI or base64 payload", kind, kind)
+	}
+	if !strings.HasPrefix(lower, "data:") {
+		return "", url, nil
+	}
+
+	header, payload, found := strings.Cut(url[len("data:"):], ",")
+	if !found {
+		return "", "", errors.New("invalid data URI: missing ',' separator")
+	}
+	params := strings.Split(header, ";")
+	isBase64 := false
+	for _, param := range params[1:] {
+		if strings.EqualFold(strings.TrimSpace(param), "base64") {
+			isBase64 = true
+		}
+	}
+	if !isBase64 {
+		return "", "", errors.New("invalid data URI: only base64-encoded data is supported")
+	}
+	return strings.TrimSpace(params[0]), payload, nil
+}
+
+func buildImageBlock(mediaType string, payload string) (brtypes.ImageBlock, error) {
+	data, err := decodeBase64Payload(payload)
+	if err != nil {
+		return brtypes.ImageBlock{}, fmt.Errorf("invalid base64 image data: %w", err)
+	}
+	if len(data) == 0 {
+		return brtypes.ImageBlock{}, errors.New("image data is empty")
+	}
+	if len(data) > maxImageBytes {
+		return brtypes.ImageBlock{}, fmt.Errorf("image is %d bytes, exceeds the %d byte limit", len(data), maxImageBytes)
+	}
+
+	// 优先按文件头识别格式：客户端声明的 media type 经常与实际内容不符，而 Bedrock 会校验真实格式。
+	format, ok := detectImageFormat(data)
+	if !ok {
+		format, ok = imageFormatFromMediaType(mediaType)
+	}
+	if !ok {
+		return brtypes.ImageBlock{}, fmt.Errorf("unsupported image format (media type %q); supported formats are png, jpeg, gif and webp", mediaType)
+	}
+
+	return brtypes.ImageBlock{
+		Format: format,
+		Source: &brtypes.ImageSourceMemberBytes{Value: data},
+	}, nil
+}
+
+// documentBlockFromPart 识别文件 part 并转为 Bedrock DocumentBlock：
+//   - OpenAI Chat：{"type":"file","file":{"filename":"spec.pdf","file_data":"data:application/pdf;base64,..."}}
+//   - Responses：{"type":"input_file","filename":"spec.pdf","file_data":"data:application/pdf;base64,..."}
+//   - Anthropic：{"type":"document","title":"spec","source":{"type":"base64","media_type":"application/pdf","data":"..."}}
+//
+// file_id / file_url 引用无法在代理侧解析，直接报错而不是静默丢弃。
+func documentBlockFromPart(part map[string]any) (brtypes.DocumentBlock, bool, error) {
+	partType := strings.ToLower(strings.TrimSpace(stringField(part, "type")))
+
+	var filename, fileData, fileID, fileURL string
+	switch partType {
+	case "file":
+		file, _ := part["file"].(map[string]any)
+		filename = stringField(file, "filename")
+		fileData = stringField(file, "file_data")
+		fileID = stringField(file, "file_id")
+	case "input_file":
+		filename = stringField(part, "filename")
+		fileData = stringField(part, "file_data")
+		fileID = stringField(part, "file_id")
+		fileURL = stringField(part, "file_url")
+	case "document":
+		source, _ := part["source"].(map[string]any)
+		filename = stringField(part, "title")
+		switch sourceType := strings.ToLower(strings.TrimSpace(stringField(source, "type"))); sourceType {
+		case "base64":
+			document, err := buildDocumentBlock(stringField(source, "media_type"), filename, stringField(source, "data"))
+			return document, err == nil, err
+		case "text":
+			document, err := buildDocumentBlockFromBytes("text/plain", filename, []byte(stringField(source, "data")))
+			return document, err == nil, err
+		default:
+			return brtypes.DocumentBlock{}, false, fmt.Errorf("unsupported document source type: %q (only base64 and text are supported)", sourceType)
+		}
+	default:
+		return brtypes.DocumentBlock{}, false, nil
+	}
+
+	if strings.TrimSpace(fileData) == "" {
+		switch {
+		case strings.TrimSpace(fileID) != "":
+			return brtypes.DocumentBlock{}, false, errors.New("file_id references are not supported; send the file inline as file_data")
+		case strings.TrimSpace(fileURL) != "":
+			return brtypes.DocumentBlock{}, false, errors.New("file_url references are not supported; send the file inline as file_data")
+		default:
+			return brtypes.DocumentBlock{}, false, fmt.Errorf("%s part requires file_data", partType)
+		}
+	}
+
+	mediaType, payload, err := splitDataURI(fileData, "file")
+	if err != nil {
+		return brtypes.DocumentBlock{}, false, err
+	}
+	document, err := buildDocumentBlock(mediaType, filename, payload)
+	return document, err == nil, err
+}
+
+func buildDocumentBlock(mediaType string, filename string, payload string) (brtypes.DocumentBlock, error) {
+	data, err := decodeBase64Payload(payload)
+	if err != nil {
+		return brtypes.DocumentBlock{}, fmt.Errorf("invalid base64 file data: %w", err)
+	}
+	return buildDocumentBlockFromBytes(mediaType, filename, data)
+}
+
+func buildDocumentBlockFromBytes(mediaType string, filename string, data []byte) (brtypes.DocumentBlock, error) {
+	if len(data) == 0 {
+		return brtypes.DocumentBlock{}, errors.New("file data is empty")
+	}
+	if len(data) > maxDocumentBytes {
+		return brtypes.DocumentBlock{}, fmt.Errorf("file is %d bytes, exceeds the %d byte limit", len(data), maxDocumentBytes)
+	}
+
+	format, ok := documentFormatFromMediaType(mediaType)
+	if !ok {
+		format, ok = documentFormatFromFilename(filename)
+	}
+	if !ok {
+		label := strings.TrimSpace(mediaType)
+		if label == "" {
+			label = strings.TrimSpace(filename)
+		}
+		return brtypes.DocumentBlock{}, fmt.Errorf("unsupported file type %q; supported types are pdf, csv, doc, docx, xls, xlsx, html, txt and md", label)
+	}
+
+	return brtypes.DocumentBlock{
+		Name:   aws.String(sanitizeDocumentName(filename)),
+		Format: format,
+		Source: &brtypes.DocumentSourceMemberBytes{Value: data},
+	}, nil
+}
+
+func documentFormatFromMediaType(mediaType string) (brtypes.DocumentFormat, bool) {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "application/pdf":
+		return brtypes.DocumentFormatPdf, true
+	case "text/csv":
+		return brtypes.DocumentFormatCsv, true
+	case "application/msword":
+		return brtypes.DocumentFormatDoc, true
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return brtypes.DocumentFormatDocx, true
+	case "application/vnd.ms-excel":
+		return brtypes.DocumentFormatXls, true
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return brtypes.DocumentFormatXlsx, true
+	case "text/html":
+		return brtypes.DocumentFormatHtml, true
+	case "text/plain":
+		return brtypes.DocumentFormatTxt, true
+	case "text/markdown", "text/x-markdown":
+		return brtypes.DocumentFormatMd, true
+	default:
+		return "", false
+	}
+}
+
+// documentFormatFromFilename 在 MIME 缺失或为 application/octet-stream 时按扩展名推断格式。
+func documentFormatFromFilename(filename string) (brtypes.DocumentFormat, bool) {
+	index := strings.LastIndex(filename, ".")
+	if index < 0 {
+		return "", false
+	}
+	switch strings.ToLower(filename[index+1:]) {
+	case "pdf":
+		return brtypes.DocumentFormatPdf, true
+	case "csv":
+		return brtypes.DocumentFormatCsv, true
+	case "doc":
+		return brtypes.DocumentFormatDoc, true
+	case "docx":
+		return brtypes.DocumentFormatDocx, true
+	case "xls":
+		return brtypes.DocumentFormatXls, true
+	case "xlsx":
+		return brtypes.DocumentFormatXlsx, true
+	case "html", "htm":
+		return brtypes.DocumentFormatHtml, true
+	case "txt":
+		return brtypes.DocumentFormatTxt, true
+	case "md", "markdown":
+		return brtypes.DocumentFormatMd, true
+	default:
+		return "", false
+	}
+}
+
+// sanitizeDocumentName 生成符合 Bedrock 要求的文档名：只允许字母数字、单个空白、连字符、圆括号和方括号。
+func sanitizeDocumentName(filename string) string {
+	name := strings.TrimSpace(filename)
+	if index := strings.LastIndex(name, "."); index > 0 {
+		name = name[:index]
+	}
+
+	var builder strings.Builder
+	lastSpace := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '(' || r == ')' || r == '[' || r == ']':
+			builder.WriteRune(r)
+			lastSpace = false
+		default:
+			if !lastSpace && builder.Len() > 0 {
+				builder.WriteRune(' ')
+				lastSpace = true
+			}
+		}
+	}
+
+	name = strings.TrimSpace(builder.String())
+	if name == "" {
+		return "document"
+	}
+	if runes := []rune(name); len(runes) > maxDocumentNameRunes {
+		name = strings.TrimSpace(string(runes[:maxDocumentNameRunes]))
+	}
+	return name
+}
+
+func decodeBase64Payload(payload string) ([]byte, error) {
+	payload = strings.Join(strings.Fields(payload), "")
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err == nil {
+		return data, nil
+	}
+	if data, rawErr := base64.RawStdEncoding.DecodeString(payload); rawErr == nil {
+		return data, nil
+	}
+	if data, urlErr := base64.URLEncoding.DecodeString(payload); urlErr == nil {
+		return data, nil
+	}
+	return nil, err
+}
+
+func detectImageFormat(data []byte) (brtypes.ImageFormat, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return brtypes.ImageFormatPng, true
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return brtypes.ImageFormatJpeg, true
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return brtypes.ImageFormatGif, true
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return brtypes.ImageFormatWebp, true
+	default:
+		return "", false
+	}
+}
+
+func imageFormatFromMediaType(mediaType string) (brtypes.ImageFormat, bool) {
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "image/png":
+		return brtypes.ImageFormatPng, true
+	case "image/jpeg", "image/jpg":
+		return brtypes.ImageFormatJpeg, true
+	case "image/gif":
+		return brtypes.ImageFormatGif, true
+	case "image/webp":
+		return brtypes.ImageFormatWebp, true
+	default:
+		return "", false
+	}
+}
+
+func stringField(item map[string]any, key string) string {
+	if item == nil {
+		return ""
+	}
+	value, _ := item[key].(string)
+	return value
+}
@@ -0,0 +1,181 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"aws-cursor-router/internal/openai"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestBuildBedrockMessagesImageURLDataURI(t *testing.T) {
+	dataURI := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(testPNG)
+	content, _ := json.Marshal([]map[string]any{
+		{"type": "text", "text": "before "},
+		{"type": "image_url", "image_url": map[string]any{"url": dataURI, "detail": "high"}},
+		{"type": "text", "text": "after"},
+	})
+
+	out, _, err := BuildBedrockMessages([]openai.ChatMessage{{Role: "user", Content: content}})
+	if err != nil {
+		t.Fatalf("BuildBedrockMessages returned error: %v", err)
+	}
+	if len(out) != 1 || len(out[0].Content) != 3 {
+		t.Fatalf("expected text, image, text blocks, got %#v", out)
+	}
+	image, ok := out[0].Content[1].(*brtypes.ContentBlockMemberImage)
+	if !ok {
+		t.Fatalf("expected image block in the middle, got %T", out[0].Content[1])
+	}
+	// 声明为 jpeg，但文件头是 png：以实际内容为准
+	if image.Value.Format != brtypes.ImageFormatPng {
+		t.Fatalf("unexpected image format: %s", image.Value.Format)
+	}
+	if text, ok := out[0].Content[2].(*brtypes.ContentBlockMemberText); !ok || text.Value != "after" {
+		t.Fatalf("unexpected trailing block: %#v", out[0].Content[2])
+	}
+}
+
+func TestBuildBedrockMessagesRawBase64Image(t *testing.T) {
+	content, _ := json.Marshal([]map[string]any{
+		{"type": "input_image", "image_url": base64.StdEncoding.EncodeToString(testPNG)},
+	})
+
+	out, _, err := BuildBedrockMessages([]openai.ChatMessage{{Role: "user", Content: content}})
+	if err != nil {
+		t.Fatalf("BuildBedrockMessages returned error: %v", err)
+	}
+	if _, ok := out[0].Content[0].(*brtypes.ContentBlockMemberImage); !ok {
+		t.Fatalf("expected image block, got %T", out[0].Content[0])
+	}
+}
+
+func TestBuildBedrockMessagesRejectsInvalidImages(t *testing.T) {
+	oversized := "data:image/png;base64," + base64.StdEncoding.EncodeToString(append(testPNG, make([]byte, maxImageBytes)...))
+	cases := map[string]string{
+		"remote url":  "https://example.com/cat.png",
+		"bad base64":  "data:image/png;base64,***",
+		"unsupported": "data:image/bmp;base64," + base64.StdEncoding.EncodeToString([]byte("BMxxxx")),
+		"oversized":   oversized,
+	}
+
+	for name, url := range cases {
+		content, _ := json.Marshal([]map[string]any{
+			{"type": "image_url", "image_url": map[string]any{"url": url}},
+		})
+		_, _, err := BuildBedrockMessages([]openai.ChatMessage{{Role: "user", Content: content}})
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestBuildBedrockMessagesToolResultImage(t *testing.T) {
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG)
+	content, _ := json.Marshal([]map[string]any{
+		{"type": "text", "text": "screenshot taken"},
+		{"type": "image_url", "image_url": map[string]any{"url": dataURI}},
+	})
+	messages := []openai.ChatMessage{
+		{Role: "user", Content: json.RawMessage(`"take a screenshot"`)},
+		{
+			Role:    "assistant",
+			Content: json.RawMessage(`null`),
+			ToolCalls: []openai.ToolCall{{
+				ID:       "call_1",
+				Type:     "function",
+				Function: openai.ToolCallFunction{Name: "screenshot", Arguments: `{}`},
+			}},
+		},
+		{Role: "tool", ToolCallID: "call_1", Content: content},
+	}
+
+	out, _, err := BuildBedrockMessages(messages)
+	if err != nil {
+		t.Fatalf("BuildBedrockMessages returned error: %v", err)
+	}
+	toolResult, ok := out[2].Content[0].(*brtypes.ContentBlockMemberToolResult)
+	if !ok {
+		t.Fatalf("expected tool result block, got %T", out[2].Content[0])
+	}
+	if len(toolResult.Value.Content) != 2 {
+		t.Fatalf("expected text + image tool result content, got %d", len(toolResult.Value.Content))
+	}
+	if _, ok := toolResult.Value.Content[1].(*brtypes.ToolResultContentBlockMemberImage); !ok {
+		t.Fatalf("expected image tool result content, got %T", toolResult.Value.Content[1])
+	}
+}
+
+func TestConverseReturnsRequestErrorForInvalidImage(t *testing.T) {
+	service := NewService(nil, "anthropic.default", nil, 2048, 8192, false, false)
+	_, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{{
+			Role:    "user",
+			Content: json.RawMessage(`[{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]`),
+		}},
+	}, "anthropic.default")
+	if !IsRequestError(err) {
+		t.Fatalf("expected RequestError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "remote image URLs") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+}
+
+var testPDF = []byte("%PDF-1.4\n%test\n")
+
+func TestBuildBedrockMessagesFileParts(t *testing.T) {
+	pdfData := "data:application/pdf;base64," + base64.StdEncoding.EncodeToString(testPDF)
+	content, _ := json.Marshal([]map[string]any{
+		{"type": "text", "text": "summarize these"},
+		{"type": "file", "file": map[string]any{"filename": "report.pdf", "file_data": pdfData}},
+		{"type": "input_file", "filename": "report.pdf", "file_data": base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n"))},
+	})
+
+	out, _, err := BuildBedrockMessages([]openai.ChatMessage{{Role: "user", Content: content}})
+	if err != nil {
+		t.Fatalf("BuildBedrockMessages returned error: %v", err)
+	}
+	if len(out) != 1 || len(out[0].Content) != 3 {
+		t.Fatalf("expected text + 2 document blocks, got %#v", out)
+	}
+	first, ok := out[0].Content[1].(*brtypes.ContentBlockMemberDocument)
+	if !ok {
+		t.Fatalf("expected document block, got %T", out[0].Content[1])
+	}
+	if first.Value.Format != brtypes.DocumentFormatPdf {
+		t.Fatalf("unexpected document format: %s", first.Value.Format)
+	}
+	if first.Value.Name == nil || *first.Value.Name != "report" {
+		t.Fatalf("unexpected document name: %v", first.Value.Name)
+	}
+	second, ok := out[0].Content[2].(*brtypes.ContentBlockMemberDocument)
+	if !ok {
+		t.Fatalf("expected document block, got %T", out[0].Content[2])
+	}
+	// 同一条消息中重名的文档需要去重
+	if second.Value.Name == nil || *second.Value.Name == *first.Value.Name {
+		t.Fatalf("expected deduplicated document name, got %v", second.Value.Name)
+	}
+}
+
+func TestBuildBedrockMessagesRejectsInvalidFiles(t *testing.T) {
+	cases := map[string]map[string]any{
+		"unsupported type": {"type": "file", "file": map[string]any{"filename": "app.exe", "file_data": "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString([]byte("MZ"))}},
+		"file id":          {"type": "file", "file": map[string]any{"file_id": "file-abc"}},
+		"file url":         {"type": "input_file", "file_url": "https://example.com/report.pdf"},
+	}
+
+	for name, part := range cases {
+		content, _ := json.Marshal([]map[string]any{part})
+		_, _, err := BuildBedrockMessages([]openai.ChatMessage{{Role: "user", Content: content}})
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
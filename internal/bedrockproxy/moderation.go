@@ -0,0 +1,176 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// ApplyGuardrailAPI 是 /v1/moderations 使用的 Bedrock ApplyGuardrail 调用：不经过模型，直接用 Guardrail 评估文本。
+type ApplyGuardrailAPI interface {
+	ApplyGuardrail(ctx context.Context, params *bedrockruntime.ApplyGuardrailInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ApplyGuardrailOutput, error)
+}
+
+// guardrailContentCategories 把 Guardrail 内容过滤器映射到 OpenAI 类别；PROMPT_ATTACK 没有对应类别，作为扩展类别返回。
+var guardrailContentCategories = map[brtypes.GuardrailContentFilterType]string{
+	brtypes.GuardrailContentFilterTypeHate:         "hate",
+	brtypes.GuardrailContentFilterTypeInsults:      "harassment",
+	brtypes.GuardrailContentFilterTypeSexual:       "sexual",
+	brtypes.GuardrailContentFilterTypeViolence:     "violence",
+	brtypes.GuardrailContentFilterTypeMisconduct:   "illicit",
+	brtypes.GuardrailContentFilterTypePromptAttack: "prompt_attack",
+}
+
+// guardrailConfidenceScores 把过滤器的置信度等级换算为 category_scores。
+var guardrailConfidenceScores = map[brtypes.GuardrailContentFilterConfidence]float64{
+	brtypes.GuardrailContentFilterConfidenceNone:   0,
+	brtypes.GuardrailContentFilterConfidenceLow:    0.3,
+	brtypes.GuardrailContentFilterConfidenceMedium: 0.6,
+	brtypes.GuardrailContentFilterConfidenceHigh:   0.9,
+}
+
+// moderationPIICategory 是敏感信息（PII 实体与正则）命中时返回的扩展类别。
+const moderationPIICategory = "pii"
+
+type ModerationResult struct {
+	Results []openai.ModerationResult
+	// 任一输入触发了 Guardrail 介入
+	Intervened bool
+	LatencyMs  int64
+	// 1 + 限流 / 瞬时错误后的重试次数
+	Attempts int
+}
+
+func (s *Service) HasGuardrailClient() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.guardrailClient != nil
+}
+
+// Moderate 对每条输入调用一次 ApplyGuardrail（source=INPUT，outputScope=FULL 以拿到未拦截的评估），
+// 并把主题、内容过滤与敏感信息评估映射为 OpenAI 的 categories / category_scores / flagged。
+func (s *Service) Moderate(ctx context.Context, inputs []string, guardrail *openai.GuardrailConfig) (ModerationResult, error) {
+	if guardrail == nil || strings.TrimSpace(guardrail.Identifier) == "" {
+		return ModerationResult{}, &RequestError{Err: errors.New("no guardrail is configured for moderation")}
+	}
+
+	s.mu.RLock()
+	client := s.guardrailClient
+	s.mu.RUnlock()
+	if client == nil {
+		return ModerationResult{}, errors.New("bedrock guardrail client is not configured")
+	}
+
+	startedAt := time.Now()
+	result := ModerationResult{Results: make([]openai.ModerationResult, 0, len(inputs)), Attempts: 1}
+	for _, input := range inputs {
+		var output *bedrockruntime.ApplyGuardrailOutput
+		attempts, err := s.retry(ctx, "ApplyGuardrail", nil, func() error {
+			var err error
+			output, err = client.ApplyGuardrail(ctx, &bedrockruntime.ApplyGuardrailInput{
+				GuardrailIdentifier: aws.String(strings.TrimSpace(guardrail.Identifier)),
+				GuardrailVersion:    aws.String(strings.TrimSpace(guardrail.Version)),
+				Source:              brtypes.GuardrailContentSourceInput,
+				OutputScope:         brtypes.GuardrailOutputScopeFull,
+				Content: []brtypes.GuardrailContentBlock{
+					&brtypes.GuardrailContentBlockMemberText{Value: brtypes.GuardrailTextBlock{Text: aws.String(input)}},
+				},
+			})
+			return err
+		})
+		result.Attempts += attempts - 1
+		if err != nil {
+			result.LatencyMs = time.Since(startedAt).Milliseconds()
+			return result, err
+		}
+		intervened := output.Action == brtypes.GuardrailActionGuardrailIntervened
+		result.Intervened = result.Intervened || intervened
+		result.Results = append(result.Results, buildModerationResult(output.Assessments, intervened))
+	}
+	result.LatencyMs = time.Since(startedAt).Milliseconds()
+	return result, nil
+}
+
+// buildModerationResult 合并一次 ApplyGuardrail 的全部评估。未显式返回 detected 的旧版响应按 action 是否为 NONE 判断；
+// 拒绝主题的名称若与 OpenAI 类别一致（忽略大小写，空格 / 下划线视为 -）则计入该类别，否则以主题名作为扩展类别。
+// 词语过滤等没有对应类别的策略介入时，只设置 flagged。
+func buildModerationResult(assessments []brtypes.GuardrailAssessment, intervened bool) openai.ModerationResult {
+	result := openai.NewModerationResult()
+	mark := func(category string, detected bool, score float64) {
+		result.Categories[category] = result.Categories[category] || detected
+		result.CategoryScores[category] = max(result.CategoryScores[category], score)
+	}
+
+	for _, assessment := range assessments {
+		if policy := assessment.ContentPolicy; policy != nil {
+			for _, filter := range policy.Filters {
+				category, ok := guardrailContentCategories[filter.Type]
+				if !ok {
+					continue
+				}
+				detected := guardrailDetected(filter.Detected, string(filter.Action))
+				mark(category, detected, guardrailConfidenceScores[filter.Confidence])
+			}
+		}
+		if policy := assessment.TopicPolicy; policy != nil {
+			for _, topic := range policy.Topics {
+				category := moderationTopicCategory(aws.ToString(topic.Name))
+				if category == "" {
+					continue
+				}
+				detected := guardrailDetected(topic.Detected, string(topic.Action))
+				mark(category, detected, boolScore(detected))
+			}
+		}
+		if policy := assessment.SensitiveInformationPolicy; policy != nil {
+			for _, entity := range policy.PiiEntities {
+				detected := guardrailDetected(entity.Detected, string(entity.Action))
+				mark(moderationPIICategory, detected, boolScore(detected))
+			}
+			for _, regex := range policy.Regexes {
+				detected := guardrailDetected(regex.Detected, string(regex.Action))
+				mark(moderationPIICategory, detected, boolScore(detected))
+			}
+		}
+	}
+
+	result.Flagged = intervened
+	for _, flagged := range result.Categories {
+		result.Flagged = result.Flagged || flagged
+	}
+	return result
+}
+
+func guardrailDetected(detected *bool, action string) bool {
+	if detected != nil {
+		return *detected
+	}
+	return action != "" && action != "NONE"
+}
+
+func boolScore(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func moderationTopicCategory(name string) string {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return ""
+	}
+	normalized = strings.NewReplacer(" ", "-", "_", "-").Replace(normalized)
+	for _, category := range openai.ModerationCategories {
+		if normalized == category {
+			return category
+		}
+	}
+	return strings.TrimSpace(name)
+}
@@ -0,0 +1,88 @@
+package bedrockproxy
+
+import (
+	"context"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+type fakeGuardrailClient struct {
+	bedrocktest.Client
+	inputs []*bedrockruntime.ApplyGuardrailInput
+	handle func(text string) *bedrockruntime.ApplyGuardrailOutput
+}
+
+func (f *fakeGuardrailClient) ApplyGuardrail(ctx context.Context, params *bedrockruntime.ApplyGuardrailInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ApplyGuardrailOutput, error) {
+	f.inputs = append(f.inputs, params)
+	text := params.Content[0].(*brtypes.GuardrailContentBlockMemberText).Value.Text
+	return f.handle(aws.ToString(text)), nil
+}
+
+func TestModerateMapsGuardrailAssessments(t *testing.T) {
+	client := &fakeGuardrailClient{handle: func(text string) *bedrockruntime.ApplyGuardrailOutput {
+		if text == "fine" {
+			return &bedrockruntime.ApplyGuardrailOutput{
+				Action: brtypes.GuardrailActionNone,
+				Assessments: []brtypes.GuardrailAssessment{{
+					ContentPolicy: &brtypes.GuardrailContentPolicyAssessment{Filters: []brtypes.GuardrailContentFilter{{
+						Type:       brtypes.GuardrailContentFilterTypeHate,
+						Confidence: brtypes.GuardrailContentFilterConfidenceLow,
+						Action:     brtypes.GuardrailContentPolicyActionNone,
+						Detected:   aws.Bool(false),
+					}}},
+				}},
+			}
+		}
+		return &bedrockruntime.ApplyGuardrailOutput{
+			Action: brtypes.GuardrailActionGuardrailIntervened,
+			Assessments: []brtypes.GuardrailAssessment{{
+				ContentPolicy: &brtypes.GuardrailContentPolicyAssessment{Filters: []brtypes.GuardrailContentFilter{{
+					Type:       brtypes.GuardrailContentFilterTypeInsults,
+					Confidence: brtypes.GuardrailContentFilterConfidenceHigh,
+					Action:     brtypes.GuardrailContentPolicyActionBlocked,
+				}}},
+				TopicPolicy: &brtypes.GuardrailTopicPolicyAssessment{Topics: []brtypes.GuardrailTopic{
+					{Name: aws.String("Self Harm"), Action: brtypes.GuardrailTopicPolicyActionBlocked, Detected: aws.Bool(true)},
+					{Name: aws.String("Investment advice"), Action: brtypes.GuardrailTopicPolicyActionBlocked},
+				}},
+				SensitiveInformationPolicy: &brtypes.GuardrailSensitiveInformationPolicyAssessment{PiiEntities: []brtypes.GuardrailPiiEntityFilter{{
+					Type:   brtypes.GuardrailPiiEntityTypeEmail,
+					Action: brtypes.GuardrailSensitiveInformationPolicyActionAnonymized,
+				}}},
+			}},
+		}
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	result, err := service.Moderate(context.Background(), []string{"fine", "bad"}, &openai.GuardrailConfig{Identifier: "gr-1", Version: "DRAFT"})
+	if err != nil {
+		t.Fatalf("Moderate returned error: %v", err)
+	}
+	if len(client.inputs) != 2 || client.inputs[0].Source != brtypes.GuardrailContentSourceInput || client.inputs[0].OutputScope != brtypes.GuardrailOutputScopeFull {
+		t.Fatalf("unexpected ApplyGuardrail inputs: %#v", client.inputs)
+	}
+	if !result.Intervened || len(result.Results) != 2 {
+		t.Fatalf("unexpected moderation result: %#v", result)
+	}
+
+	fine := result.Results[0]
+	if fine.Flagged || fine.Categories["hate"] || fine.CategoryScores["hate"] != 0.3 {
+		t.Fatalf("unexpected result for unflagged input: %#v", fine)
+	}
+	if len(fine.Categories) != len(openai.ModerationCategories) {
+		t.Fatalf("expected every OpenAI category to be present, got %d", len(fine.Categories))
+	}
+
+	bad := result.Results[1]
+	if !bad.Flagged || !bad.Categories["harassment"] || bad.CategoryScores["harassment"] != 0.9 {
+		t.Fatalf("expected harassment to be flagged: %#v", bad)
+	}
+	if !bad.Categories["self-harm"] || !bad.Categories["Investment advice"] || !bad.Categories["pii"] {
+		t.Fatalf("expected topic and pii categories to be flagged: %#v", bad.Categories)
+	}
+}
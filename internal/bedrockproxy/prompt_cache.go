@@ -0,0 +1,81 @@
+package bedrockproxy
+
+import (
+	"strings"
+
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// PromptCachePolicy 描述某个模型的提示缓存插入位置（管理员按模型配置）。
+// Bedrock 每个请求最多 4 个 cachePoint，这里最多插入 3 个。
+type PromptCachePolicy struct {
+	System   bool
+	Tools    bool
+	Messages bool
+}
+
+func (p PromptCachePolicy) enabled() bool {
+	return p.System || p.Tools || p.Messages
+}
+
+// SetPromptCachePolicies 替换按模型 ID 配置的提示缓存策略；未配置的模型不插入 cachePoint。
+func (s *Service) SetPromptCachePolicies(policies map[string]PromptCachePolicy) {
+	normalized := make(map[string]PromptCachePolicy, len(policies))
+	for modelID, policy := range policies {
+		modelID = strings.ToLower(strings.TrimSpace(modelID))
+		if modelID == "" || !policy.enabled() {
+			continue
+		}
+		normalized[modelID] = policy
+	}
+
+	s.mu.Lock()
+	s.promptCachePolicies = normalized
+	s.mu.Unlock()
+}
+
+func (s *Service) promptCachePolicy(bedrockModelID string) PromptCachePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.promptCachePolicies[strings.ToLower(strings.TrimSpace(bedrockModelID))]
+}
+
+func newCachePoint() brtypes.CachePointBlock {
+	return brtypes.CachePointBlock{Type: brtypes.CachePointTypeDefault}
+}
+
+// applyCachePoints 按策略在 system 末尾、tools 末尾以及最后一个 user 轮次末尾追加 cachePoint。
+// 最后一个 user 轮次之前的内容在下一轮请求中保持不变，因此下一轮可以命中这里写入的缓存。
+func applyCachePoints(
+	policy PromptCachePolicy,
+	messages []brtypes.Message,
+	system []brtypes.SystemContentBlock,
+	toolConfig *brtypes.ToolConfiguration,
+) ([]brtypes.Message, []brtypes.SystemContentBlock) {
+	if policy.System && len(system) > 0 {
+		system = append(system, &brtypes.SystemContentBlockMemberCachePoint{Value: newCachePoint()})
+	}
+	if policy.Tools && toolConfig != nil && len(toolConfig.Tools) > 0 {
+		toolConfig.Tools = append(toolConfig.Tools, &brtypes.ToolMemberCachePoint{Value: newCachePoint()})
+	}
+	if policy.Messages {
+		for index := len(messages) - 1; index >= 0; index-- {
+			if messages[index].Role != brtypes.ConversationRoleUser || len(messages[index].Content) == 0 {
+				continue
+			}
+			content := make([]brtypes.ContentBlock, 0, len(messages[index].Content)+1)
+			content = append(content, messages[index].Content...)
+			messages[index].Content = append(content, &brtypes.ContentBlockMemberCachePoint{Value: newCachePoint()})
+			break
+		}
+	}
+	return messages, system
+}
+
+// cacheTokensFromUsage 读取提示缓存读取 / 写入的 token 数。
+func cacheTokensFromUsage(usage *brtypes.TokenUsage) (int, int) {
+	if usage == nil {
+		return 0, 0
+	}
+	return int(ptrInt32(usage.CacheReadInputTokens)), int(ptrInt32(usage.CacheWriteInputTokens))
+}
@@ -0,0 +1,96 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestConverseInsertsCachePoints(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: "ok"}},
+		}},
+		Usage: &brtypes.TokenUsage{
+			InputTokens:           aws.Int32(10),
+			OutputTokens:          aws.Int32(2),
+			TotalTokens:           aws.Int32(1512),
+			CacheReadInputTokens:  aws.Int32(1200),
+			CacheWriteInputTokens: aws.Int32(300),
+		},
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+	service.SetPromptCachePolicies(map[string]PromptCachePolicy{
+		"US.Anthropic.Claude-3-7-Sonnet-20250219-v1:0": {System: true, Tools: true, Messages: true},
+	})
+
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{
+			{Role: "system", Content: json.RawMessage(`"be brief"`)},
+			{Role: "user", Content: json.RawMessage(`"first"`)},
+			{Role: "assistant", Content: json.RawMessage(`"ok"`)},
+			{Role: "user", Content: json.RawMessage(`"second"`)},
+		},
+		Tools: []openai.Tool{{
+			Type:     "function",
+			Function: &openai.ToolFunction{Name: "read", Parameters: json.RawMessage(`{"type":"object"}`)},
+		}},
+	}, "us.anthropic.claude-3-7-sonnet-20250219-v1:0")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	if result.CacheReadInputTokens != 1200 || result.CacheWriteInputTokens != 300 {
+		t.Fatalf("unexpected cache usage: %+v", result)
+	}
+
+	input := client.LastInput()
+	if _, ok := input.System[len(input.System)-1].(*brtypes.SystemContentBlockMemberCachePoint); !ok {
+		t.Fatalf("expected cache point after system blocks")
+	}
+	tools := input.ToolConfig.Tools
+	if _, ok := tools[len(tools)-1].(*brtypes.ToolMemberCachePoint); !ok {
+		t.Fatalf("expected cache point after tools")
+	}
+	for index, message := range input.Messages {
+		last := message.Content[len(message.Content)-1]
+		_, isCachePoint := last.(*brtypes.ContentBlockMemberCachePoint)
+		if isCachePoint != (index == len(input.Messages)-1) {
+			t.Fatalf("unexpected cache point placement at message %d", index)
+		}
+	}
+}
+
+func TestConverseWithoutCachePolicy(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: "ok"}},
+		}},
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+	service.SetPromptCachePolicies(map[string]PromptCachePolicy{
+		"amazon.nova-pro-v1:0": {System: true},
+	})
+
+	_, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{
+			{Role: "system", Content: json.RawMessage(`"be brief"`)},
+			{Role: "user", Content: json.RawMessage(`"hi"`)},
+		},
+	}, "us.anthropic.claude-3-7-sonnet-20250219-v1:0")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	for _, block := range client.LastInput().System {
+		if _, ok := block.(*brtypes.SystemContentBlockMemberCachePoint); ok {
+			t.Fatalf("expected no cache point for model without policy")
+		}
+	}
+}
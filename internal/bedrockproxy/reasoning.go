@@ -0,0 +1,202 @@
+package bedrockproxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// thinkingAnswerTokens 是开启 extended thinking 且 max_tokens 不足以覆盖思考预算时，额外留给正式回答的 token 数。
+const thinkingAnswerTokens = 4096
+
+// reasoningBudgetTokens 把 reasoning_effort 映射为 Claude extended thinking 的 budget_tokens（最小值为 1024）。
+func reasoningBudgetTokens(effort string) int {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "minimal":
+		return 1024
+	case "low":
+		return 2048
+	case "medium":
+		return 8192
+	case "high":
+		return 16384
+	default:
+		return 0
+	}
+}
+
+// supportsExtendedThinking 判断模型是否支持 extended thinking（Claude 3.7 Sonnet 与 Claude 4 系列）。
+// 其他模型收到 reasoning_effort 时直接忽略，Cursor 等客户端会对所有模型都带上该字段。
+func supportsExtendedThinking(bedrockModelID string) bool {
+	modelID := strings.ToLower(bedrockModelID)
+	if !strings.Contains(modelID, "anthropic.claude") {
+		return false
+	}
+	if strings.Contains(modelID, "claude-3-7") {
+		return true
+	}
+	for _, family := range []string{"claude-opus-4", "claude-sonnet-4", "claude-haiku-4"} {
+		if strings.Contains(modelID, family) {
+			return true
+		}
+	}
+	return false
+}
+
+// thinkingBudgetForRequest 返回本次请求实际使用的思考预算；0 表示不开启。
+// 若最后一条带 tool_calls 的 assistant 消息没有回传签名思考块（客户端丢弃了 thinking_blocks），
+// Bedrock 会以 ValidationException 拒绝，此时降级为不开启思考以保证工具调用循环可以继续。
+// 客户端要求强制调用工具（response_format 或 tool_choice 为 required / 指定函数）时同样不开启思考：
+// 开启思考后 Claude 只接受 auto / none，思考是可选项，强制调用才是客户端必须得到的结果。
+func thinkingBudgetForRequest(request openai.ChatCompletionRequest, bedrockModelID string) int {
+	budget := reasoningBudgetTokens(request.ReasoningEffort)
+	if budget == 0 || !supportsExtendedThinking(bedrockModelID) {
+		return 0
+	}
+	if requestForcesToolUse(request) {
+		fmt.Printf("[WARN] extended thinking disabled: response_format or a forced tool_choice requires tool use\n")
+		return 0
+	}
+	for index := len(request.Messages) - 1; index >= 0; index-- {
+		message := request.Messages[index]
+		if message.Role != "assistant" {
+			continue
+		}
+		if len(message.ToolCalls) > 0 && !hasSignedThinkingBlock(message.ThinkingBlocks) {
+			fmt.Printf("[WARN] extended thinking disabled: last assistant tool call turn has no signed thinking_blocks\n")
+			return 0
+		}
+		break
+	}
+	return budget
+}
+
+// requestForcesToolUse 判断客户端是否要求强制调用工具：response_format（由合成工具实现），
+// 或带有工具时 tool_choice 为 required / 指定函数。格式错误留给 buildToolConfiguration 报 400。
+func requestForcesToolUse(request openai.ChatCompletionRequest) bool {
+	if structured, err := newStructuredOutput(request.ResponseFormat); err == nil && structured != nil {
+		return true
+	}
+	if len(request.Tools) == 0 {
+		return false
+	}
+	toolChoice, _, err := parseToolChoice(request.ToolChoice)
+	if err != nil {
+		return false
+	}
+	switch toolChoice.(type) {
+	case *brtypes.ToolChoiceMemberAny, *brtypes.ToolChoiceMemberTool:
+		return true
+	}
+	return false
+}
+
+func hasSignedThinkingBlock(blocks []openai.ThinkingBlock) bool {
+	for _, block := range blocks {
+		if block.Signature != "" || (block.Type == "redacted_thinking" && block.Data != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// stripThinkingBlocks 在未开启思考时移除历史中的思考块，避免发送给不支持的模型。
+// messages 须是 FixMissingToolResponses 返回的副本，这里会原地修改。
+func stripThinkingBlocks(messages []openai.ChatMessage) {
+	for index := range messages {
+		messages[index].ThinkingBlocks = nil
+	}
+}
+
+// applyExtendedThinking 调整开启思考后的请求：Claude 不允许同时修改 temperature / top_k，top_p 需不低于 0.95，
+// 且 max_tokens 必须大于 budget_tokens（不足时在预算之外再留出原本的回答长度）。
+func applyExtendedThinking(request *openai.ChatCompletionRequest, budget int, defaultMaxOutputToken int32) {
+	request.Temperature = nil
+	request.TopK = nil
+	if request.TopP != nil && *request.TopP < 0.95 {
+		request.TopP = nil
+	}
+
+	maxTokens := int(defaultMaxOutputToken)
+	if request.MaxTokens != nil && *request.MaxTokens > 0 {
+		maxTokens = *request.MaxTokens
+	}
+	if maxTokens <= budget {
+		answerTokens := maxTokens
+		if answerTokens <= 0 {
+			answerTokens = thinkingAnswerTokens
+		}
+		maxTokens = budget + answerTokens
+	}
+	request.MaxTokens = &maxTokens
+}
+
+// thinkingRequestField 是写入 AdditionalModelRequestFields 的 thinking 配置。
+func thinkingRequestField(budget int) map[string]any {
+	return map[string]any{"type": "enabled", "budget_tokens": budget}
+}
+
+// relaxToolChoiceForThinking：开启思考时 Claude 只接受 auto / none 的 tool_choice。
+// 客户端要求的强制调用已在 thinkingBudgetForRequest 中改为不开启思考，这里只剩 FORCE_TOOL_USE 加上的 any，降级为 auto。
+func relaxToolChoiceForThinking(toolConfig *brtypes.ToolConfiguration) {
+	if toolConfig == nil {
+		return
+	}
+	switch toolConfig.ToolChoice.(type) {
+	case *brtypes.ToolChoiceMemberAny, *brtypes.ToolChoiceMemberTool:
+		toolConfig.ToolChoice = &brtypes.ToolChoiceMemberAuto{Value: brtypes.AutoToolChoice{}}
+	}
+}
+
+// buildReasoningContentBlocks 把回传的思考块转换为 Bedrock reasoningContent；没有签名的块无法通过校验，直接跳过。
+func buildReasoningContentBlocks(blocks []openai.ThinkingBlock) ([]brtypes.ContentBlock, error) {
+	out := make([]brtypes.ContentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case "thinking":
+			if block.Signature == "" {
+				continue
+			}
+			out = append(out, &brtypes.ContentBlockMemberReasoningContent{
+				Value: &brtypes.ReasoningContentBlockMemberReasoningText{Value: brtypes.ReasoningTextBlock{
+					Text:      aws.String(block.Thinking),
+					Signature: aws.String(block.Signature),
+				}},
+			})
+		case "redacted_thinking":
+			data, err := base64.StdEncoding.DecodeString(block.Data)
+			if err != nil || len(data) == 0 {
+				return nil, fmt.Errorf("invalid redacted_thinking data")
+			}
+			out = append(out, &brtypes.ContentBlockMemberReasoningContent{
+				Value: &brtypes.ReasoningContentBlockMemberRedactedContent{Value: data},
+			})
+		default:
+			return nil, fmt.Errorf("unsupported thinking block type: %s", block.Type)
+		}
+	}
+	return out, nil
+}
+
+// thinkingBlockFromBedrock 把 Bedrock reasoningContent 转换为可回传的思考块。
+func thinkingBlockFromBedrock(block brtypes.ReasoningContentBlock) (openai.ThinkingBlock, bool) {
+	switch value := block.(type) {
+	case *brtypes.ReasoningContentBlockMemberReasoningText:
+		return openai.ThinkingBlock{
+			Type:      "thinking",
+			Thinking:  aws.ToString(value.Value.Text),
+			Signature: aws.ToString(value.Value.Signature),
+		}, true
+	case *brtypes.ReasoningContentBlockMemberRedactedContent:
+		return openai.ThinkingBlock{
+			Type: "redacted_thinking",
+			Data: base64.StdEncoding.EncodeToString(value.Value),
+		}, true
+	default:
+		return openai.ThinkingBlock{}, false
+	}
+}
@@ -0,0 +1,177 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const testThinkingModel = "us.anthropic.claude-sonnet-4-20250514-v1:0"
+
+func TestConverseEnablesExtendedThinking(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonEndTurn,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role: brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{
+				&brtypes.ContentBlockMemberReasoningContent{Value: &brtypes.ReasoningContentBlockMemberReasoningText{
+					Value: brtypes.ReasoningTextBlock{Text: aws.String("think"), Signature: aws.String("sig-1")},
+				}},
+				&brtypes.ContentBlockMemberText{Value: "answer"},
+			},
+		}},
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	temperature := 0.2
+	maxTokens := 1000
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages:        []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		Temperature:     &temperature,
+		MaxTokens:       &maxTokens,
+		ReasoningEffort: "medium",
+	}, testThinkingModel)
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	if result.Text != "answer" || len(result.ThinkingBlocks) != 1 || result.ThinkingBlocks[0].Signature != "sig-1" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(documentToJSONString(client.LastInput().AdditionalModelRequestFields)), &fields); err != nil {
+		t.Fatalf("unmarshal additional fields failed: %v", err)
+	}
+	thinking, _ := fields["thinking"].(map[string]any)
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != float64(8192) {
+		t.Fatalf("unexpected thinking config: %#v", fields)
+	}
+	inference := client.LastInput().InferenceConfig
+	if inference.Temperature != nil {
+		t.Fatalf("expected temperature to be dropped when thinking is enabled")
+	}
+	if aws.ToInt32(inference.MaxTokens) != 8192+1000 {
+		t.Fatalf("expected max_tokens above thinking budget, got %d", aws.ToInt32(inference.MaxTokens))
+	}
+}
+
+func TestConverseIgnoresReasoningEffortForUnsupportedModel(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: "ok"}},
+		}},
+	}}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+
+	_, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{
+			{Role: "user", Content: json.RawMessage(`"hi"`)},
+			{Role: "assistant", Content: json.RawMessage(`"hello"`), ThinkingBlocks: []openai.ThinkingBlock{{Type: "thinking", Thinking: "x", Signature: "sig"}}},
+			{Role: "user", Content: json.RawMessage(`"again"`)},
+		},
+		ReasoningEffort: "high",
+	}, "amazon.nova-pro-v1:0")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	if client.LastInput().AdditionalModelRequestFields != nil {
+		t.Fatalf("expected no thinking config for unsupported model")
+	}
+	for _, block := range client.LastInput().Messages[1].Content {
+		if _, ok := block.(*brtypes.ContentBlockMemberReasoningContent); ok {
+			t.Fatalf("expected thinking blocks to be stripped when thinking is disabled")
+		}
+	}
+}
+
+func TestForcedToolUseDisablesThinking(t *testing.T) {
+	tools := []openai.Tool{{Type: "function", Function: &openai.ToolFunction{Name: "lookup"}}}
+	request := openai.ChatCompletionRequest{
+		Messages:        []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		Tools:           tools,
+		ToolChoice:      json.RawMessage(`"auto"`),
+		ReasoningEffort: "low",
+	}
+	if budget := thinkingBudgetForRequest(request, testThinkingModel); budget != 2048 {
+		t.Fatalf("expected auto tool_choice to keep thinking, got %d", budget)
+	}
+
+	client := &bedrocktest.Client{}
+	service := NewService(client, "", nil, 2048, 8192, false, false)
+	for name, mutate := range map[string]func(*openai.ChatCompletionRequest){
+		"required": func(r *openai.ChatCompletionRequest) { r.ToolChoice = json.RawMessage(`"required"`) },
+		"named function": func(r *openai.ChatCompletionRequest) {
+			r.ToolChoice = json.RawMessage(`{"type":"function","function":{"name":"lookup"}}`)
+		},
+		"response_format": func(r *openai.ChatCompletionRequest) {
+			r.Tools, r.ToolChoice = nil, nil
+			r.ResponseFormat = json.RawMessage(`{"type":"json_object"}`)
+		},
+	} {
+		forced := request
+		mutate(&forced)
+		if _, err := service.Converse(context.Background(), forced, testThinkingModel); err != nil && !IsStructuredOutputError(err) {
+			t.Fatalf("%s: Converse returned error: %v", name, err)
+		}
+		input := client.LastInput()
+		if input.AdditionalModelRequestFields != nil {
+			t.Fatalf("%s: expected thinking to be disabled, got %s", name, documentToJSONString(input.AdditionalModelRequestFields))
+		}
+		switch input.ToolConfig.ToolChoice.(type) {
+		case *brtypes.ToolChoiceMemberAny, *brtypes.ToolChoiceMemberTool:
+		default:
+			t.Fatalf("%s: expected the forced tool_choice to be kept, got %T", name, input.ToolConfig.ToolChoice)
+		}
+	}
+}
+
+func TestThinkingBudgetKeepsSignedToolTurn(t *testing.T) {
+	toolTurn := openai.ChatMessage{
+		Role:    "assistant",
+		Content: json.RawMessage(`null`),
+		ToolCalls: []openai.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: openai.ToolCallFunction{Name: "read", Arguments: `{}`},
+		}},
+	}
+	request := openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{
+			{Role: "user", Content: json.RawMessage(`"hi"`)},
+			toolTurn,
+			{Role: "tool", ToolCallID: "call_1", Content: json.RawMessage(`"done"`)},
+		},
+		ReasoningEffort: "low",
+	}
+	if budget := thinkingBudgetForRequest(request, testThinkingModel); budget != 0 {
+		t.Fatalf("expected thinking to be disabled without signed blocks, got %d", budget)
+	}
+
+	request.Messages[1].ThinkingBlocks = []openai.ThinkingBlock{{Type: "thinking", Thinking: "plan", Signature: "sig"}}
+	if budget := thinkingBudgetForRequest(request, testThinkingModel); budget != 2048 {
+		t.Fatalf("expected low budget, got %d", budget)
+	}
+
+	blocks, err := buildAssistantContentBlocks(request.Messages[1])
+	if err != nil {
+		t.Fatalf("buildAssistantContentBlocks returned error: %v", err)
+	}
+	reasoning, ok := blocks[0].(*brtypes.ContentBlockMemberReasoningContent)
+	if !ok {
+		t.Fatalf("expected reasoning block first, got %T", blocks[0])
+	}
+	text, ok := reasoning.Value.(*brtypes.ReasoningContentBlockMemberReasoningText)
+	if !ok || aws.ToString(text.Value.Signature) != "sig" {
+		t.Fatalf("unexpected reasoning block: %#v", reasoning.Value)
+	}
+	if _, ok := blocks[1].(*brtypes.ContentBlockMemberToolUse); !ok {
+		t.Fatalf("expected tool use after reasoning, got %T", blocks[1])
+	}
+}
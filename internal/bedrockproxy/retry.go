@@ -0,0 +1,130 @@
+package bedrockproxy
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+// RetryPolicy 控制对限流 / 瞬时错误（见 IsUpstreamUnavailable）的自动重试。
+// 第 n 次重试前等待 min(BaseDelay*2^(n-1), MaxDelay) 的一半加上随机的另一半（抖动）。
+// 重试预算：每个请求为预算存入 BudgetRatio 个额度（上限 retryBudgetCap），每次重试消耗 1 个，
+// 额度不足时直接返回错误，避免 Bedrock 持续限流时重试放大流量。MaxRetries 为 0 表示不重试。
+type RetryPolicy struct {
+	MaxRetries  int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	BudgetRatio float64
+}
+
+// retryBudgetCap 是重试预算的上限，也是初始额度，允许空闲后出现的突发限流立即重试。
+const retryBudgetCap = 10
+
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+}
+
+func (b *retryBudget) deposit(amount float64) {
+	b.mu.Lock()
+	b.tokens = min(b.tokens+amount, retryBudgetCap)
+	b.mu.Unlock()
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetRetryPolicy 替换重试策略；预算重置为满额。
+func (s *Service) SetRetryPolicy(policy RetryPolicy) {
+	s.mu.Lock()
+	s.retryPolicy = policy
+	s.retryBudget = &retryBudget{tokens: retryBudgetCap}
+	s.mu.Unlock()
+}
+
+// retry 执行 call，遇到可重试错误时按 RetryPolicy 退避后重试，返回实际尝试次数（至少为 1）。
+// canRetry 不为 nil 且返回 false 时不再重试（流式调用已向客户端输出内容）。
+func (s *Service) retry(ctx context.Context, operation string, canRetry func() bool, call func() error) (int, error) {
+	s.mu.RLock()
+	policy := s.retryPolicy
+	budget := s.retryBudget
+	s.mu.RUnlock()
+	if budget != nil {
+		budget.deposit(policy.BudgetRatio)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !IsUpstreamUnavailable(err) || ctx.Err() != nil {
+			return attempt, err
+		}
+		if attempt > policy.MaxRetries || (canRetry != nil && !canRetry()) {
+			return attempt, err
+		}
+		if budget == nil || !budget.withdraw() {
+			fmt.Printf("[WARN %s] retry budget exhausted after attempt %d: %v\n", operation, attempt, err)
+			return attempt, err
+		}
+
+		delay := retryDelay(policy, attempt)
+		fmt.Printf("[WARN %s] attempt %d failed, retrying in %s: %v\n", operation, attempt, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
+
+func retryDelay(policy RetryPolicy, attempt int) time.Duration {
+	delay := policy.BaseDelay << (attempt - 1)
+	if policy.MaxDelay > 0 && (delay > policy.MaxDelay || delay <= 0) {
+		delay = policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(delay-half)+1))
+}
+
+// retryingInvokeClient 为 InvokeModel 调用加上重试。嵌入与图片生成的一个请求可能并发调用多次，
+// 这里累计全部重试次数，Attempts 返回 1 + 重试次数。
+type retryingInvokeClient struct {
+	service *Service
+	client  InvokeModelAPI
+	retries atomic.Int64
+}
+
+func (s *Service) retryingInvoker(client InvokeModelAPI) *retryingInvokeClient {
+	return &retryingInvokeClient{service: s, client: client}
+}
+
+func (c *retryingInvokeClient) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	var output *bedrockruntime.InvokeModelOutput
+	attempts, err := c.service.retry(ctx, "InvokeModel", nil, func() error {
+		var err error
+		output, err = c.client.InvokeModel(ctx, params, optFns...)
+		return err
+	})
+	c.retries.Add(int64(attempts - 1))
+	return output, err
+}
+
+func (c *retryingInvokeClient) Attempts() int {
+	return 1 + int(c.retries.Load())
+}
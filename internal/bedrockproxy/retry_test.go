@@ -0,0 +1,84 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/smithy-go"
+)
+
+func newRetryTestService(client ConverseAPI, maxRetries int) *Service {
+	service := NewService(client, "", nil, 1024, 1024, false, false)
+	service.SetRetryPolicy(RetryPolicy{MaxRetries: maxRetries, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, BudgetRatio: 0.2})
+	return service
+}
+
+func testChatRequest() openai.ChatCompletionRequest {
+	return openai.ChatCompletionRequest{Messages: []openai.ChatMessage{{Role: "user", Content: []byte(`"hi"`)}}}
+}
+
+func TestConverseRetriesThrottling(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException"}
+	client := &bedrocktest.Client{Errors: []error{throttled, throttled}}
+	service := newRetryTestService(client, 2)
+
+	result, err := service.Converse(context.Background(), testChatRequest(), "m")
+	if err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+	if result.Attempts != 3 || client.Calls() != 3 || result.Text != "ok" {
+		t.Fatalf("unexpected result after retries: attempts=%d calls=%d text=%q", result.Attempts, client.Calls(), result.Text)
+	}
+}
+
+func TestConverseDoesNotRetryClientErrors(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Fault: smithy.FaultClient}
+	client := &bedrocktest.Client{Errors: []error{denied}}
+	service := newRetryTestService(client, 2)
+
+	result, err := service.Converse(context.Background(), testChatRequest(), "m")
+	if !errors.Is(err, denied) || result.Attempts != 1 || client.Calls() != 1 {
+		t.Fatalf("expected a single attempt, got attempts=%d calls=%d err=%v", result.Attempts, client.Calls(), err)
+	}
+}
+
+func TestRetryStopsAfterOutputOrWhenBudgetIsExhausted(t *testing.T) {
+	service := newRetryTestService(nil, 5)
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException"}
+
+	calls := 0
+	attempts, err := service.retry(context.Background(), "test", func() bool { return calls < 2 }, func() error {
+		calls++
+		return throttled
+	})
+	if err == nil || attempts != 2 {
+		t.Fatalf("expected retries to stop once output was emitted, got attempts=%d err=%v", attempts, err)
+	}
+
+	// 预算上限为 retryBudgetCap，耗尽后不再重试
+	service.retryBudget.tokens = 0
+	calls = 0
+	attempts, _ = service.retry(context.Background(), "test", nil, func() error {
+		calls++
+		return throttled
+	})
+	if attempts != 1 || calls != 1 {
+		t.Fatalf("expected no retry without budget, got attempts=%d", attempts)
+	}
+}
+
+func TestRetryDelayIsBoundedWithJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: 300 * time.Millisecond} {
+		for range 20 {
+			delay := retryDelay(policy, attempt)
+			if delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, delay, ceiling/2, ceiling)
+			}
+		}
+	}
+}
@@ -14,6 +14,7 @@ package bedrockproxy
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -36,12 +37,24 @@ type ConverseAPI interface {
 
 type Service struct {
 	client                ConverseAPI
+	invokeClient          InvokeModelAPI
+	countClient           CountTokensAPI
+	guardrailClient       ApplyGuardrailAPI
 	mu                    sync.RWMutex
 	defaultModelID        string
 	defaultMaxOutputToken int32
 	minToolMaxOutputToken int32
 	forceToolUse          bool // 当请求包含 tools 时，强制模型调用工具
 	bufferToolCallArgs    bool // 为 true 时在流结束时一次性发送完整 tool_calls 参数（与 bedrock-access-gateway 一致时为 false，按 delta 逐条转发）
+	// 允许透传到 AdditionalModelRequestFields 的字段（管理员配置）
+	allowedRequestFields map[string]struct{}
+	// 按模型 ID（小写）配置的提示缓存策略
+	promptCachePolicies map[string]PromptCachePolicy
+	// 模型别名（小写）-> Bedrock 模型 ID，由管理员维护（admin_model_mappings）
+	modelAliases map[string]string
+	// 限流 / 瞬时错误的重试策略与共享的重试预算
+	retryPolicy RetryPolicy
+	retryBudget *retryBudget
 }
 
 type ChatResult struct {
@@ -52,6 +65,18 @@ type ChatResult struct {
 	TotalTokens  int
 	LatencyMs    int64
 	FinishReason string
+	StopReason   string // Bedrock 原始 stopReason（如 end_turn / stop_sequence），供 Anthropic 协议等需要区分的场景使用
+	// extended thinking 的思考块（含签名），按输出顺序排列
+	ThinkingBlocks []openai.ThinkingBlock
+	// 提示缓存命中与写入的 token 数（不包含在 InputTokens 内）
+	CacheReadInputTokens  int
+	CacheWriteInputTokens int
+	// Guardrail 介入（或内容被过滤）导致停止生成，FinishReason 为 content_filter
+	GuardrailIntervened bool
+	// 调用 Bedrock 的尝试次数（含限流 / 瞬时错误后的重试）
+	Attempts int
+	// 实际生成结果的 Bedrock 模型 ID；按降级链切换模型后与请求的模型不同
+	ModelID string
 }
 
 type StreamDelta struct {
@@ -59,6 +84,29 @@ type StreamDelta struct {
 	Text         string
 	ToolCalls    []openai.ChatChunkToolCall
 	FinishReason string
+	// 思考文本增量；一个思考块结束（签名到齐）时通过 ThinkingBlocks 发送完整块
+	ReasoningContent string
+	ThinkingBlocks   []openai.ThinkingBlock
+}
+
+// RequestError 表示请求内容本身无法转换为 Bedrock 输入（例如不支持的图片格式、非法的 tool 参数），
+// 路由层据此返回 400 而不是 502。
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// IsRequestError 判断 err 是否由请求内容不合法导致。
+func IsRequestError(err error) bool {
+	var requestErr *RequestError
+	return errors.As(err, &requestErr)
 }
 
 func NewService(
@@ -70,40 +118,74 @@ func NewService(
 	forceToolUse bool,
 	bufferToolCallArgs bool,
 ) *Service {
-	_ = modelRouter
-
+	invokeClient, _ := client.(InvokeModelAPI)
+	countClient, _ := client.(CountTokensAPI)
+	guardrailClient, _ := client.(ApplyGuardrailAPI)
 	return &Service{
 		client:                client,
+		invokeClient:          invokeClient,
+		countClient:           countClient,
+		guardrailClient:       guardrailClient,
 		defaultModelID:        strings.TrimSpace(defaultModelID),
 		defaultMaxOutputToken: defaultMaxOutputToken,
 		minToolMaxOutputToken: minToolMaxOutputToken,
 		forceToolUse:          forceToolUse,
 		bufferToolCallArgs:    bufferToolCallArgs,
+		modelAliases:          normalizeModelAliases(modelRouter),
 	}
 }
 
+// ResolveModel 返回 (请求的模型名, Bedrock 模型 ID)：model 为空或为 default 时使用默认模型，
+// 命中别名（忽略大小写）时返回别名当前指向的模型，否则按 Bedrock 模型 ID 原样使用。
 func (s *Service) ResolveModel(requestModel string) (string, string, error) {
 	requestModel = strings.TrimSpace(requestModel)
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if requestModel == "" {
+	if requestModel == "" || (strings.EqualFold(requestModel, "default") && s.defaultModelID != "") {
 		if s.defaultModelID == "" {
 			return "", "", errors.New("model is required")
 		}
 		return "default", s.defaultModelID, nil
 	}
+	if target, ok := s.modelAliases[strings.ToLower(requestModel)]; ok {
+		return requestModel, target, nil
+	}
 
 	return requestModel, requestModel, nil
 }
 
+// ReplaceModelRouter 整体替换模型别名表（启动时从 admin_model_mappings 加载）。
 func (s *Service) ReplaceModelRouter(modelRouter map[string]string) {
-	_ = modelRouter
+	aliases := normalizeModelAliases(modelRouter)
+	s.mu.Lock()
+	s.modelAliases = aliases
+	s.mu.Unlock()
+}
+
+// AliasTarget 返回别名当前指向的 Bedrock 模型 ID；"default" 指向默认模型。
+func (s *Service) AliasTarget(alias string) (string, bool) {
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if alias == "default" && s.defaultModelID != "" {
+		return s.defaultModelID, true
+	}
+	target, ok := s.modelAliases[alias]
+	return target, ok
 }
 
+// ReplaceClient 替换 Bedrock 客户端；若同时实现了 InvokeModelAPI / CountTokensAPI / ApplyGuardrailAPI
+// （*bedrockruntime.Client 即是），也用于嵌入与图片调用、token 计数与 /v1/moderations。
 func (s *Service) ReplaceClient(client ConverseAPI) {
+	invokeClient, _ := client.(InvokeModelAPI)
+	countClient, _ := client.(CountTokensAPI)
+	guardrailClient, _ := client.(ApplyGuardrailAPI)
 	s.mu.Lock()
 	s.client = client
+	s.invokeClient = invokeClient
+	s.countClient = countClient
+	s.guardrailClient = guardrailClient
 	s.mu.Unlock()
 }
 
@@ -113,38 +195,120 @@ func (s *Service) SetDefaultModelID(defaultModelID string) {
 	s.mu.Unlock()
 }
 
+// SetAllowedRequestFields 替换允许透传到 AdditionalModelRequestFields 的字段白名单。
+func (s *Service) SetAllowedRequestFields(fields []string) {
+	allowed := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			allowed[field] = struct{}{}
+		}
+	}
+
+	s.mu.Lock()
+	s.allowedRequestFields = allowed
+	s.mu.Unlock()
+}
+
 func (s *Service) HasClient() bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.client != nil
 }
 
+// UpsertModelMapping 新增或修改一个别名。别名不能是 default，也不能形成链（目标是另一个别名，或别名已被其他别名指向）。
 func (s *Service) UpsertModelMapping(alias, bedrockModelID string) error {
-	_ = alias
-	_ = bedrockModelID
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	bedrockModelID = strings.TrimSpace(bedrockModelID)
+	if alias == "" {
+		return errors.New("alias is required")
+	}
+	if bedrockModelID == "" {
+		return errors.New("bedrock_model_id is required")
+	}
+	if alias == "default" {
+		return errors.New("alias default is reserved for the default model")
+	}
+	if alias == strings.ToLower(bedrockModelID) {
+		return errors.New("alias must differ from bedrock_model_id")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.modelAliases[strings.ToLower(bedrockModelID)]; ok {
+		return fmt.Errorf("bedrock_model_id %s is itself an alias", bedrockModelID)
+	}
+	for other, target := range s.modelAliases {
+		if other != alias && strings.ToLower(target) == alias {
+			return fmt.Errorf("alias %s is the target of alias %s", alias, other)
+		}
+	}
+	aliases := make(map[string]string, len(s.modelAliases)+1)
+	for key, value := range s.modelAliases {
+		aliases[key] = value
+	}
+	aliases[alias] = bedrockModelID
+	s.modelAliases = aliases
 	return nil
 }
 
+// DeleteModelMapping 删除别名，别名不存在时返回 false。
 func (s *Service) DeleteModelMapping(alias string) bool {
-	_ = alias
-	return false
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.modelAliases[alias]; !ok {
+		return false
+	}
+	aliases := make(map[string]string, len(s.modelAliases))
+	for key, value := range s.modelAliases {
+		if key != alias {
+			aliases[key] = value
+		}
+	}
+	s.modelAliases = aliases
+	return true
 }
 
+// ListModelMappings 返回别名表的副本。
 func (s *Service) ListModelMappings() map[string]string {
-	return map[string]string{}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]string, len(s.modelAliases))
+	for alias, target := range s.modelAliases {
+		out[alias] = target
+	}
+	return out
 }
 
+// ListModelAliases 返回全部可用别名（含指向默认模型的 default），按名称排序。
 func (s *Service) ListModelAliases() []string {
 	s.mu.RLock()
-	aliases := make([]string, 0, 1)
+	aliases := make([]string, 0, len(s.modelAliases)+1)
 	if s.defaultModelID != "" {
 		aliases = append(aliases, "default")
 	}
+	for alias := range s.modelAliases {
+		aliases = append(aliases, alias)
+	}
 	s.mu.RUnlock()
 	sort.Strings(aliases)
 	return aliases
 }
 
+func normalizeModelAliases(modelRouter map[string]string) map[string]string {
+	aliases := make(map[string]string, len(modelRouter))
+	for alias, target := range modelRouter {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		target = strings.TrimSpace(target)
+		if alias == "" || target == "" {
+			continue
+		}
+		aliases[alias] = target
+	}
+	return aliases
+}
+
 // hasToolResponses 判断当前对话中是否已经包含工具结果（role=tool 的消息）。
 // 如果已经有 tool 消息，则说明上一轮工具调用已经完成，后续轮次就不应该再强制模型「必须」调用工具，
 // 否则容易出现模型在每一轮都重复发起同一个工具调用的情况。
@@ -171,15 +335,32 @@ func hasInlineToolResultMessage(raw json.RawMessage) bool {
 	return err == nil && len(blocks) > 0
 }
 
+// Converse 调用 Bedrock Converse；限流 / 瞬时错误按 RetryPolicy 重试，ChatResult.Attempts 为实际尝试次数。
 func (s *Service) Converse(ctx context.Context, request openai.ChatCompletionRequest, bedrockModelID string) (ChatResult, error) {
+	var result ChatResult
+	attempts, err := s.retry(ctx, "Converse", nil, func() error {
+		var err error
+		result, err = s.converseOnce(ctx, request, bedrockModelID)
+		return err
+	})
+	result.Attempts = attempts
+	result.ModelID = bedrockModelID
+	return result, err
+}
+
+func (s *Service) converseOnce(ctx context.Context, request openai.ChatCompletionRequest, bedrockModelID string) (ChatResult, error) {
 	// Fix messages: ensure tool_call IDs and fix missing tool responses
 	request.Messages = openai.EnsureToolCallIDs(request.Messages)
 	request.Messages = openai.FixMissingToolResponses(request.Messages)
 	toolArgNormalizer := newToolArgumentNormalizer(request.Tools)
+	thinkingBudget := thinkingBudgetForRequest(request, bedrockModelID)
+	if thinkingBudget == 0 {
+		stripThinkingBlocks(request.Messages)
+	}
 
 	messages, system, err := BuildBedrockMessages(request.Messages)
 	if err != nil {
-		return ChatResult{}, err
+		return ChatResult{}, &RequestError{Err: err}
 	}
 
 	// 仅在「尚未出现任何 tool 消息」时才强制工具调用。
@@ -191,26 +372,42 @@ func (s *Service) Converse(ctx context.Context, request openai.ChatCompletionReq
 	forceToolUse := s.forceToolUse && !hasTool
 	s.mu.RUnlock()
 
-	toolConfig, err := buildToolConfiguration(request.Tools, request.ToolChoice, forceToolUse)
+	structured, err := newStructuredOutput(request.ResponseFormat)
 	if err != nil {
-		return ChatResult{}, err
+		return ChatResult{}, &RequestError{Err: err}
 	}
+	toolConfig, err := buildToolConfiguration(request.Tools, request.ToolChoice, forceToolUse, structured)
+	if err != nil {
+		return ChatResult{}, &RequestError{Err: err}
+	}
+	if thinkingBudget > 0 {
+		relaxToolChoiceForThinking(toolConfig)
+	}
+	messages, system = applyCachePoints(s.promptCachePolicy(bedrockModelID), messages, system, toolConfig)
 
 	s.mu.RLock()
 	client := s.client
 	defaultMaxOutputToken := s.defaultMaxOutputToken
 	minToolMaxOutputToken := s.minToolMaxOutputToken
+	allowedRequestFields := s.allowedRequestFields
 	s.mu.RUnlock()
 
 	if client == nil {
 		return ChatResult{}, errors.New("bedrock client is not configured")
 	}
 
+	if thinkingBudget > 0 {
+		applyExtendedThinking(&request, thinkingBudget, defaultMaxOutputToken)
+	}
 	inferenceConfig, maxTokensRaised, originalMaxTokens, effectiveMaxTokens := buildInferenceConfig(
 		request,
 		defaultMaxOutputToken,
 		minToolMaxOutputToken,
 	)
+	additionalFields, err := buildAdditionalModelRequestFields(request, allowedRequestFields, thinkingBudget)
+	if err != nil {
+		return ChatResult{}, &RequestError{Err: err}
+	}
 	if maxTokensRaised {
 		fmt.Printf(
 			"[WARN Converse] raised max_tokens from %d to %d because tools are present (MIN_TOOL_MAX_OUTPUT_TOKENS)\n",
@@ -220,11 +417,13 @@ func (s *Service) Converse(ctx context.Context, request openai.ChatCompletionReq
 	}
 
 	output, err := client.Converse(ctx, &bedrockruntime.ConverseInput{
-		ModelId:         aws.String(bedrockModelID),
-		Messages:        messages,
-		System:          system,
-		InferenceConfig: inferenceConfig,
-		ToolConfig:      toolConfig,
+		ModelId:                      aws.String(bedrockModelID),
+		Messages:                     messages,
+		System:                       system,
+		InferenceConfig:              inferenceConfig,
+		ToolConfig:                   toolConfig,
+		AdditionalModelRequestFields: additionalFields,
+		GuardrailConfig:              buildGuardrailConfig(request.Guardrail),
 	})
 	if err != nil {
 		return ChatResult{}, err
@@ -232,38 +431,75 @@ func (s *Service) Converse(ctx context.Context, request openai.ChatCompletionReq
 
 	payload := extractOutputPayload(output.Output)
 	result := ChatResult{
-		Text:         payload.Text,
-		ToolCalls:    toolArgNormalizer.normalizeToolCalls(payload.ToolCalls),
-		FinishReason: mapStopReason(output.StopReason),
+		Text:                payload.Text,
+		ToolCalls:           toolArgNormalizer.normalizeToolCalls(payload.ToolCalls),
+		FinishReason:        mapStopReason(output.StopReason),
+		StopReason:          string(output.StopReason),
+		ThinkingBlocks:      payload.ThinkingBlocks,
+		GuardrailIntervened: isGuardrailStop(output.StopReason),
 	}
 
 	if output.Usage != nil {
 		result.InputTokens = int(ptrInt32(output.Usage.InputTokens))
 		result.OutputTokens = int(ptrInt32(output.Usage.OutputTokens))
 		result.TotalTokens = int(ptrInt32(output.Usage.TotalTokens))
+		result.CacheReadInputTokens, result.CacheWriteInputTokens = cacheTokensFromUsage(output.Usage)
 	}
 	if output.Metrics != nil {
 		result.LatencyMs = ptrInt64(output.Metrics.LatencyMs)
 	}
+	if structured != nil {
+		// 校验失败时仍返回 usage，调用方据此记账
+		if err := structured.apply(&result); err != nil {
+			return result, err
+		}
+	}
 	maybeLogTruncatedToolCalls("Converse", result.FinishReason, result.ToolCalls, result.OutputTokens)
 
 	return result, nil
 }
 
+// ConverseStream 调用 Bedrock ConverseStream；只有在尚未向 onDelta 输出任何内容时才对限流 / 瞬时错误重试，
+// 流中途的错误直接返回。
 func (s *Service) ConverseStream(
 	ctx context.Context,
 	request openai.ChatCompletionRequest,
 	bedrockModelID string,
 	onDelta func(delta StreamDelta) error,
+) (ChatResult, error) {
+	emitted := false
+	var result ChatResult
+	attempts, err := s.retry(ctx, "ConverseStream", func() bool { return !emitted }, func() error {
+		var err error
+		result, err = s.converseStreamOnce(ctx, request, bedrockModelID, func(delta StreamDelta) error {
+			emitted = true
+			return onDelta(delta)
+		})
+		return err
+	})
+	result.Attempts = attempts
+	result.ModelID = bedrockModelID
+	return result, err
+}
+
+func (s *Service) converseStreamOnce(
+	ctx context.Context,
+	request openai.ChatCompletionRequest,
+	bedrockModelID string,
+	onDelta func(delta StreamDelta) error,
 ) (ChatResult, error) {
 	// Fix messages: ensure tool_call IDs and fix missing tool responses
 	request.Messages = openai.EnsureToolCallIDs(request.Messages)
 	request.Messages = openai.FixMissingToolResponses(request.Messages)
 	toolArgNormalizer := newToolArgumentNormalizer(request.Tools)
+	thinkingBudget := thinkingBudgetForRequest(request, bedrockModelID)
+	if thinkingBudget == 0 {
+		stripThinkingBlocks(request.Messages)
+	}
 
 	messages, system, err := BuildBedrockMessages(request.Messages)
 	if err != nil {
-		return ChatResult{}, err
+		return ChatResult{}, &RequestError{Err: err}
 	}
 
 	// 同 Converse：只有在历史中还没有任何 tool 结果时才启用 FORCE_TOOL_USE，
@@ -276,10 +512,18 @@ func (s *Service) ConverseStream(
 	s.mu.RUnlock()
 	effectiveBufferToolCallArgs := bufferToolCallArgs || toolArgNormalizer.requiresBufferedOutput()
 
-	toolConfig, err := buildToolConfiguration(request.Tools, request.ToolChoice, forceToolUse)
+	structured, err := newStructuredOutput(request.ResponseFormat)
 	if err != nil {
-		return ChatResult{}, err
+		return ChatResult{}, &RequestError{Err: err}
+	}
+	toolConfig, err := buildToolConfiguration(request.Tools, request.ToolChoice, forceToolUse, structured)
+	if err != nil {
+		return ChatResult{}, &RequestError{Err: err}
 	}
+	if thinkingBudget > 0 {
+		relaxToolChoiceForThinking(toolConfig)
+	}
+	messages, system = applyCachePoints(s.promptCachePolicy(bedrockModelID), messages, system, toolConfig)
 
 	// 调试日志：打印工具配置
 	if toolConfig != nil {
@@ -304,17 +548,25 @@ func (s *Service) ConverseStream(
 	client := s.client
 	defaultMaxOutputToken := s.defaultMaxOutputToken
 	minToolMaxOutputToken := s.minToolMaxOutputToken
+	allowedRequestFields := s.allowedRequestFields
 	s.mu.RUnlock()
 
 	if client == nil {
 		return ChatResult{}, errors.New("bedrock client is not configured")
 	}
 
+	if thinkingBudget > 0 {
+		applyExtendedThinking(&request, thinkingBudget, defaultMaxOutputToken)
+	}
 	inferenceConfig, maxTokensRaised, originalMaxTokens, effectiveMaxTokens := buildInferenceConfig(
 		request,
 		defaultMaxOutputToken,
 		minToolMaxOutputToken,
 	)
+	additionalFields, err := buildAdditionalModelRequestFields(request, allowedRequestFields, thinkingBudget)
+	if err != nil {
+		return ChatResult{}, &RequestError{Err: err}
+	}
 	if maxTokensRaised {
 		fmt.Printf(
 			"[WARN ConverseStream] raised max_tokens from %d to %d because tools are present (MIN_TOOL_MAX_OUTPUT_TOKENS)\n",
@@ -324,11 +576,13 @@ func (s *Service) ConverseStream(
 	}
 
 	output, err := client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
-		ModelId:         aws.String(bedrockModelID),
-		Messages:        messages,
-		System:          system,
-		InferenceConfig: inferenceConfig,
-		ToolConfig:      toolConfig,
+		ModelId:                      aws.String(bedrockModelID),
+		Messages:                     messages,
+		System:                       system,
+		InferenceConfig:              inferenceConfig,
+		ToolConfig:                   toolConfig,
+		AdditionalModelRequestFields: additionalFields,
+		GuardrailConfig:              buildGuardrailStreamConfig(request.Guardrail),
 	})
 	if err != nil {
 		return ChatResult{}, err
@@ -341,6 +595,14 @@ func (s *Service) ConverseStream(
 	roleSent := false
 	toolCalls := make([]openai.ToolCall, 0, 2)
 	toolCallIndexByContentBlock := make(map[int]int)
+	// response_format：合成工具的参数作为文本 delta 转发；模型在工具调用之外输出的文本先缓存，
+	// 仅当模型没有调用任何工具时才作为兜底内容发送。
+	structuredBlocks := make(map[int]bool)
+	var structuredText strings.Builder
+	var plainText strings.Builder
+	// extended thinking：按 content block 累积思考文本与签名，块结束时作为完整思考块发送
+	thinkingByContentBlock := make(map[int]*openai.ThinkingBlock)
+	var thinkingBlocks []openai.ThinkingBlock
 
 	for event := range stream.Events() {
 		switch value := event.(type) {
@@ -358,6 +620,17 @@ func (s *Service) ConverseStream(
 				continue
 			}
 
+			if structured != nil && aws.ToString(toolStart.Value.Name) == structuredOutputToolName {
+				structuredBlocks[blockIndex] = true
+				if !roleSent {
+					roleSent = true
+					if err := onDelta(StreamDelta{Role: "assistant"}); err != nil {
+						return ChatResult{}, err
+					}
+				}
+				continue
+			}
+
 			toolCallIndex := len(toolCalls)
 			toolCallID := strings.TrimSpace(aws.ToString(toolStart.Value.ToolUseId))
 			if toolCallID == "" {
@@ -405,6 +678,10 @@ func (s *Service) ConverseStream(
 			blockIndex := int(ptrInt32(value.Value.ContentBlockIndex))
 			switch delta := value.Value.Delta.(type) {
 			case *brtypes.ContentBlockDeltaMemberText:
+				if structured != nil {
+					plainText.WriteString(delta.Value)
+					continue
+				}
 				if !roleSent {
 					roleSent = true
 					if err := onDelta(StreamDelta{Role: "assistant"}); err != nil {
@@ -418,7 +695,44 @@ func (s *Service) ConverseStream(
 				if err := onDelta(StreamDelta{Text: delta.Value}); err != nil {
 					return ChatResult{}, err
 				}
+			case *brtypes.ContentBlockDeltaMemberReasoningContent:
+				thinking, exists := thinkingByContentBlock[blockIndex]
+				if !exists {
+					thinking = &openai.ThinkingBlock{Type: "thinking"}
+					thinkingByContentBlock[blockIndex] = thinking
+				}
+				switch reasoning := delta.Value.(type) {
+				case *brtypes.ReasoningContentBlockDeltaMemberText:
+					if reasoning.Value == "" {
+						continue
+					}
+					thinking.Thinking += reasoning.Value
+					if !roleSent {
+						roleSent = true
+						if err := onDelta(StreamDelta{Role: "assistant"}); err != nil {
+							return ChatResult{}, err
+						}
+					}
+					if err := onDelta(StreamDelta{ReasoningContent: reasoning.Value}); err != nil {
+						return ChatResult{}, err
+					}
+				case *brtypes.ReasoningContentBlockDeltaMemberSignature:
+					thinking.Signature += reasoning.Value
+				case *brtypes.ReasoningContentBlockDeltaMemberRedactedContent:
+					thinking.Type = "redacted_thinking"
+					thinking.Data = base64.StdEncoding.EncodeToString(reasoning.Value)
+				}
 			case *brtypes.ContentBlockDeltaMemberToolUse:
+				if structuredBlocks[blockIndex] {
+					if delta.Value.Input == nil || *delta.Value.Input == "" {
+						continue
+					}
+					structuredText.WriteString(*delta.Value.Input)
+					if err := onDelta(StreamDelta{Text: *delta.Value.Input}); err != nil {
+						return ChatResult{}, err
+					}
+					continue
+				}
 				toolCallIndex, exists := toolCallIndexByContentBlock[blockIndex]
 				if !exists {
 					toolCallIndex = len(toolCalls)
@@ -448,8 +762,21 @@ func (s *Service) ConverseStream(
 					}
 				}
 			}
+		case *brtypes.ConverseStreamOutputMemberContentBlockStop:
+			blockIndex := int(ptrInt32(value.Value.ContentBlockIndex))
+			thinking, exists := thinkingByContentBlock[blockIndex]
+			if !exists {
+				continue
+			}
+			delete(thinkingByContentBlock, blockIndex)
+			thinkingBlocks = append(thinkingBlocks, *thinking)
+			if err := onDelta(StreamDelta{ThinkingBlocks: []openai.ThinkingBlock{*thinking}}); err != nil {
+				return ChatResult{}, err
+			}
 		case *brtypes.ConverseStreamOutputMemberMessageStop:
 			result.FinishReason = mapStopReason(value.Value.StopReason)
+			result.StopReason = string(value.Value.StopReason)
+			result.GuardrailIntervened = isGuardrailStop(value.Value.StopReason)
 			if len(toolCalls) > 0 {
 				toolCalls = toolArgNormalizer.normalizeToolCalls(toolCalls)
 			}
@@ -480,6 +807,7 @@ func (s *Service) ConverseStream(
 				result.InputTokens = int(ptrInt32(value.Value.Usage.InputTokens))
 				result.OutputTokens = int(ptrInt32(value.Value.Usage.OutputTokens))
 				result.TotalTokens = int(ptrInt32(value.Value.Usage.TotalTokens))
+				result.CacheReadInputTokens, result.CacheWriteInputTokens = cacheTokensFromUsage(value.Value.Usage)
 			}
 			if value.Value.Metrics != nil {
 				result.LatencyMs = ptrInt64(value.Value.Metrics.LatencyMs)
@@ -493,6 +821,29 @@ func (s *Service) ConverseStream(
 
 	result.Text = textBuilder.String()
 	result.ToolCalls = toolCalls
+	result.ThinkingBlocks = thinkingBlocks
+	if structured != nil {
+		if len(structuredBlocks) > 0 {
+			result.ToolCalls = append(result.ToolCalls, openai.ToolCall{
+				Type:     "function",
+				Function: openai.ToolCallFunction{Name: structuredOutputToolName, Arguments: structuredText.String()},
+			})
+		} else if len(toolCalls) == 0 && plainText.Len() > 0 {
+			// 模型没有调用合成工具（例如不支持 toolChoice 的模型），把缓存的文本原样发出
+			result.Text = plainText.String()
+			if !roleSent {
+				if err := onDelta(StreamDelta{Role: "assistant"}); err != nil {
+					return ChatResult{}, err
+				}
+			}
+			if err := onDelta(StreamDelta{Text: result.Text}); err != nil {
+				return ChatResult{}, err
+			}
+		}
+		if err := structured.apply(&result); err != nil {
+			return result, err
+		}
+	}
 	maybeLogTruncatedToolCalls("ConverseStream", result.FinishReason, result.ToolCalls, result.OutputTokens)
 	return result, nil
 }
@@ -556,16 +907,12 @@ func BuildBedrockMessages(messages []openai.ChatMessage) ([]brtypes.Message, []b
 
 		case "", "user", "function":
 			flushToolResults() // 先刷新待处理的 tool results
-			text, err := openai.DecodeContentAsText(message.Content)
+			// 文本、图片（image_url / input_image / image）与文档（file / input_file / document）按原顺序转换
+			blocks, err := buildUserContentBlocks(message.Content)
 			if err != nil {
 				return nil, nil, fmt.Errorf("invalid user message content at index %d: %w", index, err)
 			}
 
-			blocks := make([]brtypes.ContentBlock, 0, 2)
-			if strings.TrimSpace(text) != "" {
-				blocks = append(blocks, &brtypes.ContentBlockMemberText{Value: text})
-			}
-
 			inlineToolResults, err := buildInlineToolResultBlocks(message.Content)
 			if err != nil {
 				return nil, nil, fmt.Errorf("invalid inline tool result content at index %d: %w", index, err)
@@ -595,7 +942,14 @@ func BuildBedrockMessages(messages []openai.ChatMessage) ([]brtypes.Message, []b
 }
 
 func buildAssistantContentBlocks(message openai.ChatMessage) ([]brtypes.ContentBlock, error) {
-	blocks := make([]brtypes.ContentBlock, 0, 1+len(message.ToolCalls))
+	blocks := make([]brtypes.ContentBlock, 0, 1+len(message.ThinkingBlocks)+len(message.ToolCalls))
+
+	// 签名思考块必须位于 assistant 消息开头
+	reasoningBlocks, err := buildReasoningContentBlocks(message.ThinkingBlocks)
+	if err != nil {
+		return nil, err
+	}
+	blocks = append(blocks, reasoningBlocks...)
 
 	text, err := openai.DecodeContentAsText(message.Content)
 	if err != nil {
@@ -849,7 +1203,10 @@ func parseToolResultContent(raw json.RawMessage) ([]brtypes.ToolResultContentBlo
 			&brtypes.ToolResultContentBlockMemberText{Value: value},
 		}, nil
 	case []any:
-		blocks := parseToolResultArrayContent(value)
+		blocks, err := parseToolResultArrayContent(value)
+		if err != nil {
+			return nil, err
+		}
 		if len(blocks) > 0 {
 			return blocks, nil
 		}
@@ -872,13 +1229,30 @@ func parseToolResultContent(raw json.RawMessage) ([]brtypes.ToolResultContentBlo
 	}
 }
 
-func parseToolResultArrayContent(items []any) []brtypes.ToolResultContentBlock {
+func parseToolResultArrayContent(items []any) ([]brtypes.ToolResultContentBlock, error) {
 	blocks := make([]brtypes.ToolResultContentBlock, 0, len(items))
 	for _, item := range items {
 		switch value := item.(type) {
 		case string:
 			blocks = append(blocks, &brtypes.ToolResultContentBlockMemberText{Value: value})
 		case map[string]any:
+			// 截图类工具结果：图片单独转为 image block，避免把 base64 当文本塞进上下文
+			image, ok, err := imageBlockFromPart(value)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				blocks = append(blocks, &brtypes.ToolResultContentBlockMemberImage{Value: image})
+				continue
+			}
+			document, ok, err := documentBlockFromPart(value)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				blocks = append(blocks, &brtypes.ToolResultContentBlockMemberDocument{Value: document})
+				continue
+			}
 			if text, ok := extractToolResultTextFromObject(value); ok {
 				blocks = append(blocks, &brtypes.ToolResultContentBlockMemberText{Value: text})
 				continue
@@ -899,7 +1273,7 @@ func parseToolResultArrayContent(items []any) []brtypes.ToolResultContentBlock {
 			blocks = append(blocks, &brtypes.ToolResultContentBlockMemberText{Value: string(blob)})
 		}
 	}
-	return blocks
+	return blocks, nil
 }
 
 func extractToolResultTextFromObject(value map[string]any) (string, bool) {
@@ -1010,12 +1384,15 @@ func parseInlineToolResultBlock(raw json.RawMessage) (brtypes.ContentBlock, bool
 		}
 	}
 
-	return &brtypes.ContentBlockMemberToolResult{
-		Value: brtypes.ToolResultBlock{
-			ToolUseId: aws.String(toolUseID),
-			Content:   resultContent,
-		},
-	}, true, nil
+	toolResult := brtypes.ToolResultBlock{
+		ToolUseId: aws.String(toolUseID),
+		Content:   resultContent,
+	}
+	// Anthropic 格式：is_error=true 表示工具执行失败
+	if strings.TrimSpace(string(item["is_error"])) == "true" {
+		toolResult.Status = brtypes.ToolResultStatusError
+	}
+	return &brtypes.ContentBlockMemberToolResult{Value: toolResult}, true, nil
 }
 
 func rawJSONFieldString(item map[string]json.RawMessage, key string) string {
@@ -1212,7 +1589,13 @@ func applyArgumentAliases(args map[string]any, aliases map[string]string) bool {
 	return changed
 }
 
-func buildToolConfiguration(tools []openai.Tool, rawToolChoice json.RawMessage, forceToolUse bool) (*brtypes.ToolConfiguration, error) {
+// buildToolConfiguration 构建 Bedrock ToolConfiguration；structured 非 nil 时追加 response_format 的合成工具。
+func buildToolConfiguration(
+	tools []openai.Tool,
+	rawToolChoice json.RawMessage,
+	forceToolUse bool,
+	structured *structuredOutput,
+) (*brtypes.ToolConfiguration, error) {
 	// 调试日志：打印输入参数
 	fmt.Printf("[DEBUG buildToolConfiguration] 输入: tools=%d, rawToolChoice=%s, forceToolUse=%v\n",
 		len(tools), string(rawToolChoice), forceToolUse)
@@ -1276,6 +1659,10 @@ func buildToolConfiguration(tools []openai.Tool, rawToolChoice json.RawMessage,
 
 	fmt.Printf("[DEBUG buildToolConfiguration] 解析到的工具: %v\n", toolNames)
 
+	if structured != nil {
+		return buildStructuredToolConfiguration(bedrockTools, rawToolChoice, structured)
+	}
+
 	if len(bedrockTools) == 0 {
 		fmt.Printf("[DEBUG buildToolConfiguration] 没有有效的工具，返回 nil\n")
 		return nil, nil
@@ -1388,8 +1775,9 @@ func parseToolChoice(raw json.RawMessage) (brtypes.ToolChoice, bool, error) {
 }
 
 type outputPayload struct {
-	Text      string
-	ToolCalls []openai.ToolCall
+	Text           string
+	ToolCalls      []openai.ToolCall
+	ThinkingBlocks []openai.ThinkingBlock
 }
 
 func extractOutputPayload(output brtypes.ConverseOutput) outputPayload {
@@ -1400,10 +1788,15 @@ func extractOutputPayload(output brtypes.ConverseOutput) outputPayload {
 
 	var builder strings.Builder
 	toolCalls := make([]openai.ToolCall, 0, 2)
+	var thinkingBlocks []openai.ThinkingBlock
 	for _, block := range message.Value.Content {
 		switch value := block.(type) {
 		case *brtypes.ContentBlockMemberText:
 			builder.WriteString(value.Value)
+		case *brtypes.ContentBlockMemberReasoningContent:
+			if thinking, ok := thinkingBlockFromBedrock(value.Value); ok {
+				thinkingBlocks = append(thinkingBlocks, thinking)
+			}
 		case *brtypes.ContentBlockMemberToolUse:
 			toolCallID := strings.TrimSpace(aws.ToString(value.Value.ToolUseId))
 			if toolCallID == "" {
@@ -1429,8 +1822,9 @@ func extractOutputPayload(output brtypes.ConverseOutput) outputPayload {
 	}
 
 	return outputPayload{
-		Text:      builder.String(),
-		ToolCalls: toolCalls,
+		Text:           builder.String(),
+		ToolCalls:      toolCalls,
+		ThinkingBlocks: thinkingBlocks,
 	}
 }
 
@@ -1463,6 +1857,11 @@ func mapStopReason(reason brtypes.StopReason) string {
 		return "length"
 	case brtypes.StopReasonToolUse:
 		return "tool_calls"
+	case brtypes.StopReasonStopSequence, brtypes.StopReasonEndTurn:
+		// 命中 stop 序列在 OpenAI 协议中同样是 "stop"
+		return "stop"
+	case brtypes.StopReasonGuardrailIntervened, brtypes.StopReasonContentFiltered:
+		return "content_filter"
 	default:
 		return "stop"
 	}
@@ -1498,6 +1897,11 @@ func buildInferenceConfig(
 		inferenceConfig.TopP = aws.Float32(float32(*request.TopP))
 		hasAny = true
 	}
+	// stop 已在 ValidateChatRequest 中校验，这里忽略解析错误
+	if stopSequences, _ := openai.ParseStopSequences(request.Stop); len(stopSequences) > 0 {
+		inferenceConfig.StopSequences = stopSequences
+		hasAny = true
+	}
 
 	originalMaxTokens := int32(0)
 	effectiveMaxTokens := int32(0)
@@ -1525,6 +1929,47 @@ func buildInferenceConfig(
 	return inferenceConfig, maxTokensRaised, originalMaxTokens, effectiveMaxTokens
 }
 
+// buildAdditionalModelRequestFields 收集 top_k 与 additional_model_request_fields，
+// 只保留管理员白名单中的字段；不在白名单中的字段被丢弃并打印警告，避免客户端向 Bedrock 透传任意内容。
+// thinkingBudget > 0 时追加由 reasoning_effort 生成的 thinking 配置（路由自身生成，不受白名单限制）。
+func buildAdditionalModelRequestFields(request openai.ChatCompletionRequest, allowed map[string]struct{}, thinkingBudget int) (document.Interface, error) {
+	fields := make(map[string]any, len(request.AdditionalModelRequestFields)+1)
+	for key, raw := range request.AdditionalModelRequestFields {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid additional_model_request_fields.%s: %w", key, err)
+		}
+		fields[key] = value
+	}
+	if request.TopK != nil {
+		fields["top_k"] = *request.TopK
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := allowed[key]; !ok {
+			fmt.Printf("[WARN] dropped additional model request field %q: not in admin allowlist\n", key)
+			delete(fields, key)
+		}
+	}
+	if thinkingBudget > 0 {
+		fields["thinking"] = thinkingRequestField(thinkingBudget)
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return document.NewLazyDocument(fields), nil
+}
+
 func maybeLogTruncatedToolCalls(stage string, finishReason string, toolCalls []openai.ToolCall, outputTokens int) {
 	if strings.TrimSpace(finishReason) != "length" || len(toolCalls) == 0 {
 		return
@@ -1,10 +1,14 @@
 package bedrockproxy
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
+	"aws-cursor-router/internal/bedrocktest"
 	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 )
 
 func TestResolveModelDirect(t *testing.T) {
@@ -19,9 +23,51 @@ func TestResolveModelDirect(t *testing.T) {
 	if requested != "gpt-4o" {
 		t.Fatalf("unexpected requested: %s", requested)
 	}
-	if bedrockID != "gpt-4o" {
+	if bedrockID != "anthropic.claude-3-7-sonnet-20250219-v1:0" {
 		t.Fatalf("unexpected bedrock id: %s", bedrockID)
 	}
+
+	requested, bedrockID, err = service.ResolveModel("GPT-4o")
+	if err != nil || requested != "GPT-4o" || bedrockID != "anthropic.claude-3-7-sonnet-20250219-v1:0" {
+		t.Fatalf("expected case-insensitive alias match, got %s %s %v", requested, bedrockID, err)
+	}
+
+	requested, bedrockID, err = service.ResolveModel("anthropic.claude-3-5-haiku")
+	if err != nil || requested != "anthropic.claude-3-5-haiku" || bedrockID != "anthropic.claude-3-5-haiku" {
+		t.Fatalf("expected unknown model to pass through, got %s %s %v", requested, bedrockID, err)
+	}
+}
+
+func TestUpsertModelMappingRejectsChainsAndReservedAlias(t *testing.T) {
+	service := NewService(nil, "anthropic.default", map[string]string{
+		"fast": "anthropic.claude-3-5-haiku",
+	}, 2048, 8192, false, false)
+
+	if err := service.UpsertModelMapping("default", "anthropic.other"); err == nil {
+		t.Fatal("expected default alias to be reserved")
+	}
+	if err := service.UpsertModelMapping("faster", "fast"); err == nil {
+		t.Fatal("expected alias targeting another alias to be rejected")
+	}
+	if err := service.UpsertModelMapping("anthropic.claude-3-5-haiku", "anthropic.other"); err == nil {
+		t.Fatal("expected alias that is another alias's target to be rejected")
+	}
+	if err := service.UpsertModelMapping("Smart", "anthropic.claude-sonnet-4"); err != nil {
+		t.Fatalf("unexpected upsert error: %v", err)
+	}
+	if _, bedrockID, _ := service.ResolveModel("smart"); bedrockID != "anthropic.claude-sonnet-4" {
+		t.Fatalf("expected new alias to resolve immediately, got %s", bedrockID)
+	}
+	if target, ok := service.AliasTarget("default"); !ok || target != "anthropic.default" {
+		t.Fatalf("expected default alias target, got %s %v", target, ok)
+	}
+
+	if !service.DeleteModelMapping("fast") || service.DeleteModelMapping("fast") {
+		t.Fatal("expected delete to succeed once")
+	}
+	if _, bedrockID, _ := service.ResolveModel("fast"); bedrockID != "fast" {
+		t.Fatalf("expected deleted alias to pass through, got %s", bedrockID)
+	}
 }
 
 func TestResolveModelDefault(t *testing.T) {
@@ -152,3 +198,85 @@ func TestBuildInferenceConfigDoesNotEnforceToolMinimumWithoutTools(t *testing.T)
 		t.Fatalf("unexpected inference max tokens: %d", *cfg.MaxTokens)
 	}
 }
+
+func TestBuildInferenceConfigStopSequences(t *testing.T) {
+	request := openai.ChatCompletionRequest{Stop: json.RawMessage(`["END", ""]`)}
+
+	cfg, _, _, _ := buildInferenceConfig(request, 0, 0)
+	if cfg == nil || len(cfg.StopSequences) != 1 || cfg.StopSequences[0] != "END" {
+		t.Fatalf("unexpected stop sequences: %#v", cfg)
+	}
+
+	request.Stop = json.RawMessage(`"###"`)
+	cfg, _, _, _ = buildInferenceConfig(request, 0, 0)
+	if cfg == nil || len(cfg.StopSequences) != 1 || cfg.StopSequences[0] != "###" {
+		t.Fatalf("unexpected stop sequences for string stop: %#v", cfg)
+	}
+}
+
+func TestConverseAdditionalModelRequestFieldsAllowlist(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonStopSequence,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: "partial"}},
+		}},
+	}}
+	service := NewService(client, "anthropic.default", nil, 2048, 8192, false, false)
+	service.SetAllowedRequestFields([]string{"top_k"})
+
+	topK := 40
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages: []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		TopK:     &topK,
+		AdditionalModelRequestFields: map[string]json.RawMessage{
+			"anthropic_beta": json.RawMessage(`["x"]`),
+		},
+	}, "anthropic.default")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	if result.FinishReason != "stop" || result.StopReason != "stop_sequence" {
+		t.Fatalf("unexpected finish/stop reason: %q / %q", result.FinishReason, result.StopReason)
+	}
+
+	fields := client.LastInput().AdditionalModelRequestFields
+	if fields == nil {
+		t.Fatalf("expected additional model request fields")
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(documentToJSONString(fields)), &payload); err != nil {
+		t.Fatalf("unmarshal additional fields failed: %v", err)
+	}
+	if len(payload) != 1 || payload["top_k"] == nil {
+		t.Fatalf("expected only allowlisted top_k, got %#v", payload)
+	}
+}
+
+func TestBuildBedrockMessagesAnthropicImageBlock(t *testing.T) {
+	messages := []openai.ChatMessage{
+		{
+			Role:    "user",
+			Content: json.RawMessage(`[{"type":"text","text":"what is this?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"aGVsbG8="}}]`),
+		},
+	}
+
+	out, _, err := BuildBedrockMessages(messages)
+	if err != nil {
+		t.Fatalf("BuildBedrockMessages returned error: %v", err)
+	}
+	if len(out) != 1 || len(out[0].Content) != 2 {
+		t.Fatalf("expected one message with text + image blocks, got %#v", out)
+	}
+	image, ok := out[0].Content[1].(*brtypes.ContentBlockMemberImage)
+	if !ok {
+		t.Fatalf("expected image block, got %T", out[0].Content[1])
+	}
+	if image.Value.Format != brtypes.ImageFormatPng {
+		t.Fatalf("unexpected image format: %s", image.Value.Format)
+	}
+	source, ok := image.Value.Source.(*brtypes.ImageSourceMemberBytes)
+	if !ok || string(source.Value) != "hello" {
+		t.Fatalf("unexpected image source: %#v", image.Value.Source)
+	}
+}
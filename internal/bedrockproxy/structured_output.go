@@ -0,0 +1,156 @@
+package bedrockproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// structuredOutputToolName 是 response_format 对应的合成工具名。
+// Bedrock Converse 没有原生的 JSON 模式，这里用「强制调用一个 input schema 即目标 schema 的工具」来模拟，
+// 模型返回的 toolUse.input 会被还原为 message.content。
+const structuredOutputToolName = "json_response"
+
+type structuredOutput struct {
+	format openai.ResponseFormat
+	schema map[string]any
+}
+
+// newStructuredOutput 解析 response_format；type=text 或未设置时返回 nil。
+func newStructuredOutput(raw json.RawMessage) (*structuredOutput, error) {
+	format, err := openai.ParseResponseFormat(raw)
+	if err != nil || format == nil {
+		return nil, err
+	}
+
+	schema := map[string]any{"type": "object"}
+	if format.Type == "json_schema" {
+		schema = map[string]any{}
+		if err := json.Unmarshal(format.Schema, &schema); err != nil {
+			return nil, fmt.Errorf("invalid response_format schema: %w", err)
+		}
+		if _, ok := schema["type"]; !ok {
+			schema["type"] = "object"
+		}
+	}
+	return &structuredOutput{format: *format, schema: schema}, nil
+}
+
+func (s *structuredOutput) toolSpec() brtypes.Tool {
+	description := "Respond to the user by calling this tool. The tool input is the final answer and must match the JSON schema."
+	if s.format.Name != "" {
+		description += " Schema: " + s.format.Name + "."
+	}
+	if s.format.Description != "" {
+		description += " " + s.format.Description
+	}
+
+	spec := brtypes.ToolSpecification{
+		Name:        aws.String(structuredOutputToolName),
+		Description: aws.String(description),
+		InputSchema: &brtypes.ToolInputSchemaMemberJson{
+			Value: document.NewLazyDocument(s.schema),
+		},
+	}
+	if s.format.Strict != nil {
+		spec.Strict = s.format.Strict
+	}
+	return &brtypes.ToolMemberToolSpec{Value: spec}
+}
+
+// buildStructuredToolConfiguration 在用户工具之外追加合成工具：
+//   - 没有用户工具（或 tool_choice=none）时强制调用合成工具；
+//   - 有用户工具且 tool_choice 为 auto/未设置时改为 any，模型要么调用业务工具，要么通过合成工具给出最终答案；
+//   - 用户显式指定了 tool_choice 时保持不变。
+func buildStructuredToolConfiguration(bedrockTools []brtypes.Tool, rawToolChoice json.RawMessage, structured *structuredOutput) (*brtypes.ToolConfiguration, error) {
+	toolChoice, disableTools, err := parseToolChoice(rawToolChoice)
+	if err != nil {
+		return nil, err
+	}
+	if disableTools {
+		bedrockTools = nil
+	}
+
+	cfg := &brtypes.ToolConfiguration{
+		Tools: append(bedrockTools, structured.toolSpec()),
+	}
+	_, isAuto := toolChoice.(*brtypes.ToolChoiceMemberAuto)
+	switch {
+	case len(bedrockTools) == 0:
+		cfg.ToolChoice = &brtypes.ToolChoiceMemberTool{
+			Value: brtypes.SpecificToolChoice{Name: aws.String(structuredOutputToolName)},
+		}
+	case toolChoice == nil || isAuto:
+		cfg.ToolChoice = &brtypes.ToolChoiceMemberAny{Value: brtypes.AnyToolChoice{}}
+	default:
+		cfg.ToolChoice = toolChoice
+	}
+
+	return cfg, nil
+}
+
+// StructuredOutputError 表示模型输出不是合法 JSON 或不符合 response_format 的 schema。
+// 此时 Bedrock 调用本身已成功并产生费用，Converse / ConverseStream 会同时返回带 usage 的结果。
+type StructuredOutputError struct {
+	Err error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+func IsStructuredOutputError(err error) bool {
+	var target *StructuredOutputError
+	return errors.As(err, &target)
+}
+
+// apply 把合成工具调用还原为文本内容，并按 schema 校验，校验失败时返回 *StructuredOutputError。
+// finish_reason=length 时输出可能被截断，不做校验，与 OpenAI 行为一致。
+func (s *structuredOutput) apply(result *ChatResult) error {
+	toolCalls := make([]openai.ToolCall, 0, len(result.ToolCalls))
+	found := false
+	for _, toolCall := range result.ToolCalls {
+		if toolCall.Function.Name == structuredOutputToolName && !found {
+			found = true
+			result.Text = toolCall.Function.Arguments
+			continue
+		}
+		toolCalls = append(toolCalls, toolCall)
+	}
+	result.ToolCalls = toolCalls
+
+	if found && len(toolCalls) == 0 && result.FinishReason == "tool_calls" {
+		result.FinishReason = "stop"
+	}
+	if result.FinishReason == "length" || (!found && len(toolCalls) > 0) {
+		return nil
+	}
+	if err := s.validate(result.Text); err != nil {
+		return &StructuredOutputError{Err: err}
+	}
+	return nil
+}
+
+func (s *structuredOutput) validate(text string) error {
+	var value any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &value); err != nil {
+		return fmt.Errorf("model output is not valid JSON for response_format: %w", err)
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return errors.New("model output for response_format must be a JSON object")
+	}
+	if err := validateJSONSchema(value, s.schema); err != nil {
+		return fmt.Errorf("model output does not match response_format schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package bedrockproxy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"aws-cursor-router/internal/openai"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+const testInvoiceFormat = `{"type":"json_schema","json_schema":{"name":"invoice","schema":{"type":"object","properties":{"total":{"type":"number"},"currency":{"type":"string","enum":["USD","EUR"]}},"required":["total","currency"],"additionalProperties":false}}}`
+
+func TestBuildToolConfigurationForcesStructuredOutputTool(t *testing.T) {
+	structured, err := newStructuredOutput(json.RawMessage(testInvoiceFormat))
+	if err != nil {
+		t.Fatalf("newStructuredOutput returned error: %v", err)
+	}
+
+	cfg, err := buildToolConfiguration(nil, nil, false, structured)
+	if err != nil {
+		t.Fatalf("buildToolConfiguration returned error: %v", err)
+	}
+	if cfg == nil || len(cfg.Tools) != 1 {
+		t.Fatalf("expected synthetic tool only, got %#v", cfg)
+	}
+	choice, ok := cfg.ToolChoice.(*brtypes.ToolChoiceMemberTool)
+	if !ok || aws.ToString(choice.Value.Name) != structuredOutputToolName {
+		t.Fatalf("expected forced %s tool choice, got %#v", structuredOutputToolName, cfg.ToolChoice)
+	}
+
+	tools := []openai.Tool{{Type: "function", Function: &openai.ToolFunction{Name: "lookup"}}}
+	cfg, err = buildToolConfiguration(tools, json.RawMessage(`"auto"`), false, structured)
+	if err != nil {
+		t.Fatalf("buildToolConfiguration with tools returned error: %v", err)
+	}
+	if len(cfg.Tools) != 2 {
+		t.Fatalf("expected user tool + synthetic tool, got %d", len(cfg.Tools))
+	}
+	if _, ok := cfg.ToolChoice.(*brtypes.ToolChoiceMemberAny); !ok {
+		t.Fatalf("expected any tool choice when user tools are present, got %T", cfg.ToolChoice)
+	}
+}
+
+func TestConverseUnwrapsStructuredOutput(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonToolUse,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role: brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberToolUse{Value: brtypes.ToolUseBlock{
+				ToolUseId: aws.String("tooluse_1"),
+				Name:      aws.String(structuredOutputToolName),
+				Input:     document.NewLazyDocument(map[string]any{"total": 42.5, "currency": "USD"}),
+			}}},
+		}},
+	}}
+	service := NewService(client, "anthropic.default", nil, 2048, 8192, false, false)
+
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages:       []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"extract the invoice"`)}},
+		ResponseFormat: json.RawMessage(testInvoiceFormat),
+	}, "anthropic.default")
+	if err != nil {
+		t.Fatalf("Converse returned error: %v", err)
+	}
+	if len(result.ToolCalls) != 0 {
+		t.Fatalf("expected synthetic tool call to be removed, got %#v", result.ToolCalls)
+	}
+	if result.FinishReason != "stop" {
+		t.Fatalf("unexpected finish reason: %q", result.FinishReason)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(result.Text), &payload); err != nil || payload["currency"] != "USD" {
+		t.Fatalf("unexpected content: %q", result.Text)
+	}
+	if client.LastInput().ToolConfig == nil || len(client.LastInput().ToolConfig.Tools) != 1 {
+		t.Fatalf("expected synthetic tool in request, got %#v", client.LastInput().ToolConfig)
+	}
+}
+
+func TestStructuredOutputRejectsSchemaMismatch(t *testing.T) {
+	structured, err := newStructuredOutput(json.RawMessage(testInvoiceFormat))
+	if err != nil {
+		t.Fatalf("newStructuredOutput returned error: %v", err)
+	}
+
+	cases := map[string]string{
+		"not json":      `total: 3`,
+		"missing field": `{"total":3}`,
+		"wrong enum":    `{"total":3,"currency":"JPY"}`,
+		"extra field":   `{"total":3,"currency":"USD","note":"x"}`,
+		"wrong type":    `{"total":"3","currency":"USD"}`,
+	}
+	for name, text := range cases {
+		result := ChatResult{Text: text, FinishReason: "stop"}
+		if err := structured.apply(&result); err == nil || !strings.Contains(err.Error(), "response_format") {
+			t.Fatalf("%s: expected response_format validation error, got %v", name, err)
+		}
+	}
+
+	truncated := ChatResult{Text: `{"total":3,`, FinishReason: "length"}
+	if err := structured.apply(&truncated); err != nil {
+		t.Fatalf("expected truncated output to skip validation, got %v", err)
+	}
+}
+
+func TestConverseKeepsUsageWhenStructuredOutputInvalid(t *testing.T) {
+	client := &bedrocktest.Client{Output: &bedrockruntime.ConverseOutput{
+		StopReason: brtypes.StopReasonToolUse,
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role: brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberToolUse{Value: brtypes.ToolUseBlock{
+				ToolUseId: aws.String("tooluse_1"),
+				Name:      aws.String(structuredOutputToolName),
+				Input:     document.NewLazyDocument(map[string]any{"total": 42.5, "currency": "JPY"}),
+			}}},
+		}},
+		Usage: &brtypes.TokenUsage{InputTokens: aws.Int32(120), OutputTokens: aws.Int32(30), TotalTokens: aws.Int32(150)},
+	}}
+	service := NewService(client, "anthropic.default", nil, 2048, 8192, false, false)
+
+	result, err := service.Converse(context.Background(), openai.ChatCompletionRequest{
+		Messages:       []openai.ChatMessage{{Role: "user", Content: json.RawMessage(`"extract the invoice"`)}},
+		ResponseFormat: json.RawMessage(testInvoiceFormat),
+	}, "anthropic.default")
+	if !IsStructuredOutputError(err) {
+		t.Fatalf("expected structured output error, got %v", err)
+	}
+	if IsRequestError(err) || IsUpstreamUnavailable(err) {
+		t.Fatalf("validation failure must not look like a request or upstream error: %v", err)
+	}
+	if result.InputTokens != 120 || result.OutputTokens != 30 || result.TotalTokens != 150 {
+		t.Fatalf("expected usage to survive validation failure, got %+v", result)
+	}
+}
+
+func TestValidateJSONSchemaRefs(t *testing.T) {
+	var schema map[string]any
+	_ = json.Unmarshal([]byte(`{
+		"type":"object",
+		"properties":{"items":{"type":"array","items":{"$ref":"#/$defs/item"},"minItems":1}},
+		"$defs":{"item":{"type":"object","properties":{"qty":{"type":"integer"},"note":{"type":["string","null"]}},"required":["qty"]}}
+	}`), &schema)
+
+	var valid any
+	_ = json.Unmarshal([]byte(`{"items":[{"qty":2,"note":null}]}`), &valid)
+	if err := validateJSONSchema(valid, schema); err != nil {
+		t.Fatalf("expected valid document, got %v", err)
+	}
+
+	var invalid any
+	_ = json.Unmarshal([]byte(`{"items":[{"qty":2.5}]}`), &invalid)
+	if err := validateJSONSchema(invalid, schema); err == nil {
+		t.Fatalf("expected integer validation error")
+	}
+}
@@ -103,7 +103,7 @@ func TestBuildToolConfiguration(t *testing.T) {
 		},
 	}
 
-	cfg, err := buildToolConfiguration(tools, json.RawMessage(`"required"`), false)
+	cfg, err := buildToolConfiguration(tools, json.RawMessage(`"required"`), false, nil)
 	if err != nil {
 		t.Fatalf("buildToolConfiguration(required) returned error: %v", err)
 	}
@@ -117,7 +117,7 @@ func TestBuildToolConfiguration(t *testing.T) {
 		t.Fatalf("expected ToolChoiceMemberAny, got %T", cfg.ToolChoice)
 	}
 
-	cfgNone, err := buildToolConfiguration(tools, json.RawMessage(`"none"`), false)
+	cfgNone, err := buildToolConfiguration(tools, json.RawMessage(`"none"`), false, nil)
 	if err != nil {
 		t.Fatalf("buildToolConfiguration(none) returned error: %v", err)
 	}
@@ -125,7 +125,7 @@ func TestBuildToolConfiguration(t *testing.T) {
 		t.Fatalf("expected nil config when tool_choice is none")
 	}
 
-	cfgSpecific, err := buildToolConfiguration(tools, json.RawMessage(`{"type":"function","function":{"name":"search_docs"}}`), false)
+	cfgSpecific, err := buildToolConfiguration(tools, json.RawMessage(`{"type":"function","function":{"name":"search_docs"}}`), false, nil)
 	if err != nil {
 		t.Fatalf("buildToolConfiguration(function choice) returned error: %v", err)
 	}
@@ -137,7 +137,7 @@ func TestBuildToolConfiguration(t *testing.T) {
 	}
 
 	// Test forceToolUse with auto choice
-	cfgForced, err := buildToolConfiguration(tools, json.RawMessage(`"auto"`), true)
+	cfgForced, err := buildToolConfiguration(tools, json.RawMessage(`"auto"`), true, nil)
 	if err != nil {
 		t.Fatalf("buildToolConfiguration(auto, forceToolUse=true) returned error: %v", err)
 	}
@@ -0,0 +1,327 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go"
+)
+
+// Upstream 是一个 Bedrock 上游：某个账号 / 区域的 bedrockruntime 客户端，以及它服务的模型。
+type Upstream struct {
+	Name   string
+	Client ConverseAPI
+	// 为空表示服务所有模型，否则只接收这些 Bedrock 模型 ID（忽略大小写）的请求
+	Models []string
+	// Priority 越小越先尝试；同一优先级内按 Weight 加权随机
+	Priority int
+	Weight   int
+}
+
+// UpstreamHealth 是单个上游的健康状态快照，供管理后台展示。
+type UpstreamHealth struct {
+	Name                string
+	Healthy             bool
+	ConsecutiveFailures int
+	Requests            int64
+	Failures            int64
+	LastError           string
+	LastErrorAt         time.Time
+	CooldownUntil       time.Time
+}
+
+// UpstreamPool 把多个上游组合成一个客户端（实现 ConverseAPI / InvokeModelAPI / CountTokensAPI / ApplyGuardrailAPI）：
+// 按请求的 ModelId 选出候选上游，限流或 5xx 时把该上游冷却 cooldown 并切换到下一个。
+// 冷却中的上游排在最后，所有健康上游都失败时仍会尝试。
+type UpstreamPool struct {
+	upstreams []*pooledUpstream
+	cooldown  time.Duration
+	// 加权随机使用的随机数，测试中可替换
+	random func() float64
+	now    func() time.Time
+}
+
+type pooledUpstream struct {
+	Upstream
+	models map[string]struct{}
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	requests            int64
+	failures            int64
+	lastError           string
+	lastErrorAt         time.Time
+	cooldownUntil       time.Time
+}
+
+func NewUpstreamPool(upstreams []Upstream, cooldown time.Duration) *UpstreamPool {
+	pool := &UpstreamPool{
+		upstreams: make([]*pooledUpstream, 0, len(upstreams)),
+		cooldown:  cooldown,
+		random:    rand.Float64,
+		now:       time.Now,
+	}
+	for _, upstream := range upstreams {
+		if upstream.Client == nil {
+			continue
+		}
+		if upstream.Weight <= 0 {
+			upstream.Weight = 1
+		}
+		models := make(map[string]struct{}, len(upstream.Models))
+		for _, modelID := range upstream.Models {
+			if modelID = strings.ToLower(strings.TrimSpace(modelID)); modelID != "" {
+				models[modelID] = struct{}{}
+			}
+		}
+		pool.upstreams = append(pool.upstreams, &pooledUpstream{Upstream: upstream, models: models})
+	}
+	return pool
+}
+
+// Len 返回池中可用（已配置客户端）的上游数量。
+func (p *UpstreamPool) Len() int {
+	return len(p.upstreams)
+}
+
+// Health 按配置顺序返回每个上游的健康状态。
+func (p *UpstreamPool) Health() []UpstreamHealth {
+	now := p.now()
+	out := make([]UpstreamHealth, 0, len(p.upstreams))
+	for _, upstream := range p.upstreams {
+		upstream.mu.Lock()
+		out = append(out, UpstreamHealth{
+			Name:                upstream.Name,
+			Healthy:             !now.Before(upstream.cooldownUntil),
+			ConsecutiveFailures: upstream.consecutiveFailures,
+			Requests:            upstream.requests,
+			Failures:            upstream.failures,
+			LastError:           upstream.lastError,
+			LastErrorAt:         upstream.lastErrorAt,
+			CooldownUntil:       upstream.cooldownUntil,
+		})
+		upstream.mu.Unlock()
+	}
+	return out
+}
+
+func (p *UpstreamPool) Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	return callUpstreams(ctx, p, aws.ToString(params.ModelId), func(client ConverseAPI) (*bedrockruntime.ConverseOutput, bool, error) {
+		output, err := client.Converse(ctx, params, optFns...)
+		return output, true, err
+	})
+}
+
+// ConverseStream 只在建立流之前切换上游；流开始后的错误由调用方处理。
+func (p *UpstreamPool) ConverseStream(ctx context.Context, params *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseStreamOutput, error) {
+	return callUpstreams(ctx, p, aws.ToString(params.ModelId), func(client ConverseAPI) (*bedrockruntime.ConverseStreamOutput, bool, error) {
+		output, err := client.ConverseStream(ctx, params, optFns...)
+		return output, true, err
+	})
+}
+
+func (p *UpstreamPool) InvokeModel(ctx context.Context, params *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	return callUpstreams(ctx, p, aws.ToString(params.ModelId), func(client ConverseAPI) (*bedrockruntime.InvokeModelOutput, bool, error) {
+		invokeClient, ok := client.(InvokeModelAPI)
+		if !ok {
+			return nil, false, nil
+		}
+		output, err := invokeClient.InvokeModel(ctx, params, optFns...)
+		return output, true, err
+	})
+}
+
+func (p *UpstreamPool) CountTokens(ctx context.Context, params *bedrockruntime.CountTokensInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.CountTokensOutput, error) {
+	return callUpstreams(ctx, p, aws.ToString(params.ModelId), func(client ConverseAPI) (*bedrockruntime.CountTokensOutput, bool, error) {
+		countClient, ok := client.(CountTokensAPI)
+		if !ok {
+			return nil, false, nil
+		}
+		output, err := countClient.CountTokens(ctx, params, optFns...)
+		return output, true, err
+	})
+}
+
+// ApplyGuardrail 不涉及模型，由所有上游按优先级服务；Guardrail 标识需在这些账号中都可用（或使用跨账号可访问的 ARN）。
+func (p *UpstreamPool) ApplyGuardrail(ctx context.Context, params *bedrockruntime.ApplyGuardrailInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ApplyGuardrailOutput, error) {
+	return callUpstreams(ctx, p, "", func(client ConverseAPI) (*bedrockruntime.ApplyGuardrailOutput, bool, error) {
+		guardrailClient, ok := client.(ApplyGuardrailAPI)
+		if !ok {
+			return nil, false, nil
+		}
+		output, err := guardrailClient.ApplyGuardrail(ctx, params, optFns...)
+		return output, true, err
+	})
+}
+
+// callUpstreams 依次尝试候选上游；call 返回 false 表示该上游不支持此操作（跳过且不计入健康统计）。
+func callUpstreams[T any](ctx context.Context, p *UpstreamPool, modelID string, call func(client ConverseAPI) (T, bool, error)) (T, error) {
+	var zero T
+	var lastErr error
+	for _, upstream := range p.candidates(modelID) {
+		output, supported, err := call(upstream.Client)
+		if !supported {
+			continue
+		}
+		if err == nil {
+			upstream.recordSuccess()
+			return output, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !IsUpstreamUnavailable(err) {
+			upstream.recordRequest()
+			return zero, err
+		}
+		upstream.recordFailure(err, p.now().Add(p.cooldown))
+	}
+	if lastErr != nil {
+		return zero, lastErr
+	}
+	if modelID == "" {
+		return zero, errors.New("no bedrock upstream supports this operation")
+	}
+	return zero, errors.New("no bedrock upstream is configured for model " + modelID)
+}
+
+// candidates 返回服务该模型的上游：健康的按 Priority 升序、同优先级加权随机排列，冷却中的按恢复时间排在最后。
+func (p *UpstreamPool) candidates(modelID string) []*pooledUpstream {
+	modelID = strings.ToLower(strings.TrimSpace(modelID))
+	now := p.now()
+	healthy := make([]*pooledUpstream, 0, len(p.upstreams))
+	cooling := make([]*pooledUpstream, 0)
+	cooldownUntil := make(map[*pooledUpstream]time.Time)
+	for _, upstream := range p.upstreams {
+		if !upstream.serves(modelID) {
+			continue
+		}
+		upstream.mu.Lock()
+		until := upstream.cooldownUntil
+		upstream.mu.Unlock()
+		if now.Before(until) {
+			cooldownUntil[upstream] = until
+			cooling = append(cooling, upstream)
+			continue
+		}
+		healthy = append(healthy, upstream)
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return healthy[i].Priority < healthy[j].Priority
+	})
+	ordered := make([]*pooledUpstream, 0, len(healthy)+len(cooling))
+	for start := 0; start < len(healthy); {
+		end := start
+		for end < len(healthy) && healthy[end].Priority == healthy[start].Priority {
+			end++
+		}
+		ordered = append(ordered, p.weightedOrder(healthy[start:end])...)
+		start = end
+	}
+
+	sort.SliceStable(cooling, func(i, j int) bool {
+		return cooldownUntil[cooling[i]].Before(cooldownUntil[cooling[j]])
+	})
+	return append(ordered, cooling...)
+}
+
+// weightedOrder 按权重做不放回抽样，得到同一优先级内的尝试顺序。
+func (p *UpstreamPool) weightedOrder(group []*pooledUpstream) []*pooledUpstream {
+	remaining := append([]*pooledUpstream(nil), group...)
+	out := make([]*pooledUpstream, 0, len(group))
+	for len(remaining) > 0 {
+		total := 0
+		for _, upstream := range remaining {
+			total += upstream.Weight
+		}
+		target := p.random() * float64(total)
+		index := len(remaining) - 1
+		for i, upstream := range remaining {
+			target -= float64(upstream.Weight)
+			if target < 0 {
+				index = i
+				break
+			}
+		}
+		out = append(out, remaining[index])
+		remaining = append(remaining[:index], remaining[index+1:]...)
+	}
+	return out
+}
+
+func (u *pooledUpstream) serves(modelID string) bool {
+	if len(u.models) == 0 || modelID == "" {
+		return true
+	}
+	_, ok := u.models[modelID]
+	return ok
+}
+
+func (u *pooledUpstream) recordRequest() {
+	u.mu.Lock()
+	u.requests++
+	u.mu.Unlock()
+}
+
+func (u *pooledUpstream) recordSuccess() {
+	u.mu.Lock()
+	u.requests++
+	u.consecutiveFailures = 0
+	u.cooldownUntil = time.Time{}
+	u.mu.Unlock()
+}
+
+func (u *pooledUpstream) recordFailure(err error, cooldownUntil time.Time) {
+	u.mu.Lock()
+	u.requests++
+	u.failures++
+	u.consecutiveFailures++
+	u.lastError = err.Error()
+	u.lastErrorAt = time.Now().UTC()
+	u.cooldownUntil = cooldownUntil
+	u.mu.Unlock()
+}
+
+// IsUpstreamUnavailable 判断错误是否为限流、Bedrock 服务端错误（HTTP 429 / 5xx、ThrottlingException 等）或连接错误，
+// 这类错误换一个上游（或稍后重试）可能成功；请求内容错误、权限错误等不在此列。
+func IsUpstreamUnavailable(err error) bool {
+	if err == nil || IsRequestError(err) {
+		return false
+	}
+	var connErr interface{ ConnectionError() bool }
+	if errors.As(err, &connErr) && connErr.ConnectionError() {
+		return true
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		status := statusErr.HTTPStatusCode()
+		if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException", "ServiceUnavailableException",
+			"InternalServerException", "ModelNotReadyException", "ServiceQuotaExceededException":
+			return true
+		}
+		return apiErr.ErrorFault() == smithy.FaultServer
+	}
+	return false
+}
+
+// 确保 UpstreamPool 可以直接作为 Service 的客户端使用。
+var (
+	_ ConverseAPI       = (*UpstreamPool)(nil)
+	_ InvokeModelAPI    = (*UpstreamPool)(nil)
+	_ CountTokensAPI    = (*UpstreamPool)(nil)
+	_ ApplyGuardrailAPI = (*UpstreamPool)(nil)
+)
@@ -0,0 +1,105 @@
+package bedrockproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"aws-cursor-router/internal/bedrocktest"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/smithy-go"
+)
+
+// recordingClient 把每次调用记录到共享的 calls（用于断言跨上游的调用顺序），并始终返回 err
+func recordingClient(name string, calls *[]string, err error) *bedrocktest.Client {
+	return &bedrocktest.Client{Hook: func(ctx context.Context, modelID string) error {
+		*calls = append(*calls, name)
+		return err
+	}}
+}
+
+func TestUpstreamPoolFailsOverOnThrottling(t *testing.T) {
+	var calls []string
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down"}
+	pool := NewUpstreamPool([]Upstream{
+		{Name: "primary", Client: recordingClient("primary", &calls, throttled), Priority: 0},
+		{Name: "backup", Client: recordingClient("backup", &calls, nil), Priority: 1},
+	}, time.Minute)
+
+	if _, err := pool.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("m")}); err != nil {
+		t.Fatalf("expected failover to succeed, got %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "primary" || calls[1] != "backup" {
+		t.Fatalf("unexpected call order: %v", calls)
+	}
+
+	health := pool.Health()
+	if health[0].Healthy || health[0].ConsecutiveFailures != 1 || health[0].LastError == "" || !health[1].Healthy {
+		t.Fatalf("unexpected health after failover: %+v", health)
+	}
+
+	// 冷却中的上游排到最后
+	calls = nil
+	if _, err := pool.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("m")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "backup" {
+		t.Fatalf("expected cooling upstream to be skipped, got %v", calls)
+	}
+}
+
+func TestUpstreamPoolDoesNotFailOverOnClientError(t *testing.T) {
+	var calls []string
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "no access", Fault: smithy.FaultClient}
+	pool := NewUpstreamPool([]Upstream{
+		{Name: "primary", Client: recordingClient("primary", &calls, denied)},
+		{Name: "backup", Client: recordingClient("backup", &calls, nil), Priority: 1},
+	}, time.Minute)
+
+	_, err := pool.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("m")})
+	if !errors.Is(err, denied) {
+		t.Fatalf("expected client error to be returned, got %v", err)
+	}
+	if len(calls) != 1 || !pool.Health()[0].Healthy {
+		t.Fatalf("client errors must not fail over or mark the upstream unhealthy: %v %+v", calls, pool.Health())
+	}
+}
+
+func TestUpstreamPoolFiltersByModelAndWeight(t *testing.T) {
+	var calls []string
+	pool := NewUpstreamPool([]Upstream{
+		{Name: "all", Client: recordingClient("all", &calls, nil), Priority: 1},
+		{Name: "light", Client: recordingClient("light", &calls, nil), Models: []string{"Claude"}, Weight: 1},
+		{Name: "heavy", Client: recordingClient("heavy", &calls, nil), Models: []string{"claude"}, Weight: 3},
+	}, time.Minute)
+	pool.random = func() float64 { return 0.5 }
+
+	if _, err := pool.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("claude")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pool.Converse(context.Background(), &bedrockruntime.ConverseInput{ModelId: aws.String("other")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "heavy" || calls[1] != "all" {
+		t.Fatalf("unexpected upstream selection: %v", calls)
+	}
+}
+
+func TestIsUpstreamUnavailable(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"throttling":    {&smithy.GenericAPIError{Code: "ThrottlingException"}, true},
+		"server fault":  {&smithy.GenericAPIError{Code: "Whatever", Fault: smithy.FaultServer}, true},
+		"validation":    {&smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient}, false},
+		"request error": {&RequestError{Err: errors.New("bad")}, false},
+		"plain":         {errors.New("boom"), false},
+	} {
+		if got := IsUpstreamUnavailable(testCase.err); got != testCase.want {
+			t.Fatalf("%s: IsUpstreamUnavailable = %v, want %v", name, got, testCase.want)
+		}
+	}
+}
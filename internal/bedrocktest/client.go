@@ -0,0 +1,111 @@
+// Package bedrocktest 提供各包测试共用的可编排 Bedrock runtime 客户端。
+package bedrocktest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	brtypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Client 实现 Converse / ConverseStream（bedrockproxy.ConverseAPI），不实现 InvokeModel、CountTokens 等可选接口；
+// 需要这些能力的测试嵌入 *Client 再补充方法。
+//
+// 每次调用先记录模型 ID 与输入，再依次检查 Hook、ModelErrors、Errors（按调用顺序消费），
+// 都没有返回错误时 Converse 返回 Output（为 nil 时返回一条 "ok" 文本消息）。
+// ConverseStream 没有流式输出可返回，未被 Hook 或错误拦截时返回 ErrStreamNotScripted。
+type Client struct {
+	Output      *bedrockruntime.ConverseOutput
+	Errors      []error
+	ModelErrors map[string]error
+	// Hook 在每次调用开始时执行（不持有锁），返回非 nil 错误时作为调用结果；可用于阻塞、记录跨客户端的调用顺序等
+	Hook func(ctx context.Context, modelID string) error
+
+	mu       sync.Mutex
+	modelIDs []string
+	inputs   []*bedrockruntime.ConverseInput
+}
+
+// ErrStreamNotScripted 是 ConverseStream 未被 Hook 或错误拦截时返回的错误。
+var ErrStreamNotScripted = errors.New("bedrocktest: ConverseStream output is not scripted")
+
+func (c *Client) Converse(ctx context.Context, params *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	c.mu.Lock()
+	c.inputs = append(c.inputs, params)
+	c.mu.Unlock()
+	if err := c.begin(ctx, aws.ToString(params.ModelId)); err != nil {
+		return nil, err
+	}
+	if c.Output != nil {
+		return c.Output, nil
+	}
+	return TextOutput("ok"), nil
+}
+
+func (c *Client) ConverseStream(ctx context.Context, params *bedrockruntime.ConverseStreamInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseStreamOutput, error) {
+	if err := c.begin(ctx, aws.ToString(params.ModelId)); err != nil {
+		return nil, err
+	}
+	return nil, ErrStreamNotScripted
+}
+
+func (c *Client) begin(ctx context.Context, modelID string) error {
+	c.mu.Lock()
+	c.modelIDs = append(c.modelIDs, modelID)
+	c.mu.Unlock()
+	if c.Hook != nil {
+		if err := c.Hook(ctx, modelID); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ModelErrors[modelID]; err != nil {
+		return err
+	}
+	if len(c.Errors) > 0 {
+		err := c.Errors[0]
+		c.Errors = c.Errors[1:]
+		return err
+	}
+	return nil
+}
+
+// Calls 返回 Converse 与 ConverseStream 的调用次数。
+func (c *Client) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.modelIDs)
+}
+
+// ModelIDs 按调用顺序返回每次调用的模型 ID。
+func (c *Client) ModelIDs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.modelIDs...)
+}
+
+// LastInput 返回最近一次 Converse 的输入，没有调用时返回 nil。
+func (c *Client) LastInput() *bedrockruntime.ConverseInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.inputs) == 0 {
+		return nil
+	}
+	return c.inputs[len(c.inputs)-1]
+}
+
+// TextOutput 返回只含一段文本、以 end_turn 结束的 Converse 输出。
+func TextOutput(text string) *bedrockruntime.ConverseOutput {
+	return &bedrockruntime.ConverseOutput{
+		Output: &brtypes.ConverseOutputMemberMessage{Value: brtypes.Message{
+			Role:    brtypes.ConversationRoleAssistant,
+			Content: []brtypes.ContentBlock{&brtypes.ContentBlockMemberText{Value: text}},
+		}},
+		StopReason: brtypes.StopReasonEndTurn,
+	}
+}
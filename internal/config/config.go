@@ -30,6 +30,24 @@ type Config struct {
 	TLSProxyCertFile      string
 	TLSProxyKeyFile       string
 	TLSProxyTargetURL     string
+	// Responses API store=true 时保存响应的有效期与单条记录大小上限（字节）
+	ResponsesStoreTTL      time.Duration
+	ResponsesStoreMaxBytes int
+	// Responses API background=true 的后台执行 worker 数、排队上限，以及单个后台响应的执行时长上限
+	BackgroundWorkers   int
+	BackgroundQueueSize int
+	BackgroundTimeout   time.Duration
+	// /v1/batches 本地执行器：同时调用 Bedrock 的请求数、单行失败后的重试次数，以及 /v1/files 单个文件的大小上限（字节）
+	BatchConcurrency int
+	BatchMaxRetries  int
+	FilesMaxBytes    int64
+	// 上游（账号 / 区域）遇到限流或 5xx 后被跳过的时长
+	UpstreamCooldown time.Duration
+	// 限流 / 瞬时错误的自动重试：最大重试次数、指数退避的初始与最大等待，以及重试预算（每 100 个请求允许的重试数）
+	BedrockMaxRetries         int
+	BedrockRetryBaseDelay     time.Duration
+	BedrockRetryMaxDelay      time.Duration
+	BedrockRetryBudgetPercent int
 }
 
 type ClientConfig struct {
@@ -65,6 +83,22 @@ func Load() (Config, error) {
 		TLSProxyCertFile:      strings.TrimSpace(os.Getenv("TLS_PROXY_CERT_FILE")),
 		TLSProxyKeyFile:       strings.TrimSpace(os.Getenv("TLS_PROXY_KEY_FILE")),
 		TLSProxyTargetURL:     getEnv("TLS_PROXY_TARGET_URL", "http://127.0.0.1:8080"),
+		ResponsesStoreTTL:     time.Duration(getEnvInt("RESPONSES_STORE_TTL_HOURS", 720)) * time.Hour,
+		// 默认 4 MiB
+		ResponsesStoreMaxBytes: getEnvInt("RESPONSES_STORE_MAX_BYTES", 4<<20),
+		BackgroundWorkers:      getEnvInt("BACKGROUND_WORKERS", 8),
+		BackgroundQueueSize:    getEnvInt("BACKGROUND_QUEUE_SIZE", 256),
+		BackgroundTimeout:      time.Duration(getEnvInt("BACKGROUND_TIMEOUT_SECONDS", 3600)) * time.Second,
+		BatchConcurrency:       getEnvInt("BATCH_CONCURRENCY", 2),
+		BatchMaxRetries:        getEnvInt("BATCH_MAX_RETRIES", 3),
+		// 默认 100 MiB
+		FilesMaxBytes:    int64(getEnvInt("FILES_MAX_BYTES", 100<<20)),
+		UpstreamCooldown: time.Duration(getEnvInt("UPSTREAM_COOLDOWN_SECONDS", 30)) * time.Second,
+		// 默认最多重试 2 次，退避 500ms 起、不超过 8s，重试数不超过请求数的 20%
+		BedrockMaxRetries:         getEnvInt("BEDROCK_MAX_RETRIES", 2),
+		BedrockRetryBaseDelay:     time.Duration(getEnvInt("BEDROCK_RETRY_BASE_DELAY_MS", 500)) * time.Millisecond,
+		BedrockRetryMaxDelay:      time.Duration(getEnvInt("BEDROCK_RETRY_MAX_DELAY_MS", 8000)) * time.Millisecond,
+		BedrockRetryBudgetPercent: getEnvInt("BEDROCK_RETRY_BUDGET_PERCENT", 20),
 	}
 
 	if cfg.DefaultMaxOutputToken < 0 {
@@ -85,6 +119,42 @@ func Load() (Config, error) {
 	if cfg.MaxContentChars <= 0 {
 		return Config{}, errors.New("MAX_CONTENT_CHARS must be > 0")
 	}
+	if cfg.ResponsesStoreTTL <= 0 {
+		return Config{}, errors.New("RESPONSES_STORE_TTL_HOURS must be > 0")
+	}
+	if cfg.ResponsesStoreMaxBytes <= 0 {
+		return Config{}, errors.New("RESPONSES_STORE_MAX_BYTES must be > 0")
+	}
+	if cfg.BackgroundWorkers <= 0 {
+		return Config{}, errors.New("BACKGROUND_WORKERS must be > 0")
+	}
+	if cfg.BackgroundQueueSize <= 0 {
+		return Config{}, errors.New("BACKGROUND_QUEUE_SIZE must be > 0")
+	}
+	if cfg.BackgroundTimeout <= 0 {
+		return Config{}, errors.New("BACKGROUND_TIMEOUT_SECONDS must be > 0")
+	}
+	if cfg.BatchConcurrency <= 0 {
+		return Config{}, errors.New("BATCH_CONCURRENCY must be > 0")
+	}
+	if cfg.BatchMaxRetries < 0 {
+		return Config{}, errors.New("BATCH_MAX_RETRIES must be >= 0")
+	}
+	if cfg.FilesMaxBytes <= 0 {
+		return Config{}, errors.New("FILES_MAX_BYTES must be > 0")
+	}
+	if cfg.UpstreamCooldown < 0 {
+		return Config{}, errors.New("UPSTREAM_COOLDOWN_SECONDS must be >= 0")
+	}
+	if cfg.BedrockMaxRetries < 0 {
+		return Config{}, errors.New("BEDROCK_MAX_RETRIES must be >= 0")
+	}
+	if cfg.BedrockRetryBaseDelay < 0 || cfg.BedrockRetryMaxDelay < 0 {
+		return Config{}, errors.New("BEDROCK_RETRY_BASE_DELAY_MS and BEDROCK_RETRY_MAX_DELAY_MS must be >= 0")
+	}
+	if cfg.BedrockRetryBudgetPercent < 0 {
+		return Config{}, errors.New("BEDROCK_RETRY_BUDGET_PERCENT must be >= 0")
+	}
 
 	if cfg.TLSProxyEnabled {
 		if cfg.TLSProxyCertFile == "" || cfg.TLSProxyKeyFile == "" {
@@ -0,0 +1,571 @@
+// Package gemini 实现 Gemini REST API generateContent / streamGenerateContent 的请求与响应格式，
+// 请求统一转换为内部使用的 OpenAI Chat 请求，复用 bedrockproxy.Service。
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+)
+
+type GenerateContentRequest struct {
+	Contents          []Content         `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+	// Gemini 的安全设置由 Bedrock Guardrails（管理员配置）代替，忽略
+	SafetySettings json.RawMessage `json:"safetySettings,omitempty"`
+	CachedContent  string          `json:"cachedContent,omitempty"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	Thought          bool              `json:"thought,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type FunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+	// googleSearch / codeExecution 等 Gemini 内置工具在 Bedrock 上没有对应能力
+	GoogleSearch          json.RawMessage `json:"googleSearch,omitempty"`
+	GoogleSearchRetrieval json.RawMessage `json:"googleSearchRetrieval,omitempty"`
+	CodeExecution         json.RawMessage `json:"codeExecution,omitempty"`
+}
+
+type FunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	// 标准 JSON Schema 形式的参数定义，优先于 parameters
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type ToolConfig struct {
+	FunctionCallingConfig *FunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+type GenerationConfig struct {
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"topP,omitempty"`
+	TopK               *int            `json:"topK,omitempty"`
+	MaxOutputTokens    *int            `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	CandidateCount     *int            `json:"candidateCount,omitempty"`
+	Seed               *int            `json:"seed,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseSchema     json.RawMessage `json:"responseSchema,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type ThinkingConfig struct {
+	// 0 关闭思考，-1 为动态预算
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+type GenerateContentResponse struct {
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string         `json:"modelVersion,omitempty"`
+	ResponseID    string         `json:"responseId,omitempty"`
+}
+
+type Candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+	Index        int     `json:"index"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error ErrorPayload `json:"error"`
+}
+
+type ErrorPayload struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// opaqueFields 中的字段是用户数据（函数参数、返回值、Schema），解析时保留原始 key，不做 snake_case 转换。
+var opaqueFields = map[string]struct{}{
+	"args":                 {},
+	"response":             {},
+	"parameters":           {},
+	"parametersJsonSchema": {},
+	"responseSchema":       {},
+	"responseJsonSchema":   {},
+}
+
+// ParseGenerateContentRequest 解析请求体。Gemini REST API 同时接受 camelCase 与 snake_case 字段名
+// （官方 curl 示例多用 system_instruction / inline_data），这里先统一转为 camelCase 再解码。
+func ParseGenerateContentRequest(raw []byte) (GenerateContentRequest, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return GenerateContentRequest{}, err
+	}
+	blob, err := json.Marshal(camelizeKeys(value))
+	if err != nil {
+		return GenerateContentRequest{}, err
+	}
+	var request GenerateContentRequest
+	if err := json.Unmarshal(blob, &request); err != nil {
+		return GenerateContentRequest{}, err
+	}
+	return request, nil
+}
+
+func camelizeKeys(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(typed))
+		for key, item := range typed {
+			key = snakeToCamel(key)
+			if _, ok := opaqueFields[key]; ok {
+				result[key] = item
+				continue
+			}
+			result[key] = camelizeKeys(item)
+		}
+		return result
+	case []any:
+		for index, item := range typed {
+			typed[index] = camelizeKeys(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	words := strings.Split(key, "_")
+	var builder strings.Builder
+	builder.WriteString(words[0])
+	for _, word := range words[1:] {
+		if word == "" {
+			continue
+		}
+		builder.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return builder.String()
+}
+
+func ValidateGenerateContentRequest(request GenerateContentRequest) error {
+	if len(request.Contents) == 0 {
+		return errors.New("contents is required")
+	}
+	for index, content := range request.Contents {
+		switch content.Role {
+		case "", "user", "model", "function":
+		default:
+			return fmt.Errorf("contents[%d].role must be user or model", index)
+		}
+		if len(content.Parts) == 0 {
+			return fmt.Errorf("contents[%d].parts is required", index)
+		}
+	}
+	for _, tool := range request.Tools {
+		if len(tool.GoogleSearch) > 0 || len(tool.GoogleSearchRetrieval) > 0 || len(tool.CodeExecution) > 0 {
+			return errors.New("only functionDeclarations tools are supported")
+		}
+	}
+	if config := request.GenerationConfig; config != nil && config.CandidateCount != nil && *config.CandidateCount > 1 {
+		return errors.New("candidateCount greater than 1 is not supported")
+	}
+	if request.CachedContent != "" {
+		return errors.New("cachedContent is not supported")
+	}
+	return nil
+}
+
+// GenerateContentRequestToChat 将 Gemini 请求转为内部使用的 OpenAI Chat 请求：
+// model 角色的 functionCall 转为 assistant tool_calls，functionResponse 转为 tool 消息，
+// 二者没有 ID 时生成 ID 并按函数名（缺省时按顺序）关联。
+func GenerateContentRequestToChat(model string, request GenerateContentRequest, stream bool) (openai.ChatCompletionRequest, error) {
+	messages := make([]openai.ChatMessage, 0, len(request.Contents)+1)
+	if request.SystemInstruction != nil {
+		var system []string
+		for _, part := range request.SystemInstruction.Parts {
+			if part.Text != "" {
+				system = append(system, part.Text)
+			}
+		}
+		if len(system) > 0 {
+			blob, _ := json.Marshal(strings.Join(system, "\n"))
+			messages = append(messages, openai.ChatMessage{Role: "system", Content: blob})
+		}
+	}
+
+	type pendingCall struct {
+		id   string
+		name string
+	}
+	var pending []pendingCall
+
+	for contentIndex, content := range request.Contents {
+		if content.Role == "model" {
+			message := openai.ChatMessage{Role: "assistant"}
+			var text strings.Builder
+			pending = pending[:0]
+			for partIndex, part := range content.Parts {
+				switch {
+				case part.FunctionCall != nil:
+					id := part.FunctionCall.ID
+					if id == "" {
+						id = fmt.Sprintf("call_%d_%d", contentIndex, partIndex)
+					}
+					arguments := strings.TrimSpace(string(part.FunctionCall.Args))
+					if arguments == "" || arguments == "null" {
+						arguments = "{}"
+					}
+					message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+						ID:       id,
+						Type:     "function",
+						Function: openai.ToolCallFunction{Name: part.FunctionCall.Name, Arguments: arguments},
+					})
+					pending = append(pending, pendingCall{id: id, name: part.FunctionCall.Name})
+				case part.Thought:
+					// 思考内容没有 Bedrock 签名，无法回传
+				default:
+					text.WriteString(part.Text)
+				}
+			}
+			message.Content, _ = json.Marshal(text.String())
+			messages = append(messages, message)
+			continue
+		}
+
+		var parts []map[string]any
+		for partIndex, part := range content.Parts {
+			switch {
+			case part.FunctionResponse != nil:
+				id := part.FunctionResponse.ID
+				if id == "" {
+					for pendingIndex, call := range pending {
+						if call.name == part.FunctionResponse.Name {
+							id = call.id
+							pending = append(pending[:pendingIndex], pending[pendingIndex+1:]...)
+							break
+						}
+					}
+				}
+				response := strings.TrimSpace(string(part.FunctionResponse.Response))
+				if response == "" {
+					response = "{}"
+				}
+				blob, _ := json.Marshal(response)
+				messages = append(messages, openai.ChatMessage{Role: "tool", ToolCallID: id, Content: blob})
+			case part.InlineData != nil:
+				parts = append(parts, inlineDataPart(part.InlineData, contentIndex, partIndex))
+			case part.FileData != nil:
+				return openai.ChatCompletionRequest{}, fmt.Errorf("contents[%d].parts[%d]: fileData references are not supported; send the file as inlineData", contentIndex, partIndex)
+			case part.FunctionCall != nil:
+				return openai.ChatCompletionRequest{}, fmt.Errorf("contents[%d].parts[%d]: functionCall is only allowed in model contents", contentIndex, partIndex)
+			case part.Text != "":
+				parts = append(parts, map[string]any{"type": "text", "text": part.Text})
+			}
+		}
+		if len(parts) > 0 {
+			blob, err := json.Marshal(parts)
+			if err != nil {
+				return openai.ChatCompletionRequest{}, err
+			}
+			messages = append(messages, openai.ChatMessage{Role: "user", Content: blob})
+		}
+	}
+
+	chatRequest := openai.ChatCompletionRequest{
+		Model:    strings.TrimSpace(model),
+		Messages: messages,
+		Stream:   stream,
+	}
+	tools, toolChoice, err := convertTools(request.Tools, request.ToolConfig)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	chatRequest.Tools = tools
+	chatRequest.ToolChoice = toolChoice
+	if err := applyGenerationConfig(&chatRequest, request.GenerationConfig); err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	return chatRequest, nil
+}
+
+// inlineDataPart 将 inlineData 转为 OpenAI 的 image_url（图片）或 file（文档）内容块。
+func inlineDataPart(blob *Blob, contentIndex, partIndex int) map[string]any {
+	mimeType := strings.ToLower(strings.TrimSpace(blob.MimeType))
+	dataURI := "data:" + mimeType + ";base64," + strings.TrimSpace(blob.Data)
+	if strings.HasPrefix(mimeType, "image/") {
+		return map[string]any{"type": "image_url", "image_url": map[string]any{"url": dataURI}}
+	}
+	return map[string]any{
+		"type": "file",
+		"file": map[string]any{
+			"filename":  fmt.Sprintf("document-%d-%d", contentIndex, partIndex),
+			"file_data": dataURI,
+		},
+	}
+}
+
+func convertTools(geminiTools []Tool, toolConfig *ToolConfig) ([]openai.Tool, json.RawMessage, error) {
+	var tools []openai.Tool
+	for _, tool := range geminiTools {
+		for _, declaration := range tool.FunctionDeclarations {
+			name := strings.TrimSpace(declaration.Name)
+			if name == "" {
+				return nil, nil, errors.New("functionDeclarations[].name is required")
+			}
+			parameters := declaration.ParametersJSONSchema
+			if len(parameters) == 0 && len(declaration.Parameters) > 0 {
+				converted, err := openAPISchemaToJSONSchema(declaration.Parameters)
+				if err != nil {
+					return nil, nil, fmt.Errorf("function %s parameters: %w", name, err)
+				}
+				parameters = converted
+			}
+			if len(parameters) == 0 {
+				parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tools = append(tools, openai.Tool{
+				Type: "function",
+				Function: &openai.ToolFunction{
+					Name:        name,
+					Description: declaration.Description,
+					Parameters:  parameters,
+				},
+			})
+		}
+	}
+
+	if toolConfig == nil || toolConfig.FunctionCallingConfig == nil || len(tools) == 0 {
+		return tools, nil, nil
+	}
+	config := toolConfig.FunctionCallingConfig
+	switch strings.ToUpper(strings.TrimSpace(config.Mode)) {
+	case "", "AUTO", "MODE_UNSPECIFIED", "VALIDATED":
+		return tools, nil, nil
+	case "NONE":
+		return tools, json.RawMessage(`"none"`), nil
+	case "ANY":
+		if len(config.AllowedFunctionNames) == 1 {
+			choice, err := json.Marshal(map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": config.AllowedFunctionNames[0]},
+			})
+			return tools, choice, err
+		}
+		return tools, json.RawMessage(`"required"`), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported functionCallingConfig.mode: %s", config.Mode)
+	}
+}
+
+// openAPISchemaToJSONSchema 将 Gemini 的 OpenAPI Schema（type 为 OBJECT / STRING 等大写枚举）转为 JSON Schema。
+func openAPISchemaToJSONSchema(raw json.RawMessage) (json.RawMessage, error) {
+	var schema any
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+	return json.Marshal(lowercaseSchemaTypes(schema))
+}
+
+func lowercaseSchemaTypes(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, item := range typed {
+			if key == "type" {
+				if text, ok := item.(string); ok {
+					typed[key] = strings.ToLower(text)
+					continue
+				}
+			}
+			typed[key] = lowercaseSchemaTypes(item)
+		}
+		return typed
+	case []any:
+		for index, item := range typed {
+			typed[index] = lowercaseSchemaTypes(item)
+		}
+		return typed
+	default:
+		return value
+	}
+}
+
+func applyGenerationConfig(chatRequest *openai.ChatCompletionRequest, config *GenerationConfig) error {
+	if config == nil {
+		return nil
+	}
+	chatRequest.Temperature = config.Temperature
+	chatRequest.TopP = config.TopP
+	chatRequest.TopK = config.TopK
+	chatRequest.Seed = config.Seed
+	if config.MaxOutputTokens != nil && *config.MaxOutputTokens > 0 {
+		chatRequest.MaxTokens = config.MaxOutputTokens
+	}
+	if len(config.StopSequences) > 0 {
+		blob, err := json.Marshal(config.StopSequences)
+		if err != nil {
+			return err
+		}
+		chatRequest.Stop = blob
+	}
+
+	schema := config.ResponseJSONSchema
+	if len(schema) == 0 && len(config.ResponseSchema) > 0 {
+		converted, err := openAPISchemaToJSONSchema(config.ResponseSchema)
+		if err != nil {
+			return fmt.Errorf("responseSchema: %w", err)
+		}
+		schema = converted
+	}
+	switch {
+	case len(schema) > 0:
+		format, err := json.Marshal(map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "schema": schema},
+		})
+		if err != nil {
+			return err
+		}
+		chatRequest.ResponseFormat = format
+	case strings.EqualFold(strings.TrimSpace(config.ResponseMimeType), "application/json"):
+		chatRequest.ResponseFormat = json.RawMessage(`{"type":"json_object"}`)
+	}
+
+	if config.ThinkingConfig != nil && config.ThinkingConfig.ThinkingBudget != nil {
+		chatRequest.ReasoningEffort = reasoningEffortFromBudget(*config.ThinkingConfig.ThinkingBudget)
+	}
+	return nil
+}
+
+// reasoningEffortFromBudget 将 thinkingBudget 映射到最接近的 reasoning_effort 档位。
+func reasoningEffortFromBudget(budget int) string {
+	switch {
+	case budget == 0:
+		return "none"
+	case budget < 0:
+		return "medium"
+	case budget <= 1024:
+		return "minimal"
+	case budget <= 2048:
+		return "low"
+	case budget <= 8192:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// IncludeThoughts 返回响应中是否需要带上 thought 部分。
+func IncludeThoughts(request GenerateContentRequest) bool {
+	return request.GenerationConfig != nil &&
+		request.GenerationConfig.ThinkingConfig != nil &&
+		request.GenerationConfig.ThinkingConfig.IncludeThoughts
+}
+
+// BuildFunctionCallParts 将模型返回的 tool_calls 转为 functionCall 部分（args 为 JSON 对象）。
+func BuildFunctionCallParts(toolCalls []openai.ToolCall) []Part {
+	parts := make([]Part, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		args := json.RawMessage(`{}`)
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &parsed); err == nil && parsed != nil {
+			args = json.RawMessage(toolCall.Function.Arguments)
+		}
+		parts = append(parts, Part{FunctionCall: &FunctionCall{
+			ID:   toolCall.ID,
+			Name: toolCall.Function.Name,
+			Args: args,
+		}})
+	}
+	return parts
+}
+
+// FinishReason 将 OpenAI finish_reason 转为 Gemini 的 finishReason。
+func FinishReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// ErrorStatus 返回 HTTP 状态码对应的 Google API 错误状态名。
+func ErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		if status >= 500 {
+			return "INTERNAL"
+		}
+		return "UNKNOWN"
+	}
+}
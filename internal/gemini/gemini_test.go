@@ -0,0 +1,92 @@
+package gemini
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseGenerateContentRequestAcceptsSnakeCase(t *testing.T) {
+	request, err := ParseGenerateContentRequest([]byte(`{
+		"system_instruction": {"parts": [{"text": "be brief"}]},
+		"contents": [{"role": "user", "parts": [
+			{"text": "what is this?"},
+			{"inline_data": {"mime_type": "image/png", "data": "aGVsbG8="}}
+		]}],
+		"tools": [{"function_declarations": [{"name": "lookup", "parameters": {"type": "OBJECT", "properties": {"user_id": {"type": "STRING"}}}}]}],
+		"generation_config": {"max_output_tokens": 64, "response_mime_type": "application/json"}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseGenerateContentRequest returned error: %v", err)
+	}
+	if err := ValidateGenerateContentRequest(request); err != nil {
+		t.Fatalf("ValidateGenerateContentRequest returned error: %v", err)
+	}
+
+	chatRequest, err := GenerateContentRequestToChat("anthropic.model", request, false)
+	if err != nil {
+		t.Fatalf("GenerateContentRequestToChat returned error: %v", err)
+	}
+	if len(chatRequest.Messages) != 2 || chatRequest.Messages[0].Role != "system" {
+		t.Fatalf("unexpected messages: %+v", chatRequest.Messages)
+	}
+	if !strings.Contains(string(chatRequest.Messages[1].Content), "data:image/png;base64,aGVsbG8=") {
+		t.Fatalf("expected inline image part: %s", chatRequest.Messages[1].Content)
+	}
+	if chatRequest.MaxTokens == nil || *chatRequest.MaxTokens != 64 || string(chatRequest.ResponseFormat) != `{"type":"json_object"}` {
+		t.Fatalf("unexpected generation config mapping: %+v", chatRequest)
+	}
+	// 参数 Schema 中的字段名属于用户数据，不做 snake_case 转换；type 转为小写
+	if len(chatRequest.Tools) != 1 || string(chatRequest.Tools[0].Function.Parameters) != `{"properties":{"user_id":{"type":"string"}},"type":"object"}` {
+		t.Fatalf("unexpected tools: %s", chatRequest.Tools[0].Function.Parameters)
+	}
+}
+
+func TestGenerateContentRequestToChatLinksFunctionResponses(t *testing.T) {
+	request := GenerateContentRequest{
+		Contents: []Content{
+			{Role: "user", Parts: []Part{{Text: "weather in Paris?"}}},
+			{Role: "model", Parts: []Part{{FunctionCall: &FunctionCall{Name: "get_weather", Args: json.RawMessage(`{"city":"Paris"}`)}}}},
+			{Role: "user", Parts: []Part{
+				{FunctionResponse: &FunctionResponse{Name: "get_weather", Response: json.RawMessage(`{"temp":21}`)}},
+				{Text: "and tomorrow?"},
+			}},
+		},
+		Tools: []Tool{{FunctionDeclarations: []FunctionDeclaration{{Name: "get_weather"}, {Name: "get_time"}}}},
+		ToolConfig: &ToolConfig{FunctionCallingConfig: &FunctionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{"get_weather"},
+		}},
+	}
+
+	chatRequest, err := GenerateContentRequestToChat("anthropic.model", request, true)
+	if err != nil {
+		t.Fatalf("GenerateContentRequestToChat returned error: %v", err)
+	}
+	if len(chatRequest.Messages) != 4 {
+		t.Fatalf("unexpected messages: %+v", chatRequest.Messages)
+	}
+	assistant, tool, user := chatRequest.Messages[1], chatRequest.Messages[2], chatRequest.Messages[3]
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Fatalf("unexpected assistant message: %+v", assistant)
+	}
+	if tool.Role != "tool" || tool.ToolCallID != assistant.ToolCalls[0].ID || string(tool.Content) != `"{\"temp\":21}"` {
+		t.Fatalf("unexpected tool message: %+v", tool)
+	}
+	if user.Role != "user" {
+		t.Fatalf("expected trailing user text, got %+v", user)
+	}
+	if string(chatRequest.ToolChoice) != `{"function":{"name":"get_weather"},"type":"function"}` {
+		t.Fatalf("unexpected tool choice: %s", chatRequest.ToolChoice)
+	}
+}
+
+func TestValidateGenerateContentRequestRejectsUnsupportedTools(t *testing.T) {
+	request := GenerateContentRequest{
+		Contents: []Content{{Role: "user", Parts: []Part{{Text: "hi"}}}},
+		Tools:    []Tool{{GoogleSearch: json.RawMessage(`{}`)}},
+	}
+	if err := ValidateGenerateContentRequest(request); err == nil {
+		t.Fatalf("expected googleSearch tool to be rejected")
+	}
+}
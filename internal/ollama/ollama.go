@@ -0,0 +1,394 @@
+// Package ollama 实现 Ollama API（/api/chat、/api/generate、/api/tags）的请求与响应格式，
+// 请求统一转换为内部使用的 OpenAI Chat 请求，复用 bedrockproxy.Service。
+package ollama
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"aws-cursor-router/internal/openai"
+)
+
+type ChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []Message       `json:"messages"`
+	Tools    []openai.Tool   `json:"tools,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  *Options        `json:"options,omitempty"`
+	Stream   *bool           `json:"stream,omitempty"`
+	// true / false 或 low / medium / high
+	Think     json.RawMessage `json:"think,omitempty"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+type GenerateRequest struct {
+	Model     string          `json:"model"`
+	Prompt    string          `json:"prompt"`
+	Suffix    string          `json:"suffix,omitempty"`
+	System    string          `json:"system,omitempty"`
+	Images    []string        `json:"images,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"`
+	Options   *Options        `json:"options,omitempty"`
+	Stream    *bool           `json:"stream,omitempty"`
+	Raw       bool            `json:"raw,omitempty"`
+	Think     json.RawMessage `json:"think,omitempty"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+	// 本地模型的 KV 上下文，Bedrock 无对应概念，忽略
+	Context []int `json:"context,omitempty"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// base64 编码的图片（不带 data URI 前缀）
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// role=tool 时为对应的工具名
+	ToolName string `json:"tool_name,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+}
+
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Index     *int            `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Options 只读取 Bedrock 能对应的采样参数；num_ctx 等本地运行参数忽略。
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+}
+
+type ChatResponse struct {
+	Model      string   `json:"model"`
+	CreatedAt  string   `json:"created_at"`
+	Message    *Message `json:"message,omitempty"`
+	Done       bool     `json:"done"`
+	DoneReason string   `json:"done_reason,omitempty"`
+	Metrics
+}
+
+type GenerateResponse struct {
+	Model      string `json:"model"`
+	CreatedAt  string `json:"created_at"`
+	Response   string `json:"response"`
+	Thinking   string `json:"thinking,omitempty"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+	Metrics
+}
+
+// Metrics 只在 done=true 的最后一条消息中返回；时长单位为纳秒。
+type Metrics struct {
+	TotalDuration   int64 `json:"total_duration,omitempty"`
+	PromptEvalCount int   `json:"prompt_eval_count,omitempty"`
+	EvalCount       int   `json:"eval_count,omitempty"`
+}
+
+type TagsResponse struct {
+	Models []ModelTag `json:"models"`
+}
+
+type ModelTag struct {
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	ModifiedAt string       `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+type ModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// StreamEnabled 返回是否流式输出；与 Ollama 一致，未传 stream 时默认流式。
+func StreamEnabled(stream *bool) bool {
+	return stream == nil || *stream
+}
+
+func ValidateChatRequest(request ChatRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	if len(request.Messages) == 0 {
+		return errors.New("messages cannot be empty")
+	}
+	for index, message := range request.Messages {
+		switch strings.ToLower(strings.TrimSpace(message.Role)) {
+		case "system", "user", "assistant", "tool":
+		default:
+			return fmt.Errorf("messages.%d.role must be system, user, assistant or tool", index)
+		}
+	}
+	return nil
+}
+
+func ValidateGenerateRequest(request GenerateRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	if strings.TrimSpace(request.Prompt) == "" && len(request.Images) == 0 {
+		return errors.New("prompt is required")
+	}
+	if request.Suffix != "" {
+		return errors.New("suffix (fill-in-the-middle) is not supported")
+	}
+	return nil
+}
+
+// ChatRequestToChat 将 Ollama /api/chat 请求转为内部使用的 OpenAI Chat 请求。
+// Ollama 的工具调用没有 ID，这里为 assistant 的 tool_calls 生成 ID，并按 tool_name（缺省时按顺序）关联后续 tool 消息。
+func ChatRequestToChat(request ChatRequest) (openai.ChatCompletionRequest, error) {
+	messages := make([]openai.ChatMessage, 0, len(request.Messages))
+	type pendingCall struct {
+		id   string
+		name string
+	}
+	var pending []pendingCall
+
+	for index, message := range request.Messages {
+		role := strings.ToLower(strings.TrimSpace(message.Role))
+		content, err := buildContent(message.Content, message.Images)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, fmt.Errorf("messages.%d: %w", index, err)
+		}
+		chatMessage := openai.ChatMessage{Role: role, Content: content}
+
+		switch role {
+		case "assistant":
+			pending = pending[:0]
+			for callIndex, call := range message.ToolCalls {
+				id := fmt.Sprintf("call_%d_%d", index, callIndex)
+				chatMessage.ToolCalls = append(chatMessage.ToolCalls, openai.ToolCall{
+					ID:   id,
+					Type: "function",
+					Function: openai.ToolCallFunction{
+						Name:      call.Function.Name,
+						Arguments: toolArgumentsString(call.Function.Arguments),
+					},
+				})
+				pending = append(pending, pendingCall{id: id, name: call.Function.Name})
+			}
+		case "tool":
+			match := -1
+			for pendingIndex, call := range pending {
+				if message.ToolName == "" || call.name == message.ToolName {
+					match = pendingIndex
+					break
+				}
+			}
+			if match >= 0 {
+				chatMessage.ToolCallID = pending[match].id
+				pending = append(pending[:match], pending[match+1:]...)
+			}
+		}
+		messages = append(messages, chatMessage)
+	}
+
+	chatRequest := openai.ChatCompletionRequest{
+		Model:    strings.TrimSpace(request.Model),
+		Messages: messages,
+		Stream:   StreamEnabled(request.Stream),
+		Tools:    request.Tools,
+	}
+	if err := applyCommonOptions(&chatRequest, request.Format, request.Options, request.Think); err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	return chatRequest, nil
+}
+
+// GenerateRequestToChat 将 Ollama /api/generate 请求转为单轮 Chat 请求（system + user）。
+func GenerateRequestToChat(request GenerateRequest) (openai.ChatCompletionRequest, error) {
+	messages := make([]openai.ChatMessage, 0, 2)
+	if system := strings.TrimSpace(request.System); system != "" {
+		blob, _ := json.Marshal(system)
+		messages = append(messages, openai.ChatMessage{Role: "system", Content: blob})
+	}
+	content, err := buildContent(request.Prompt, request.Images)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	messages = append(messages, openai.ChatMessage{Role: "user", Content: content})
+
+	chatRequest := openai.ChatCompletionRequest{
+		Model:    strings.TrimSpace(request.Model),
+		Messages: messages,
+		Stream:   StreamEnabled(request.Stream),
+	}
+	if err := applyCommonOptions(&chatRequest, request.Format, request.Options, request.Think); err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	return chatRequest, nil
+}
+
+func applyCommonOptions(chatRequest *openai.ChatCompletionRequest, format json.RawMessage, options *Options, think json.RawMessage) error {
+	responseFormat, err := responseFormatFromFormat(format)
+	if err != nil {
+		return err
+	}
+	chatRequest.ResponseFormat = responseFormat
+
+	effort, err := reasoningEffortFromThink(think)
+	if err != nil {
+		return err
+	}
+	chatRequest.ReasoningEffort = effort
+
+	if options == nil {
+		return nil
+	}
+	chatRequest.Temperature = options.Temperature
+	chatRequest.TopP = options.TopP
+	chatRequest.TopK = options.TopK
+	chatRequest.Seed = options.Seed
+	if options.NumPredict != nil && *options.NumPredict > 0 {
+		chatRequest.MaxTokens = options.NumPredict
+	}
+	if len(options.Stop) > 0 {
+		blob, err := json.Marshal(options.Stop)
+		if err != nil {
+			return err
+		}
+		chatRequest.Stop = blob
+	}
+	return nil
+}
+
+// buildContent 把文本与 base64 图片组合成 OpenAI content：无图片时为字符串，有图片时为 text + image_url 数组。
+func buildContent(text string, images []string) (json.RawMessage, error) {
+	if len(images) == 0 {
+		return json.Marshal(text)
+	}
+	parts := make([]map[string]any, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, map[string]any{"type": "text", "text": text})
+	}
+	for index, image := range images {
+		image = strings.TrimSpace(image)
+		if strings.HasPrefix(image, "data:") {
+			parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]any{"url": image}})
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(image)
+		if err != nil {
+			return nil, fmt.Errorf("images.%d is not valid base64", index)
+		}
+		mediaType := http.DetectContentType(decoded)
+		if !strings.HasPrefix(mediaType, "image/") {
+			return nil, fmt.Errorf("images.%d is not a supported image", index)
+		}
+		parts = append(parts, map[string]any{
+			"type":      "image_url",
+			"image_url": map[string]any{"url": "data:" + mediaType + ";base64," + image},
+		})
+	}
+	return json.Marshal(parts)
+}
+
+// responseFormatFromFormat 将 format（"json" 或 JSON Schema 对象）转为 response_format。
+func responseFormatFromFormat(format json.RawMessage) (json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(format))
+	if trimmed == "" || trimmed == "null" || trimmed == `""` {
+		return nil, nil
+	}
+	if trimmed == `"json"` {
+		return json.RawMessage(`{"type":"json_object"}`), nil
+	}
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, errors.New(`format must be "json" or a JSON schema object`)
+	}
+	return json.Marshal(map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "response",
+			"schema": format,
+		},
+	})
+}
+
+// reasoningEffortFromThink 将 think 映射为 reasoning_effort：true 为 medium，false 为 none，字符串原样使用。
+func reasoningEffortFromThink(think json.RawMessage) (string, error) {
+	trimmed := strings.TrimSpace(string(think))
+	switch trimmed {
+	case "", "null":
+		return "", nil
+	case "true":
+		return "medium", nil
+	case "false":
+		return "none", nil
+	}
+	var effort string
+	if err := json.Unmarshal(think, &effort); err != nil {
+		return "", errors.New("think must be a boolean or one of low, medium, high")
+	}
+	if err := openai.ValidateReasoningEffort(effort); err != nil {
+		return "", errors.New("think must be a boolean or one of low, medium, high")
+	}
+	return strings.ToLower(strings.TrimSpace(effort)), nil
+}
+
+// toolArgumentsString 将 Ollama 的对象形式参数转为 OpenAI 的 JSON 字符串形式。
+func toolArgumentsString(arguments json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(arguments))
+	if trimmed == "" || trimmed == "null" {
+		return "{}"
+	}
+	// 部分客户端按 OpenAI 习惯传字符串
+	var text string
+	if err := json.Unmarshal(arguments, &text); err == nil {
+		return text
+	}
+	return trimmed
+}
+
+// BuildToolCalls 将模型返回的 tool_calls 转为 Ollama 格式（arguments 为 JSON 对象）。
+func BuildToolCalls(toolCalls []openai.ToolCall) []ToolCall {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+	calls := make([]ToolCall, 0, len(toolCalls))
+	for index, toolCall := range toolCalls {
+		arguments := json.RawMessage(`{}`)
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &parsed); err == nil && parsed != nil {
+			arguments = json.RawMessage(toolCall.Function.Arguments)
+		}
+		callIndex := index
+		calls = append(calls, ToolCall{Function: ToolCallFunction{
+			Index:     &callIndex,
+			Name:      toolCall.Function.Name,
+			Arguments: arguments,
+		}})
+	}
+	return calls
+}
+
+// DoneReason 将 OpenAI finish_reason 转为 Ollama done_reason（工具调用在 Ollama 中同样以 stop 结束）。
+func DoneReason(finishReason string) string {
+	if finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
@@ -0,0 +1,104 @@
+package ollama
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 1x1 PNG
+const testPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg=="
+
+func TestChatRequestToChatLinksToolResults(t *testing.T) {
+	var request ChatRequest
+	if err := json.Unmarshal([]byte(`{
+		"model": "anthropic.model",
+		"messages": [
+			{"role": "user", "content": "weather and time?"},
+			{"role": "assistant", "content": "", "tool_calls": [
+				{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}},
+				{"function": {"name": "get_time", "arguments": {}}}
+			]},
+			{"role": "tool", "tool_name": "get_time", "content": "12:00"},
+			{"role": "tool", "content": "sunny"}
+		],
+		"options": {"temperature": 0.2, "num_predict": 128, "stop": ["END"]}
+	}`), &request); err != nil {
+		t.Fatalf("unmarshal request failed: %v", err)
+	}
+	if err := ValidateChatRequest(request); err != nil {
+		t.Fatalf("ValidateChatRequest returned error: %v", err)
+	}
+
+	chatRequest, err := ChatRequestToChat(request)
+	if err != nil {
+		t.Fatalf("ChatRequestToChat returned error: %v", err)
+	}
+	if !chatRequest.Stream {
+		t.Fatalf("expected stream to default to true")
+	}
+	if chatRequest.MaxTokens == nil || *chatRequest.MaxTokens != 128 || string(chatRequest.Stop) != `["END"]` {
+		t.Fatalf("unexpected options mapping: %+v", chatRequest)
+	}
+	assistant := chatRequest.Messages[1]
+	if len(assistant.ToolCalls) != 2 || assistant.ToolCalls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Fatalf("unexpected assistant tool calls: %+v", assistant.ToolCalls)
+	}
+	if chatRequest.Messages[2].ToolCallID != assistant.ToolCalls[1].ID {
+		t.Fatalf("expected get_time result to link by tool_name, got %q", chatRequest.Messages[2].ToolCallID)
+	}
+	if chatRequest.Messages[3].ToolCallID != assistant.ToolCalls[0].ID {
+		t.Fatalf("expected unnamed result to link to the remaining call, got %q", chatRequest.Messages[3].ToolCallID)
+	}
+}
+
+func TestGenerateRequestToChat(t *testing.T) {
+	stream := false
+	chatRequest, err := GenerateRequestToChat(GenerateRequest{
+		Model:  "anthropic.model",
+		Prompt: "describe",
+		System: "be brief",
+		Images: []string{testPNG},
+		Format: json.RawMessage(`"json"`),
+		Think:  json.RawMessage(`true`),
+		Stream: &stream,
+	})
+	if err != nil {
+		t.Fatalf("GenerateRequestToChat returned error: %v", err)
+	}
+	if chatRequest.Stream || len(chatRequest.Messages) != 2 || chatRequest.Messages[0].Role != "system" {
+		t.Fatalf("unexpected chat request: %+v", chatRequest)
+	}
+	if !strings.Contains(string(chatRequest.Messages[1].Content), "data:image/png;base64,") {
+		t.Fatalf("expected image part with detected media type: %s", chatRequest.Messages[1].Content)
+	}
+	if string(chatRequest.ResponseFormat) != `{"type":"json_object"}` || chatRequest.ReasoningEffort != "medium" {
+		t.Fatalf("unexpected format / think mapping: %s %q", chatRequest.ResponseFormat, chatRequest.ReasoningEffort)
+	}
+
+	if _, err := GenerateRequestToChat(GenerateRequest{Model: "m", Prompt: "x", Images: []string{"bm90IGFuIGltYWdl"}}); err == nil {
+		t.Fatalf("expected non-image data to be rejected")
+	}
+	if err := ValidateGenerateRequest(GenerateRequest{Model: "m", Prompt: "x", Suffix: "y"}); err == nil {
+		t.Fatalf("expected suffix to be rejected")
+	}
+}
+
+func TestResponseFormatFromSchema(t *testing.T) {
+	raw, err := responseFormatFromFormat(json.RawMessage(`{"type":"object","properties":{"a":{"type":"string"}}}`))
+	if err != nil {
+		t.Fatalf("responseFormatFromFormat returned error: %v", err)
+	}
+	var format struct {
+		Type       string `json:"type"`
+		JSONSchema struct {
+			Schema map[string]any `json:"schema"`
+		} `json:"json_schema"`
+	}
+	if err := json.Unmarshal(raw, &format); err != nil || format.Type != "json_schema" || format.JSONSchema.Schema["type"] != "object" {
+		t.Fatalf("unexpected response_format: %s", raw)
+	}
+	if _, err := responseFormatFromFormat(json.RawMessage(`"yaml"`)); err == nil {
+		t.Fatalf("expected unsupported format to be rejected")
+	}
+}
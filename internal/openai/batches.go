@@ -0,0 +1,158 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxBatchRequests 是单个 batch 输入文件允许的最大请求行数（与 OpenAI 的 50000 一致）。
+const MaxBatchRequests = 50000
+
+// BatchEndpointChatCompletions 是目前 /v1/batches 支持的唯一 endpoint。
+const BatchEndpointChatCompletions = "/v1/chat/completions"
+
+type FileObject struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type FileList struct {
+	Object string       `json:"object"`
+	Data   []FileObject `json:"data"`
+}
+
+type BatchCreateRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type BatchObject struct {
+	ID               string            `json:"id"`
+	Object           string            `json:"object"`
+	Endpoint         string            `json:"endpoint"`
+	Errors           any               `json:"errors"`
+	InputFileID      string            `json:"input_file_id"`
+	CompletionWindow string            `json:"completion_window"`
+	Status           string            `json:"status"`
+	OutputFileID     *string           `json:"output_file_id"`
+	ErrorFileID      *string           `json:"error_file_id"`
+	CreatedAt        int64             `json:"created_at"`
+	InProgressAt     *int64            `json:"in_progress_at"`
+	ExpiresAt        *int64            `json:"expires_at"`
+	FinalizingAt     *int64            `json:"finalizing_at"`
+	CompletedAt      *int64            `json:"completed_at"`
+	FailedAt         *int64            `json:"failed_at"`
+	ExpiredAt        *int64            `json:"expired_at"`
+	CancellingAt     *int64            `json:"cancelling_at"`
+	CancelledAt      *int64            `json:"cancelled_at"`
+	RequestCounts    BatchRequestCount `json:"request_counts"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+type BatchRequestCount struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type BatchList struct {
+	Object  string        `json:"object"`
+	Data    []BatchObject `json:"data"`
+	FirstID *string       `json:"first_id"`
+	LastID  *string       `json:"last_id"`
+	HasMore bool          `json:"has_more"`
+}
+
+// BatchInputLine 是 batch 输入文件（JSONL）中的一行。
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// BatchOutputLine 是输出文件 / 错误文件中的一行：请求得到 HTTP 响应时 response 非空（含 4xx / 5xx），
+// 未能执行（如 batch 过期）时 error 非空。
+type BatchOutputLine struct {
+	ID       string               `json:"id"`
+	CustomID string               `json:"custom_id"`
+	Response *BatchOutputResponse `json:"response"`
+	Error    *OpenAIErrorPayload  `json:"error"`
+}
+
+type BatchOutputResponse struct {
+	StatusCode int    `json:"status_code"`
+	RequestID  string `json:"request_id"`
+	Body       any    `json:"body"`
+}
+
+// ValidateBatchCreateRequest 校验 POST /v1/batches 的参数；completion_window 目前只有 24h。
+func ValidateBatchCreateRequest(request BatchCreateRequest) error {
+	if strings.TrimSpace(request.InputFileID) == "" {
+		return errors.New("input_file_id is required")
+	}
+	if request.Endpoint != BatchEndpointChatCompletions {
+		return fmt.Errorf("unsupported endpoint: %q (only %s is supported)", request.Endpoint, BatchEndpointChatCompletions)
+	}
+	if request.CompletionWindow != "24h" {
+		return fmt.Errorf("unsupported completion_window: %q (only 24h is supported)", request.CompletionWindow)
+	}
+	if len(request.Metadata) > 16 {
+		return errors.New("metadata must not have more than 16 keys")
+	}
+	return nil
+}
+
+// ParseBatchInput 解析 batch 输入文件：每个非空行是一个请求，custom_id 必须唯一，
+// method 必须为 POST，url 必须与 batch 的 endpoint 一致，body 必须是 JSON 对象。
+// 出错时返回带行号（从 1 开始）的错误。
+func ParseBatchInput(content []byte, endpoint string) ([]BatchInputLine, error) {
+	lines := make([]BatchInputLine, 0)
+	seen := make(map[string]struct{})
+	for index, raw := range bytes.Split(content, []byte("\n")) {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+		lineNumber := index + 1
+
+		var line BatchInputLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return nil, fmt.Errorf("line %d: invalid json: %w", lineNumber, err)
+		}
+		line.CustomID = strings.TrimSpace(line.CustomID)
+		if line.CustomID == "" {
+			return nil, fmt.Errorf("line %d: custom_id is required", lineNumber)
+		}
+		if _, ok := seen[line.CustomID]; ok {
+			return nil, fmt.Errorf("line %d: duplicate custom_id: %s", lineNumber, line.CustomID)
+		}
+		seen[line.CustomID] = struct{}{}
+		if !strings.EqualFold(strings.TrimSpace(line.Method), "POST") {
+			return nil, fmt.Errorf("line %d: method must be POST", lineNumber)
+		}
+		if strings.TrimSpace(line.URL) != endpoint {
+			return nil, fmt.Errorf("line %d: url %q does not match batch endpoint %s", lineNumber, line.URL, endpoint)
+		}
+		if body := bytes.TrimSpace(line.Body); len(body) == 0 || body[0] != '{' {
+			return nil, fmt.Errorf("line %d: body must be a json object", lineNumber)
+		}
+		lines = append(lines, line)
+		if len(lines) > MaxBatchRequests {
+			return nil, fmt.Errorf("batch input must not have more than %d requests", MaxBatchRequests)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("batch input file has no requests")
+	}
+	return lines, nil
+}
@@ -0,0 +1,57 @@
+package openai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	content := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m","messages":[]}}
+
+{"custom_id":"b","method":"post","url":"/v1/chat/completions","body":{"model":"m","messages":[]}}
+`
+	lines, err := ParseBatchInput([]byte(content), BatchEndpointChatCompletions)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(lines) != 2 || lines[0].CustomID != "a" || lines[1].CustomID != "b" || !strings.Contains(string(lines[1].Body), `"model":"m"`) {
+		t.Fatalf("unexpected lines: %+v", lines)
+	}
+
+	cases := map[string]string{
+		"duplicate": `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}` + "\n" +
+			`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}`,
+		"url":      `{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{}}`,
+		"method":   `{"custom_id":"a","method":"GET","url":"/v1/chat/completions","body":{}}`,
+		"body":     `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":"x"}`,
+		"custom":   `{"method":"POST","url":"/v1/chat/completions","body":{}}`,
+		"json":     `not json`,
+		"no lines": "\n\n",
+	}
+	for name, input := range cases {
+		if _, err := ParseBatchInput([]byte(input), BatchEndpointChatCompletions); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	_, err = ParseBatchInput([]byte("\n"+`{"custom_id":"a","method":"GET","url":"/v1/chat/completions","body":{}}`), BatchEndpointChatCompletions)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("expected error with line number, got %v", err)
+	}
+}
+
+func TestValidateBatchCreateRequest(t *testing.T) {
+	valid := BatchCreateRequest{InputFileID: "file-1", Endpoint: "/v1/chat/completions", CompletionWindow: "24h"}
+	if err := ValidateBatchCreateRequest(valid); err != nil {
+		t.Fatalf("expected valid request, got %v", err)
+	}
+	invalid := valid
+	invalid.Endpoint = "/v1/embeddings"
+	if err := ValidateBatchCreateRequest(invalid); err == nil {
+		t.Fatalf("expected unsupported endpoint error")
+	}
+	invalid = valid
+	invalid.CompletionWindow = "1h"
+	if err := ValidateBatchCreateRequest(invalid); err == nil {
+		t.Fatalf("expected unsupported completion_window error")
+	}
+}
@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MaxEmbeddingInputs 是单次 /v1/embeddings 请求允许的最大输入条数（与 OpenAI 的 2048 一致）。
+const MaxEmbeddingInputs = 2048
+
+type EmbeddingsRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+	// Cohere 专用：search_document / search_query / classification / clustering，未设置时为 search_document
+	InputType string `json:"input_type,omitempty"`
+}
+
+type EmbeddingsResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingsUsage `json:"usage"`
+}
+
+type EmbeddingData struct {
+	Object string `json:"object"`
+	Index  int    `json:"index"`
+	// encoding_format=float 时为 []float64，base64 时为 little-endian float32 的 base64 字符串
+	Embedding any `json:"embedding"`
+}
+
+type EmbeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ParseEmbeddingInput 解析 input（字符串或字符串数组）。
+// OpenAI 还允许传 token 数组，Bedrock 嵌入模型只接受文本，这里直接拒绝。
+func ParseEmbeddingInput(raw json.RawMessage) ([]string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, errors.New("input is required")
+	}
+
+	if strings.HasPrefix(trimmed, "\"") {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		if value == "" {
+			return nil, errors.New("input must not be empty")
+		}
+		return []string{value}, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, errors.New("input must be a string or an array of strings (token arrays are not supported)")
+	}
+	if len(values) == 0 {
+		return nil, errors.New("input must not be empty")
+	}
+	if len(values) > MaxEmbeddingInputs {
+		return nil, fmt.Errorf("input must contain at most %d items", MaxEmbeddingInputs)
+	}
+	for index, value := range values {
+		if value == "" {
+			return nil, fmt.Errorf("input[%d] must not be empty", index)
+		}
+	}
+	return values, nil
+}
+
+func ValidateEmbeddingsRequest(request EmbeddingsRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	if _, err := ParseEmbeddingInput(request.Input); err != nil {
+		return err
+	}
+	switch request.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		return errors.New("encoding_format must be float or base64")
+	}
+	if request.Dimensions != nil && *request.Dimensions < 1 {
+		return errors.New("dimensions must be a positive integer")
+	}
+	return nil
+}
+
+// EncodeEmbedding 按 encoding_format 输出向量；base64 与 OpenAI 一致，为 little-endian float32 字节序列。
+func EncodeEmbedding(values []float64, encodingFormat string) any {
+	if encodingFormat != "base64" {
+		return values
+	}
+	buf := make([]byte, 4*len(values))
+	for index, value := range values {
+		binary.LittleEndian.PutUint32(buf[index*4:], math.Float32bits(float32(value)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxImagesPerRequest 是单次 /v1/images/generations 请求允许的最大图片数（与 OpenAI 的 n <= 10 一致）。
+const MaxImagesPerRequest = 10
+
+type ImagesRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	N      *int   `json:"n,omitempty"`
+	// 形如 1024x1024；为空或 auto 时使用模型默认尺寸
+	Size string `json:"size,omitempty"`
+	// 只支持 b64_json：网关不托管图片，无法返回 url
+	ResponseFormat string `json:"response_format,omitempty"`
+	// standard / hd（dall-e-3）或 low / medium / high（gpt-image-1），hd / high 映射为 Titan 的 premium
+	Quality string `json:"quality,omitempty"`
+	Style   string `json:"style,omitempty"`
+	User    string `json:"user,omitempty"`
+	// Bedrock 扩展：反向提示词与随机种子
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Seed           *int64 `json:"seed,omitempty"`
+}
+
+type ImagesResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+type ImageData struct {
+	B64JSON       string `json:"b64_json"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// ValidateImagesRequest 校验 /v1/images/generations 的参数；尺寸是否被模型支持由 Bedrock 校验。
+func ValidateImagesRequest(request ImagesRequest) error {
+	if strings.TrimSpace(request.Model) == "" {
+		return errors.New("model is required")
+	}
+	if strings.TrimSpace(request.Prompt) == "" {
+		return errors.New("prompt is required")
+	}
+	if request.N != nil && (*request.N < 1 || *request.N > MaxImagesPerRequest) {
+		return fmt.Errorf("n must be between 1 and %d", MaxImagesPerRequest)
+	}
+	switch request.ResponseFormat {
+	case "", "b64_json":
+	case "url":
+		return errors.New("response_format url is not supported; use b64_json")
+	default:
+		return errors.New("response_format must be b64_json")
+	}
+	if _, _, err := ParseImageSize(request.Size); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ImageCount 返回请求的图片数量，未设置 n 时为 1。
+func (r ImagesRequest) ImageCount() int {
+	if r.N == nil {
+		return 1
+	}
+	return *r.N
+}
+
+// ParseImageSize 解析 WIDTHxHEIGHT 形式的 size；为空或 auto 时返回 0, 0。
+func ParseImageSize(size string) (int, int, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" || size == "auto" {
+		return 0, 0, nil
+	}
+	rawWidth, rawHeight, ok := strings.Cut(size, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size: %q (expected WIDTHxHEIGHT)", size)
+	}
+	width, err := strconv.Atoi(rawWidth)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid size: %q (expected WIDTHxHEIGHT)", size)
+	}
+	height, err := strconv.Atoi(rawHeight)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size: %q (expected WIDTHxHEIGHT)", size)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultModerationModel 是未指定 model 时使用的模型名；管理员为该名称（或 API key）绑定 Guardrail。
+const DefaultModerationModel = "omni-moderation-latest"
+
+// MaxModerationInputs 限制单次 /v1/moderations 的输入条数，每条对应一次 ApplyGuardrail 调用。
+const MaxModerationInputs = 32
+
+// ModerationCategories 是 OpenAI omni-moderation 返回的全部类别，响应中每个类别都会出现（未命中时为 false / 0）。
+var ModerationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/intent",
+	"self-harm/instructions",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+type ModerationRequest struct {
+	Model string          `json:"model,omitempty"`
+	Input json.RawMessage `json:"input"`
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// NewModerationResult 返回所有 OpenAI 类别均未命中的结果。
+func NewModerationResult() ModerationResult {
+	result := ModerationResult{
+		Categories:     make(map[string]bool, len(ModerationCategories)),
+		CategoryScores: make(map[string]float64, len(ModerationCategories)),
+	}
+	for _, category := range ModerationCategories {
+		result.Categories[category] = false
+		result.CategoryScores[category] = 0
+	}
+	return result
+}
+
+// ParseModerationInput 解析 input：字符串、字符串数组（每条单独给出一个结果），
+// 或 omni-moderation 的多模态数组（[{type:text,text:...}]，整体作为一条输入）。图片输入暂不支持。
+func ParseModerationInput(raw json.RawMessage) ([]string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, errors.New("input is required")
+	}
+
+	if strings.HasPrefix(trimmed, "\"") {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		if strings.TrimSpace(value) == "" {
+			return nil, errors.New("input must not be empty")
+		}
+		return []string{value}, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, errors.New("input must be a string or an array")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("input must not be empty")
+	}
+
+	if first := strings.TrimSpace(string(items[0])); strings.HasPrefix(first, "{") {
+		var parts []contentPart
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		texts := make([]string, 0, len(parts))
+		for index, part := range parts {
+			if part.Type != "text" {
+				return nil, fmt.Errorf("input[%d]: only text inputs are supported", index)
+			}
+			texts = append(texts, part.Text)
+		}
+		text := strings.Join(texts, "\n")
+		if strings.TrimSpace(text) == "" {
+			return nil, errors.New("input must not be empty")
+		}
+		return []string{text}, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, errors.New("input must be a string, an array of strings or an array of text parts")
+	}
+	if len(values) > MaxModerationInputs {
+		return nil, fmt.Errorf("input must contain at most %d items", MaxModerationInputs)
+	}
+	for index, value := range values {
+		if strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("input[%d] must not be empty", index)
+		}
+	}
+	return values, nil
+}
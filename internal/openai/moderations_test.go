@@ -0,0 +1,17 @@
+package openai
+
+import "testing"
+
+func TestParseModerationInputShapes(t *testing.T) {
+	inputs, err := ParseModerationInput([]byte(`[{"type":"text","text":"a"},{"type":"text","text":"b"}]`))
+	if err != nil || len(inputs) != 1 || inputs[0] != "a\nb" {
+		t.Fatalf("unexpected multimodal parse: %#v, %v", inputs, err)
+	}
+	inputs, err = ParseModerationInput([]byte(`["a","b"]`))
+	if err != nil || len(inputs) != 2 {
+		t.Fatalf("unexpected string array parse: %#v, %v", inputs, err)
+	}
+	if _, err := ParseModerationInput([]byte(`[{"type":"image_url","image_url":{"url":"x"}}]`)); err == nil {
+		t.Fatalf("expected image input to be rejected")
+	}
+}
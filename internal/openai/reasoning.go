@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ThinkingBlock 是一段带签名的扩展思考内容（Claude extended thinking）。
+// 后续轮次必须把签名原样带回，Bedrock 才会接受含 tool_use 的 assistant 消息；
+// 对 Chat 接口以 assistant 消息的 thinking_blocks 字段往返，对 Responses 接口编码进 reasoning 项的 encrypted_content。
+type ThinkingBlock struct {
+	Type      string `json:"type"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// type=redacted_thinking 时为 base64 编码的加密内容
+	Data string `json:"data,omitempty"`
+}
+
+// ValidateReasoningEffort 校验 reasoning_effort / reasoning.effort 的取值。
+func ValidateReasoningEffort(effort string) error {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "", "none", "minimal", "low", "medium", "high":
+		return nil
+	default:
+		return fmt.Errorf("reasoning effort must be one of none, minimal, low, medium, high")
+	}
+}
+
+// ParseResponsesReasoningEffort 读取 Responses 请求 reasoning.effort。
+func ParseResponsesReasoningEffort(raw json.RawMessage) (string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return "", nil
+	}
+	var reasoning struct {
+		Effort string `json:"effort"`
+	}
+	if err := json.Unmarshal(raw, &reasoning); err != nil {
+		return "", fmt.Errorf("invalid reasoning: %w", err)
+	}
+	if err := ValidateReasoningEffort(reasoning.Effort); err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(reasoning.Effort)), nil
+}
+
+// ReasoningText 拼接所有可读思考块的文本，用于 reasoning_content 与 Responses reasoning summary。
+func ReasoningText(blocks []ThinkingBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "thinking" && block.Thinking != "" {
+			parts = append(parts, block.Thinking)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// EncodeReasoningEncryptedContent 把思考块编码为 Responses reasoning 项的 encrypted_content。
+// 内容本身并未加密（签名由模型提供方校验），客户端只需原样回传。
+func EncodeReasoningEncryptedContent(blocks []ThinkingBlock) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	blob, err := json.Marshal(blocks)
+	if err != nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(blob)
+}
+
+// DecodeReasoningEncryptedContent 是 EncodeReasoningEncryptedContent 的逆过程；无法识别时返回 nil。
+func DecodeReasoningEncryptedContent(value string) []ThinkingBlock {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	blob, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	var blocks []ThinkingBlock
+	if err := json.Unmarshal(blob, &blocks); err != nil {
+		return nil
+	}
+	return blocks
+}
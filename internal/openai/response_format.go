@@ -0,0 +1,143 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResponseFormat 是 response_format / text.format 解析后的统一表示。
+// Type 为 "json_object" 或 "json_schema"；"text" 或未设置时 ParseResponseFormat 返回 nil。
+type ResponseFormat struct {
+	Type        string
+	Name        string
+	Description string
+	Schema      json.RawMessage
+	Strict      *bool
+}
+
+type chatResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Schema      json.RawMessage `json:"schema,omitempty"`
+		Strict      *bool           `json:"strict,omitempty"`
+	} `json:"json_schema,omitempty"`
+}
+
+// responsesTextFormat 对应 Responses API 的 text.format，name/schema 与 type 平级。
+type responsesTextFormat struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ParseResponseFormat 解析 Chat Completions 的 response_format。
+func ParseResponseFormat(raw json.RawMessage) (*ResponseFormat, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+
+	var value chatResponseFormat
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("invalid response_format: %w", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(value.Type)) {
+	case "", "text":
+		return nil, nil
+	case "json_object":
+		return &ResponseFormat{Type: "json_object"}, nil
+	case "json_schema":
+		if value.JSONSchema == nil {
+			return nil, errors.New("response_format.json_schema is required when type is json_schema")
+		}
+		format := &ResponseFormat{
+			Type:        "json_schema",
+			Name:        strings.TrimSpace(value.JSONSchema.Name),
+			Description: strings.TrimSpace(value.JSONSchema.Description),
+			Schema:      value.JSONSchema.Schema,
+			Strict:      value.JSONSchema.Strict,
+		}
+		if err := validateResponseFormatSchema(format, "response_format.json_schema"); err != nil {
+			return nil, err
+		}
+		return format, nil
+	default:
+		return nil, fmt.Errorf("unsupported response_format type: %s", value.Type)
+	}
+}
+
+// normalizeResponsesTextFormat 将 Responses API 的 text 参数转为 Chat Completions 的 response_format。
+func normalizeResponsesTextFormat(raw json.RawMessage) (json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+
+	var text struct {
+		Format *responsesTextFormat `json:"format,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return nil, fmt.Errorf("invalid text: %w", err)
+	}
+	if text.Format == nil {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(text.Format.Type)) {
+	case "", "text":
+		return nil, nil
+	case "json_object":
+		return json.RawMessage(`{"type":"json_object"}`), nil
+	case "json_schema":
+		format := &ResponseFormat{
+			Type:   "json_schema",
+			Name:   strings.TrimSpace(text.Format.Name),
+			Schema: text.Format.Schema,
+		}
+		if err := validateResponseFormatSchema(format, "text.format"); err != nil {
+			return nil, err
+		}
+		jsonSchema := map[string]any{
+			"name":   format.Name,
+			"schema": text.Format.Schema,
+		}
+		if description := strings.TrimSpace(text.Format.Description); description != "" {
+			jsonSchema["description"] = description
+		}
+		if text.Format.Strict != nil {
+			jsonSchema["strict"] = *text.Format.Strict
+		}
+		return json.Marshal(map[string]any{
+			"type":        "json_schema",
+			"json_schema": jsonSchema,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported text.format type: %s", text.Format.Type)
+	}
+}
+
+func validateResponseFormatSchema(format *ResponseFormat, field string) error {
+	if format.Name == "" {
+		return fmt.Errorf("%s.name is required", field)
+	}
+	schema := strings.TrimSpace(string(format.Schema))
+	if schema == "" || schema == "null" {
+		return fmt.Errorf("%s.schema is required", field)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(format.Schema, &object); err != nil {
+		return fmt.Errorf("%s.schema must be a JSON object: %w", field, err)
+	}
+	// Bedrock 工具的 input schema 根节点必须是 object
+	if schemaType, ok := object["type"].(string); ok && schemaType != "object" {
+		return fmt.Errorf("%s.schema root type must be object, got %s", field, schemaType)
+	}
+	return nil
+}
@@ -26,6 +26,17 @@ type ResponsesCreateRequest struct {
 	Truncation         json.RawMessage `json:"truncation,omitempty"`
 	Store              *bool           `json:"store,omitempty"`
 	Metadata           json.RawMessage `json:"metadata,omitempty"`
+	Text               json.RawMessage `json:"text,omitempty"`
+	// background=true 时立即返回 queued 状态的响应，由服务端后台执行
+	Background bool `json:"background,omitempty"`
+
+	// History 是按 previous_response_id 链还原的历史消息（不含 instructions），由路由层填充
+	History []ChatMessage `json:"-"`
+}
+
+// StoreEnabled 对应 OpenAI 默认行为：未显式传 store=false 时保存响应。
+func (r ResponsesCreateRequest) StoreEnabled() bool {
+	return r.Store == nil || *r.Store
 }
 
 type ResponsesTool struct {
@@ -49,14 +60,24 @@ type ResponsesCreateResponse struct {
 	ParallelToolCalls bool                  `json:"parallel_tool_calls"`
 	ToolChoice        json.RawMessage       `json:"tool_choice,omitempty"`
 	OutputText        string                `json:"output_text,omitempty"`
+	Text              json.RawMessage       `json:"text,omitempty"`
+	Reasoning         any                   `json:"reasoning,omitempty"`
 	Error             any                   `json:"error"`
 	IncompleteDetails any                   `json:"incomplete_details"`
+	// 回显请求的 previous_response_id 与是否已保存
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	Store              bool   `json:"store"`
 }
 
 type ResponsesUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
-	TotalTokens  int `json:"total_tokens"`
+	InputTokens        int                         `json:"input_tokens"`
+	OutputTokens       int                         `json:"output_tokens"`
+	TotalTokens        int                         `json:"total_tokens"`
+	InputTokensDetails ResponsesInputTokensDetails `json:"input_tokens_details"`
+}
+
+type ResponsesInputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
 }
 
 type ResponsesOutputItem struct {
@@ -68,6 +89,9 @@ type ResponsesOutputItem struct {
 	CallID    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments string                   `json:"arguments,omitempty"`
+	// type=reasoning 时使用
+	Summary          []ResponsesOutputContent `json:"summary,omitempty"`
+	EncryptedContent string                   `json:"encrypted_content,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -91,6 +115,9 @@ func ValidateResponsesCreateRequest(request ResponsesCreateRequest) error {
 	if strings.TrimSpace(string(request.Input)) == "" || strings.TrimSpace(string(request.Input)) == "null" {
 		return errors.New("input is required")
 	}
+	if request.Background && !request.StoreEnabled() {
+		return errors.New("background responses require store to be true")
+	}
 	return nil
 }
 
@@ -99,10 +126,29 @@ func ResponsesRequestToChat(request ResponsesCreateRequest) (ChatCompletionReque
 	if err != nil {
 		return ChatCompletionRequest{}, err
 	}
+	if len(request.History) > 0 {
+		// 本轮 instructions 仍位于最前，历史消息插在其后、本轮输入之前
+		head := 0
+		if strings.TrimSpace(request.Instructions) != "" {
+			head = 1
+		}
+		combined := make([]ChatMessage, 0, len(request.History)+len(messages))
+		combined = append(combined, messages[:head]...)
+		combined = append(combined, request.History...)
+		messages = append(combined, messages[head:]...)
+	}
 	tools, err := normalizeResponsesTools(request.Tools)
 	if err != nil {
 		return ChatCompletionRequest{}, err
 	}
+	responseFormat, err := normalizeResponsesTextFormat(request.Text)
+	if err != nil {
+		return ChatCompletionRequest{}, err
+	}
+	reasoningEffort, err := ParseResponsesReasoningEffort(request.Reasoning)
+	if err != nil {
+		return ChatCompletionRequest{}, err
+	}
 
 	return ChatCompletionRequest{
 		Model:             strings.TrimSpace(request.Model),
@@ -115,6 +161,8 @@ func ResponsesRequestToChat(request ResponsesCreateRequest) (ChatCompletionReque
 		Tools:             tools,
 		ToolChoice:        request.ToolChoice,
 		ParallelToolCalls: request.ParallelToolCalls,
+		ResponseFormat:    responseFormat,
+		ReasoningEffort:   reasoningEffort,
 	}, nil
 }
 
@@ -171,12 +219,115 @@ func ParseResponsesInputMessages(input json.RawMessage, instructions string) ([]
 		return nil, errors.New("unsupported responses input format")
 	}
 
+	items = attachResponsesReasoning(items)
 	if len(items) == 0 {
 		return nil, errors.New("responses input yielded no usable messages")
 	}
 	return items, nil
 }
 
+// NormalizeResponsesInputItems 把 input 统一为输入项数组：字符串输入转换为 user message 项。
+func NormalizeResponsesInputItems(input json.RawMessage) ([]json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(input))
+	if trimmed == "" || trimmed == "null" {
+		return []json.RawMessage{}, nil
+	}
+
+	switch trimmed[0] {
+	case '"':
+		var text string
+		if err := json.Unmarshal(input, &text); err != nil {
+			return nil, fmt.Errorf("invalid string responses input: %w", err)
+		}
+		item, err := json.Marshal(map[string]any{
+			"type":    "message",
+			"role":    "user",
+			"content": []map[string]any{{"type": "input_text", "text": text}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []json.RawMessage{item}, nil
+	case '{':
+		return []json.RawMessage{json.RawMessage(trimmed)}, nil
+	case '[':
+		var rawItems []json.RawMessage
+		if err := json.Unmarshal(input, &rawItems); err != nil {
+			return nil, fmt.Errorf("invalid responses input array: %w", err)
+		}
+		return rawItems, nil
+	default:
+		return nil, errors.New("unsupported responses input format")
+	}
+}
+
+// ResponsesInputItemList 是 GET /v1/responses/{id}/input_items 的分页结果。
+type ResponsesInputItemList struct {
+	Object  string           `json:"object"`
+	Data    []map[string]any `json:"data"`
+	FirstID *string          `json:"first_id"`
+	LastID  *string          `json:"last_id"`
+	HasMore bool             `json:"has_more"`
+}
+
+// BuildResponsesInputItems 把保存的输入项转换为列表接口返回的形状：补齐 type 与 id（缺失时按响应 ID + 序号生成），
+// message 的字符串 content 转换为 input_text / output_text 内容数组。
+func BuildResponsesInputItems(responseID string, rawItems []json.RawMessage) []map[string]any {
+	items := make([]map[string]any, 0, len(rawItems))
+	for index, raw := range rawItems {
+		var item map[string]any
+		if err := json.Unmarshal(raw, &item); err != nil || item == nil {
+			continue
+		}
+		if _, ok := item["type"]; !ok {
+			if _, hasRole := item["role"]; hasRole {
+				item["type"] = "message"
+			}
+		}
+		if id, _ := item["id"].(string); strings.TrimSpace(id) == "" {
+			item["id"] = fmt.Sprintf("%s_in_%d", responseID, index)
+		}
+		if item["type"] == "message" {
+			if text, ok := item["content"].(string); ok {
+				partType := "input_text"
+				if item["role"] == "assistant" {
+					partType = "output_text"
+				}
+				item["content"] = []map[string]any{{"type": partType, "text": text}}
+			}
+			if _, ok := item["status"]; !ok {
+				item["status"] = "completed"
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// attachResponsesReasoning 把 reasoning 项（只含 ThinkingBlocks 的 assistant 消息）并入紧随其后的 assistant 消息，
+// Bedrock 要求思考块与对应的文本 / tool_use 位于同一条 assistant 消息开头；后面没有 assistant 消息的 reasoning 项直接丢弃。
+func attachResponsesReasoning(items []ChatMessage) []ChatMessage {
+	out := make([]ChatMessage, 0, len(items))
+	var pending []ThinkingBlock
+	for _, item := range items {
+		if isReasoningOnlyMessage(item) {
+			pending = append(pending, item.ThinkingBlocks...)
+			continue
+		}
+		if item.Role == "assistant" && len(pending) > 0 {
+			item.ThinkingBlocks = append(pending, item.ThinkingBlocks...)
+		}
+		pending = nil
+		out = append(out, item)
+	}
+	return out
+}
+
+func isReasoningOnlyMessage(message ChatMessage) bool {
+	return message.Role == "assistant" && len(message.ThinkingBlocks) > 0 &&
+		len(message.ToolCalls) == 0 && string(message.Content) == "null"
+}
+
 func parseSingleResponsesInputItem(raw json.RawMessage) ([]ChatMessage, error) {
 	trimmed := strings.TrimSpace(string(raw))
 	if trimmed == "" || trimmed == "null" {
@@ -258,6 +409,18 @@ func parseSingleResponsesInputItem(raw json.RawMessage) ([]ChatMessage, error) {
 			Content:    output,
 		}}, nil
 
+	case "reasoning":
+		// 只有带 encrypted_content（本路由生成，含签名）的 reasoning 项才能回传给 Bedrock；summary 文本无法单独使用
+		blocks := DecodeReasoningEncryptedContent(jsonString(item["encrypted_content"]))
+		if len(blocks) == 0 {
+			return nil, nil
+		}
+		return []ChatMessage{{
+			Role:           "assistant",
+			Content:        json.RawMessage("null"),
+			ThinkingBlocks: blocks,
+		}}, nil
+
 	case "input_text", "output_text", "text":
 		text := strings.TrimSpace(jsonString(item["text"]))
 		content, _ := json.Marshal(text)
@@ -300,6 +463,9 @@ func normalizeResponsesMessageContent(raw json.RawMessage) json.RawMessage {
 		if err := json.Unmarshal(raw, &entries); err != nil {
 			return json.RawMessage(`""`)
 		}
+		if hasResponsesAttachmentParts(entries) {
+			return normalizeResponsesMixedContent(entries)
+		}
 
 		parts := make([]string, 0, len(entries))
 		for _, entry := range entries {
@@ -317,6 +483,49 @@ func normalizeResponsesMessageContent(raw json.RawMessage) json.RawMessage {
 	}
 }
 
+// hasResponsesAttachmentParts 判断 content 中是否含有图片 / 文件 part。
+func hasResponsesAttachmentParts(entries []json.RawMessage) bool {
+	for _, entry := range entries {
+		switch responsesContentPartType(entry) {
+		case "input_image", "image_url", "input_file", "file":
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeResponsesMixedContent 保留图片 / 文件 part 原样（由 BuildBedrockMessages 转为 image / document block），
+// 文本类 part 统一改写为 {"type":"text"}，与 Chat Completions 的 content 数组格式一致。
+func normalizeResponsesMixedContent(entries []json.RawMessage) json.RawMessage {
+	parts := make([]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		switch responsesContentPartType(entry) {
+		case "input_image", "image_url", "input_file", "file":
+			parts = append(parts, entry)
+		default:
+			text := extractResponseContentText(entry)
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			blob, _ := json.Marshal(map[string]string{"type": "text", "text": text})
+			parts = append(parts, blob)
+		}
+	}
+	blob, err := json.Marshal(parts)
+	if err != nil {
+		return json.RawMessage(`""`)
+	}
+	return blob
+}
+
+func responsesContentPartType(raw json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed[0] != '{' {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(jsonStringFromObject(raw, "type")))
+}
+
 func normalizeResponsesToolOutput(raw json.RawMessage) json.RawMessage {
 	trimmed := strings.TrimSpace(string(raw))
 	if trimmed == "" || trimmed == "null" {
@@ -454,6 +663,24 @@ func BuildResponsesOutputItems(requestID string, text string, toolCalls []ToolCa
 	return items
 }
 
+// BuildResponsesReasoningItem 构造 type=reasoning 的输出项：summary 为思考文本，encrypted_content 携带签名以便下一轮回传。
+func BuildResponsesReasoningItem(requestID string, blocks []ThinkingBlock) (ResponsesOutputItem, bool) {
+	if len(blocks) == 0 {
+		return ResponsesOutputItem{}, false
+	}
+	summary := []ResponsesOutputContent{}
+	if text := ReasoningText(blocks); text != "" {
+		summary = append(summary, ResponsesOutputContent{Type: "summary_text", Text: text})
+	}
+	return ResponsesOutputItem{
+		ID:               "rs_" + requestID,
+		Type:             "reasoning",
+		Status:           "completed",
+		Summary:          summary,
+		EncryptedContent: EncodeReasoningEncryptedContent(blocks),
+	}, true
+}
+
 func BuildResponsesOutputText(items []ResponsesOutputItem) string {
 	if len(items) == 0 {
 		return ""
@@ -68,6 +68,32 @@ func TestResponsesRequestToChat(t *testing.T) {
 	}
 }
 
+func TestResponsesRequestToChatKeepsFileParts(t *testing.T) {
+	request := ResponsesCreateRequest{
+		Model: "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		Input: json.RawMessage(`[{"role":"user","content":[{"type":"input_text","text":"summarize"},{"type":"input_file","filename":"a.pdf","file_data":"JVBERi0="}]}]`),
+	}
+
+	chatRequest, err := ResponsesRequestToChat(request)
+	if err != nil {
+		t.Fatalf("ResponsesRequestToChat returned error: %v", err)
+	}
+	if len(chatRequest.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(chatRequest.Messages))
+	}
+
+	var parts []map[string]any
+	if err := json.Unmarshal(chatRequest.Messages[0].Content, &parts); err != nil {
+		t.Fatalf("expected content array, got %s", chatRequest.Messages[0].Content)
+	}
+	if len(parts) != 2 || parts[0]["type"] != "text" || parts[0]["text"] != "summarize" {
+		t.Fatalf("unexpected text part: %#v", parts)
+	}
+	if parts[1]["type"] != "input_file" || parts[1]["filename"] != "a.pdf" {
+		t.Fatalf("expected input_file part to be preserved, got %#v", parts[1])
+	}
+}
+
 func TestParseResponsesInputMessagesWithFunctionCallItems(t *testing.T) {
 	input := json.RawMessage(`[
 		{"type":"message","role":"user","content":[{"type":"input_text","text":"What is the weather?"}]},
@@ -155,3 +181,123 @@ func TestValidateResponsesCreateRequest(t *testing.T) {
 		t.Fatalf("expected error when input is null")
 	}
 }
+
+func TestResponsesRequestToChatTextFormat(t *testing.T) {
+	request := ResponsesCreateRequest{
+		Model: "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		Input: json.RawMessage(`"extract"`),
+		Text:  json.RawMessage(`{"format":{"type":"json_schema","name":"invoice","strict":true,"schema":{"type":"object","properties":{"total":{"type":"number"}}}}}`),
+	}
+
+	chatRequest, err := ResponsesRequestToChat(request)
+	if err != nil {
+		t.Fatalf("ResponsesRequestToChat returned error: %v", err)
+	}
+	format, err := ParseResponseFormat(chatRequest.ResponseFormat)
+	if err != nil {
+		t.Fatalf("ParseResponseFormat returned error: %v", err)
+	}
+	if format == nil || format.Type != "json_schema" || format.Name != "invoice" {
+		t.Fatalf("unexpected response format: %#v", format)
+	}
+	if format.Strict == nil || !*format.Strict {
+		t.Fatalf("expected strict=true, got %#v", format.Strict)
+	}
+
+	request.Text = json.RawMessage(`{"format":{"type":"json_schema","schema":{"type":"object"}}}`)
+	if _, err := ResponsesRequestToChat(request); err == nil {
+		t.Fatalf("expected error when text.format.name is missing")
+	}
+}
+
+func TestResponsesReasoningItemRoundTrip(t *testing.T) {
+	blocks := []ThinkingBlock{{Type: "thinking", Thinking: "check the weather tool", Signature: "sig-1"}}
+	item, ok := BuildResponsesReasoningItem("req_1", blocks)
+	if !ok || item.Type != "reasoning" || item.EncryptedContent == "" {
+		t.Fatalf("unexpected reasoning item: %#v", item)
+	}
+	if len(item.Summary) != 1 || item.Summary[0].Text != "check the weather tool" {
+		t.Fatalf("unexpected reasoning summary: %#v", item.Summary)
+	}
+
+	itemJSON, _ := json.Marshal(item)
+	input := json.RawMessage(`[
+		{"type":"message","role":"user","content":"What is the weather?"},
+		` + string(itemJSON) + `,
+		{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{}"},
+		{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+	]`)
+	messages, err := ParseResponsesInputMessages(input, "")
+	if err != nil {
+		t.Fatalf("ParseResponsesInputMessages returned error: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected reasoning to merge into the function_call message, got %d messages", len(messages))
+	}
+	if len(messages[1].ThinkingBlocks) != 1 || messages[1].ThinkingBlocks[0].Signature != "sig-1" || len(messages[1].ToolCalls) != 1 {
+		t.Fatalf("unexpected assistant message: %#v", messages[1])
+	}
+}
+
+func TestResponsesRequestToChatReasoningEffort(t *testing.T) {
+	chat, err := ResponsesRequestToChat(ResponsesCreateRequest{
+		Model:     "claude",
+		Input:     json.RawMessage(`"hi"`),
+		Reasoning: json.RawMessage(`{"effort":"High","summary":"auto"}`),
+	})
+	if err != nil {
+		t.Fatalf("ResponsesRequestToChat returned error: %v", err)
+	}
+	if chat.ReasoningEffort != "high" {
+		t.Fatalf("unexpected reasoning effort: %q", chat.ReasoningEffort)
+	}
+
+	if _, err := ResponsesRequestToChat(ResponsesCreateRequest{
+		Model:     "claude",
+		Input:     json.RawMessage(`"hi"`),
+		Reasoning: json.RawMessage(`{"effort":"extreme"}`),
+	}); err == nil {
+		t.Fatalf("expected invalid effort error")
+	}
+}
+
+func TestResponsesRequestToChatWithHistory(t *testing.T) {
+	request := ResponsesCreateRequest{
+		Model:        "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		Input:        json.RawMessage(`[{"type":"function_call_output","call_id":"call_1","output":"42"}]`),
+		Instructions: "be concise",
+		History: []ChatMessage{
+			{Role: "user", Content: json.RawMessage(`"what is 6*7?"`)},
+			{Role: "assistant", Content: json.RawMessage(`null`), ToolCalls: []ToolCall{{
+				ID:       "call_1",
+				Type:     "function",
+				Function: ToolCallFunction{Name: "calc", Arguments: `{"expr":"6*7"}`},
+			}}},
+		},
+	}
+
+	chatRequest, err := ResponsesRequestToChat(request)
+	if err != nil {
+		t.Fatalf("ResponsesRequestToChat returned error: %v", err)
+	}
+	roles := make([]string, 0, len(chatRequest.Messages))
+	for _, message := range chatRequest.Messages {
+		roles = append(roles, message.Role)
+	}
+	if len(roles) != 4 || roles[0] != "developer" || roles[1] != "user" || roles[2] != "assistant" || roles[3] != "tool" {
+		t.Fatalf("unexpected message order: %v", roles)
+	}
+	if chatRequest.Messages[3].ToolCallID != "call_1" {
+		t.Fatalf("unexpected tool message: %+v", chatRequest.Messages[3])
+	}
+
+	items, err := NormalizeResponsesInputItems(json.RawMessage(`"hi"`))
+	if err != nil || len(items) != 1 {
+		t.Fatalf("unexpected normalized input items: %v %v", items, err)
+	}
+	var item map[string]any
+	_ = json.Unmarshal(items[0], &item)
+	if item["type"] != "message" || item["role"] != "user" {
+		t.Fatalf("unexpected normalized item: %s", items[0])
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"strings"
 )
 
+// MaxChoices 是单个请求允许的 n 上限；每个候选都是一次独立的 Bedrock 调用。
+const MaxChoices = 8
+
 type ChatCompletionRequest struct {
 	Model             string          `json:"model"`
 	Messages          []ChatMessage   `json:"messages"`
@@ -29,6 +32,21 @@ type ChatCompletionRequest struct {
 	Seed             *int            `json:"seed,omitempty"`
 	ResponseFormat   json.RawMessage `json:"response_format,omitempty"`
 	StreamOptions    json.RawMessage `json:"stream_options,omitempty"`
+	// 模型特有参数，经管理员白名单过滤后透传到 Bedrock AdditionalModelRequestFields
+	TopK                         *int                       `json:"top_k,omitempty"`
+	AdditionalModelRequestFields map[string]json.RawMessage `json:"additional_model_request_fields,omitempty"`
+	// none / minimal / low / medium / high，映射为 Claude extended thinking 的 budget_tokens
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	// Guardrail 由路由层按 API key / 模型（管理员配置）填充，客户端无法传入
+	Guardrail *GuardrailConfig `json:"-"`
+}
+
+// GuardrailConfig 是 Bedrock Guardrail 的标识与版本；StreamProcessingMode 为 sync / async，仅用于流式调用。
+type GuardrailConfig struct {
+	Identifier           string
+	Version              string
+	StreamProcessingMode string
 }
 
 type ChatMessage struct {
@@ -37,6 +55,9 @@ type ChatMessage struct {
 	Name       string          `json:"name,omitempty"`
 	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
 	ToolCallID string          `json:"tool_call_id,omitempty"`
+	// assistant 消息的思考内容；thinking_blocks 带签名，客户端在后续轮次原样回传
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ThinkingBlocks   []ThinkingBlock `json:"thinking_blocks,omitempty"`
 }
 
 type ChatCompletionResponse struct {
@@ -58,6 +79,12 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// 命中提示缓存时返回，prompt_tokens 已包含其中的 cached_tokens
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
 }
 
 type ChatCompletionChunk struct {
@@ -78,9 +105,11 @@ type ChatChunkChoice struct {
 }
 
 type ChatChunkDelta struct {
-	Role      string              `json:"role,omitempty"`
-	Content   string              `json:"content,omitempty"`
-	ToolCalls []ChatChunkToolCall `json:"tool_calls,omitempty"`
+	Role             string              `json:"role,omitempty"`
+	Content          string              `json:"content,omitempty"`
+	ReasoningContent string              `json:"reasoning_content,omitempty"`
+	ThinkingBlocks   []ThinkingBlock     `json:"thinking_blocks,omitempty"`
+	ToolCalls        []ChatChunkToolCall `json:"tool_calls,omitempty"`
 }
 
 type ChatChunkToolCall struct {
@@ -146,6 +175,28 @@ type ModelInfo struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	OwnedBy string `json:"owned_by"`
+	// 模型别名指向的 Bedrock model id
+	AliasFor string `json:"alias_for,omitempty"`
+	// 以下为 Bedrock 模型元数据（ListFoundationModels + 管理员覆盖），未知时省略
+	Name              string   `json:"name,omitempty"`
+	Provider          string   `json:"provider,omitempty"`
+	InputModalities   []string `json:"input_modalities,omitempty"`
+	OutputModalities  []string `json:"output_modalities,omitempty"`
+	SupportsStreaming *bool    `json:"supports_streaming,omitempty"`
+	SupportsTools     *bool    `json:"supports_tools,omitempty"`
+	ContextWindow     *int     `json:"context_window,omitempty"`
+	MaxOutputTokens   *int     `json:"max_output_tokens,omitempty"`
+	LifecycleStatus   string   `json:"lifecycle_status,omitempty"`
+}
+
+// TokenCountResponse 是 /v1/chat/completions/count_tokens 的响应（非 OpenAI 官方接口）。
+// Estimated 为 true 表示 Bedrock CountTokens 不可用、input_tokens 为本地近似值；上下文窗口未配置时省略。
+type TokenCountResponse struct {
+	Object        string `json:"object"`
+	Model         string `json:"model"`
+	InputTokens   int    `json:"input_tokens"`
+	ContextWindow *int   `json:"context_window,omitempty"`
+	Estimated     bool   `json:"estimated"`
 }
 
 type ErrorResponse struct {
@@ -159,17 +210,93 @@ type OpenAIErrorPayload struct {
 }
 
 type contentPart struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	ImageURL json.RawMessage `json:"image_url,omitempty"`
+	Source   *struct {
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+	} `json:"source,omitempty"`
+	File     *contentFile `json:"file,omitempty"`
+	Filename string       `json:"filename,omitempty"`
+	FileData string       `json:"file_data,omitempty"`
+	Title    string       `json:"title,omitempty"`
+}
+
+type contentFile struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
 }
 
 func ValidateChatRequest(request ChatCompletionRequest) error {
 	if len(request.Messages) == 0 {
 		return errors.New("messages cannot be empty")
 	}
+	if _, err := ParseResponseFormat(request.ResponseFormat); err != nil {
+		return err
+	}
+	if _, err := ParseStopSequences(request.Stop); err != nil {
+		return err
+	}
+	if request.N != nil && (*request.N < 1 || *request.N > MaxChoices) {
+		return fmt.Errorf("n must be between 1 and %d", MaxChoices)
+	}
+	if err := ValidateReasoningEffort(request.ReasoningEffort); err != nil {
+		return err
+	}
 	return nil
 }
 
+// StreamIncludeUsage 返回 stream_options.include_usage 是否为 true。
+func StreamIncludeUsage(raw json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return false
+	}
+	var options struct {
+		IncludeUsage bool `json:"include_usage"`
+	}
+	if err := json.Unmarshal(raw, &options); err != nil {
+		return false
+	}
+	return options.IncludeUsage
+}
+
+// ParseStopSequences 解析 stop 参数（字符串或字符串数组），忽略空字符串。
+func ParseStopSequences(raw json.RawMessage) ([]string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(trimmed, "\"") {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid stop: %w", err)
+		}
+		if value == "" {
+			return nil, nil
+		}
+		return []string{value}, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, errors.New("stop must be a string or an array of strings")
+	}
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			out = append(out, value)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
 func DecodeContentAsText(raw json.RawMessage) (string, error) {
 	trimmed := strings.TrimSpace(string(raw))
 	if trimmed == "" || trimmed == "null" {
@@ -219,7 +346,7 @@ func RenderMessagesForLog(messages []ChatMessage, maxChars int) string {
 
 	var builder strings.Builder
 	for _, message := range messages {
-		text, err := DecodeContentAsText(message.Content)
+		text, err := renderContentForLog(message.Content)
 		if err != nil {
 			text = "<unparseable-content>"
 		}
@@ -263,6 +390,91 @@ func RenderMessagesForLog(messages []ChatMessage, maxChars int) string {
 	return output[:maxChars]
 }
 
+// renderContentForLog 与 DecodeContentAsText 一致地提取文本，但为图片 / 文件 part 输出占位符，
+// 避免调用日志里存下整段 base64。
+func renderContentForLog(raw json.RawMessage) (string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if !strings.HasPrefix(trimmed, "[") {
+		return DecodeContentAsText(raw)
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("invalid array content: %w", err)
+	}
+	var builder strings.Builder
+	for _, part := range parts {
+		switch part.Type {
+		case "", "text":
+			builder.WriteString(part.Text)
+		case "image_url", "input_image", "image":
+			builder.WriteString(imagePlaceholderForLog(part))
+		case "file", "input_file", "document":
+			builder.WriteString(filePlaceholderForLog(part))
+		}
+	}
+	return builder.String(), nil
+}
+
+func imagePlaceholderForLog(part contentPart) string {
+	mediaType := ""
+	payload := ""
+	if part.Source != nil {
+		mediaType = part.Source.MediaType
+		payload = part.Source.Data
+	} else {
+		url := jsonString(part.ImageURL)
+		if url == "" {
+			url = jsonStringFromObject(part.ImageURL, "url")
+		}
+		if header, data, found := strings.Cut(url, ","); found && strings.HasPrefix(strings.ToLower(header), "data:") {
+			mediaType, _, _ = strings.Cut(header[len("data:"):], ";")
+			payload = data
+		} else if strings.HasPrefix(strings.ToLower(url), "http") {
+			return "[image " + url + "]"
+		} else {
+			payload = url
+		}
+	}
+
+	label := "[image"
+	if mediaType = strings.TrimSpace(mediaType); mediaType != "" {
+		label += " " + mediaType
+	}
+	if payload != "" {
+		label += fmt.Sprintf(" ~%dKB", (len(payload)*3/4+1023)/1024)
+	}
+	return label + "]"
+}
+
+func filePlaceholderForLog(part contentPart) string {
+	name := part.Filename
+	payload := part.FileData
+	switch {
+	case part.File != nil:
+		name = part.File.Filename
+		payload = part.File.FileData
+		if name == "" {
+			name = part.File.FileID
+		}
+	case part.Source != nil:
+		name = part.Title
+		payload = part.Source.Data
+	}
+	if _, data, found := strings.Cut(payload, ","); found && strings.HasPrefix(strings.ToLower(payload), "data:") {
+		payload = data
+	}
+
+	label := "[file"
+	if name = strings.TrimSpace(name); name != "" {
+		label += " " + name
+	}
+	if payload != "" {
+		label += fmt.Sprintf(" ~%dKB", (len(payload)*3/4+1023)/1024)
+	}
+	return label + "]"
+}
+
 // RenderRequestForLog 渲染完整的请求信息，包括 messages、tools 和 tool_choice
 func RenderRequestForLog(request ChatCompletionRequest, maxChars int) string {
 	if maxChars <= 0 {
@@ -1,6 +1,9 @@
 package openai
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestDecodeContentAsText_String(t *testing.T) {
 	value, err := DecodeContentAsText([]byte(`"hello"`))
@@ -21,3 +24,68 @@ func TestDecodeContentAsText_Array(t *testing.T) {
 		t.Fatalf("unexpected value: %q", value)
 	}
 }
+
+func TestParseStopSequences(t *testing.T) {
+	values, err := ParseStopSequences([]byte(`"END"`))
+	if err != nil || len(values) != 1 || values[0] != "END" {
+		t.Fatalf("unexpected string stop result: %v, %v", values, err)
+	}
+	values, err = ParseStopSequences([]byte(`["a", "", "b"]`))
+	if err != nil || len(values) != 2 {
+		t.Fatalf("unexpected array stop result: %v, %v", values, err)
+	}
+	if _, err := ParseStopSequences([]byte(`[1, 2]`)); err == nil {
+		t.Fatalf("expected error for non-string stop values")
+	}
+}
+
+func TestStreamIncludeUsage(t *testing.T) {
+	if !StreamIncludeUsage([]byte(`{"include_usage":true}`)) {
+		t.Fatalf("expected include_usage=true")
+	}
+	if StreamIncludeUsage(nil) || StreamIncludeUsage([]byte(`{"include_usage":false}`)) || StreamIncludeUsage([]byte(`"bad"`)) {
+		t.Fatalf("expected include_usage=false")
+	}
+}
+
+func TestRenderMessagesForLogImagePlaceholder(t *testing.T) {
+	payload := strings.Repeat("A", 4096)
+	messages := []ChatMessage{{
+		Role:    "user",
+		Content: []byte(`[{"type":"text","text":"look: "},{"type":"image_url","image_url":{"url":"data:image/png;base64,` + payload + `"}}]`),
+	}}
+
+	rendered := RenderMessagesForLog(messages, 10000)
+	if strings.Contains(rendered, payload) {
+		t.Fatalf("expected base64 payload to be omitted from log: %q", rendered)
+	}
+	if rendered != "user: look: [image image/png ~3KB]" {
+		t.Fatalf("unexpected rendered log: %q", rendered)
+	}
+}
+
+func TestParseEmbeddingInput(t *testing.T) {
+	inputs, err := ParseEmbeddingInput([]byte(`"hello"`))
+	if err != nil || len(inputs) != 1 || inputs[0] != "hello" {
+		t.Fatalf("unexpected single input: %#v, %v", inputs, err)
+	}
+	inputs, err = ParseEmbeddingInput([]byte(`["a","b"]`))
+	if err != nil || len(inputs) != 2 {
+		t.Fatalf("unexpected batched input: %#v, %v", inputs, err)
+	}
+	for _, raw := range []string{``, `[]`, `[1,2]`, `[""]`} {
+		if _, err := ParseEmbeddingInput([]byte(raw)); err == nil {
+			t.Fatalf("expected error for input %q", raw)
+		}
+	}
+}
+
+func TestEncodeEmbeddingBase64(t *testing.T) {
+	// 1.0 的 float32 little-endian 表示为 00 00 80 3f
+	if encoded := EncodeEmbedding([]float64{1}, "base64"); encoded != "AACAPw==" {
+		t.Fatalf("unexpected base64 embedding: %v", encoded)
+	}
+	if values, ok := EncodeEmbedding([]float64{1}, "float").([]float64); !ok || values[0] != 1 {
+		t.Fatalf("expected float embedding passthrough")
+	}
+}
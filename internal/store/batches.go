@@ -0,0 +1,476 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FileRecord 是 /v1/files 上传的文件（内容单独读取，避免列表查询加载整个文件）。
+type FileRecord struct {
+	FileID    string
+	ClientID  string
+	Purpose   string
+	Filename  string
+	Bytes     int64
+	CreatedAt time.Time
+}
+
+// BatchRecord 是一个 /v1/batches 任务。Status 取值与 OpenAI 一致：
+// validating（排队等待执行）/ in_progress / finalizing / completed / failed / expired / cancelling / cancelled。
+// 各状态的进入时间为零值表示尚未进入该状态。
+type BatchRecord struct {
+	BatchID          string
+	ClientID         string
+	Endpoint         string
+	InputFileID      string
+	CompletionWindow string
+	Status           string
+	OutputFileID     string
+	ErrorFileID      string
+	MetadataJSON     string
+	RequestTotal     int
+	RequestCompleted int
+	RequestFailed    int
+	CreatedAt        time.Time
+	InProgressAt     time.Time
+	FinalizingAt     time.Time
+	CompletedAt      time.Time
+	FailedAt         time.Time
+	ExpiredAt        time.Time
+	CancellingAt     time.Time
+	CancelledAt      time.Time
+	ExpiresAt        time.Time
+}
+
+// BatchItem 是输入文件中的一行请求。Status 为 pending / completed / failed，
+// ResultJSON 是该行最终写入输出文件（completed）或错误文件（failed）的一行 JSON。
+type BatchItem struct {
+	LineIndex  int
+	CustomID   string
+	BodyJSON   string
+	Status     string
+	Attempts   int
+	ResultJSON string
+}
+
+// batchStatusTimeColumns 是进入各状态时记录时间的列。
+var batchStatusTimeColumns = map[string]string{
+	"in_progress": "in_progress_at",
+	"finalizing":  "finalizing_at",
+	"completed":   "completed_at",
+	"failed":      "failed_at",
+	"expired":     "expired_at",
+	"cancelling":  "cancelling_at",
+	"cancelled":   "cancelled_at",
+}
+
+const batchColumns = `
+batch_id, client_id, endpoint, input_file_id, completion_window, status,
+output_file_id, error_file_id, metadata_json, request_total, request_completed, request_failed,
+created_at, in_progress_at, finalizing_at, completed_at, failed_at, expired_at,
+cancelling_at, cancelled_at, expires_at`
+
+// SaveFile 保存一个上传的文件。
+func (s *Store) SaveFile(ctx context.Context, record FileRecord, content []byte) error {
+	record.FileID = strings.TrimSpace(record.FileID)
+	if record.FileID == "" {
+		return errors.New("file id is required")
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO files(file_id, client_id, purpose, filename, bytes, content, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`,
+		record.FileID,
+		record.ClientID,
+		record.Purpose,
+		record.Filename,
+		int64(len(content)),
+		content,
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+// GetFile 按 ID 读取属于 clientID 的文件信息；其他客户端的文件视为不存在。
+func (s *Store) GetFile(ctx context.Context, clientID, fileID string) (FileRecord, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT file_id, client_id, purpose, filename, bytes, created_at
+FROM files
+WHERE file_id = ? AND client_id = ?
+`, strings.TrimSpace(fileID), clientID)
+	record, err := scanFile(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return FileRecord{}, false, nil
+		}
+		return FileRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+// GetFileContent 读取属于 clientID 的文件内容。
+func (s *Store) GetFileContent(ctx context.Context, clientID, fileID string) ([]byte, bool, error) {
+	var content []byte
+	err := s.db.QueryRowContext(ctx, `SELECT content FROM files WHERE file_id = ? AND client_id = ?`, strings.TrimSpace(fileID), clientID).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// ListFiles 按创建时间倒序列出属于 clientID 的文件；purpose 非空时只列出该用途的文件。
+func (s *Store) ListFiles(ctx context.Context, clientID, purpose string, limit int) ([]FileRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	query := `
+SELECT file_id, client_id, purpose, filename, bytes, created_at
+FROM files
+WHERE client_id = ?
+`
+	args := []any{clientID}
+	if purpose != "" {
+		query += "AND purpose = ?\n"
+		args = append(args, purpose)
+	}
+	query += "ORDER BY created_at DESC, file_id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]FileRecord, 0)
+	for rows.Next() {
+		record, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+// DeleteFile 删除属于 clientID 的文件；返回是否确实删除了文件。
+func (s *Store) DeleteFile(ctx context.Context, clientID, fileID string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM files WHERE file_id = ? AND client_id = ?`, strings.TrimSpace(fileID), clientID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CreateBatch 在一个事务中写入 batch 任务与输入文件拆出的全部请求行。
+func (s *Store) CreateBatch(ctx context.Context, record BatchRecord, items []BatchItem) error {
+	record.BatchID = strings.TrimSpace(record.BatchID)
+	if record.BatchID == "" {
+		return errors.New("batch id is required")
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	if record.Status == "" {
+		record.Status = "validating"
+	}
+	if record.MetadataJSON == "" {
+		record.MetadataJSON = "{}"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO batches(
+batch_id, client_id, endpoint, input_file_id, completion_window, status,
+metadata_json, request_total, created_at, expires_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`,
+		record.BatchID,
+		record.ClientID,
+		record.Endpoint,
+		record.InputFileID,
+		record.CompletionWindow,
+		record.Status,
+		record.MetadataJSON,
+		len(items),
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		record.ExpiresAt.UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO batch_items(batch_id, line_index, custom_id, body_json, status)
+VALUES (?, ?, ?, ?, 'pending')
+`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, item := range items {
+		if _, err := stmt.ExecContext(ctx, record.BatchID, item.LineIndex, item.CustomID, item.BodyJSON); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBatch 按 ID 读取属于 clientID 的 batch 任务；其他客户端的任务视为不存在。
+func (s *Store) GetBatch(ctx context.Context, clientID, batchID string) (BatchRecord, bool, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+batchColumns+`
+FROM batches
+WHERE batch_id = ? AND client_id = ?
+`, strings.TrimSpace(batchID), clientID)
+	record, err := scanBatch(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BatchRecord{}, false, nil
+		}
+		return BatchRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+// ListBatches 按创建时间倒序列出属于 clientID 的 batch 任务；after 非空时从该任务之后（更早创建的任务）开始。
+func (s *Store) ListBatches(ctx context.Context, clientID string, limit int, after string) ([]BatchRecord, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `SELECT ` + batchColumns + `
+FROM batches
+WHERE client_id = ?
+`
+	args := []any{clientID}
+	if after = strings.TrimSpace(after); after != "" {
+		query += `AND (created_at, batch_id) < (SELECT created_at, batch_id FROM batches WHERE batch_id = ? AND client_id = ?)
+`
+		args = append(args, after, clientID)
+	}
+	query += "ORDER BY created_at DESC, batch_id DESC LIMIT ?"
+	args = append(args, limit)
+	return s.queryBatches(ctx, query, args...)
+}
+
+// ListUnfinishedBatches 按创建时间顺序列出所有尚未结束的 batch 任务，供执行器（包括重启后）继续处理。
+func (s *Store) ListUnfinishedBatches(ctx context.Context) ([]BatchRecord, error) {
+	return s.queryBatches(ctx, `SELECT `+batchColumns+`
+FROM batches
+WHERE status IN ('validating', 'in_progress', 'finalizing', 'cancelling')
+ORDER BY created_at ASC, batch_id ASC
+`)
+}
+
+func (s *Store) queryBatches(ctx context.Context, query string, args ...any) ([]BatchRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]BatchRecord, 0)
+	for rows.Next() {
+		record, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+// TransitionBatch 在任务当前状态属于 from 时把它切换到 status，并记录进入该状态的时间；
+// 返回是否发生了切换（例如任务已被取消时执行器的状态推进会失败）。
+func (s *Store) TransitionBatch(ctx context.Context, batchID string, from []string, status string, at time.Time) (bool, error) {
+	if len(from) == 0 {
+		return false, errors.New("from statuses are required")
+	}
+	query := `UPDATE batches SET status = ?`
+	args := []any{status}
+	if column, ok := batchStatusTimeColumns[status]; ok {
+		query += fmt.Sprintf(", %s = ?", column)
+		args = append(args, at.UTC().Format(time.RFC3339Nano))
+	}
+	query += ` WHERE batch_id = ? AND status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+	args = append(args, batchID)
+	for _, value := range from {
+		args = append(args, value)
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetBatchFiles 记录任务生成的输出文件与错误文件 ID。
+func (s *Store) SetBatchFiles(ctx context.Context, batchID, outputFileID, errorFileID string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE batches SET output_file_id = ?, error_file_id = ? WHERE batch_id = ?`, outputFileID, errorFileID, batchID)
+	return err
+}
+
+// PendingBatchItems 按行号顺序返回尚未执行完成的请求行。
+func (s *Store) PendingBatchItems(ctx context.Context, batchID string) ([]BatchItem, error) {
+	return s.queryBatchItems(ctx, `
+SELECT line_index, custom_id, body_json, status, attempts, result_json
+FROM batch_items
+WHERE batch_id = ? AND status = 'pending'
+ORDER BY line_index ASC
+`, batchID)
+}
+
+// FinishedBatchItems 按行号顺序返回指定状态（completed / failed）的请求行，用于生成输出文件与错误文件。
+func (s *Store) FinishedBatchItems(ctx context.Context, batchID, status string) ([]BatchItem, error) {
+	return s.queryBatchItems(ctx, `
+SELECT line_index, custom_id, body_json, status, attempts, result_json
+FROM batch_items
+WHERE batch_id = ? AND status = ?
+ORDER BY line_index ASC
+`, batchID, status)
+}
+
+func (s *Store) queryBatchItems(ctx context.Context, query string, args ...any) ([]BatchItem, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]BatchItem, 0)
+	for rows.Next() {
+		var item BatchItem
+		if err := rows.Scan(&item.LineIndex, &item.CustomID, &item.BodyJSON, &item.Status, &item.Attempts, &item.ResultJSON); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// FinishBatchItem 保存一行请求的最终结果（status 为 completed 或 failed），并同步更新任务的完成 / 失败计数。
+// 已结束的行不会被重复计数。
+func (s *Store) FinishBatchItem(ctx context.Context, batchID string, lineIndex int, status string, attempts int, resultJSON string) error {
+	var counter string
+	switch status {
+	case "completed":
+		counter = "request_completed"
+	case "failed":
+		counter = "request_failed"
+	default:
+		return fmt.Errorf("invalid batch item status: %s", status)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `
+UPDATE batch_items SET status = ?, attempts = ?, result_json = ?
+WHERE batch_id = ? AND line_index = ? AND status = 'pending'
+`, status, attempts, resultJSON, batchID, lineIndex)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE batches SET %s = %s + 1 WHERE batch_id = ?", counter, counter), batchID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFile(row rowScanner) (FileRecord, error) {
+	var record FileRecord
+	var createdAt string
+	if err := row.Scan(
+		&record.FileID,
+		&record.ClientID,
+		&record.Purpose,
+		&record.Filename,
+		&record.Bytes,
+		&createdAt,
+	); err != nil {
+		return FileRecord{}, err
+	}
+	record.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	return record, nil
+}
+
+func scanBatch(row rowScanner) (BatchRecord, error) {
+	var record BatchRecord
+	var createdAt, inProgressAt, finalizingAt, completedAt, failedAt, expiredAt, cancellingAt, cancelledAt, expiresAt string
+	if err := row.Scan(
+		&record.BatchID,
+		&record.ClientID,
+		&record.Endpoint,
+		&record.InputFileID,
+		&record.CompletionWindow,
+		&record.Status,
+		&record.OutputFileID,
+		&record.ErrorFileID,
+		&record.MetadataJSON,
+		&record.RequestTotal,
+		&record.RequestCompleted,
+		&record.RequestFailed,
+		&createdAt,
+		&inProgressAt,
+		&finalizingAt,
+		&completedAt,
+		&failedAt,
+		&expiredAt,
+		&cancellingAt,
+		&cancelledAt,
+		&expiresAt,
+	); err != nil {
+		return BatchRecord{}, err
+	}
+	// 尚未进入的状态时间列为空字符串，解析失败时保持零值
+	record.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	record.InProgressAt, _ = time.Parse(time.RFC3339Nano, inProgressAt)
+	record.FinalizingAt, _ = time.Parse(time.RFC3339Nano, finalizingAt)
+	record.CompletedAt, _ = time.Parse(time.RFC3339Nano, completedAt)
+	record.FailedAt, _ = time.Parse(time.RFC3339Nano, failedAt)
+	record.ExpiredAt, _ = time.Parse(time.RFC3339Nano, expiredAt)
+	record.CancellingAt, _ = time.Parse(time.RFC3339Nano, cancellingAt)
+	record.CancelledAt, _ = time.Parse(time.RFC3339Nano, cancelledAt)
+	record.ExpiresAt, _ = time.Parse(time.RFC3339Nano, expiresAt)
+	return record, nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ResponseRecord 是一次 store=true 的 Responses API 调用：输入项、输出项，以及本轮新增的对话消息
+// （本轮输入解析出的消息 + 模型回复，不含 instructions），previous_response_id 链按顺序拼接即可还原完整对话。
+type ResponseRecord struct {
+	ResponseID         string
+	ClientID           string
+	Model              string
+	PreviousResponseID string
+	InputItemsJSON     string
+	OutputItemsJSON    string
+	MessagesJSON       string
+	// 返回给客户端的完整 response 对象
+	ResponseJSON string
+	// queued / in_progress / completed / failed / cancelled；为空时按 completed 保存
+	Status    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SizeBytes 返回记录中 JSON 内容的总字节数，用于存储大小上限校验。
+func (r ResponseRecord) SizeBytes() int {
+	return len(r.InputItemsJSON) + len(r.OutputItemsJSON) + len(r.MessagesJSON) + len(r.ResponseJSON)
+}
+
+// ErrResponseIDConflict 表示 response ID 已被另一个客户端的记录占用；SaveResponse 不会覆盖其他客户端的记录。
+var ErrResponseIDConflict = errors.New("response id belongs to another client")
+
+// SaveResponse 写入一条响应记录，并顺带清理已过期的记录。同一客户端可以更新自己的记录（后台任务的状态变化），
+// 其他客户端的同名记录保持不变并返回 ErrResponseIDConflict。
+func (s *Store) SaveResponse(ctx context.Context, record ResponseRecord) error {
+	record.ResponseID = strings.TrimSpace(record.ResponseID)
+	if record.ResponseID == "" {
+		return errors.New("response id is required")
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	record.Status = strings.TrimSpace(record.Status)
+	if record.Status == "" {
+		record.Status = "completed"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM responses WHERE expires_at < ?`, time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `
+INSERT INTO responses(
+response_id, client_id, model, previous_response_id,
+input_items_json, output_items_json, messages_json, response_json, status, created_at, expires_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(response_id) DO UPDATE SET
+client_id = excluded.client_id,
+model = excluded.model,
+previous_response_id = excluded.previous_response_id,
+input_items_json = excluded.input_items_json,
+output_items_json = excluded.output_items_json,
+messages_json = excluded.messages_json,
+response_json = excluded.response_json,
+status = excluded.status,
+created_at = excluded.created_at,
+expires_at = excluded.expires_at
+WHERE responses.client_id = excluded.client_id
+`,
+		record.ResponseID,
+		record.ClientID,
+		record.Model,
+		strings.TrimSpace(record.PreviousResponseID),
+		record.InputItemsJSON,
+		record.OutputItemsJSON,
+		record.MessagesJSON,
+		record.ResponseJSON,
+		record.Status,
+		record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		record.ExpiresAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrResponseIDConflict
+	}
+
+	return tx.Commit()
+}
+
+// GetResponse 按 ID 读取属于 clientID 且未过期的响应记录；其他客户端的记录视为不存在。
+func (s *Store) GetResponse(ctx context.Context, clientID, responseID string) (ResponseRecord, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+SELECT
+response_id, client_id, model, previous_response_id,
+input_items_json, output_items_json, messages_json, response_json, status, created_at, expires_at
+FROM responses
+WHERE response_id = ? AND client_id = ? AND expires_at >= ?
+`, strings.TrimSpace(responseID), clientID, time.Now().UTC().Format(time.RFC3339Nano))
+
+	var record ResponseRecord
+	var createdAt, expiresAt string
+	if err := row.Scan(
+		&record.ResponseID,
+		&record.ClientID,
+		&record.Model,
+		&record.PreviousResponseID,
+		&record.InputItemsJSON,
+		&record.OutputItemsJSON,
+		&record.MessagesJSON,
+		&record.ResponseJSON,
+		&record.Status,
+		&createdAt,
+		&expiresAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ResponseRecord{}, false, nil
+		}
+		return ResponseRecord{}, false, err
+	}
+	record.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	record.ExpiresAt, _ = time.Parse(time.RFC3339Nano, expiresAt)
+	return record, true, nil
+}
+
+// DeleteResponse 删除属于 clientID 的响应记录；返回是否确实删除了记录。
+func (s *Store) DeleteResponse(ctx context.Context, clientID, responseID string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM responses WHERE response_id = ? AND client_id = ?`, strings.TrimSpace(responseID), clientID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// FailUnfinishedResponses 把仍处于 queued / in_progress 的后台响应标记为 failed。
+// 后台任务只保存在进程内存中，服务重启后无法继续执行，启动时调用。
+func (s *Store) FailUnfinishedResponses(ctx context.Context, message string) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+UPDATE responses
+SET status = 'failed',
+response_json = json_set(response_json, '$.status', 'failed', '$.error', json_object('code', 'server_error', 'message', ?))
+WHERE status IN ('queued', 'in_progress')
+`, message)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
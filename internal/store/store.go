@@ -32,40 +32,71 @@ type CallRecord struct {
 	ResponseContent string
 	IsStream        bool
 	CreatedAt       time.Time
+	// 提示缓存命中（读取）与写入的 token 数，不包含在 InputTokens 内，按各自单价计费
+	CacheReadInputTokens  int
+	CacheWriteInputTokens int
+	// 本次调用使用的 Bedrock Guardrail 标识，以及 Guardrail 是否介入（拦截 / 过滤）
+	GuardrailID         string
+	GuardrailIntervened bool
+	// 由 /v1/batches 执行器发起的调用，用量额外计入 batch_* 列
+	IsBatch bool
+	// /v1/images/generations 生成的图片数量，按模型的单张图片价格计费
+	ImageCount int
+	// 调用 Bedrock 的尝试次数（1 + 限流 / 瞬时错误后的重试次数），未调用 Bedrock 时为 0
+	Attempts int
 }
 
 type UsageRow struct {
-	ClientID     string `json:"client_id"`
-	InputTokens  int64  `json:"input_tokens"`
-	OutputTokens int64  `json:"output_tokens"`
-	TotalTokens  int64  `json:"total_tokens"`
-	RequestCount int64  `json:"request_count"`
+	ClientID              string `json:"client_id"`
+	InputTokens           int64  `json:"input_tokens"`
+	OutputTokens          int64  `json:"output_tokens"`
+	TotalTokens           int64  `json:"total_tokens"`
+	CacheReadInputTokens  int64  `json:"cache_read_input_tokens"`
+	CacheWriteInputTokens int64  `json:"cache_write_input_tokens"`
+	RequestCount          int64  `json:"request_count"`
+	// 其中由 /v1/batches 执行的请求数与 token 数
+	BatchRequestCount int64 `json:"batch_request_count"`
+	BatchTotalTokens  int64 `json:"batch_total_tokens"`
+	ImageCount        int64 `json:"image_count"`
 }
 
 type UsageByModelRow struct {
-	ClientID     string `json:"client_id"`
-	Model        string `json:"model"`
-	InputTokens  int64  `json:"input_tokens"`
-	OutputTokens int64  `json:"output_tokens"`
-	TotalTokens  int64  `json:"total_tokens"`
-	RequestCount int64  `json:"request_count"`
+	ClientID              string `json:"client_id"`
+	Model                 string `json:"model"`
+	InputTokens           int64  `json:"input_tokens"`
+	OutputTokens          int64  `json:"output_tokens"`
+	TotalTokens           int64  `json:"total_tokens"`
+	CacheReadInputTokens  int64  `json:"cache_read_input_tokens"`
+	CacheWriteInputTokens int64  `json:"cache_write_input_tokens"`
+	RequestCount          int64  `json:"request_count"`
+	// 其中由 /v1/batches 执行的请求数与 token 数
+	BatchRequestCount int64 `json:"batch_request_count"`
+	BatchTotalTokens  int64 `json:"batch_total_tokens"`
+	ImageCount        int64 `json:"image_count"`
 }
 
 type CallLogRow struct {
-	RequestID       string `json:"request_id"`
-	ClientID        string `json:"client_id"`
-	Model           string `json:"model"`
-	BedrockModelID  string `json:"bedrock_model_id"`
-	InputTokens     int    `json:"input_tokens"`
-	OutputTokens    int    `json:"output_tokens"`
-	TotalTokens     int    `json:"total_tokens"`
-	LatencyMs       int64  `json:"latency_ms"`
-	StatusCode      int    `json:"status_code"`
-	ErrorMessage    string `json:"error_message"`
-	RequestContent  string `json:"request_content"`
-	ResponseContent string `json:"response_content"`
-	IsStream        bool   `json:"is_stream"`
-	CreatedAt       string `json:"created_at"`
+	RequestID             string `json:"request_id"`
+	ClientID              string `json:"client_id"`
+	Model                 string `json:"model"`
+	BedrockModelID        string `json:"bedrock_model_id"`
+	InputTokens           int    `json:"input_tokens"`
+	OutputTokens          int    `json:"output_tokens"`
+	TotalTokens           int    `json:"total_tokens"`
+	CacheReadInputTokens  int    `json:"cache_read_input_tokens"`
+	CacheWriteInputTokens int    `json:"cache_write_input_tokens"`
+	LatencyMs             int64  `json:"latency_ms"`
+	StatusCode            int    `json:"status_code"`
+	ErrorMessage          string `json:"error_message"`
+	RequestContent        string `json:"request_content"`
+	ResponseContent       string `json:"response_content"`
+	IsStream              bool   `json:"is_stream"`
+	CreatedAt             string `json:"created_at"`
+	GuardrailID           string `json:"guardrail_id"`
+	GuardrailIntervened   bool   `json:"guardrail_intervened"`
+	IsBatch               bool   `json:"is_batch"`
+	ImageCount            int    `json:"image_count"`
+	Attempts              int    `json:"attempts"`
 }
 
 type AWSRuntimeConfig struct {
@@ -80,6 +111,69 @@ type ModelPricingRow struct {
 	ModelID          string  `json:"model_id"`
 	InputPricePer1K  float64 `json:"input_price_per_1k"`
 	OutputPricePer1K float64 `json:"output_price_per_1k"`
+	// 提示缓存读取 / 写入单价（每 1K token），Bedrock 对二者分别计价
+	CacheReadPricePer1K  float64 `json:"cache_read_price_per_1k"`
+	CacheWritePricePer1K float64 `json:"cache_write_price_per_1k"`
+	// 图片生成模型按张计价（/v1/images/generations），与 token 单价相加
+	PricePerImage float64 `json:"price_per_image"`
+}
+
+// PromptCacheRow 是单个模型的提示缓存策略：分别控制是否在 system、tools、最后一个 user 轮次之后插入 cachePoint。
+type PromptCacheRow struct {
+	ModelID       string `json:"model_id"`
+	CacheSystem   bool   `json:"cache_system"`
+	CacheTools    bool   `json:"cache_tools"`
+	CacheMessages bool   `json:"cache_messages"`
+}
+
+// ModelMetadataRow 是管理员对单个模型元数据的覆盖（/v1/models 返回的能力与上下文窗口信息）；
+// 为空（nil / 空字符串 / 空数组）的字段沿用 Bedrock ListFoundationModels 返回的值。
+type ModelMetadataRow struct {
+	ModelID           string   `json:"model_id"`
+	Provider          string   `json:"provider,omitempty"`
+	InputModalities   []string `json:"input_modalities,omitempty"`
+	OutputModalities  []string `json:"output_modalities,omitempty"`
+	SupportsStreaming *bool    `json:"supports_streaming,omitempty"`
+	SupportsTools     *bool    `json:"supports_tools,omitempty"`
+	ContextWindow     *int     `json:"context_window,omitempty"`
+	MaxOutputTokens   *int     `json:"max_output_tokens,omitempty"`
+	LifecycleStatus   string   `json:"lifecycle_status,omitempty"`
+	// Created 是 /v1/models 的 created（Unix 秒）；Bedrock 不提供模型发布时间
+	Created *int64 `json:"created,omitempty"`
+}
+
+// GuardrailRow 把 Bedrock Guardrail 绑定到一个 API key（client_id）或一个模型（model_id），二者只能设置其一；
+// 同一请求两者都命中时以 API key 的配置为准。StreamProcessingMode 为 sync（默认）或 async。
+type GuardrailRow struct {
+	ClientID             string `json:"client_id,omitempty"`
+	ModelID              string `json:"model_id,omitempty"`
+	GuardrailIdentifier  string `json:"guardrail_identifier"`
+	GuardrailVersion     string `json:"guardrail_version"`
+	StreamProcessingMode string `json:"stream_processing_mode,omitempty"`
+}
+
+// UpstreamRow 是一个额外的 Bedrock 上游（账号 / 区域）。Models 为空表示服务所有模型；Priority 越小越先尝试，
+// 同一优先级按 Weight 加权。名为 default 的行不带区域与凭证，只用于调整 /config/aws 主账号的 Priority / Weight / Models。
+type UpstreamRow struct {
+	Name            string   `json:"name"`
+	Region          string   `json:"region,omitempty"`
+	AccessKeyID     string   `json:"access_key_id,omitempty"`
+	SecretAccessKey string   `json:"secret_access_key,omitempty"`
+	SessionToken    string   `json:"session_token,omitempty"`
+	Models          []string `json:"models"`
+	Priority        int      `json:"priority"`
+	Weight          int      `json:"weight"`
+	Disabled        bool     `json:"disabled"`
+}
+
+// DefaultUpstreamName 是 /config/aws 主账号在上游池中的名称。
+const DefaultUpstreamName = "default"
+
+// ModelFallbackRow 是一个模型的降级链：调用 ModelID 遇到限流、模型未就绪或无权限时，按顺序改用 FallbackModels。
+// 降级只看主模型的配置，不会继续展开备选模型自己的降级链。
+type ModelFallbackRow struct {
+	ModelID        string   `json:"model_id"`
+	FallbackModels []string `json:"fallback_models"`
 }
 
 type AdminAuthConfig struct {
@@ -410,9 +504,62 @@ VALUES (?, ?)
 	return tx.Commit()
 }
 
+// ListAllowedRequestFields 返回允许透传到 Bedrock AdditionalModelRequestFields 的字段名。
+func (s *Store) ListAllowedRequestFields(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT field_name
+FROM admin_request_field_allowlist
+ORDER BY field_name ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]string, 0)
+	for rows.Next() {
+		var fieldName string
+		if err := rows.Scan(&fieldName); err != nil {
+			return nil, err
+		}
+		fieldName = strings.TrimSpace(fieldName)
+		if fieldName == "" {
+			continue
+		}
+		result = append(result, fieldName)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) ReplaceAllowedRequestFields(ctx context.Context, fieldNames []string) error {
+	fieldNames = uniqueNonEmpty(fieldNames)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_request_field_allowlist`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, fieldName := range fieldNames {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_request_field_allowlist(field_name, updated_at)
+VALUES (?, ?)
+`, fieldName, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (s *Store) ListModelPricing(ctx context.Context) ([]ModelPricingRow, error) {
 	rows, err := s.db.QueryContext(ctx, `
-SELECT model_id, input_price_per_1k, output_price_per_1k
+SELECT model_id, input_price_per_1k, output_price_per_1k, cache_read_price_per_1k, cache_write_price_per_1k, price_per_image
 FROM admin_model_pricing
 ORDER BY model_id ASC
 `)
@@ -424,7 +571,14 @@ ORDER BY model_id ASC
 	result := make([]ModelPricingRow, 0)
 	for rows.Next() {
 		var row ModelPricingRow
-		if err := rows.Scan(&row.ModelID, &row.InputPricePer1K, &row.OutputPricePer1K); err != nil {
+		if err := rows.Scan(
+			&row.ModelID,
+			&row.InputPricePer1K,
+			&row.OutputPricePer1K,
+			&row.CacheReadPricePer1K,
+			&row.CacheWritePricePer1K,
+			&row.PricePerImage,
+		); err != nil {
 			return nil, err
 		}
 		row.ModelID = strings.TrimSpace(row.ModelID)
@@ -437,6 +591,15 @@ ORDER BY model_id ASC
 		if row.OutputPricePer1K < 0 {
 			row.OutputPricePer1K = 0
 		}
+		if row.CacheReadPricePer1K < 0 {
+			row.CacheReadPricePer1K = 0
+		}
+		if row.CacheWritePricePer1K < 0 {
+			row.CacheWritePricePer1K = 0
+		}
+		if row.PricePerImage < 0 {
+			row.PricePerImage = 0
+		}
 		result = append(result, row)
 	}
 	return result, rows.Err()
@@ -461,9 +624,548 @@ func (s *Store) ReplaceModelPricing(ctx context.Context, pricing []ModelPricingR
 	now := time.Now().UTC().Format(time.RFC3339Nano)
 	for _, item := range pricing {
 		if _, err := tx.ExecContext(ctx, `
-INSERT INTO admin_model_pricing(model_id, input_price_per_1k, output_price_per_1k, updated_at)
-VALUES (?, ?, ?, ?)
-`, item.ModelID, item.InputPricePer1K, item.OutputPricePer1K, now); err != nil {
+INSERT INTO admin_model_pricing(
+model_id, input_price_per_1k, output_price_per_1k, cache_read_price_per_1k, cache_write_price_per_1k, price_per_image, updated_at
+) VALUES (?, ?, ?, ?, ?, ?, ?)
+`, item.ModelID, item.InputPricePer1K, item.OutputPricePer1K, item.CacheReadPricePer1K, item.CacheWritePricePer1K, item.PricePerImage, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListPromptCacheModels 返回按模型配置的提示缓存策略。
+func (s *Store) ListPromptCacheModels(ctx context.Context) ([]PromptCacheRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT model_id, cache_system, cache_tools, cache_messages
+FROM admin_prompt_cache_models
+ORDER BY model_id ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]PromptCacheRow, 0)
+	for rows.Next() {
+		var row PromptCacheRow
+		var cacheSystem, cacheTools, cacheMessages int
+		if err := rows.Scan(&row.ModelID, &cacheSystem, &cacheTools, &cacheMessages); err != nil {
+			return nil, err
+		}
+		row.ModelID = strings.TrimSpace(row.ModelID)
+		if row.ModelID == "" {
+			continue
+		}
+		row.CacheSystem = cacheSystem == 1
+		row.CacheTools = cacheTools == 1
+		row.CacheMessages = cacheMessages == 1
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) ReplacePromptCacheModels(ctx context.Context, items []PromptCacheRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_prompt_cache_models`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, item := range items {
+		modelID := strings.TrimSpace(item.ModelID)
+		if modelID == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_prompt_cache_models(model_id, cache_system, cache_tools, cache_messages, updated_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(model_id) DO UPDATE SET
+cache_system = excluded.cache_system,
+cache_tools = excluded.cache_tools,
+cache_messages = excluded.cache_messages,
+updated_at = excluded.updated_at
+`, modelID, boolToInt(item.CacheSystem), boolToInt(item.CacheTools), boolToInt(item.CacheMessages), now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListGuardrails 返回按 API key / 模型绑定的 Bedrock Guardrail。
+func (s *Store) ListGuardrails(ctx context.Context) ([]GuardrailRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT client_id, model_id, guardrail_identifier, guardrail_version, stream_processing_mode
+FROM admin_guardrails
+ORDER BY client_id ASC, model_id ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]GuardrailRow, 0)
+	for rows.Next() {
+		var row GuardrailRow
+		if err := rows.Scan(&row.ClientID, &row.ModelID, &row.GuardrailIdentifier, &row.GuardrailVersion, &row.StreamProcessingMode); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) ReplaceGuardrails(ctx context.Context, items []GuardrailRow) error {
+	items, err := normalizeGuardrails(items)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_guardrails`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_guardrails(client_id, model_id, guardrail_identifier, guardrail_version, stream_processing_mode, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`, item.ClientID, item.ModelID, item.GuardrailIdentifier, item.GuardrailVersion, item.StreamProcessingMode, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListUpstreams 返回额外的 Bedrock 上游，按名称排序。
+func (s *Store) ListUpstreams(ctx context.Context) ([]UpstreamRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT name, region, access_key_id, secret_access_key, session_token, models_json, priority, weight, is_disabled
+FROM admin_upstreams
+ORDER BY name ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]UpstreamRow, 0)
+	for rows.Next() {
+		var (
+			row          UpstreamRow
+			modelsJSON   string
+			disabledFlag int
+		)
+		if err := rows.Scan(
+			&row.Name,
+			&row.Region,
+			&row.AccessKeyID,
+			&row.SecretAccessKey,
+			&row.SessionToken,
+			&modelsJSON,
+			&row.Priority,
+			&row.Weight,
+			&disabledFlag,
+		); err != nil {
+			return nil, err
+		}
+		row.Disabled = disabledFlag == 1
+		if strings.TrimSpace(modelsJSON) != "" {
+			_ = json.Unmarshal([]byte(modelsJSON), &row.Models)
+		}
+		if row.Models == nil {
+			row.Models = []string{}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ReplaceUpstreams 整体替换额外的 Bedrock 上游。
+func (s *Store) ReplaceUpstreams(ctx context.Context, items []UpstreamRow) error {
+	items, err := normalizeUpstreams(items)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_upstreams`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, item := range items {
+		modelsJSON, err := json.Marshal(item.Models)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_upstreams(
+name, region, access_key_id, secret_access_key, session_token, models_json, priority, weight, is_disabled, updated_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`,
+			item.Name,
+			item.Region,
+			item.AccessKeyID,
+			item.SecretAccessKey,
+			item.SessionToken,
+			string(modelsJSON),
+			item.Priority,
+			item.Weight,
+			boolToInt(item.Disabled),
+			now,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListModelFallbacks 返回按模型配置的降级链，按模型 ID 排序。
+func (s *Store) ListModelFallbacks(ctx context.Context) ([]ModelFallbackRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT model_id, fallback_models_json
+FROM admin_model_fallbacks
+ORDER BY model_id ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]ModelFallbackRow, 0)
+	for rows.Next() {
+		var (
+			row        ModelFallbackRow
+			modelsJSON string
+		)
+		if err := rows.Scan(&row.ModelID, &modelsJSON); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(modelsJSON) != "" {
+			_ = json.Unmarshal([]byte(modelsJSON), &row.FallbackModels)
+		}
+		if row.FallbackModels == nil {
+			row.FallbackModels = []string{}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ReplaceModelFallbacks 整体替换模型降级链。
+func (s *Store) ReplaceModelFallbacks(ctx context.Context, items []ModelFallbackRow) error {
+	items, err := normalizeModelFallbacks(items)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_model_fallbacks`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, item := range items {
+		modelsJSON, err := json.Marshal(item.FallbackModels)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_model_fallbacks(model_id, fallback_models_json, updated_at)
+VALUES (?, ?, ?)
+`, item.ModelID, string(modelsJSON), now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func normalizeModelFallbacks(items []ModelFallbackRow) ([]ModelFallbackRow, error) {
+	out := make([]ModelFallbackRow, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		item.ModelID = strings.TrimSpace(item.ModelID)
+		if item.ModelID == "" {
+			return nil, fmt.Errorf("model_id is required for model fallback")
+		}
+		key := strings.ToLower(item.ModelID)
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("duplicate model fallback for %q", item.ModelID)
+		}
+		seen[key] = struct{}{}
+
+		models := make([]string, 0, len(item.FallbackModels))
+		seenModels := map[string]struct{}{key: {}}
+		for _, modelID := range item.FallbackModels {
+			modelID = strings.TrimSpace(modelID)
+			if modelID == "" {
+				continue
+			}
+			if _, ok := seenModels[strings.ToLower(modelID)]; ok {
+				return nil, fmt.Errorf("fallback chain for %q repeats model %q", item.ModelID, modelID)
+			}
+			seenModels[strings.ToLower(modelID)] = struct{}{}
+			models = append(models, modelID)
+		}
+		if len(models) == 0 {
+			return nil, fmt.Errorf("fallback_models is required for %q", item.ModelID)
+		}
+		item.FallbackModels = models
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func normalizeUpstreams(items []UpstreamRow) ([]UpstreamRow, error) {
+	out := make([]UpstreamRow, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		item.Name = strings.TrimSpace(item.Name)
+		item.Region = strings.TrimSpace(item.Region)
+		item.AccessKeyID = strings.TrimSpace(item.AccessKeyID)
+		item.SecretAccessKey = strings.TrimSpace(item.SecretAccessKey)
+		item.SessionToken = strings.TrimSpace(item.SessionToken)
+
+		if item.Name == "" {
+			return nil, fmt.Errorf("name is required for upstream")
+		}
+		key := strings.ToLower(item.Name)
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("duplicate upstream %q", item.Name)
+		}
+		seen[key] = struct{}{}
+
+		if key == DefaultUpstreamName {
+			// 主账号的区域与凭证来自 /config/aws
+			item.Name = DefaultUpstreamName
+			if item.Region != "" || item.AccessKeyID != "" || item.SecretAccessKey != "" || item.SessionToken != "" {
+				return nil, fmt.Errorf("upstream %q uses the aws config; region and credentials must be empty", item.Name)
+			}
+		} else {
+			if item.Region == "" {
+				return nil, fmt.Errorf("region is required for upstream %q", item.Name)
+			}
+			if (item.AccessKeyID == "") != (item.SecretAccessKey == "") {
+				return nil, fmt.Errorf("access_key_id and secret_access_key must be set together for upstream %q", item.Name)
+			}
+		}
+		if item.Priority < 0 {
+			return nil, fmt.Errorf("priority must be >= 0 for upstream %q", item.Name)
+		}
+		if item.Weight < 0 {
+			return nil, fmt.Errorf("weight must be >= 0 for upstream %q", item.Name)
+		}
+		if item.Weight == 0 {
+			item.Weight = 1
+		}
+
+		models := make([]string, 0, len(item.Models))
+		seenModels := make(map[string]struct{}, len(item.Models))
+		for _, modelID := range item.Models {
+			modelID = strings.TrimSpace(modelID)
+			if modelID == "" {
+				continue
+			}
+			if _, ok := seenModels[strings.ToLower(modelID)]; ok {
+				continue
+			}
+			seenModels[strings.ToLower(modelID)] = struct{}{}
+			models = append(models, modelID)
+		}
+		item.Models = models
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func normalizeGuardrails(items []GuardrailRow) ([]GuardrailRow, error) {
+	out := make([]GuardrailRow, 0, len(items))
+	seen := make(map[[2]string]struct{}, len(items))
+	for _, item := range items {
+		item.ClientID = strings.TrimSpace(item.ClientID)
+		item.ModelID = strings.TrimSpace(item.ModelID)
+		item.GuardrailIdentifier = strings.TrimSpace(item.GuardrailIdentifier)
+		item.GuardrailVersion = strings.TrimSpace(item.GuardrailVersion)
+		item.StreamProcessingMode = strings.ToLower(strings.TrimSpace(item.StreamProcessingMode))
+
+		if (item.ClientID == "") == (item.ModelID == "") {
+			return nil, fmt.Errorf("exactly one of client_id or model_id is required for guardrail %q", item.GuardrailIdentifier)
+		}
+		target := item.ClientID + item.ModelID
+		if item.GuardrailIdentifier == "" {
+			return nil, fmt.Errorf("guardrail_identifier is required for %q", target)
+		}
+		if item.GuardrailVersion == "" {
+			return nil, fmt.Errorf("guardrail_version is required for %q (use DRAFT or a version number)", target)
+		}
+		if item.StreamProcessingMode == "" {
+			item.StreamProcessingMode = "sync"
+		}
+		if item.StreamProcessingMode != "sync" && item.StreamProcessingMode != "async" {
+			return nil, fmt.Errorf("stream_processing_mode must be sync or async for %q", target)
+		}
+		key := [2]string{item.ClientID, item.ModelID}
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("duplicate guardrail for %q", target)
+		}
+		seen[key] = struct{}{}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// ListModelMetadataOverrides 返回管理员配置的模型元数据覆盖。
+func (s *Store) ListModelMetadataOverrides(ctx context.Context) ([]ModelMetadataRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT
+model_id, provider, input_modalities_json, output_modalities_json,
+supports_streaming, supports_tools, context_window, max_output_tokens, lifecycle_status, created
+FROM admin_model_metadata
+ORDER BY model_id ASC
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]ModelMetadataRow, 0)
+	for rows.Next() {
+		var row ModelMetadataRow
+		var inputModalitiesJSON, outputModalitiesJSON string
+		var supportsStreaming, supportsTools, contextWindow, maxOutputTokens, created sql.NullInt64
+		if err := rows.Scan(
+			&row.ModelID,
+			&row.Provider,
+			&inputModalitiesJSON,
+			&outputModalitiesJSON,
+			&supportsStreaming,
+			&supportsTools,
+			&contextWindow,
+			&maxOutputTokens,
+			&row.LifecycleStatus,
+			&created,
+		); err != nil {
+			return nil, err
+		}
+		row.ModelID = strings.TrimSpace(row.ModelID)
+		if row.ModelID == "" {
+			continue
+		}
+		_ = json.Unmarshal([]byte(inputModalitiesJSON), &row.InputModalities)
+		_ = json.Unmarshal([]byte(outputModalitiesJSON), &row.OutputModalities)
+		if supportsStreaming.Valid {
+			value := supportsStreaming.Int64 == 1
+			row.SupportsStreaming = &value
+		}
+		if supportsTools.Valid {
+			value := supportsTools.Int64 == 1
+			row.SupportsTools = &value
+		}
+		if contextWindow.Valid {
+			value := int(contextWindow.Int64)
+			row.ContextWindow = &value
+		}
+		if maxOutputTokens.Valid {
+			value := int(maxOutputTokens.Int64)
+			row.MaxOutputTokens = &value
+		}
+		if created.Valid {
+			value := created.Int64
+			row.Created = &value
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) ReplaceModelMetadataOverrides(ctx context.Context, items []ModelMetadataRow) error {
+	for _, item := range items {
+		modelID := strings.TrimSpace(item.ModelID)
+		if item.ContextWindow != nil && *item.ContextWindow <= 0 {
+			return fmt.Errorf("context_window must be > 0 for model %q", modelID)
+		}
+		if item.MaxOutputTokens != nil && *item.MaxOutputTokens <= 0 {
+			return fmt.Errorf("max_output_tokens must be > 0 for model %q", modelID)
+		}
+		if item.Created != nil && *item.Created <= 0 {
+			return fmt.Errorf("created must be a positive unix timestamp for model %q", modelID)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admin_model_metadata`); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, item := range items {
+		modelID := strings.TrimSpace(item.ModelID)
+		if modelID == "" {
+			continue
+		}
+		inputModalitiesJSON, _ := json.Marshal(normalizeModalities(item.InputModalities))
+		outputModalitiesJSON, _ := json.Marshal(normalizeModalities(item.OutputModalities))
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO admin_model_metadata(
+model_id, provider, input_modalities_json, output_modalities_json,
+supports_streaming, supports_tools, context_window, max_output_tokens, lifecycle_status, created, updated_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(model_id) DO UPDATE SET
+provider = excluded.provider,
+input_modalities_json = excluded.input_modalities_json,
+output_modalities_json = excluded.output_modalities_json,
+supports_streaming = excluded.supports_streaming,
+supports_tools = excluded.supports_tools,
+context_window = excluded.context_window,
+max_output_tokens = excluded.max_output_tokens,
+lifecycle_status = excluded.lifecycle_status,
+created = excluded.created,
+updated_at = excluded.updated_at
+`,
+			modelID,
+			strings.TrimSpace(item.Provider),
+			string(inputModalitiesJSON),
+			string(outputModalitiesJSON),
+			nullableBool(item.SupportsStreaming),
+			nullableBool(item.SupportsTools),
+			nullableInt(item.ContextWindow),
+			nullableInt(item.MaxOutputTokens),
+			strings.ToUpper(strings.TrimSpace(item.LifecycleStatus)),
+			nullableInt64(item.Created),
+			now,
+		); err != nil {
 			return err
 		}
 	}
@@ -471,6 +1173,44 @@ VALUES (?, ?, ?, ?)
 	return tx.Commit()
 }
 
+func nullableBool(value *bool) any {
+	if value == nil {
+		return nil
+	}
+	return boolToInt(*value)
+}
+
+func nullableInt(value *int) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func nullableInt64(value *int64) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func normalizeModalities(values []string) []string {
+	out := make([]string, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		out = append(out, value)
+	}
+	return out
+}
+
 func (s *Store) ListClients(ctx context.Context) ([]config.ClientConfig, error) {
 	rows, err := s.db.QueryContext(ctx, `
 SELECT id, name, api_key, max_requests_per_minute, max_concurrent, allowed_models_json, is_disabled
@@ -626,7 +1366,9 @@ func (s *Store) DeleteModelMapping(ctx context.Context, alias string) error {
 
 func (s *Store) GetUsage(ctx context.Context, fromDate, toDate, clientID string) ([]UsageRow, error) {
 	base := `
-SELECT client_id, SUM(input_tokens), SUM(output_tokens), SUM(total_tokens), SUM(request_count)
+SELECT client_id, SUM(input_tokens), SUM(output_tokens), SUM(total_tokens),
+SUM(cache_read_input_tokens), SUM(cache_write_input_tokens), SUM(request_count),
+SUM(batch_request_count), SUM(batch_total_tokens), SUM(image_count)
 FROM usage_daily
 WHERE usage_date BETWEEN ? AND ?
 `
@@ -651,7 +1393,12 @@ WHERE usage_date BETWEEN ? AND ?
 			&row.InputTokens,
 			&row.OutputTokens,
 			&row.TotalTokens,
+			&row.CacheReadInputTokens,
+			&row.CacheWriteInputTokens,
 			&row.RequestCount,
+			&row.BatchRequestCount,
+			&row.BatchTotalTokens,
+			&row.ImageCount,
 		); err != nil {
 			return nil, err
 		}
@@ -662,7 +1409,9 @@ WHERE usage_date BETWEEN ? AND ?
 
 func (s *Store) GetUsageByModel(ctx context.Context, fromDate, toDate, clientID string) ([]UsageByModelRow, error) {
 	base := `
-SELECT client_id, model, SUM(input_tokens), SUM(output_tokens), SUM(total_tokens), SUM(request_count)
+SELECT client_id, model, SUM(input_tokens), SUM(output_tokens), SUM(total_tokens),
+SUM(cache_read_input_tokens), SUM(cache_write_input_tokens), SUM(request_count),
+SUM(batch_request_count), SUM(batch_total_tokens), SUM(image_count)
 FROM usage_model_daily
 WHERE usage_date BETWEEN ? AND ?
 `
@@ -688,7 +1437,12 @@ WHERE usage_date BETWEEN ? AND ?
 			&row.InputTokens,
 			&row.OutputTokens,
 			&row.TotalTokens,
+			&row.CacheReadInputTokens,
+			&row.CacheWriteInputTokens,
 			&row.RequestCount,
+			&row.BatchRequestCount,
+			&row.BatchTotalTokens,
+			&row.ImageCount,
 		); err != nil {
 			return nil, err
 		}
@@ -697,13 +1451,64 @@ WHERE usage_date BETWEEN ? AND ?
 	return result, rows.Err()
 }
 
+// RekeyModelUsage 把旧版本按请求模型名（别名、default）汇总的 usage_model_daily 行并入 targets 指向的
+// Bedrock model id（名称不区分大小写）。启动时执行一次即可固定历史用量的计价模型。
+func (s *Store) RekeyModelUsage(ctx context.Context, targets map[string]string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for from, to := range targets {
+		from = strings.ToLower(strings.TrimSpace(from))
+		to = strings.TrimSpace(to)
+		if from == "" || to == "" || from == strings.ToLower(to) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO usage_model_daily(
+client_id, model, usage_date, input_tokens, output_tokens, total_tokens,
+cache_read_input_tokens, cache_write_input_tokens, request_count,
+batch_request_count, batch_total_tokens, image_count, last_seen_at
+)
+SELECT client_id, ?, usage_date, input_tokens, output_tokens, total_tokens,
+cache_read_input_tokens, cache_write_input_tokens, request_count,
+batch_request_count, batch_total_tokens, image_count, last_seen_at
+FROM usage_model_daily
+WHERE lower(model) = ?
+ON CONFLICT(client_id, model, usage_date)
+DO UPDATE SET
+input_tokens = input_tokens + excluded.input_tokens,
+output_tokens = output_tokens + excluded.output_tokens,
+total_tokens = total_tokens + excluded.total_tokens,
+cache_read_input_tokens = cache_read_input_tokens + excluded.cache_read_input_tokens,
+cache_write_input_tokens = cache_write_input_tokens + excluded.cache_write_input_tokens,
+request_count = request_count + excluded.request_count,
+batch_request_count = batch_request_count + excluded.batch_request_count,
+batch_total_tokens = batch_total_tokens + excluded.batch_total_tokens,
+image_count = image_count + excluded.image_count,
+last_seen_at = MAX(last_seen_at, excluded.last_seen_at)
+`, to, from); err != nil {
+			return fmt.Errorf("rekey usage for %s: %w", from, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM usage_model_daily WHERE lower(model) = ?`, from); err != nil {
+			return fmt.Errorf("rekey usage for %s: %w", from, err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *Store) GetTotalCost(ctx context.Context) (float64, error) {
 	row := s.db.QueryRowContext(ctx, `
 SELECT COALESCE(SUM(
 (
 CAST(umd.input_tokens AS REAL) * COALESCE(mp.input_price_per_1k, 0) +
-CAST(umd.output_tokens AS REAL) * COALESCE(mp.output_price_per_1k, 0)
-) / 1000.0
+CAST(umd.output_tokens AS REAL) * COALESCE(mp.output_price_per_1k, 0) +
+CAST(umd.cache_read_input_tokens AS REAL) * COALESCE(mp.cache_read_price_per_1k, 0) +
+CAST(umd.cache_write_input_tokens AS REAL) * COALESCE(mp.cache_write_price_per_1k, 0)
+) / 1000.0 +
+CAST(umd.image_count AS REAL) * COALESCE(mp.price_per_image, 0)
 ), 0)
 FROM usage_model_daily AS umd
 LEFT JOIN admin_model_pricing AS mp ON mp.model_id = umd.model
@@ -748,7 +1553,9 @@ func (s *Store) GetCalls(ctx context.Context, limit int, offset int, clientID st
 	base := `
 SELECT
 request_id, client_id, model, bedrock_model_id, input_tokens, output_tokens, total_tokens,
-latency_ms, status_code, error_message, request_content, response_content, is_stream, created_at
+cache_read_input_tokens, cache_write_input_tokens,
+latency_ms, status_code, error_message, request_content, response_content, is_stream, created_at,
+guardrail_id, guardrail_intervened, is_batch, image_count, attempts
 FROM call_logs
 `
 	args := []any{}
@@ -769,7 +1576,7 @@ FROM call_logs
 	result := make([]CallLogRow, 0)
 	for rows.Next() {
 		var row CallLogRow
-		var streamFlag int
+		var streamFlag, guardrailFlag, batchFlag int
 		if err := rows.Scan(
 			&row.RequestID,
 			&row.ClientID,
@@ -778,6 +1585,8 @@ FROM call_logs
 			&row.InputTokens,
 			&row.OutputTokens,
 			&row.TotalTokens,
+			&row.CacheReadInputTokens,
+			&row.CacheWriteInputTokens,
 			&row.LatencyMs,
 			&row.StatusCode,
 			&row.ErrorMessage,
@@ -785,10 +1594,17 @@ FROM call_logs
 			&row.ResponseContent,
 			&streamFlag,
 			&row.CreatedAt,
+			&row.GuardrailID,
+			&guardrailFlag,
+			&batchFlag,
+			&row.ImageCount,
+			&row.Attempts,
 		); err != nil {
 			return nil, err
 		}
 		row.IsStream = streamFlag == 1
+		row.GuardrailIntervened = guardrailFlag == 1
+		row.IsBatch = batchFlag == 1
 		result = append(result, row)
 	}
 	return result, rows.Err()
@@ -821,6 +1637,11 @@ func (s *Store) insertRecord(record CallRecord) error {
 	if strings.TrimSpace(record.Model) == "" {
 		record.Model = "default"
 	}
+	// 按模型的用量以实际调用的 Bedrock model id 汇总，计价不受别名事后改指向影响
+	usageModel := strings.TrimSpace(record.BedrockModelID)
+	if usageModel == "" {
+		usageModel = record.Model
+	}
 
 	tx, err := s.db.BeginTx(context.Background(), nil)
 	if err != nil {
@@ -837,8 +1658,10 @@ func (s *Store) insertRecord(record CallRecord) error {
 	_, err = tx.Exec(`
 INSERT INTO call_logs(
 request_id, client_id, model, bedrock_model_id, input_tokens, output_tokens, total_tokens,
-latency_ms, status_code, error_message, request_content, response_content, is_stream, created_at
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+cache_read_input_tokens, cache_write_input_tokens,
+latency_ms, status_code, error_message, request_content, response_content, is_stream, created_at,
+guardrail_id, guardrail_intervened, is_batch, image_count, attempts
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `,
 		record.RequestID,
 		record.ClientID,
@@ -847,6 +1670,8 @@ latency_ms, status_code, error_message, request_content, response_content, is_st
 		record.InputTokens,
 		record.OutputTokens,
 		record.TotalTokens,
+		record.CacheReadInputTokens,
+		record.CacheWriteInputTokens,
 		record.LatencyMs,
 		record.StatusCode,
 		record.ErrorMessage,
@@ -854,22 +1679,38 @@ latency_ms, status_code, error_message, request_content, response_content, is_st
 		record.ResponseContent,
 		streamFlag,
 		createdAt,
+		record.GuardrailID,
+		boolToInt(record.GuardrailIntervened),
+		boolToInt(record.IsBatch),
+		record.ImageCount,
+		record.Attempts,
 	)
 	if err != nil {
 		return err
 	}
 
 	usageDate := record.CreatedAt.UTC().Format("2006-01-02")
+	batchRequests, batchTokens := 0, 0
+	if record.IsBatch {
+		batchRequests, batchTokens = 1, record.TotalTokens
+	}
 	_, err = tx.Exec(`
 INSERT INTO usage_daily(
-client_id, usage_date, input_tokens, output_tokens, total_tokens, request_count, last_seen_at
-) VALUES (?, ?, ?, ?, ?, 1, ?)
+client_id, usage_date, input_tokens, output_tokens, total_tokens,
+cache_read_input_tokens, cache_write_input_tokens, request_count,
+batch_request_count, batch_total_tokens, image_count, last_seen_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
 ON CONFLICT(client_id, usage_date)
 DO UPDATE SET
 input_tokens = input_tokens + excluded.input_tokens,
 output_tokens = output_tokens + excluded.output_tokens,
 total_tokens = total_tokens + excluded.total_tokens,
+cache_read_input_tokens = cache_read_input_tokens + excluded.cache_read_input_tokens,
+cache_write_input_tokens = cache_write_input_tokens + excluded.cache_write_input_tokens,
 request_count = request_count + 1,
+batch_request_count = batch_request_count + excluded.batch_request_count,
+batch_total_tokens = batch_total_tokens + excluded.batch_total_tokens,
+image_count = image_count + excluded.image_count,
 last_seen_at = excluded.last_seen_at
 `,
 		record.ClientID,
@@ -877,6 +1718,11 @@ last_seen_at = excluded.last_seen_at
 		record.InputTokens,
 		record.OutputTokens,
 		record.TotalTokens,
+		record.CacheReadInputTokens,
+		record.CacheWriteInputTokens,
+		batchRequests,
+		batchTokens,
+		record.ImageCount,
 		createdAt,
 	)
 	if err != nil {
@@ -885,22 +1731,34 @@ last_seen_at = excluded.last_seen_at
 
 	_, err = tx.Exec(`
 INSERT INTO usage_model_daily(
-client_id, model, usage_date, input_tokens, output_tokens, total_tokens, request_count, last_seen_at
-) VALUES (?, ?, ?, ?, ?, ?, 1, ?)
+client_id, model, usage_date, input_tokens, output_tokens, total_tokens,
+cache_read_input_tokens, cache_write_input_tokens, request_count,
+batch_request_count, batch_total_tokens, image_count, last_seen_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
 ON CONFLICT(client_id, model, usage_date)
 DO UPDATE SET
 input_tokens = input_tokens + excluded.input_tokens,
 output_tokens = output_tokens + excluded.output_tokens,
 total_tokens = total_tokens + excluded.total_tokens,
+cache_read_input_tokens = cache_read_input_tokens + excluded.cache_read_input_tokens,
+cache_write_input_tokens = cache_write_input_tokens + excluded.cache_write_input_tokens,
 request_count = request_count + 1,
+batch_request_count = batch_request_count + excluded.batch_request_count,
+batch_total_tokens = batch_total_tokens + excluded.batch_total_tokens,
+image_count = image_count + excluded.image_count,
 last_seen_at = excluded.last_seen_at
 `,
 		record.ClientID,
-		record.Model,
+		usageModel,
 		usageDate,
 		record.InputTokens,
 		record.OutputTokens,
 		record.TotalTokens,
+		record.CacheReadInputTokens,
+		record.CacheWriteInputTokens,
+		batchRequests,
+		batchTokens,
+		record.ImageCount,
 		createdAt,
 	)
 	if err != nil {
@@ -921,13 +1779,20 @@ bedrock_model_id TEXT NOT NULL,
 input_tokens INTEGER NOT NULL DEFAULT 0,
 output_tokens INTEGER NOT NULL DEFAULT 0,
 total_tokens INTEGER NOT NULL DEFAULT 0,
+cache_read_input_tokens INTEGER NOT NULL DEFAULT 0,
+cache_write_input_tokens INTEGER NOT NULL DEFAULT 0,
 latency_ms INTEGER NOT NULL DEFAULT 0,
 status_code INTEGER NOT NULL DEFAULT 0,
 error_message TEXT NOT NULL DEFAULT '',
 request_content TEXT NOT NULL DEFAULT '',
 response_content TEXT NOT NULL DEFAULT '',
 is_stream INTEGER NOT NULL DEFAULT 0,
-created_at TEXT NOT NULL
+created_at TEXT NOT NULL,
+guardrail_id TEXT NOT NULL DEFAULT '',
+guardrail_intervened INTEGER NOT NULL DEFAULT 0,
+is_batch INTEGER NOT NULL DEFAULT 0,
+image_count INTEGER NOT NULL DEFAULT 0,
+attempts INTEGER NOT NULL DEFAULT 0
 )`,
 		`CREATE INDEX IF NOT EXISTS idx_call_logs_client_created
 ON call_logs(client_id, created_at DESC)`,
@@ -939,7 +1804,12 @@ usage_date TEXT NOT NULL,
 input_tokens INTEGER NOT NULL DEFAULT 0,
 output_tokens INTEGER NOT NULL DEFAULT 0,
 total_tokens INTEGER NOT NULL DEFAULT 0,
+cache_read_input_tokens INTEGER NOT NULL DEFAULT 0,
+cache_write_input_tokens INTEGER NOT NULL DEFAULT 0,
 request_count INTEGER NOT NULL DEFAULT 0,
+batch_request_count INTEGER NOT NULL DEFAULT 0,
+batch_total_tokens INTEGER NOT NULL DEFAULT 0,
+image_count INTEGER NOT NULL DEFAULT 0,
 last_seen_at TEXT NOT NULL,
 PRIMARY KEY (client_id, usage_date)
 )`,
@@ -950,7 +1820,12 @@ usage_date TEXT NOT NULL,
 input_tokens INTEGER NOT NULL DEFAULT 0,
 output_tokens INTEGER NOT NULL DEFAULT 0,
 total_tokens INTEGER NOT NULL DEFAULT 0,
+cache_read_input_tokens INTEGER NOT NULL DEFAULT 0,
+cache_write_input_tokens INTEGER NOT NULL DEFAULT 0,
 request_count INTEGER NOT NULL DEFAULT 0,
+batch_request_count INTEGER NOT NULL DEFAULT 0,
+batch_total_tokens INTEGER NOT NULL DEFAULT 0,
+image_count INTEGER NOT NULL DEFAULT 0,
 last_seen_at TEXT NOT NULL,
 PRIMARY KEY (client_id, model, usage_date)
 )`,
@@ -983,12 +1858,126 @@ updated_at TEXT NOT NULL
 		`CREATE TABLE IF NOT EXISTS admin_enabled_models (
 model_id TEXT PRIMARY KEY,
 updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_request_field_allowlist (
+field_name TEXT PRIMARY KEY,
+updated_at TEXT NOT NULL
 )`,
 		`CREATE TABLE IF NOT EXISTS admin_model_pricing (
 model_id TEXT PRIMARY KEY,
 input_price_per_1k REAL NOT NULL DEFAULT 0,
 output_price_per_1k REAL NOT NULL DEFAULT 0,
+cache_read_price_per_1k REAL NOT NULL DEFAULT 0,
+cache_write_price_per_1k REAL NOT NULL DEFAULT 0,
+price_per_image REAL NOT NULL DEFAULT 0,
+updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_model_metadata (
+model_id TEXT PRIMARY KEY,
+provider TEXT NOT NULL DEFAULT '',
+input_modalities_json TEXT NOT NULL DEFAULT '[]',
+output_modalities_json TEXT NOT NULL DEFAULT '[]',
+supports_streaming INTEGER,
+supports_tools INTEGER,
+context_window INTEGER,
+max_output_tokens INTEGER,
+lifecycle_status TEXT NOT NULL DEFAULT '',
+created INTEGER,
+updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_guardrails (
+client_id TEXT NOT NULL DEFAULT '',
+model_id TEXT NOT NULL DEFAULT '',
+guardrail_identifier TEXT NOT NULL,
+guardrail_version TEXT NOT NULL,
+stream_processing_mode TEXT NOT NULL DEFAULT 'sync',
+updated_at TEXT NOT NULL,
+PRIMARY KEY (client_id, model_id)
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_upstreams (
+name TEXT PRIMARY KEY,
+region TEXT NOT NULL DEFAULT '',
+access_key_id TEXT NOT NULL DEFAULT '',
+secret_access_key TEXT NOT NULL DEFAULT '',
+session_token TEXT NOT NULL DEFAULT '',
+models_json TEXT NOT NULL DEFAULT '[]',
+priority INTEGER NOT NULL DEFAULT 0,
+weight INTEGER NOT NULL DEFAULT 1,
+is_disabled INTEGER NOT NULL DEFAULT 0,
 updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_model_fallbacks (
+model_id TEXT PRIMARY KEY,
+fallback_models_json TEXT NOT NULL DEFAULT '[]',
+updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS admin_prompt_cache_models (
+model_id TEXT PRIMARY KEY,
+cache_system INTEGER NOT NULL DEFAULT 0,
+cache_tools INTEGER NOT NULL DEFAULT 0,
+cache_messages INTEGER NOT NULL DEFAULT 0,
+updated_at TEXT NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS responses (
+response_id TEXT PRIMARY KEY,
+client_id TEXT NOT NULL,
+model TEXT NOT NULL,
+previous_response_id TEXT NOT NULL DEFAULT '',
+input_items_json TEXT NOT NULL DEFAULT '[]',
+output_items_json TEXT NOT NULL DEFAULT '[]',
+messages_json TEXT NOT NULL DEFAULT '[]',
+response_json TEXT NOT NULL DEFAULT '{}',
+status TEXT NOT NULL DEFAULT 'completed',
+created_at TEXT NOT NULL,
+expires_at TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_responses_expires
+ON responses(expires_at)`,
+		`CREATE TABLE IF NOT EXISTS files (
+file_id TEXT PRIMARY KEY,
+client_id TEXT NOT NULL,
+purpose TEXT NOT NULL,
+filename TEXT NOT NULL,
+bytes INTEGER NOT NULL DEFAULT 0,
+content BLOB NOT NULL,
+created_at TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_files_client_created
+ON files(client_id, created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS batches (
+batch_id TEXT PRIMARY KEY,
+client_id TEXT NOT NULL,
+endpoint TEXT NOT NULL,
+input_file_id TEXT NOT NULL,
+completion_window TEXT NOT NULL,
+status TEXT NOT NULL,
+output_file_id TEXT NOT NULL DEFAULT '',
+error_file_id TEXT NOT NULL DEFAULT '',
+metadata_json TEXT NOT NULL DEFAULT '{}',
+request_total INTEGER NOT NULL DEFAULT 0,
+request_completed INTEGER NOT NULL DEFAULT 0,
+request_failed INTEGER NOT NULL DEFAULT 0,
+created_at TEXT NOT NULL,
+in_progress_at TEXT NOT NULL DEFAULT '',
+finalizing_at TEXT NOT NULL DEFAULT '',
+completed_at TEXT NOT NULL DEFAULT '',
+failed_at TEXT NOT NULL DEFAULT '',
+expired_at TEXT NOT NULL DEFAULT '',
+cancelling_at TEXT NOT NULL DEFAULT '',
+cancelled_at TEXT NOT NULL DEFAULT '',
+expires_at TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_batches_client_created
+ON batches(client_id, created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS batch_items (
+batch_id TEXT NOT NULL,
+line_index INTEGER NOT NULL,
+custom_id TEXT NOT NULL,
+body_json TEXT NOT NULL,
+status TEXT NOT NULL DEFAULT 'pending',
+attempts INTEGER NOT NULL DEFAULT 0,
+result_json TEXT NOT NULL DEFAULT '',
+PRIMARY KEY (batch_id, line_index)
 )`,
 		`CREATE TABLE IF NOT EXISTS admin_auth_config (
 id INTEGER PRIMARY KEY CHECK (id = 1),
@@ -1013,6 +2002,27 @@ updated_at TEXT NOT NULL
 	if err := s.migrateModelPricingColumns(ctx); err != nil {
 		return err
 	}
+	if err := s.migrateCacheTokenColumns(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateResponsesColumns(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateGuardrailColumns(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateBatchColumns(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateImageColumns(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateAttemptsColumn(ctx); err != nil {
+		return err
+	}
+	if err := s.migrateModelMetadataColumns(ctx); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1051,6 +2061,22 @@ func (s *Store) migrateModelPricingColumns(ctx context.Context) error {
 		}
 	}
 
+	if _, ok := columns["cache_read_price_per_1k"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE admin_model_pricing ADD COLUMN cache_read_price_per_1k REAL NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("migrate model pricing cache read column: %w", err)
+		}
+	}
+	if _, ok := columns["cache_write_price_per_1k"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE admin_model_pricing ADD COLUMN cache_write_price_per_1k REAL NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("migrate model pricing cache write column: %w", err)
+		}
+	}
+	if _, ok := columns["price_per_image"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE admin_model_pricing ADD COLUMN price_per_image REAL NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("migrate model pricing price per image column: %w", err)
+		}
+	}
+
 	_, hasInputPerMillion := columns["input_price_per_million"]
 	if hasInputPerMillion {
 		if _, err := s.db.ExecContext(ctx, `
@@ -1082,6 +2108,134 @@ END
 	return nil
 }
 
+// migrateCacheTokenColumns 为旧库的调用日志与用量表补充提示缓存 token 列。
+func (s *Store) migrateCacheTokenColumns(ctx context.Context) error {
+	for _, table := range []string{"call_logs", "usage_daily", "usage_model_daily"} {
+		columns, err := s.tableColumns(ctx, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range []string{"cache_read_input_tokens", "cache_write_input_tokens"} {
+			if _, ok := columns[column]; ok {
+				continue
+			}
+			if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s INTEGER NOT NULL DEFAULT 0", table, column)); err != nil {
+				return fmt.Errorf("migrate %s %s column: %w", table, column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateResponsesColumns 为旧库的 responses 表补充 status 列（后台响应的排队 / 执行状态）。
+func (s *Store) migrateResponsesColumns(ctx context.Context) error {
+	columns, err := s.tableColumns(ctx, "responses")
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["status"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE responses ADD COLUMN status TEXT NOT NULL DEFAULT 'completed'`); err != nil {
+			return fmt.Errorf("migrate responses status column: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateGuardrailColumns 为旧库的调用日志补充 Guardrail 列。
+func (s *Store) migrateGuardrailColumns(ctx context.Context) error {
+	columns, err := s.tableColumns(ctx, "call_logs")
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["guardrail_id"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE call_logs ADD COLUMN guardrail_id TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("migrate call logs guardrail id column: %w", err)
+		}
+	}
+	if _, ok := columns["guardrail_intervened"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE call_logs ADD COLUMN guardrail_intervened INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("migrate call logs guardrail intervened column: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateBatchColumns 为旧库的调用日志补充 is_batch 列，为用量表补充 batch 请求数与 token 数列。
+func (s *Store) migrateBatchColumns(ctx context.Context) error {
+	columns, err := s.tableColumns(ctx, "call_logs")
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["is_batch"]; !ok {
+		if _, err := s.db.ExecContext(ctx, `ALTER TABLE call_logs ADD COLUMN is_batch INTEGER NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("migrate call logs is_batch column: %w", err)
+		}
+	}
+	for _, table := range []string{"usage_daily", "usage_model_daily"} {
+		columns, err := s.tableColumns(ctx, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range []string{"batch_request_count", "batch_total_tokens"} {
+			if _, ok := columns[column]; ok {
+				continue
+			}
+			if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s INTEGER NOT NULL DEFAULT 0", table, column)); err != nil {
+				return fmt.Errorf("migrate %s %s column: %w", table, column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateImageColumns 为旧库的调用日志与用量表补充 image_count 列。
+// 单张图片价格 price_per_image 由 migrateModelPricingColumns 补充。
+func (s *Store) migrateImageColumns(ctx context.Context) error {
+	for _, table := range []string{"call_logs", "usage_daily", "usage_model_daily"} {
+		columns, err := s.tableColumns(ctx, table)
+		if err != nil {
+			return err
+		}
+		if _, ok := columns["image_count"]; ok {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN image_count INTEGER NOT NULL DEFAULT 0", table)); err != nil {
+			return fmt.Errorf("migrate %s image_count column: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// migrateAttemptsColumn 为旧库的调用日志补充 attempts 列（旧记录为 0，表示未记录）。
+func (s *Store) migrateAttemptsColumn(ctx context.Context) error {
+	columns, err := s.tableColumns(ctx, "call_logs")
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["attempts"]; ok {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, "ALTER TABLE call_logs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("migrate call_logs attempts column: %w", err)
+	}
+	return nil
+}
+
+// migrateModelMetadataColumns 为旧库的模型元数据覆盖表补充 created 列（NULL 表示未配置）。
+func (s *Store) migrateModelMetadataColumns(ctx context.Context) error {
+	columns, err := s.tableColumns(ctx, "admin_model_metadata")
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["created"]; ok {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, "ALTER TABLE admin_model_metadata ADD COLUMN created INTEGER"); err != nil {
+		return fmt.Errorf("migrate admin_model_metadata created column: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) tableColumns(ctx context.Context, table string) (map[string]struct{}, error) {
 	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
@@ -1200,6 +2354,9 @@ func normalizeModelPricing(items []ModelPricingRow) ([]ModelPricingRow, error) {
 
 		inputPrice := item.InputPricePer1K
 		outputPrice := item.OutputPricePer1K
+		cacheReadPrice := item.CacheReadPricePer1K
+		cacheWritePrice := item.CacheWritePricePer1K
+		imagePrice := item.PricePerImage
 
 		if math.IsNaN(inputPrice) || math.IsInf(inputPrice, 0) {
 			return nil, fmt.Errorf("invalid input_price_per_1k for model %q", modelID)
@@ -1213,11 +2370,23 @@ func normalizeModelPricing(items []ModelPricingRow) ([]ModelPricingRow, error) {
 		if outputPrice < 0 {
 			return nil, fmt.Errorf("output_price_per_1k must be >= 0 for model %q", modelID)
 		}
+		if math.IsNaN(cacheReadPrice) || math.IsInf(cacheReadPrice, 0) || cacheReadPrice < 0 {
+			return nil, fmt.Errorf("cache_read_price_per_1k must be a number >= 0 for model %q", modelID)
+		}
+		if math.IsNaN(cacheWritePrice) || math.IsInf(cacheWritePrice, 0) || cacheWritePrice < 0 {
+			return nil, fmt.Errorf("cache_write_price_per_1k must be a number >= 0 for model %q", modelID)
+		}
+		if math.IsNaN(imagePrice) || math.IsInf(imagePrice, 0) || imagePrice < 0 {
+			return nil, fmt.Errorf("price_per_image must be a number >= 0 for model %q", modelID)
+		}
 
 		byModel[modelID] = ModelPricingRow{
-			ModelID:          modelID,
-			InputPricePer1K:  inputPrice,
-			OutputPricePer1K: outputPrice,
+			ModelID:              modelID,
+			InputPricePer1K:      inputPrice,
+			OutputPricePer1K:     outputPrice,
+			CacheReadPricePer1K:  cacheReadPrice,
+			CacheWritePricePer1K: cacheWritePrice,
+			PricePerImage:        imagePrice,
 		}
 	}
 
@@ -2,8 +2,11 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -181,13 +184,13 @@ func TestStoreConfigAndUsage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("get usage by model failed: %v", err)
 	}
-	if len(usageModelRows) != 1 || usageModelRows[0].Model != "gpt-4o" {
+	if len(usageModelRows) != 1 || usageModelRows[0].Model != "anthropic.model" {
 		t.Fatalf("unexpected usage model rows: %+v", usageModelRows)
 	}
 
 	if err := s.ReplaceModelPricing(ctx, []ModelPricingRow{
-		{ModelID: "gpt-4o", InputPricePer1K: 2.5, OutputPricePer1K: 10},
-		{ModelID: "gpt-4o", InputPricePer1K: 3, OutputPricePer1K: 12},
+		{ModelID: "anthropic.model", InputPricePer1K: 2.5, OutputPricePer1K: 10},
+		{ModelID: "anthropic.model", InputPricePer1K: 3, OutputPricePer1K: 12},
 		{ModelID: "  ", InputPricePer1K: 1, OutputPricePer1K: 1},
 	}); err != nil {
 		t.Fatalf("replace model pricing failed: %v", err)
@@ -200,10 +203,21 @@ func TestStoreConfigAndUsage(t *testing.T) {
 	if len(pricingRows) != 1 {
 		t.Fatalf("expected one model pricing row, got: %+v", pricingRows)
 	}
-	if pricingRows[0].ModelID != "gpt-4o" || pricingRows[0].InputPricePer1K != 3 || pricingRows[0].OutputPricePer1K != 12 {
+	if pricingRows[0].ModelID != "anthropic.model" || pricingRows[0].InputPricePer1K != 3 || pricingRows[0].OutputPricePer1K != 12 {
 		t.Fatalf("unexpected model pricing row: %+v", pricingRows[0])
 	}
 
+	if err := s.ReplaceAllowedRequestFields(ctx, []string{"top_k", " ", "top_k", "anthropic_beta"}); err != nil {
+		t.Fatalf("replace allowed request fields failed: %v", err)
+	}
+	allowedFields, err := s.ListAllowedRequestFields(ctx)
+	if err != nil {
+		t.Fatalf("list allowed request fields failed: %v", err)
+	}
+	if len(allowedFields) != 2 || allowedFields[0] != "anthropic_beta" || allowedFields[1] != "top_k" {
+		t.Fatalf("unexpected allowed request fields: %+v", allowedFields)
+	}
+
 	totalCost, err := s.GetTotalCost(ctx)
 	if err != nil {
 		t.Fatalf("get total cost failed: %v", err)
@@ -260,6 +274,60 @@ func TestStoreCostProratedForSubThousandTokens(t *testing.T) {
 	}
 }
 
+func TestStoreRekeyModelUsage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "router.db")
+	s, err := New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+
+	// 旧版本按请求的别名汇总
+	if _, err := s.db.ExecContext(ctx, `
+INSERT INTO usage_model_daily(client_id, model, usage_date, input_tokens, output_tokens, total_tokens, request_count, last_seen_at)
+VALUES ('team-a', 'Claude-Fast', '2026-02-09', 100, 50, 150, 2, '2026-02-09T08:00:00Z')`); err != nil {
+		t.Fatalf("insert legacy usage failed: %v", err)
+	}
+	if err := s.insertRecord(CallRecord{
+		RequestID:      "req-alias",
+		ClientID:       "team-a",
+		Model:          "claude-fast",
+		BedrockModelID: "anthropic.claude-haiku",
+		InputTokens:    10,
+		OutputTokens:   5,
+		TotalTokens:    15,
+		StatusCode:     200,
+		CreatedAt:      time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC),
+	}); err != nil {
+		t.Fatalf("insert record failed: %v", err)
+	}
+
+	if err := s.RekeyModelUsage(ctx, map[string]string{"claude-fast": "anthropic.claude-haiku"}); err != nil {
+		t.Fatalf("rekey model usage failed: %v", err)
+	}
+	byModel, err := s.GetUsageByModel(ctx, "2026-02-01", "2026-02-28", "")
+	if err != nil {
+		t.Fatalf("get usage by model failed: %v", err)
+	}
+	if len(byModel) != 1 || byModel[0].Model != "anthropic.claude-haiku" || byModel[0].TotalTokens != 165 || byModel[0].RequestCount != 3 {
+		t.Fatalf("unexpected usage by model after rekey: %+v", byModel)
+	}
+
+	// 别名改指向后再次执行不影响已并入的历史用量
+	if err := s.RekeyModelUsage(ctx, map[string]string{"claude-fast": "anthropic.claude-sonnet"}); err != nil {
+		t.Fatalf("second rekey failed: %v", err)
+	}
+	byModel, err = s.GetUsageByModel(ctx, "2026-02-01", "2026-02-28", "")
+	if err != nil {
+		t.Fatalf("get usage by model failed: %v", err)
+	}
+	if len(byModel) != 1 || byModel[0].Model != "anthropic.claude-haiku" {
+		t.Fatalf("repointed alias must not move usage: %+v", byModel)
+	}
+}
+
 func TestStoreCallsPaginationDescending(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "router.db")
 	s, err := New(dbPath, 100)
@@ -342,3 +410,446 @@ func TestStoreCallsPaginationDescending(t *testing.T) {
 		t.Fatalf("unexpected page2 order: %+v", page2)
 	}
 }
+
+func TestStoreCacheTokensUsageAndCost(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "router.db")
+	s, err := New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	if err := s.insertRecord(CallRecord{
+		RequestID:             "req-cache",
+		ClientID:              "team-a",
+		Model:                 "anthropic.claude-3-7-sonnet",
+		BedrockModelID:        "anthropic.claude-3-7-sonnet",
+		InputTokens:           100,
+		OutputTokens:          10,
+		TotalTokens:           3110,
+		CacheReadInputTokens:  2000,
+		CacheWriteInputTokens: 1000,
+		StatusCode:            200,
+		CreatedAt:             time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC),
+	}); err != nil {
+		t.Fatalf("insert record failed: %v", err)
+	}
+
+	if err := s.ReplaceModelPricing(ctx, []ModelPricingRow{{
+		ModelID:              "anthropic.claude-3-7-sonnet",
+		InputPricePer1K:      0.003,
+		OutputPricePer1K:     0.015,
+		CacheReadPricePer1K:  0.0003,
+		CacheWritePricePer1K: 0.00375,
+	}}); err != nil {
+		t.Fatalf("replace model pricing failed: %v", err)
+	}
+	pricing, err := s.ListModelPricing(ctx)
+	if err != nil {
+		t.Fatalf("list model pricing failed: %v", err)
+	}
+	if len(pricing) != 1 || pricing[0].CacheReadPricePer1K != 0.0003 || pricing[0].CacheWritePricePer1K != 0.00375 {
+		t.Fatalf("unexpected model pricing: %+v", pricing)
+	}
+
+	byModel, err := s.GetUsageByModel(ctx, "2026-02-01", "2026-02-28", "")
+	if err != nil {
+		t.Fatalf("get usage by model failed: %v", err)
+	}
+	if len(byModel) != 1 || byModel[0].CacheReadInputTokens != 2000 || byModel[0].CacheWriteInputTokens != 1000 {
+		t.Fatalf("unexpected usage by model: %+v", byModel)
+	}
+	calls, err := s.GetCalls(ctx, 10, 0, "")
+	if err != nil {
+		t.Fatalf("get calls failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0].CacheReadInputTokens != 2000 || calls[0].CacheWriteInputTokens != 1000 {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+
+	totalCost, err := s.GetTotalCost(ctx)
+	if err != nil {
+		t.Fatalf("get total cost failed: %v", err)
+	}
+	// 0.1*0.003 + 0.01*0.015 + 2*0.0003 + 1*0.00375
+	expectedTotalCost := 0.0003 + 0.00015 + 0.0006 + 0.00375
+	if math.Abs(totalCost-expectedTotalCost) > 1e-12 {
+		t.Fatalf("unexpected total cost: got=%f expected=%f", totalCost, expectedTotalCost)
+	}
+}
+
+func TestStoreImageCountUsageAndCost(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "router.db")
+	s, err := New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	for index, count := range []int{3, 1} {
+		if err := s.insertRecord(CallRecord{
+			RequestID:      fmt.Sprintf("req-image-%d", index),
+			ClientID:       "team-a",
+			Model:          "amazon.nova-canvas-v1:0",
+			BedrockModelID: "amazon.nova-canvas-v1:0",
+			StatusCode:     200,
+			ImageCount:     count,
+			CreatedAt:      time.Date(2026, 2, 9, 12, index, 0, 0, time.UTC),
+		}); err != nil {
+			t.Fatalf("insert record failed: %v", err)
+		}
+	}
+
+	if err := s.ReplaceModelPricing(ctx, []ModelPricingRow{{
+		ModelID:       "amazon.nova-canvas-v1:0",
+		PricePerImage: 0.04,
+	}}); err != nil {
+		t.Fatalf("replace model pricing failed: %v", err)
+	}
+	if err := s.ReplaceModelPricing(ctx, []ModelPricingRow{{ModelID: "x", PricePerImage: -1}}); err == nil {
+		t.Fatalf("expected negative price_per_image to be rejected")
+	}
+	pricing, err := s.ListModelPricing(ctx)
+	if err != nil {
+		t.Fatalf("list model pricing failed: %v", err)
+	}
+	if len(pricing) != 1 || pricing[0].PricePerImage != 0.04 {
+		t.Fatalf("unexpected model pricing: %+v", pricing)
+	}
+
+	usage, err := s.GetUsage(ctx, "2026-02-01", "2026-02-28", "")
+	if err != nil {
+		t.Fatalf("get usage failed: %v", err)
+	}
+	byModel, err := s.GetUsageByModel(ctx, "2026-02-01", "2026-02-28", "")
+	if err != nil {
+		t.Fatalf("get usage by model failed: %v", err)
+	}
+	if len(usage) != 1 || usage[0].ImageCount != 4 || len(byModel) != 1 || byModel[0].ImageCount != 4 {
+		t.Fatalf("unexpected image usage: %+v / %+v", usage, byModel)
+	}
+	calls, err := s.GetCalls(ctx, 10, 0, "")
+	if err != nil {
+		t.Fatalf("get calls failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0].ImageCount != 1 || calls[1].ImageCount != 3 {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+
+	totalCost, err := s.GetTotalCost(ctx)
+	if err != nil {
+		t.Fatalf("get total cost failed: %v", err)
+	}
+	if math.Abs(totalCost-0.16) > 1e-12 {
+		t.Fatalf("unexpected total cost: got=%f expected=0.16", totalCost)
+	}
+}
+
+func TestStoreResponsesOwnershipAndExpiry(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "router.db")
+	s, err := New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	if err := s.SaveResponse(ctx, ResponseRecord{
+		ResponseID:   "resp_old",
+		ClientID:     "team-a",
+		Model:        "anthropic.model",
+		MessagesJSON: `[]`,
+		CreatedAt:    now.Add(-2 * time.Hour),
+		ExpiresAt:    now.Add(-time.Hour),
+	}); err != nil {
+		t.Fatalf("save expired response failed: %v", err)
+	}
+	if err := s.SaveResponse(ctx, ResponseRecord{
+		ResponseID:         "resp_new",
+		ClientID:           "team-a",
+		Model:              "anthropic.model",
+		PreviousResponseID: "resp_old",
+		MessagesJSON:       `[{"role":"user","content":"hi"}]`,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(time.Hour),
+	}); err != nil {
+		t.Fatalf("save response failed: %v", err)
+	}
+
+	record, ok, err := s.GetResponse(ctx, "team-a", "resp_new")
+	if err != nil || !ok {
+		t.Fatalf("get response failed: ok=%v err=%v", ok, err)
+	}
+	if record.PreviousResponseID != "resp_old" || record.MessagesJSON != `[{"role":"user","content":"hi"}]` {
+		t.Fatalf("unexpected response record: %+v", record)
+	}
+	if _, ok, _ := s.GetResponse(ctx, "team-b", "resp_new"); ok {
+		t.Fatalf("response must not be visible to another client")
+	}
+	// 另一个客户端不能用同一 ID 覆盖并接管这条记录
+	if err := s.SaveResponse(ctx, ResponseRecord{
+		ResponseID:   "resp_new",
+		ClientID:     "team-b",
+		Model:        "anthropic.model",
+		MessagesJSON: `[]`,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(time.Hour),
+	}); !errors.Is(err, ErrResponseIDConflict) {
+		t.Fatalf("expected response id conflict, got %v", err)
+	}
+	if record, ok, _ := s.GetResponse(ctx, "team-a", "resp_new"); !ok || record.PreviousResponseID != "resp_old" {
+		t.Fatalf("conflicting save must leave the original record intact: ok=%v %+v", ok, record)
+	}
+	// 过期记录在下一次写入时被清理
+	if _, ok, _ := s.GetResponse(ctx, "team-a", "resp_old"); ok {
+		t.Fatalf("expired response must not be returned")
+	}
+	count, err := s.countRows(ctx, "responses")
+	if err != nil || count != 1 {
+		t.Fatalf("expected expired response to be pruned, count=%d err=%v", count, err)
+	}
+}
+
+func TestStoreFailUnfinishedResponses(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for id, status := range map[string]string{"resp_queued": "queued", "resp_done": ""} {
+		if err := s.SaveResponse(ctx, ResponseRecord{
+			ResponseID:   id,
+			ClientID:     "team-a",
+			Model:        "anthropic.model",
+			ResponseJSON: `{"id":"` + id + `","status":"queued","error":null}`,
+			Status:       status,
+			CreatedAt:    now,
+			ExpiresAt:    now.Add(time.Hour),
+		}); err != nil {
+			t.Fatalf("save response failed: %v", err)
+		}
+	}
+
+	failed, err := s.FailUnfinishedResponses(ctx, "restarted")
+	if err != nil || failed != 1 {
+		t.Fatalf("expected one failed response, got %d err=%v", failed, err)
+	}
+	record, _, _ := s.GetResponse(ctx, "team-a", "resp_queued")
+	if record.Status != "failed" || !strings.Contains(record.ResponseJSON, `"status":"failed"`) || !strings.Contains(record.ResponseJSON, `"message":"restarted"`) {
+		t.Fatalf("unexpected failed record: %+v", record)
+	}
+	record, _, _ = s.GetResponse(ctx, "team-a", "resp_done")
+	if record.Status != "completed" {
+		t.Fatalf("expected empty status to be stored as completed, got %q", record.Status)
+	}
+}
+
+func TestStoreGuardrailsAndCallLogs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "router.db")
+	s, err := New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := s.ReplaceGuardrails(ctx, []GuardrailRow{{GuardrailIdentifier: "gr-1", GuardrailVersion: "1"}}); err == nil {
+		t.Fatalf("expected guardrail without client_id or model_id to be rejected")
+	}
+	if err := s.ReplaceGuardrails(ctx, []GuardrailRow{{ClientID: "team-a", GuardrailIdentifier: "gr-1", GuardrailVersion: "1", StreamProcessingMode: "later"}}); err == nil {
+		t.Fatalf("expected invalid stream_processing_mode to be rejected")
+	}
+	if err := s.ReplaceGuardrails(ctx, []GuardrailRow{
+		{ClientID: " team-a ", GuardrailIdentifier: "gr-1", GuardrailVersion: "1"},
+		{ModelID: "anthropic.model", GuardrailIdentifier: "gr-2", GuardrailVersion: "DRAFT", StreamProcessingMode: "Async"},
+	}); err != nil {
+		t.Fatalf("replace guardrails failed: %v", err)
+	}
+	items, err := s.ListGuardrails(ctx)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("unexpected guardrails: %+v err=%v", items, err)
+	}
+	if items[0].ModelID != "anthropic.model" || items[0].StreamProcessingMode != "async" ||
+		items[1].ClientID != "team-a" || items[1].StreamProcessingMode != "sync" {
+		t.Fatalf("unexpected normalized guardrails: %+v", items)
+	}
+
+	if !s.Enqueue(CallRecord{
+		RequestID:           "req-1",
+		ClientID:            "team-a",
+		Model:               "anthropic.model",
+		BedrockModelID:      "anthropic.model",
+		StatusCode:          200,
+		GuardrailID:         "gr-1",
+		GuardrailIntervened: true,
+	}) {
+		t.Fatalf("enqueue failed")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close store failed: %v", err)
+	}
+
+	s, err = New(dbPath, 100)
+	if err != nil {
+		t.Fatalf("reopen store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	calls, err := s.GetCalls(ctx, 10, 0, "team-a")
+	if err != nil || len(calls) != 1 {
+		t.Fatalf("unexpected calls: %+v err=%v", calls, err)
+	}
+	if calls[0].GuardrailID != "gr-1" || !calls[0].GuardrailIntervened {
+		t.Fatalf("expected guardrail intervention in call log: %+v", calls[0])
+	}
+}
+
+func TestStoreBatchProgressAndUsage(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	if err := s.SaveFile(ctx, FileRecord{FileID: "file-1", ClientID: "team-a", Purpose: "batch", Filename: "in.jsonl"}, []byte("{}\n")); err != nil {
+		t.Fatalf("save file failed: %v", err)
+	}
+	if _, ok, _ := s.GetFile(ctx, "team-b", "file-1"); ok {
+		t.Fatalf("file must not be visible to another client")
+	}
+	if err := s.CreateBatch(ctx, BatchRecord{
+		BatchID:          "batch_1",
+		ClientID:         "team-a",
+		Endpoint:         "/v1/chat/completions",
+		InputFileID:      "file-1",
+		CompletionWindow: "24h",
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(24 * time.Hour),
+	}, []BatchItem{
+		{LineIndex: 0, CustomID: "a", BodyJSON: `{}`},
+		{LineIndex: 1, CustomID: "b", BodyJSON: `{}`},
+	}); err != nil {
+		t.Fatalf("create batch failed: %v", err)
+	}
+
+	if ok, err := s.TransitionBatch(ctx, "batch_1", []string{"validating"}, "in_progress", now); err != nil || !ok {
+		t.Fatalf("transition to in_progress failed: ok=%v err=%v", ok, err)
+	}
+	if ok, _ := s.TransitionBatch(ctx, "batch_1", []string{"validating"}, "cancelling", now); ok {
+		t.Fatalf("transition from a non-matching status must not happen")
+	}
+	if err := s.FinishBatchItem(ctx, "batch_1", 0, "completed", 1, `{"custom_id":"a"}`); err != nil {
+		t.Fatalf("finish item failed: %v", err)
+	}
+	// 已结束的行不会重复计数
+	if err := s.FinishBatchItem(ctx, "batch_1", 0, "failed", 2, `{"custom_id":"a"}`); err != nil {
+		t.Fatalf("finish item again failed: %v", err)
+	}
+	pending, err := s.PendingBatchItems(ctx, "batch_1")
+	if err != nil || len(pending) != 1 || pending[0].CustomID != "b" {
+		t.Fatalf("unexpected pending items: %+v err=%v", pending, err)
+	}
+	unfinished, err := s.ListUnfinishedBatches(ctx)
+	if err != nil || len(unfinished) != 1 {
+		t.Fatalf("unexpected unfinished batches: %+v err=%v", unfinished, err)
+	}
+	record := unfinished[0]
+	if record.Status != "in_progress" || record.InProgressAt.IsZero() || record.RequestTotal != 2 ||
+		record.RequestCompleted != 1 || record.RequestFailed != 0 || !record.CompletedAt.IsZero() {
+		t.Fatalf("unexpected batch record: %+v", record)
+	}
+
+	if err := s.insertRecord(CallRecord{RequestID: "req-1", ClientID: "team-a", Model: "m", TotalTokens: 10, StatusCode: 200}); err != nil {
+		t.Fatalf("insert record failed: %v", err)
+	}
+	if err := s.insertRecord(CallRecord{RequestID: "batch_req_1", ClientID: "team-a", Model: "m", TotalTokens: 7, StatusCode: 200, IsBatch: true}); err != nil {
+		t.Fatalf("insert batch record failed: %v", err)
+	}
+	today := now.Format("2006-01-02")
+	usage, err := s.GetUsage(ctx, today, today, "team-a")
+	if err != nil || len(usage) != 1 {
+		t.Fatalf("unexpected usage: %+v err=%v", usage, err)
+	}
+	if usage[0].RequestCount != 2 || usage[0].TotalTokens != 17 || usage[0].BatchRequestCount != 1 || usage[0].BatchTotalTokens != 7 {
+		t.Fatalf("unexpected batch usage: %+v", usage[0])
+	}
+	byModel, err := s.GetUsageByModel(ctx, today, today, "team-a")
+	if err != nil || len(byModel) != 1 || byModel[0].BatchRequestCount != 1 {
+		t.Fatalf("unexpected usage by model: %+v err=%v", byModel, err)
+	}
+	calls, err := s.GetCalls(ctx, 10, 0, "team-a")
+	if err != nil || len(calls) != 2 || !calls[0].IsBatch || calls[1].IsBatch {
+		t.Fatalf("unexpected call logs: %+v err=%v", calls, err)
+	}
+}
+
+func TestStoreUpstreams(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	if err := s.ReplaceUpstreams(ctx, []UpstreamRow{{Name: "eu"}}); err == nil {
+		t.Fatalf("expected upstream without region to be rejected")
+	}
+	if err := s.ReplaceUpstreams(ctx, []UpstreamRow{{Name: "Default", Region: "us-west-2"}}); err == nil {
+		t.Fatalf("expected default upstream with its own region to be rejected")
+	}
+	if err := s.ReplaceUpstreams(ctx, []UpstreamRow{{Name: "eu", Region: "eu-west-1", AccessKeyID: "AKIA"}}); err == nil {
+		t.Fatalf("expected access key without secret to be rejected")
+	}
+	if err := s.ReplaceUpstreams(ctx, []UpstreamRow{
+		{Name: "eu", Region: " eu-west-1 ", Models: []string{"m1", " m1 ", ""}, Priority: 1},
+		{Name: "DEFAULT", Weight: 3},
+	}); err != nil {
+		t.Fatalf("replace upstreams failed: %v", err)
+	}
+
+	items, err := s.ListUpstreams(ctx)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("unexpected upstreams: %+v err=%v", items, err)
+	}
+	if items[0].Name != DefaultUpstreamName || items[0].Weight != 3 || len(items[0].Models) != 0 {
+		t.Fatalf("unexpected default upstream: %+v", items[0])
+	}
+	if items[1].Region != "eu-west-1" || items[1].Weight != 1 || items[1].Priority != 1 || len(items[1].Models) != 1 {
+		t.Fatalf("unexpected normalized upstream: %+v", items[1])
+	}
+}
+
+func TestStoreModelFallbacks(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "router.db"), 100)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	if err := s.ReplaceModelFallbacks(ctx, []ModelFallbackRow{{ModelID: "sonnet-4", FallbackModels: []string{"Sonnet-4"}}}); err == nil {
+		t.Fatalf("expected a chain that falls back to itself to be rejected")
+	}
+	if err := s.ReplaceModelFallbacks(ctx, []ModelFallbackRow{{ModelID: "sonnet-4", FallbackModels: []string{" ", ""}}}); err == nil {
+		t.Fatalf("expected an empty chain to be rejected")
+	}
+	if err := s.ReplaceModelFallbacks(ctx, []ModelFallbackRow{
+		{ModelID: " sonnet-4 ", FallbackModels: []string{"sonnet-3.7", " haiku ", ""}},
+	}); err != nil {
+		t.Fatalf("replace model fallbacks failed: %v", err)
+	}
+
+	items, err := s.ListModelFallbacks(ctx)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("unexpected model fallbacks: %+v err=%v", items, err)
+	}
+	if items[0].ModelID != "sonnet-4" || len(items[0].FallbackModels) != 2 || items[0].FallbackModels[1] != "haiku" {
+		t.Fatalf("unexpected normalized fallback chain: %+v", items[0])
+	}
+}